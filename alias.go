@@ -0,0 +1,70 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//Directive used to dump and load alias sections: "@alias DB = Systems/Database"
+const aliasDirective = "@alias"
+
+//Create the section name as an alias of the section target (relative to the root). Looking up or setting anything under the alias
+//does it in target, so both paths share the same contents. Aliases are dumped as "@alias name = target" and the target of an alias
+//cannot be removed while the alias exists
+func (cfg *CFG) Alias(name string, target string) error {
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
+	mark := cfg.recordMark()
+	alias, err := cfg.addAlias(name)
+	if err != nil {
+		return err
+	}
+	if err := alias.setAliasTarget(target); err != nil {
+		p := SplitPath(name)
+		alias.parent.removeChild(p[len(p)-1])
+		cfg.dropRecorded(mark)
+		return err
+	}
+	return nil
+}
+
+//Create the section for an alias. It's target is set afterwards via setAliasTarget
+func (cfg *CFG) addAlias(name string) (*CFG, error) {
+	if strings.Trim(name, trimChars) == "" {
+		return nil, errors.New("What's the name of the alias?")
+	}
+	alias, err := cfg.createSection(name, "")
+	if err != nil {
+		return nil, err
+	}
+	return alias, nil
+}
+
+func (cfg *CFG) setAliasTarget(target string) error {
+	dest, _ := cfg.root().get(SplitPath(target), false, 0)
+	if dest == nil {
+		return newError(ErrNotFound, fmt.Sprintf("Alias target %s for section %s does not exist", target, cfg.path()))
+	}
+	if dest == cfg {
+		return errors.New(fmt.Sprintf("Section %s cannot be an alias of itself", cfg.path()))
+	}
+	for parent := cfg.parent; parent != nil; parent = parent.parent {
+		if parent == dest || parent == dest.resolveAlias() {
+			return errors.New(fmt.Sprintf("Section %s cannot be an alias of its parent %s", cfg.path(), parent.path()))
+		}
+	}
+	cfg.alias = dest
+	return nil
+}
+
+//Section this one points to if it is an alias
+func (cfg *CFG) resolveAlias() *CFG {
+	sec := cfg
+	for sec.alias != nil {
+		sec = sec.alias
+	}
+	return sec
+}
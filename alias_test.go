@@ -0,0 +1,80 @@
+package cfg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAlias(t *testing.T) {
+	cfg, _ := NewCFGFromString("Systems\n{\n\tDatabase\n\t{\n\t\tHost = db1\n\t}\n}\n")
+	if err := cfg.Alias("/DB", "/Systems/Database"); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := cfg.GetOption("DB/Host"); !ok || v != "db1" {
+		t.Error("Alias was not followed:", v, ok)
+	}
+	if err := cfg.SetOption("DB/Port", "3306", ""); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := cfg.GetOption("Systems/Database/Port"); v != "3306" {
+		t.Error("Write through the alias did not reach the target:", v)
+	}
+	if sec, _ := cfg.GetSection("DB"); sec.Path() != "Systems/Database" {
+		t.Error("Unexpected section for the alias:", sec.Path())
+	}
+	if err := cfg.Remove("Systems/Database"); err == nil {
+		t.Error("Removed the target of an alias")
+	}
+	dump := cfg.String()
+	if !strings.Contains(dump, "@alias DB = Systems/Database\n") {
+		t.Error("Alias not marked in the dump:", dump)
+	}
+	loaded, err := NewCFGFromString(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Equal(cfg) {
+		t.Error("Alias lost in the round trip:", loaded.String())
+	}
+	if v, _ := loaded.GetOption("DB/Port"); v != "3306" {
+		t.Error("Loaded alias was not followed:", v)
+	}
+	if err := cfg.Alias("Other", "/Missing"); err == nil || cfg.Exists("Other") {
+		t.Error("Created an alias of a missing section")
+	}
+	if err := cfg.Alias("DB", "/Systems"); err == nil {
+		t.Error("Overwrote an existing section with an alias")
+	}
+	if _, err := NewCFGFromString("@alias DB = /Missing\n"); err == nil {
+		t.Error("Loaded an alias of a missing section")
+	}
+	if err := cfg.Remove("DB"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Remove("Systems/Database"); err != nil {
+		t.Error("Target still protected after removing the alias:", err)
+	}
+}
+
+func TestAliasOfParent(t *testing.T) {
+	cfg, _ := NewCFGFromString("X\n{\n\tA = 1\n}\nLink\n{\n}\n")
+	if err := cfg.Alias("X/Y", "X"); err == nil || cfg.Exists("X/Y") {
+		t.Error("Created an alias of its parent")
+	}
+	if err := cfg.Alias("Link/Z", "/Link"); err == nil {
+		t.Error("Created an alias of its parent")
+	}
+	if err := cfg.Alias("Up", "X"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Alias("X/Y", "Up"); err == nil || cfg.Exists("X/Y") {
+		t.Error("Created an alias of its parent through another alias")
+	}
+	if _, err := NewCFGFromString("X\n{\n\t@alias Y = X\n}\n"); err == nil {
+		t.Error("Loaded an alias of its parent")
+	}
+	var b strings.Builder
+	if err := cfg.DumpEffective(&b); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,119 @@
+package cfg
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//Directive defining an anchor: "@define host = db1.example.com". Values "&host" are read as the value of the anchor
+const defineDirective = "@define"
+
+//Prefix of the values referencing an anchor
+const anchorPrefix = "&"
+
+//Anchors defined in a tree. They live in the root
+type anchors struct {
+	values map[string]string
+	order  []string
+}
+
+//Define (or redefine) an anchor in the whole tree. Options with "&name" as value read value instead, so a value can be reused in many unrelated sections.
+//Anchors are dumped as "@define name = value" at the beginning of the root. References to anchors that are not defined are read as they are
+func (cfg *CFG) Define(name string, value string) error {
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
+	return cfg.root().define(name, value)
+}
+
+//Value of an anchor
+func (cfg *CFG) Anchor(name string) (string, bool) {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	root := cfg.root()
+	if root.anchors == nil {
+		return "", false
+	}
+	value, ok := root.anchors.values[name]
+	return value, ok
+}
+
+func (cfg *CFG) define(name string, value string) error {
+	if name == "" || strings.ContainsAny(name, trimChars+forbiddenNameChars) {
+		return errors.New(fmt.Sprintf("Invalid anchor name '%s'", name))
+	}
+	if cfg.anchors == nil {
+		cfg.anchors = &anchors{values: make(map[string]string)}
+	}
+	if _, ok := cfg.anchors.values[name]; !ok {
+		cfg.anchors.order = append(cfg.anchors.order, name)
+	}
+	cfg.anchors.values[name] = value
+	return nil
+}
+
+//Replace the references to anchors in values. The slice is only copied if something is replaced
+func (a *anchors) resolve(values []string) []string {
+	if a == nil {
+		return values
+	}
+	var resolved []string
+	for iV, value := range values {
+		if !strings.HasPrefix(value, anchorPrefix) {
+			continue
+		}
+		anchored, ok := a.values[value[len(anchorPrefix):]]
+		if !ok {
+			continue
+		}
+		if resolved == nil {
+			resolved = append([]string{}, values...)
+		}
+		resolved[iV] = anchored
+	}
+	if resolved == nil {
+		return values
+	}
+	return resolved
+}
+
+func (a *anchors) copy() *anchors {
+	if a == nil {
+		return nil
+	}
+	dup := &anchors{values: make(map[string]string), order: append([]string{}, a.order...)}
+	for name, value := range a.values {
+		dup.values[name] = value
+	}
+	return dup
+}
+
+func (a *anchors) equal(other *anchors) bool {
+	if a == nil || other == nil {
+		return (a == nil || len(a.order) == 0) && (other == nil || len(other.order) == 0)
+	}
+	if len(a.order) != len(other.order) {
+		return false
+	}
+	for iN, name := range a.order {
+		if other.order[iN] != name || other.values[name] != a.values[name] {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *anchors) dump(b *bufio.Writer) error {
+	if a == nil {
+		return nil
+	}
+	for _, name := range a.order {
+		if err := writeStrings(b, defineDirective, " ", name, " = ", a.values[name], "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package cfg
+
+import (
+	"testing"
+)
+
+func TestAnchors(t *testing.T) {
+	data := "@define dbhost = db1.example.com\nBilling {\n\tHost = &dbhost\n\tHosts = &dbhost\n\tHosts += db2\n}\nReports {\n\t@define port = 3306\n\tHost = &dbhost\n\tPort = &port\n\tURL = ${Host}:${Port}\n\tOther = &missing\n}\n"
+	cfg, err := NewCFGFromString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, value := range map[string]string{"Billing/Host": "db1.example.com", "Reports/Host": "db1.example.com", "Reports/Port": "3306", "Reports/Other": "&missing"} {
+		if v, _ := cfg.GetOption(path); v != value {
+			t.Errorf("Unexpected value for %s: %s", path, v)
+		}
+	}
+	if v, _ := cfg.GetOptionArray("Billing/Hosts"); !equalSlices(v, []string{"db1.example.com", "db2"}) {
+		t.Error("Unexpected values:", v)
+	}
+	if v, err := cfg.GetEvaluated("Reports/URL"); err != nil || v != "db1.example.com:3306" {
+		t.Error("Unexpected evaluation:", v, err)
+	}
+	expected := "@define dbhost = db1.example.com\n@define port = 3306\nBilling {\n\tHost = &dbhost\n\tHosts = &dbhost\n\tHosts += db2\n}\nReports {\n\tHost = &dbhost\n\tPort = &port\n\tURL = ${Host}:${Port}\n\tOther = &missing\n}\n"
+	if cfg.String() != expected {
+		t.Error("Unexpected dump:", cfg.String())
+	}
+	clone, _ := cfg.Clone()
+	if !clone.Equal(cfg) {
+		t.Error("Anchors lost in the clone:", clone.String())
+	}
+	if err := clone.Define("dbhost", "db9"); err != nil {
+		t.Fatal(err)
+	}
+	if clone.Equal(cfg) {
+		t.Error("Different anchors are equal")
+	}
+	if v, _ := clone.GetOption("Billing/Host"); v != "db9" {
+		t.Error("Redefined anchor not used:", v)
+	}
+	if v, ok := cfg.Anchor("dbhost"); !ok || v != "db1.example.com" {
+		t.Error("Unexpected anchor:", v, ok)
+	}
+	if err := cfg.Define("bad name", "x"); err == nil {
+		t.Error("Defined an anchor with an invalid name")
+	}
+}
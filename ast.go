@@ -0,0 +1,149 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+)
+
+//Kinds of nodes of an AST
+const (
+	ASTSection = "section"
+	ASTOption  = "option"
+	ASTAlias   = "alias"
+)
+
+//Option, section or alias of an AST. It only has plain fields, so it can be serialized as JSON for tools written in other languages
+type ASTNode struct {
+	//ASTSection, ASTOption or ASTAlias
+	Kind    string   `json:"kind"`
+	Name    string   `json:"name"`
+	Values  []string `json:"values,omitempty"`
+	Comment string   `json:"comment,omitempty"`
+	//Declared type of an option
+	Type string `json:"type,omitempty"`
+	//Path (relative to the root) of the section a section inherits from or an alias points to
+	Inherits string `json:"inherits,omitempty"`
+	Target   string `json:"target,omitempty"`
+	//File and line the node was loaded from, if any
+	Source   string    `json:"source,omitempty"`
+	Line     int       `json:"line,omitempty"`
+	Children []ASTNode `json:"children,omitempty"`
+}
+
+//Anchor defined in a tree
+type ASTAnchor struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+//The whole model of a tree as parsed: every section, option and alias in order with it's comments, inheritance and location,
+//and the anchors. Values are kept as written, without resolving anchors nor references
+type AST struct {
+	Comment string      `json:"comment,omitempty"`
+	Anchors []ASTAnchor `json:"anchors,omitempty"`
+	Nodes   []ASTNode   `json:"nodes"`
+}
+
+//Export the model of this section and everything under it. Anchors are only exported from the root
+func (cfg *CFG) ExportAST() *AST {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	ast := &AST{Comment: cfg.comment, Nodes: cfg.astNodes()}
+	if cfg.parent == nil && cfg.anchors != nil {
+		for _, name := range cfg.anchors.order {
+			ast.Anchors = append(ast.Anchors, ASTAnchor{name, cfg.anchors.values[name]})
+		}
+	}
+	return ast
+}
+
+func (cfg *CFG) astNodes() []ASTNode {
+	nodes := make([]ASTNode, 0, len(cfg.order))
+	for _, name := range cfg.order {
+		sec, ok := cfg.sections[name]
+		if !ok {
+			opt := cfg.options[name]
+			nodes = append(nodes, ASTNode{Kind: ASTOption, Name: name, Values: append([]string{}, opt.value...), Comment: opt.comment,
+				Type: opt.typ, Source: opt.source, Line: opt.line})
+			continue
+		}
+		node := ASTNode{Kind: ASTSection, Name: name, Comment: sec.comment, Source: sec.source, Line: sec.line}
+		if sec.alias != nil {
+			node.Kind = ASTAlias
+			node.Target = sec.alias.path()
+		} else {
+			node.Children = sec.astNodes()
+		}
+		if sec.inheritance != nil {
+			node.Inherits = sec.inheritance.path()
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+//Build a tree from an AST as exported by ExportAST. Inheritance and aliases are linked once all the nodes have been created
+func ImportAST(ast *AST) (*CFG, error) {
+	cfg := NewCFG()
+	cfg.comment = ast.Comment
+	for _, anchor := range ast.Anchors {
+		if err := cfg.define(anchor.Name, anchor.Value); err != nil {
+			return nil, err
+		}
+	}
+	var links []inheritanceLink
+	if err := cfg.importNodes(ast.Nodes, &links); err != nil {
+		return nil, err
+	}
+	for _, link := range links {
+		var err error
+		if link.alias {
+			err = link.section.setAliasTarget(link.inheritance)
+		} else {
+			err = link.section.setInheritance(link.inheritance)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+func (cfg *CFG) importNodes(nodes []ASTNode, links *[]inheritanceLink) error {
+	for _, node := range nodes {
+		switch node.Kind {
+		case ASTOption:
+			var err error
+			if node.Type != "" {
+				err = cfg.setTypedOptionArray(node.Name, node.Type, append([]string{}, node.Values...), node.Comment)
+			} else {
+				err = cfg.setOptionArray(node.Name, append([]string{}, node.Values...), node.Comment)
+			}
+			if err != nil {
+				return err
+			}
+			opt := cfg.options[node.Name]
+			opt.source, opt.line = node.Source, node.Line
+		case ASTSection, ASTAlias:
+			sec, err := cfg.createSection(node.Name, node.Comment)
+			if err != nil {
+				return err
+			}
+			sec.source, sec.line = node.Source, node.Line
+			if node.Kind == ASTAlias {
+				if node.Target == "" {
+					return errors.New(fmt.Sprintf("Alias %s has no target", sec.path()))
+				}
+				*links = append(*links, inheritanceLink{sec, node.Target, true})
+			} else if err := sec.importNodes(node.Children, links); err != nil {
+				return err
+			}
+			if node.Inherits != "" {
+				*links = append(*links, inheritanceLink{sec, node.Inherits, false})
+			}
+		default:
+			return errors.New(fmt.Sprintf("Unknown kind %s of node %s", node.Kind, node.Name))
+		}
+	}
+	return nil
+}
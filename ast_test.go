@@ -0,0 +1,65 @@
+package cfg
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAST(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "app.cfg")
+	data := "@define port = 80\n#Database\nDB {\n\tHost = db1\n\tPort:int = 3306\n\tReplicas = r1\n\tReplicas += r2\n}\nApp {< DB\n\tWeb = &port\n}\n@alias D = DB\n"
+	ioutil.WriteFile(filename, []byte(data), 0644)
+	cfg, err := NewCFGFromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast := cfg.ExportAST()
+	expected := &AST{Anchors: []ASTAnchor{{"port", "80"}}, Nodes: []ASTNode{
+		{Kind: ASTSection, Name: "DB", Comment: "Database", Source: filename, Line: 3, Children: []ASTNode{
+			{Kind: ASTOption, Name: "Host", Values: []string{"db1"}, Source: filename, Line: 4},
+			{Kind: ASTOption, Name: "Port", Values: []string{"3306"}, Type: "int", Source: filename, Line: 5},
+			{Kind: ASTOption, Name: "Replicas", Values: []string{"r1", "r2"}, Source: filename, Line: 7},
+		}},
+		{Kind: ASTSection, Name: "App", Inherits: "DB", Source: filename, Line: 9, Children: []ASTNode{
+			{Kind: ASTOption, Name: "Web", Values: []string{"&port"}, Source: filename, Line: 10},
+		}},
+		{Kind: ASTAlias, Name: "D", Target: "DB", Source: filename, Line: 12},
+	}}
+	if !reflect.DeepEqual(ast, expected) {
+		encoded, _ := json.Marshal(ast)
+		t.Error("Unexpected AST:", string(encoded))
+	}
+	encoded, err := json.Marshal(ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(AST)
+	if err := json.Unmarshal(encoded, decoded); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := ImportAST(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !imported.RealEqual(cfg) || imported.GetValue("App/Web", "") != "80" {
+		t.Error("Imported tree differs:", imported.String())
+	}
+	if source, _ := imported.OptionSource("DB/Host"); source != filename {
+		t.Error("Lost source:", source)
+	}
+	if _, err := ImportAST(&AST{Nodes: []ASTNode{{Kind: ASTSection, Name: "A", Inherits: "B"}}}); err == nil {
+		t.Error("Imported missing inheritance")
+	}
+	if _, err := ImportAST(&AST{Nodes: []ASTNode{{Kind: "list", Name: "A"}}}); err == nil {
+		t.Error("Imported unknown kind")
+	}
+}
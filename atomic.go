@@ -0,0 +1,101 @@
+package cfg
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+//Tree for read heavy code. Reads go to an immutable version of the tree without taking any lock, and updates modify a copy
+//of it that replaces the current version atomically once done, so readers never wait for writers nor see partial updates.
+//Watchers, audit sinks and logs of the original tree are not carried to the versions
+type AtomicCFG struct {
+	current atomic.Pointer[CFG]
+	//Serializes the updates
+	update sync.Mutex
+}
+
+//Create an AtomicCFG with a copy of cfg as it's first version. The secret resolvers, providers, fallbacks and the rest of
+//settings of the tree are kept. Configure them before, or inside Update, as versions are read without locking
+func NewAtomicCFG(cfg *CFG) *AtomicCFG {
+	cfg.lock.RLock()
+	version := cfg.newVersion()
+	cfg.lock.RUnlock()
+	version.frozen = true
+	a := &AtomicCFG{}
+	a.current.Store(version)
+	return a
+}
+
+//Copy of this section as the root of a new tree with the settings of it's root. Must hold the read lock
+func (cfg *CFG) newVersion() *CFG {
+	version := cfg.copyTree()
+	version.setLock(new(sync.RWMutex))
+	root := cfg.root()
+	version.secrets = root.secrets
+	if root.fallbacks != nil {
+		version.fallbacks = make(map[string][]string, len(root.fallbacks))
+		for path, old := range root.fallbacks {
+			version.fallbacks[path] = append([]string{}, old...)
+		}
+	}
+	version.providers = append([]provider{}, root.providers...)
+	root.copyParseSettings(version)
+	version.timeLayouts = root.timeLayouts
+	if version.anchors == nil {
+		version.anchors = root.anchors.copy()
+	}
+	return version
+}
+
+//Current version of the tree. It is frozen, so it can be read by any number of goroutines and kept as long as needed
+func (a *AtomicCFG) Load() *CFG {
+	return a.current.Load()
+}
+
+//Run fn with a modifiable copy of the current version and make it the current one if fn succeeds.
+//Updates are run one at a time while readers keep using the previous version
+func (a *AtomicCFG) Update(fn func(cfg *CFG) error) error {
+	a.update.Lock()
+	defer a.update.Unlock()
+	next := a.current.Load().newVersion()
+	if err := fn(next); err != nil {
+		return err
+	}
+	next.Freeze()
+	a.current.Store(next)
+	return nil
+}
+
+//Same as CFG.GetOptionArray on the current version without locking
+func (a *AtomicCFG) GetOptionArray(name string) ([]string, bool) {
+	version := a.current.Load()
+	values, err := version.lookupValues(name)
+	if err == nil && version.secrets != nil {
+		values, err = version.secrets.resolveAll(values)
+	}
+	return values, err == nil
+}
+
+//Same as CFG.GetOption on the current version without locking
+func (a *AtomicCFG) GetOption(name string) (string, bool) {
+	values, ok := a.GetOptionArray(name)
+	if !ok {
+		return "", false
+	}
+	return strings.Join(values, SplitChar), true
+}
+
+//Same as CFG.GetValue on the current version without locking
+func (a *AtomicCFG) GetValue(name string, defaultValue string) string {
+	if v, ok := a.GetOption(name); ok {
+		return v
+	}
+	return defaultValue
+}
+
+//Same as CFG.Exists on the current version without locking
+func (a *AtomicCFG) Exists(name string) bool {
+	sec, opt := a.current.Load().getString(name, true, 0)
+	return sec != nil || opt != nil
+}
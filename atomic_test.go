@@ -0,0 +1,66 @@
+package cfg
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestAtomicCFG(t *testing.T) {
+	cfg, _ := NewCFGFromString("App {\n\tWorkers = 0\n\tHost = @{/Defaults/Host}\n}\nDefaults {\n\tHost = db1\n}\n")
+	cfg.AddFallback("App/Port", "Defaults/Port")
+	a := NewAtomicCFG(cfg)
+	first := a.Load()
+	if !first.Frozen() {
+		t.Error("Version not frozen")
+	}
+	if v, _ := a.GetOption("App/Host"); v != "db1" || !a.Exists("App/Host") {
+		t.Error("Unexpected value:", v)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := 0
+			for j := 0; j < 1000; j++ {
+				n, _ := strconv.Atoi(a.GetValue("App/Workers", "-1"))
+				if n < last {
+					t.Error("Read an older version:", n, last)
+					return
+				}
+				last = n
+			}
+		}()
+	}
+	for i := 1; i <= 50; i++ {
+		if err := a.Update(func(cfg *CFG) error {
+			return cfg.SetOption("App/Workers", strconv.Itoa(i), "")
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	if v, _ := first.GetOption("App/Workers"); v != "0" {
+		t.Error("Update modified a loaded version:", v)
+	}
+	failed := errors.New("failed")
+	if err := a.Update(func(cfg *CFG) error {
+		cfg.SetOption("App/Workers", "100", "")
+		return failed
+	}); err != failed {
+		t.Error("Unexpected error:", err)
+	}
+	if v, _ := a.GetOption("App/Workers"); v != "50" {
+		t.Error("Failed update was applied:", v)
+	}
+	if err := a.Update(func(cfg *CFG) error {
+		return cfg.SetOption("Defaults/Port", "3306", "")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := a.GetOption("App/Port"); v != "3306" {
+		t.Error("Settings of the tree not kept:", v)
+	}
+}
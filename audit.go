@@ -0,0 +1,157 @@
+package cfg
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+type actorKey struct{}
+
+//Attach the identity of whoever makes the changes to a context, so the *Ctx mutation methods can audit it
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+//Identity attached to the context with WithActor
+func ActorFromContext(ctx context.Context) (string, bool) {
+	actor, ok := ctx.Value(actorKey{}).(string)
+	return actor, ok
+}
+
+//A mutation done through a *Ctx method
+type AuditEntry struct {
+	Time  time.Time
+	Actor string
+	//Method used (SetOption, Remove...) and the path it was called with
+	Operation string
+	Path      string
+	//Changes done to the tree. Empty if the mutation failed or did not change anything
+	Changes []ChangeEvent
+	Err     error
+}
+
+//Receives an entry for every mutation done through the *Ctx methods, after the lock of the tree has been released
+type AuditSink interface {
+	Audit(entry AuditEntry)
+}
+
+//Set the sink receiving the audit entries. Pass nil to stop auditing
+func (cfg *CFG) SetAuditSink(sink AuditSink) {
+	cfg.lock.Lock()
+	defer cfg.lock.Unlock()
+	cfg.root().auditSink = sink
+}
+
+//Run a mutation holding the write lock and audit it with the actor in ctx. The path is relative to the root
+func (cfg *CFG) audited(ctx context.Context, operation string, path string, mutation func() error) error {
+	cfg.lock.Lock()
+	root := cfg.root()
+	sink := root.auditSink
+	mark := cfg.recordMark()
+	err := ErrFrozen
+	if !root.frozen {
+		err = mutation()
+	}
+	var changes []ChangeEvent
+	if sink != nil && len(root.pending) > mark {
+		changes = append(changes, root.pending[mark:]...)
+	}
+	path = strings.Join(SplitPath(cfg.childPath(path)), SplitChar)
+	cfg.unlock()
+	if sink != nil {
+		actor, _ := ActorFromContext(ctx)
+		sink.Audit(AuditEntry{time.Now(), actor, operation, path, changes, err})
+	}
+	return err
+}
+
+//SetOptionArray auditing the change with the actor in ctx
+func (cfg *CFG) SetOptionArrayCtx(ctx context.Context, name string, value []string, comment string) error {
+	return cfg.audited(ctx, "SetOptionArray", name, func() error {
+		return cfg.setOptionArray(name, value, comment)
+	})
+}
+
+//SetOption auditing the change with the actor in ctx
+func (cfg *CFG) SetOptionCtx(ctx context.Context, name string, value string, comment string) error {
+	return cfg.audited(ctx, "SetOption", name, func() error {
+		return cfg.setOptionArray(name, []string{value}, comment)
+	})
+}
+
+//CreateSection auditing the change with the actor in ctx
+func (cfg *CFG) CreateSectionCtx(ctx context.Context, name string, comment string) (sec *CFG, err error) {
+	err = cfg.audited(ctx, "CreateSection", name, func() error {
+		sec, err = cfg.createSection(name, comment)
+		return err
+	})
+	return
+}
+
+//Remove auditing the change with the actor in ctx
+func (cfg *CFG) RemoveCtx(ctx context.Context, name string) error {
+	return cfg.audited(ctx, "Remove", name, func() error {
+		return cfg.remove(SplitPath(name))
+	})
+}
+
+//SetInheritance auditing the change with the actor in ctx
+func (cfg *CFG) SetInheritanceCtx(ctx context.Context, inheritance string) error {
+	return cfg.audited(ctx, "SetInheritance", "", func() error {
+		return cfg.setInheritance(inheritance)
+	})
+}
+
+//ApplyJSONPatch auditing the change with the actor in ctx
+func (cfg *CFG) ApplyJSONPatchCtx(ctx context.Context, patch []byte) error {
+	return cfg.audited(ctx, "ApplyJSONPatch", "", func() error {
+		return cfg.applyJSONPatch(patch)
+	})
+}
+
+//ApplyMergePatch auditing the change with the actor in ctx
+func (cfg *CFG) ApplyMergePatchCtx(ctx context.Context, patch []byte) error {
+	return cfg.audited(ctx, "ApplyMergePatch", "", func() error {
+		return cfg.applyMergePatchDocument(patch)
+	})
+}
+
+//AuditSink writing every entry as a JSON line
+type JSONAuditSink struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func NewJSONAuditSink(w io.Writer) *JSONAuditSink {
+	return &JSONAuditSink{w: w}
+}
+
+type jsonAuditEntry struct {
+	Time      time.Time         `json:"time"`
+	Actor     string            `json:"actor"`
+	Operation string            `json:"operation"`
+	Path      string            `json:"path"`
+	Changes   []jsonChangeEvent `json:"changes,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+func (s *JSONAuditSink) Audit(entry AuditEntry) {
+	out := jsonAuditEntry{Time: entry.Time, Actor: entry.Actor, Operation: entry.Operation, Path: entry.Path}
+	for _, event := range entry.Changes {
+		out.Changes = append(out.Changes, jsonChangeEvent{event.Path, event.Kind.String(), event.Value, event.Paths})
+	}
+	if entry.Err != nil {
+		out.Error = entry.Err.Error()
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.w.Write(append(data, '\n'))
+}
@@ -0,0 +1,60 @@
+package cfg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type testAuditSink struct {
+	entries []AuditEntry
+}
+
+func (s *testAuditSink) Audit(entry AuditEntry) {
+	s.entries = append(s.entries, entry)
+}
+
+func TestAudit(t *testing.T) {
+	cfg, _ := NewCFGFromString("Web\n{\n\tHost = a\n}\n")
+	sink := &testAuditSink{}
+	cfg.SetAuditSink(sink)
+	ctx := WithActor(context.Background(), "alice")
+	web, _ := cfg.GetSection("Web")
+	if err := web.SetOptionCtx(ctx, "Host", "b", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.RemoveCtx(ctx, "Nope"); err == nil {
+		t.Error("Removed a non existant option")
+	}
+	cfg.SetOption("Untracked", "1", "")
+	if len(sink.entries) != 2 {
+		t.Fatal("Unexpected audit entries:", sink.entries)
+	}
+	entry := sink.entries[0]
+	if entry.Actor != "alice" || entry.Operation != "SetOption" || entry.Path != "Web/Host" || entry.Err != nil || entry.Time.IsZero() {
+		t.Error("Unexpected entry:", entry)
+	}
+	if len(entry.Changes) != 1 || entry.Changes[0].Path != "Web/Host" || entry.Changes[0].Value[0] != "b" {
+		t.Error("Unexpected changes:", entry.Changes)
+	}
+	if sink.entries[1].Err == nil || len(sink.entries[1].Changes) != 0 {
+		t.Error("Failed mutation was not audited as such:", sink.entries[1])
+	}
+
+	var buf bytes.Buffer
+	cfg.SetAuditSink(NewJSONAuditSink(&buf))
+	h := NewHandler(cfg, HandlerOptions{Actor: func(r *http.Request) string { return r.Header.Get("X-User") }})
+	if res := doRequest(h, "PUT", "/cfg/Web/Port", "8080", map[string]string{"Content-Type": ContentTypeText, "X-User": "bob"}); res.Code != http.StatusCreated {
+		t.Fatal("Unexpected status", res.Code)
+	}
+	var logged jsonAuditEntry
+	if err := json.Unmarshal(buf.Bytes(), &logged); err != nil {
+		t.Fatal(err)
+	}
+	if logged.Actor != "bob" || logged.Operation != "PUT" || logged.Path != "Web/Port" || len(logged.Changes) != 1 || logged.Changes[0].Kind != "option set" {
+		t.Error("Unexpected JSON entry:", strings.TrimSpace(buf.String()))
+	}
+}
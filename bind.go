@@ -0,0 +1,57 @@
+package cfg
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+)
+
+//A struct kept in sync with a section. Read it's fields while holding the read lock (RLock/RUnlock) as they are replaced when the section changes
+type Binding struct {
+	sync.RWMutex
+	cfg      *CFG
+	path     string
+	target   reflect.Value
+	onChange func(error)
+	refresh  sync.Mutex
+	cancel   func()
+}
+
+//Fill the struct pointed by v with the section under path (as Unmarshal does) and refresh it every time something under path changes.
+//Fields are replaced all at once and only if the whole section can be unmarshalled, otherwise the previous values are kept.
+//onChange (if not nil) is called after every refresh with it's result. Call Close to stop refreshing
+func (cfg *CFG) Bind(path string, v interface{}, onChange func(error)) (*Binding, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("Bind needs a non nil pointer to a struct")
+	}
+	b := &Binding{cfg: cfg, path: path, target: rv.Elem(), onChange: onChange}
+	if err := b.update(); err != nil {
+		return nil, err
+	}
+	b.cancel = cfg.Watch(path, func(ChangeEvent) {
+		err := b.update()
+		if b.onChange != nil {
+			b.onChange(err)
+		}
+	})
+	return b, nil
+}
+
+func (b *Binding) update() error {
+	b.refresh.Lock()
+	defer b.refresh.Unlock()
+	fresh := reflect.New(b.target.Type())
+	if err := b.cfg.Unmarshal(b.path, fresh.Interface()); err != nil {
+		return err
+	}
+	b.Lock()
+	b.target.Set(fresh.Elem())
+	b.Unlock()
+	return nil
+}
+
+//Stop refreshing the struct
+func (b *Binding) Close() {
+	b.cancel()
+}
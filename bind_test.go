@@ -0,0 +1,49 @@
+package cfg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBind(t *testing.T) {
+	cfg, _ := NewCFGFromString("Server\n{\n\tPort = 80\n\tTimeout = 1s\n\tTLS\n\t{\n\t\tEnabled = false\n\t}\n}\n")
+	var conf struct {
+		Port    int
+		Timeout time.Duration
+		Hosts   []string `default:"localhost"`
+		TLS     struct {
+			Enabled bool
+		}
+	}
+	var results []error
+	binding, err := cfg.Bind("Server", &conf, func(err error) { results = append(results, err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Port != 80 || conf.Timeout != time.Second || conf.Hosts[0] != "localhost" || conf.TLS.Enabled {
+		t.Error("Unexpected initial values:", conf)
+	}
+	cfg.SetOption("Server/Port", "8080", "")
+	cfg.SetOption("Server/TLS/Enabled", "true", "")
+	cfg.SetOption("Other", "x", "")
+	binding.RLock()
+	if conf.Port != 8080 || !conf.TLS.Enabled {
+		t.Error("Values were not refreshed:", conf)
+	}
+	binding.RUnlock()
+	if len(results) != 2 || results[0] != nil {
+		t.Error("Unexpected notifications:", results)
+	}
+	cfg.SetOption("Server/Port", "nope", "")
+	if conf.Port != 8080 || len(results) != 3 || results[2] == nil {
+		t.Error("Invalid value was not rejected:", conf.Port, results)
+	}
+	binding.Close()
+	cfg.SetOption("Server/Port", "9090", "")
+	if conf.Port != 8080 {
+		t.Error("Closed binding was refreshed")
+	}
+	if _, err := cfg.Bind("Server", conf, nil); err == nil {
+		t.Error("Bound a non pointer")
+	}
+}
@@ -0,0 +1,147 @@
+package cfg
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+)
+
+//Options of a Cache
+type CacheOptions struct {
+	//Time the contents are served from memory before fetching them again. Defaults to one minute
+	TTL time.Duration
+	//Timeout of every fetch. Defaults to the TTL
+	Timeout time.Duration
+	//File keeping the last contents fetched successfully. It is loaded when the source cannot be fetched on creation
+	LastKnownGood string
+	//Called with every failed fetch and every failure loading or saving the last known good file
+	OnError func(error)
+}
+
+//Read-through cache of a cfg kept in a remote Source. Getters are served from memory and, once the TTL expires, trigger a
+//fetch in the background so they never wait for the source. Creating a cache never fails: if the source is down the last
+//known good file is used, and if there is none the cache starts empty until the source is back
+type Cache struct {
+	cfg        *CFG
+	refresher  *Refresher
+	opts       CacheOptions
+	lock       sync.Mutex
+	expires    time.Time
+	refreshing bool
+	background sync.WaitGroup
+}
+
+//Create a cache of source fetching it right away
+func NewCache(source Source, opts CacheOptions) *Cache {
+	if opts.TTL <= 0 {
+		opts.TTL = time.Minute
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = opts.TTL
+	}
+	c := &Cache{cfg: NewCFG(), opts: opts}
+	c.refresher = NewRefresher(c.cfg, source, RefresherOptions{Interval: opts.TTL})
+	if err := c.fetch(); err != nil && opts.LastKnownGood != "" {
+		c.loadLastKnownGood()
+	}
+	return c
+}
+
+func (c *Cache) report(err error) {
+	if c.opts.OnError != nil {
+		c.opts.OnError(err)
+	}
+}
+
+//Fetch the source and keep the contents in the last known good file if they changed
+func (c *Cache) fetch() error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.Timeout)
+	defer cancel()
+	changed, err := c.refresher.Refresh(ctx)
+	c.lock.Lock()
+	c.expires = time.Now().Add(c.opts.TTL)
+	c.lock.Unlock()
+	if err != nil {
+		c.report(err)
+		return err
+	}
+	if changed && c.opts.LastKnownGood != "" {
+		if err := c.cfg.SaveToFile(c.opts.LastKnownGood); err != nil {
+			c.report(err)
+		}
+	}
+	return nil
+}
+
+func (c *Cache) loadLastKnownGood() {
+	fi, err := os.Open(c.opts.LastKnownGood)
+	if err != nil {
+		c.report(err)
+		return
+	}
+	defer fi.Close()
+	candidate := NewCFG()
+	if err := candidate.LoadFromReader(fi); err != nil {
+		c.report(err)
+		return
+	}
+	if err := c.cfg.writeLock(); err != nil {
+		c.report(err)
+		return
+	}
+	c.cfg.replaceContents(candidate)
+	c.cfg.unlock()
+}
+
+//Start a fetch in the background if the contents expired and there is none running
+func (c *Cache) check() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.refreshing || time.Now().Before(c.expires) {
+		return
+	}
+	c.refreshing = true
+	c.background.Add(1)
+	go func() {
+		defer c.background.Done()
+		c.fetch()
+		c.lock.Lock()
+		c.refreshing = false
+		c.lock.Unlock()
+	}()
+}
+
+//Cached tree. It is updated in place by the fetches, so it can be watched, but reading it directly does not trigger them
+func (c *Cache) CFG() *CFG {
+	return c.cfg
+}
+
+//See CFG.GetOptionArray
+func (c *Cache) GetOptionArray(name string) ([]string, bool) {
+	c.check()
+	return c.cfg.GetOptionArray(name)
+}
+
+//See CFG.GetOption
+func (c *Cache) GetOption(name string) (string, bool) {
+	c.check()
+	return c.cfg.GetOption(name)
+}
+
+//See CFG.GetValue
+func (c *Cache) GetValue(name string, defaultValue string) string {
+	c.check()
+	return c.cfg.GetValue(name, defaultValue)
+}
+
+//See CFG.GetValueArray
+func (c *Cache) GetValueArray(name string, defaultValue []string) []string {
+	c.check()
+	return c.cfg.GetValueArray(name, defaultValue)
+}
+
+//Wait for the fetch running in the background, if any
+func (c *Cache) Close() {
+	c.background.Wait()
+}
@@ -0,0 +1,74 @@
+package cfg
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//Source waiting for gate to be closed before fetching, if set
+type blockingSource struct {
+	source Source
+	gate   chan struct{}
+}
+
+func (s *blockingSource) Fetch(ctx context.Context) ([]byte, error) {
+	if s.gate != nil {
+		<-s.gate
+	}
+	return s.source.Fetch(ctx)
+}
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	lkg := filepath.Join(dir, "lkg.cfg")
+	var errs []error
+	opts := CacheOptions{TTL: time.Hour, LastKnownGood: lkg, OnError: func(err error) { errs = append(errs, err) }}
+	source := &testSource{err: errors.New("down")}
+	cache := NewCache(source, opts)
+	if len(errs) != 2 || cache.GetValue("DB/Host", "none") != "none" {
+		t.Error("Unexpected start without source nor last known good file:", errs, cache.CFG().String())
+	}
+	source.err = nil
+	source.data = "DB {\n\tHost = db1\n}\n"
+	cache = NewCache(source, opts)
+	if cache.GetValue("DB/Host", "") != "db1" {
+		t.Error("Unexpected contents:", cache.CFG().String())
+	}
+	if data, _ := ioutil.ReadFile(lkg); string(data) != source.data {
+		t.Error("Unexpected last known good file:", string(data))
+	}
+	source.err = errors.New("down")
+	errs = errs[:0]
+	cache = NewCache(source, opts)
+	if len(errs) != 1 || cache.GetValue("DB/Host", "") != "db1" {
+		t.Error("Last known good file not loaded:", errs, cache.CFG().String())
+	}
+
+	source.err = nil
+	source.data = "DB {\n\tHost = db2\n}\n"
+	blocked := &blockingSource{source: source}
+	cache = NewCache(blocked, CacheOptions{TTL: time.Millisecond})
+	source.lock.Lock()
+	source.data = "DB {\n\tHost = db3\n}\n"
+	source.lock.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	blocked.gate = make(chan struct{})
+	if v := cache.GetValue("DB/Host", ""); v != "db2" {
+		t.Error("Getter waited for the fetch:", v)
+	}
+	close(blocked.gate)
+	cache.Close()
+	if v := cache.GetValue("DB/Host", ""); v != "db3" {
+		t.Error("Expired contents not refreshed:", v)
+	}
+	cache.Close()
+}
@@ -4,10 +4,16 @@ package cfg
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -15,9 +21,18 @@ import (
 const trimChars = " \n\r\t"
 const SplitChar = "/"
 
+//Maximum length in bytes of a line (and of a name spanning several lines) accepted by the parser unless changed with SetMaxLineLength
+const DefaultMaxLineLength = 1 << 20
+
 type option struct {
 	value   []string
 	comment string
+	version OptionVersion
+	//File and line the option was loaded from
+	source string
+	line   int
+	//Declared type of the values, if any
+	typ string
 }
 
 //This is a container of a cfg section. A full cfg file can be included in one *CFG and it's children
@@ -28,7 +43,43 @@ type CFG struct {
 	sections    map[string]*CFG
 	order       []string
 	comment     string
+	//File and line the section was loaded from
+	source      string
+	line        int
 	lock        *sync.RWMutex
+	migrations  map[int]func(*CFG) error
+	watchers    *watchers
+	pending     []ChangeEvent
+	versioning  *versioning
+	mutationLog *mutationLog
+	auditSink   AuditSink
+	secrets     *secretResolvers
+	fallbacks   map[string][]string
+	alias       *CFG
+	//Renamed paths already reported as deprecated
+	warnedRenames sync.Map
+	nameRules     *NameRules
+	maxLineLength int
+	frozen        bool
+	mergeSections bool
+	anchors       *anchors
+	//Source being loaded into the tree and the ones including it
+	loading     string
+	including   []string
+	providers   []provider
+	warnings    []string
+	logger      *slog.Logger
+	normalizers []normalizer
+	lookupStats *lookupStats
+	//Locked options can be overwritten
+	forcing bool
+	//Children are kept sorted by name, see SetSortedChildren
+	sorted bool
+	//Layouts used by GetTime
+	timeLayouts []string
+	//Times the tree has been locked to modify it
+	revision uint64
+	history  *history
 }
 
 //Create a new *CFG
@@ -48,12 +99,9 @@ func newCFG() (cfg *CFG) {
 
 //Create a new *CFG loading the contents from a filename
 func NewCFGFromFile(filename string) (cfg *CFG, err error) {
-	fi, err := os.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-	defer fi.Close()
-	return NewCFGFromReader(fi)
+	cfg = NewCFG()
+	err = cfg.LoadFiles(filename)
+	return
 }
 
 //Create a new *CFG loading the contents from a string
@@ -96,6 +144,27 @@ func (cfg *CFG) String() string {
 	return ""
 }
 
+//Revision of the whole tree. It is increased every time the tree is locked to modify it, so a writer that saw a revision can tell whether
+//anyone may have modified the tree since. Unlike Hash it does not dump the tree
+func (cfg *CFG) Revision() uint64 {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	return cfg.root().revision
+}
+
+//Get a hash of the contents of this cfg. Two cfgs with the same dump have the same hash
+func (cfg *CFG) Hash() string {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	return cfg.hash()
+}
+
+func (cfg *CFG) hash() string {
+	h := sha256.New()
+	cfg.dumpToWriter(h, 0)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 //Dump
 func (cfg *CFG) DumpToWriter(w io.Writer) error {
 	cfg.lock.RLock()
@@ -103,83 +172,249 @@ func (cfg *CFG) DumpToWriter(w io.Writer) error {
 	return cfg.dumpToWriter(w, 0)
 }
 
-func (cfg *CFG) dumpCommentToWriter(w io.Writer, comment string, indent string) error {
-	if comment == "" {
-		return nil
+//Save the whole tree to filename. The file is replaced atomically so readers never see a partially written cfg.
+//If a mutation log is set and can be truncated, it is emptied as it's changes are now part of the file
+func (cfg *CFG) SaveToFile(filename string) error {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	return cfg.saveToFile(filename)
+}
+
+//Check that the whole tree can be saved to filename and loaded back, and return the changes saving it would make to the contents
+//of the file, without writing anything. A missing file is treated as empty
+func (cfg *CFG) SaveToFileDryRun(filename string) ([]ChangeEvent, error) {
+	var buf bytes.Buffer
+	cfg.lock.RLock()
+	err := cfg.root().dumpToWriter(&buf, 0)
+	cfg.lock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	candidate, err := NewCFGFromReader(&buf)
+	if err != nil {
+		return nil, errors.New("The tree cannot be loaded back: " + err.Error())
+	}
+	current := NewCFG()
+	if _, err := os.Stat(filename); err == nil {
+		if current, err = NewCFGFromFile(filename); err != nil {
+			return nil, err
+		}
+	}
+	return diffChanges("", current, candidate), nil
+}
+
+func (cfg *CFG) saveToFile(filename string) error {
+	root := cfg.root()
+	if err := writeFileAtomically(filename, func(w io.Writer) error { return root.dumpToWriter(w, 0) }); err != nil {
+		return err
+	}
+	if root.mutationLog != nil {
+		return root.mutationLog.truncate()
+	}
+	return nil
+}
+
+//Replace filename with the contents written by write via a temporary file, so readers never see it partially written
+func writeFileAtomically(filename string, write func(w io.Writer) error) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
 	}
-	for _, cl := range strings.Split(comment, "\n") {
+	return os.Rename(tmp.Name(), filename)
+}
+
+//Buffered writers reused by the dumps so writing many small lines does not end up in a call to the destination for each of them
+var dumpBuffers = sync.Pool{New: func() interface{} { return bufio.NewWriterSize(nil, 32*1024) }}
+
+//Run dump writing to w through a pooled buffer
+func bufferedDump(w io.Writer, dump func(b *bufio.Writer) error) error {
+	b := dumpBuffers.Get().(*bufio.Writer)
+	b.Reset(w)
+	defer func() {
+		b.Reset(nil)
+		dumpBuffers.Put(b)
+	}()
+	if err := dump(b); err != nil {
+		return err
+	}
+	return b.Flush()
+}
+
+//Write the parts one after the other without joining them first
+func writeStrings(b *bufio.Writer, parts ...string) error {
+	for _, part := range parts {
+		if _, err := b.WriteString(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *CFG) dumpCommentToWriter(b *bufio.Writer, comment string, indent string) error {
+	for comment != "" {
+		cl := comment
+		if pos := strings.IndexByte(comment, '\n'); pos >= 0 {
+			cl, comment = comment[:pos], comment[pos+1:]
+		} else {
+			comment = ""
+		}
 		if len(cl) > 0 {
-			line := indent + "#" + cl + "\n"
-			if _, err := w.Write([]byte(line)); err != nil {
+			if err := writeStrings(b, indent, "#", cl, "\n"); err != nil {
 				return err
 			}
 		}
 	}
 	return nil
+}
 
+func (cfg *CFG) dumpOption(b *bufio.Writer, indent string, name string, typ string, value []string, comment string) error {
+	if err := cfg.dumpCommentToWriter(b, comment, indent); err != nil {
+		return err
+	}
+	for nV, val := range value {
+		var err error
+		if nV > 0 {
+			err = writeStrings(b, indent, name, " += ", val, "\n")
+		} else if typ != "" {
+			err = writeStrings(b, indent, name, typeHintChar, typ, " = ", val, "\n")
+		} else {
+			err = writeStrings(b, indent, name, " = ", val, "\n")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (cfg *CFG) dumpToWriter(w io.Writer, indent_lvl int) error {
+	return bufferedDump(w, func(b *bufio.Writer) error {
+		return cfg.dump(b, indent_lvl, DumpOptions{})
+	})
+}
+
+func (cfg *CFG) dump(b *bufio.Writer, indent_lvl int, opts DumpOptions) error {
 	indent := strings.Repeat("\t", indent_lvl)
-	var line string
+	if cfg.parent == nil {
+		if err := cfg.anchors.dump(b); err != nil {
+			return err
+		}
+	}
 	for _, name := range cfg.order {
 		//Dump the section
 		if sec, ok := cfg.sections[name]; ok {
-			if err := cfg.dumpCommentToWriter(w, sec.comment, indent); err != nil {
+			if err := cfg.dumpCommentToWriter(b, sec.comment, indent); err != nil {
+				return err
+			}
+			if sec.alias != nil {
+				if err := writeStrings(b, indent, aliasDirective, " ", name, " = ", sec.alias.path(), "\n"); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := writeStrings(b, indent, name, " {"); err != nil {
 				return err
 			}
-			line = indent + name + " {"
 			if sec.inheritance != nil {
-				line += "< " + sec.inheritance.path()
+				if err := writeStrings(b, "< ", sec.inheritance.path()); err != nil {
+					return err
+				}
 			}
-			if _, err := w.Write([]byte(line + "\n")); err != nil {
+			if err := b.WriteByte('\n'); err != nil {
 				return err
 			}
-			if err := sec.dumpToWriter(w, indent_lvl+1); err != nil {
+			if err := sec.dump(b, indent_lvl+1, opts); err != nil {
 				return err
 			}
-			line = indent + "}" + "\n"
-			if _, err := w.Write([]byte(line)); err != nil {
+			if err := writeStrings(b, indent, "}\n"); err != nil {
 				return err
 			}
 		}
 		if opt, ok := cfg.options[name]; ok {
-			if err := cfg.dumpCommentToWriter(w, opt.comment, indent); err != nil {
-				return err
+			if opts.OmitInheritedDuplicates && cfg.inheritsValue(name) {
+				continue
 			}
-			for nV, val := range opt.value {
-				if nV == 0 {
-					line = indent + name + " = " + val + "\n"
-				} else {
-					line = indent + name + " += " + val + "\n"
-				}
-				if _, err := w.Write([]byte(line)); err != nil {
-					return err
-				}
+			if err := cfg.dumpOption(b, indent, name, opt.typ, opt.value, opt.comment); err != nil {
+				return err
 			}
 		}
 	}
 	return nil
 }
 
-//load the contents of a reader into this CFG. This method fails if something gets overwritten
+//load the contents of a reader into this CFG. This method fails if something gets overwritten. Errors found while parsing are returned as *ParseError
 func (cfg *CFG) LoadFromReader(r io.Reader) (err error) {
-	cfg.lock.Lock()
-	inheritance_map := make(map[*CFG]string)
-	err = cfg.loadFromReader(bufio.NewReader(r), 0, inheritance_map)
-	if err != nil {
+	if err = cfg.writeLock(); err != nil {
+		return
+	}
+	inheritance_links := make([]inheritanceLink, 0)
+	if err = cfg.loadSource(r, "", &inheritance_links); err != nil {
+		cfg.unlock()
 		return
 	}
-	cfg.resetInheritance()
-	cfg.lock.Unlock()
-	for child, inheritance := range inheritance_map {
-		if err = child.SetInheritance(inheritance); err != nil {
+	return cfg.linkLoaded(inheritance_links)
+}
+
+//Parse the contents of a source holding the write lock. Inheritance is not applied until linkLoaded is called
+func (cfg *CFG) loadSource(r io.Reader, source string, inheritance_links *[]inheritanceLink) error {
+	root := cfg.root()
+	root.loading = source
+	defer func() { root.loading = "" }()
+	var line_counter int
+	err := cfg.loadFromReader(bufio.NewReader(r), &line_counter, inheritance_links)
+	//Errors in included files keep their source
+	if pErr, ok := err.(*ParseError); ok && pErr.Source == "" {
+		pErr.Source = source
+	}
+	return err
+}
+
+//Apply the inheritance found while loading and release the write lock
+func (cfg *CFG) linkLoaded(inheritance_links []inheritanceLink) (err error) {
+	//Merged sections keep the inheritance they had
+	if !cfg.root().mergeSections {
+		cfg.resetInheritance()
+	}
+	cfg.unlock()
+	//Links are applied in the same order they were found in the source so loop detection is deterministic
+	for _, link := range inheritance_links {
+		if link.alias {
+			cfg.lock.Lock()
+			err = link.section.setAliasTarget(link.inheritance)
+			cfg.lock.Unlock()
+		} else {
+			err = link.section.SetInheritance(link.inheritance)
+		}
+		if err != nil {
 			return
 		}
 	}
+	if cfg.parent == nil && len(cfg.migrations) > 0 {
+		err = cfg.Migrate()
+	}
 	return
 }
 
+//Inheritance declared for a section while parsing. It gets applied once the whole source has been loaded
+type inheritanceLink struct {
+	section     *CFG
+	inheritance string
+	//The section is an alias of inheritance instead of inheriting from it
+	alias bool
+}
+
 //Reset all inheritance pointers for this cfg and child ones
 func (cfg *CFG) resetInheritance() {
 	cfg.inheritance = nil
@@ -190,15 +425,21 @@ func (cfg *CFG) resetInheritance() {
 
 //Define an inheritance section for this cfg. That means that any time that an option or section is retrieved, if this cfg does not have it it will check the inheritance one
 func (cfg *CFG) SetInheritance(inheritance string) error {
-	cfg.lock.Lock()
-	defer cfg.lock.Unlock()
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
+	return cfg.setInheritance(inheritance)
+}
+
+func (cfg *CFG) setInheritance(inheritance string) error {
 	if cfg.parent == nil {
-		return errors.New("Root node cannot inherit from anyone")
+		return ErrRootInheritance
 	}
 	incfg, _ := cfg.root().getString(inheritance, false, 0)
 	myPath := cfg.path()
 	if incfg == nil {
-		return errors.New(fmt.Sprintf("Inheritance section %s for section %s does not exist", inheritance, myPath))
+		return newError(ErrNotFound, fmt.Sprintf("Inheritance section %s for section %s does not exist", inheritance, myPath))
 	}
 	path := []string{myPath}
 	current := incfg
@@ -206,27 +447,37 @@ func (cfg *CFG) SetInheritance(inheritance string) error {
 		currentPath := current.path()
 		path = append(path, currentPath)
 		if current == cfg {
-			return errors.New("Circular inheritance loop found: " + strings.Join(path, " < "))
+			return newError(ErrCircularInheritance, "Circular inheritance loop found: "+strings.Join(path, " < "))
 		}
 		for parent := cfg.parent; parent != nil; parent = parent.parent {
 			if parent == current {
-				return errors.New("Cannot inherit from a direct parent to prevent recursive loops (" + currentPath + " is parent of " + myPath + ")")
+				return newError(ErrCircularInheritance, "Cannot inherit from a direct parent to prevent recursive loops ("+currentPath+" is parent of "+myPath+")")
 			}
 		}
 
 		current = current.inheritance
 	}
 	cfg.inheritance = incfg
+	cfg.record(InheritanceSet, myPath, []string{incfg.path()})
 	return nil
 }
 
-func (cfg *CFG) processSection(section_name string, remainder string, comment []string, inheritance_map map[*CFG]string) (*CFG, error) {
-	if ocfg, opt := cfg.getString(section_name, false, 0); ocfg != nil || opt != nil {
-		return nil, errors.New(fmt.Sprintf("Section %s defined under %s is already defined", section_name, cfg.path()))
-	}
-	subCfg, err := cfg.createSection(section_name, strings.Join(comment, "\n"))
-	if err != nil {
-		return subCfg, err
+func (cfg *CFG) processSection(section_name string, remainder string, comment []string, line int, inheritance_links *[]inheritanceLink) (*CFG, error) {
+	var subCfg *CFG
+	if ocfg, opt := cfg.getString(section_name, false, 0); ocfg != nil && cfg.root().mergeSections {
+		//Merge the repeated definition into the existing section
+		subCfg = ocfg
+		if len(comment) > 0 {
+			subCfg.comment = joinComments(subCfg.comment, strings.Join(comment, "\n"))
+		}
+	} else if ocfg != nil || opt != nil {
+		return nil, newError(ErrExists, fmt.Sprintf("Section %s defined under %s is already defined", section_name, cfg.path()))
+	} else {
+		var err error
+		if subCfg, err = cfg.createSection(section_name, strings.Join(comment, "\n")); err != nil {
+			return subCfg, err
+		}
+		subCfg.source, subCfg.line = cfg.root().loading, line
 	}
 	//Check if inheritance is defined
 	remainder = strings.Trim(remainder, trimChars)
@@ -234,40 +485,85 @@ func (cfg *CFG) processSection(section_name string, remainder string, comment []
 		if remainder[0] != '<' {
 			return nil, errors.New(fmt.Sprintf("Expected inheriting section defined with '< section_name' but '%s' found", remainder))
 		}
-		inheritance_map[subCfg] = strings.Trim(remainder[1:], trimChars)
+		*inheritance_links = append(*inheritance_links, inheritanceLink{subCfg, strings.Trim(remainder[1:], trimChars), false})
 	}
 	return subCfg, nil
 }
 
-func (cfg *CFG) processOption(parsedData []rune, opt_value string, comment []string) error {
+func (cfg *CFG) processOption(parsedData []rune, opt_value string, comment []string, line int, inheritance_links *[]inheritanceLink) error {
 	opt_value = strings.Trim(opt_value, trimChars)
+	if len(parsedData) == 0 {
+		return errors.New("What is the name of the option?")
+	}
 	switch parsedData[len(parsedData)-1] {
 	case '+':
 		opt_name := strings.Trim(string(parsedData[:len(parsedData)-1]), trimChars)
 		if _, opt := cfg.getString(opt_name, false, 0); opt != nil {
 			//Option is previously defined, so ok
-			opt.value = append(opt.value, opt_value)
+			appended := cfg.root().normalize(cfg.childPath(opt_name), []string{opt_value})
+			if err := checkType(opt.typ, appended); err != nil {
+				return err
+			}
+			opt.value = append(opt.value, appended...)
+			opt.source, opt.line = cfg.root().loading, line
+			cfg.record(OptionSet, cfg.childPath(opt_name), opt.value)
 		} else {
 			//Oops. Trying to append to a non existant option!
-			return errors.New("Option " + opt_name + " was not previously defined")
+			return newError(ErrNotFound, "Option "+opt_name+" was not previously defined")
 		}
 	default:
 		opt_name := strings.Trim(string(parsedData), trimChars)
-		if sec, opt := cfg.getString(opt_name, false, 0); sec != nil || opt != nil {
-			return errors.New(opt_name + " already exists")
+		if strings.HasPrefix(opt_name, defineDirective+" ") {
+			return cfg.root().define(strings.Trim(opt_name[len(defineDirective):], trimChars), opt_value)
+		}
+		if strings.HasPrefix(opt_name, aliasDirective+" ") {
+			alias, err := cfg.addAlias(strings.Trim(opt_name[len(aliasDirective):], trimChars))
+			if err != nil {
+				return err
+			}
+			alias.comment = strings.Join(comment, "\n")
+			alias.source, alias.line = cfg.root().loading, line
+			*inheritance_links = append(*inheritance_links, inheritanceLink{alias, opt_value, true})
+			return nil
+		}
+		opt_name, typ := splitTypeHint(opt_name)
+		sec, opt := cfg.getString(opt_name, false, 0)
+		if sec != nil || opt != nil && !cfg.root().mergeSections {
+			return newError(ErrExists, opt_name+" already exists")
+		}
+		if root := cfg.root(); opt != nil && root.logger != nil {
+			root.logger.Debug("Option redefined", "path", cfg.childPath(opt_name), "origin", root.loading, "line", line, "previous", opt.source)
+		}
+		var err error
+		if typ != "" {
+			err = cfg.setTypedOptionArray(opt_name, typ, []string{opt_value}, strings.Join(comment, "\n"))
+		} else {
+			err = cfg.setOptionArray(opt_name, []string{opt_value}, strings.Join(comment, "\n"))
+		}
+		if err != nil {
+			return err
 		}
-		return cfg.setOptionArray(opt_name, []string{opt_value}, strings.Join(comment, SplitChar))
+		_, opt = cfg.getString(opt_name, false, 0)
+		opt.line = line
 	}
 	return nil
 }
 
-func (cfg *CFG) loadFromReader(source *bufio.Reader, line_counter uint32, inheritance_map map[*CFG]string) (err error) {
+func (cfg *CFG) loadFromReader(source *bufio.Reader, line_counter *int, inheritance_links *[]inheritanceLink) (err error) {
 	comment := make([]string, 0)
-	line := ""
+	raw, line := "", ""
 	parsedData := make([]rune, 0, 128)
+	maxLength := cfg.root().maxLineLength
+	if maxLength == 0 {
+		maxLength = DefaultMaxLineLength
+	}
 	for err == nil {
-		line, err = source.ReadString('\n')
-		line_counter++
+		raw, err = readLine(source, maxLength)
+		*line_counter++
+		if err == errLineTooLong {
+			return &ParseError{Line: *line_counter, Column: maxLength + 1, Msg: fmt.Sprintf("Line is longer than %d bytes", maxLength)}
+		}
+		line = raw
 		commentPos := strings.IndexRune(line, '#')
 		if commentPos > -1 {
 			comment = append(comment, strings.Trim(line[commentPos+1:], trimChars))
@@ -278,17 +574,38 @@ func (cfg *CFG) loadFromReader(source *bufio.Reader, line_counter uint32, inheri
 			//Skip empty lines and lines starting with '#' (comments)
 			continue
 		}
+		raw = strings.TrimRight(raw, "\r\n")
+		//Position of the line in the raw one to report columns
+		offset := len(raw) - len(strings.TrimLeft(raw, trimChars))
+		if len(parsedData) == 0 && isInclude(line) {
+			if err = cfg.processInclude(line, *line_counter, inheritance_links); err != nil {
+				if _, ok := err.(*ParseError); !ok {
+					err = newParseError(err, *line_counter, raw, offset)
+				}
+				return err
+			}
+			comment = comment[:0]
+			continue
+		}
 	NextLineBreak:
 		for lPos, lChar := range line {
 			switch lChar {
 			case '{':
 				section_name := strings.Trim(string(parsedData), trimChars)
+				if strings.HasPrefix(section_name, foreachDirective+" ") {
+					if err = cfg.processForeach(section_name, source, line_counter, inheritance_links); err != nil {
+						return err
+					}
+					comment = comment[:0]
+					parsedData = parsedData[:0]
+					break NextLineBreak
+				}
 				var subCfg *CFG
-				subCfg, err = cfg.processSection(section_name, line[lPos+1:], comment, inheritance_map)
+				subCfg, err = cfg.processSection(section_name, line[lPos+1:], comment, *line_counter, inheritance_links)
 				if err != nil {
-					return errors.New(fmt.Sprintf("%s (line %v)", err.Error(), line_counter))
+					return newParseError(err, *line_counter, raw, offset)
 				}
-				err = subCfg.loadFromReader(source, line_counter, inheritance_map)
+				err = subCfg.loadFromReader(source, line_counter, inheritance_links)
 				if err != nil {
 					return err
 				}
@@ -298,14 +615,17 @@ func (cfg *CFG) loadFromReader(source *bufio.Reader, line_counter uint32, inheri
 			case '}':
 				return nil
 			case '=':
-				err = cfg.processOption(parsedData, line[lPos+1:], comment)
+				err = cfg.processOption(parsedData, line[lPos+1:], comment, *line_counter, inheritance_links)
 				if err != nil {
-					return errors.New(fmt.Sprintf("%s (line %v)", err.Error(), line_counter))
+					return newParseError(err, *line_counter, raw, offset)
 				}
 				comment = comment[:0]
 				parsedData = parsedData[:0]
 				break NextLineBreak
 			default:
+				if maxLength > 0 && len(parsedData) >= maxLength {
+					return newParseError(errors.New(fmt.Sprintf("Name is longer than %d bytes", maxLength)), *line_counter, raw, offset+lPos)
+				}
 				parsedData = append(parsedData, lChar)
 			}
 
@@ -317,6 +637,43 @@ func (cfg *CFG) loadFromReader(source *bufio.Reader, line_counter uint32, inheri
 	return err
 }
 
+//Limit the length of the lines accepted when loading contents into this tree. 0 restores DefaultMaxLineLength and a negative length disables the limit
+func (cfg *CFG) SetMaxLineLength(length int) {
+	cfg.lock.Lock()
+	defer cfg.lock.Unlock()
+	cfg.root().maxLineLength = length
+}
+
+//Merge the sections defined several times when loading contents into this tree instead of failing. Bodies are merged in the order they
+//are found and options defined again replace the previous values, so fragments concatenated one after the other (conf.d style) can be loaded
+func (cfg *CFG) SetMergeSections(merge bool) {
+	cfg.lock.Lock()
+	defer cfg.lock.Unlock()
+	cfg.root().mergeSections = merge
+}
+
+var errLineTooLong = errors.New("Line too long")
+
+//Read a line like ReadString('\n') but without buffering more than max bytes (if max is positive)
+func readLine(source *bufio.Reader, max int) (string, error) {
+	var line []byte
+	for {
+		chunk, err := source.ReadSlice('\n')
+		length := len(line) + len(chunk)
+		if err == nil {
+			//The newline does not count
+			length--
+		}
+		if max > 0 && length > max {
+			return "", errLineTooLong
+		}
+		line = append(line, chunk...)
+		if err != bufio.ErrBufferFull {
+			return string(line), err
+		}
+	}
+}
+
 //Return the path to this CFG from the root one
 func (cfg *CFG) Path() string {
 	cfg.lock.RLock()
@@ -360,8 +717,47 @@ func (cfg *CFG) root() *CFG {
 }
 
 /* inner gets */
+//Same as get with SplitPath(path) but walking the string so lookups do not allocate
 func (cfg *CFG) getString(path string, follow_inheritance bool, parent_lvl int) (*CFG, *option) {
-	return cfg.get(strings.Split(path, SplitChar), follow_inheritance, parent_lvl)
+	remaining := countElements(path)
+	if remaining < 1+parent_lvl {
+		return nil, nil
+	}
+	sec := cfg
+	for start := 0; ; remaining-- {
+		name, next := nextElement(path, start)
+		if remaining == 1+parent_lvl {
+			if found := sec.getSection(name, follow_inheritance); found != nil {
+				return found, nil
+			}
+			return nil, sec.getOption(name, follow_inheritance)
+		}
+		if sec = sec.getSection(name, follow_inheritance); sec == nil {
+			return nil, nil
+		}
+		start = next
+	}
+}
+
+//Number of elements of a path as SplitPath would return
+func countElements(path string) int {
+	count := 0
+	for name, next := nextElement(path, 0); name != ""; name, next = nextElement(path, next) {
+		count++
+	}
+	return count
+}
+
+//First non empty element of path at or after start and the position following it
+func nextElement(path string, start int) (string, int) {
+	for strings.HasPrefix(path[start:], SplitChar) {
+		start += len(SplitChar)
+	}
+	end := strings.Index(path[start:], SplitChar)
+	if end < 0 {
+		return path[start:], len(path)
+	}
+	return path[start : start+end], start + end
 }
 
 func (cfg *CFG) get(path []string, follow_inheritance bool, parent_lvl int) (*CFG, *option) {
@@ -416,10 +812,18 @@ func (cfg *CFG) GetSection(name string) (*CFG, bool) {
 /* Real getters*/
 func (cfg *CFG) getSection(name string, follow_inheritance bool) *CFG {
 	if sec, ok := cfg.sections[name]; ok {
-		return sec
+		return sec.resolveAlias()
 	}
-	if follow_inheritance && cfg.inheritance != nil {
-		return cfg.inheritance.getSection(name, true)
+	if !follow_inheritance {
+		return nil
+	}
+	if cfg.inheritance != nil {
+		if sec := cfg.inheritance.getSection(name, true); sec != nil {
+			return sec
+		}
+	}
+	if wildcard := cfg.wildcard(); wildcard != nil {
+		return wildcard.getSection(name, true)
 	}
 	return nil
 }
@@ -428,16 +832,26 @@ func (cfg *CFG) getOption(name string, follow_inheritance bool) *option {
 	if opt, ok := cfg.options[name]; ok {
 		return opt
 	}
-	if follow_inheritance && cfg.inheritance != nil {
-		return cfg.inheritance.getOption(name, true)
+	if !follow_inheritance {
+		return nil
+	}
+	if cfg.inheritance != nil {
+		if opt := cfg.inheritance.getOption(name, true); opt != nil {
+			return opt
+		}
+	}
+	if wildcard := cfg.wildcard(); wildcard != nil {
+		return wildcard.getOption(name, true)
 	}
 	return nil
 }
 
 //Creates a section.Does not create all the intermediate ones and does not overwrite if there's one already present
 func (cfg *CFG) CreateSection(name string, comment string) (*CFG, error) {
-	cfg.lock.Lock()
-	defer cfg.lock.Unlock()
+	if err := cfg.writeLock(); err != nil {
+		return nil, err
+	}
+	defer cfg.unlock()
 	return cfg.createSection(name, comment)
 }
 
@@ -450,28 +864,53 @@ func (cfg *CFG) createSection(name string, comment string) (*CFG, error) {
 	case 1:
 		parentCfg = cfg
 	default:
-		parentCfg, _ := cfg.get(p, false, 1)
+		parentCfg, _ = cfg.get(p, false, 1)
 		if parentCfg == nil {
-			return nil, errors.New("Parent section for " + strings.Join(p, "\n") + " does not exist")
+			return nil, newError(ErrNoParent, "Parent section for "+strings.Join(p, SplitChar)+" does not exist")
 		}
 	}
-	if _, ok := parentCfg.sections[p[0]]; ok {
-		return nil, errors.New("Section " + p[0] + " already exists")
-	}
 	section_name := p[len(p)-1]
+	if err := cfg.checkName(section_name, false); err != nil {
+		return nil, err
+	}
+	if _, ok := parentCfg.sections[section_name]; ok {
+		return nil, newError(ErrExists, "Section "+section_name+" already exists")
+	}
+	if _, ok := parentCfg.options[section_name]; ok {
+		return nil, newError(ErrExists, "Option "+section_name+" already exists")
+	}
 	subCfg := newCFG()
 	parentCfg.sections[section_name] = subCfg
-	parentCfg.order = append(parentCfg.order, section_name)
+	parentCfg.appendChild(section_name)
 	subCfg.parent = parentCfg
 	subCfg.lock = cfg.lock
 	subCfg.comment = comment
+	subCfg.record(SectionCreated, subCfg.path(), nil)
 	return subCfg, nil
 }
 
+//Get the section under the path creating all the missing ones on the way
+func (cfg *CFG) ensureSection(p []string) (*CFG, error) {
+	sec := cfg
+	for _, name := range p {
+		sub, ok := sec.sections[name]
+		if !ok {
+			var err error
+			if sub, err = sec.createSection(name, ""); err != nil {
+				return nil, err
+			}
+		}
+		sec = sub.resolveAlias()
+	}
+	return sec, nil
+}
+
 //Set an option value. This overwrites if it exists
 func (cfg *CFG) SetOptionArray(name string, value []string, comment string) error {
-	cfg.lock.Lock()
-	defer cfg.lock.Unlock()
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
 	return cfg.setOptionArray(name, value, comment)
 }
 func (cfg *CFG) setOptionArray(name string, value []string, comment string) error {
@@ -484,19 +923,38 @@ func (cfg *CFG) setOptionArray(name string, value []string, comment string) erro
 	case 1:
 		opt = cfg.options[p[0]]
 	default:
-		pcfg, opt = cfg.get(p, false, 1)
+		pcfg, _ = cfg.get(p, false, 1)
 		if pcfg == nil {
-			return errors.New(fmt.Sprintf("Parent %s section does not exist", strings.Join(p[:len(p)-1], SplitChar)))
+			return newError(ErrNoParent, fmt.Sprintf("Parent %s section does not exist", strings.Join(p[:len(p)-1], SplitChar)))
 		}
+		opt = pcfg.options[p[len(p)-1]]
 	}
 	if opt == nil {
-		opt = new(option)
 		opt_name := p[len(p)-1]
+		if _, ok := pcfg.sections[opt_name]; ok {
+			return newError(ErrExists, "Section "+opt_name+" already exists")
+		}
+		if err := cfg.checkName(opt_name, true); err != nil {
+			return err
+		}
+		opt = new(option)
 		pcfg.options[opt_name] = opt
-		pcfg.order = append(cfg.order, opt_name)
+		pcfg.appendChild(opt_name)
+	}
+	if err := pcfg.checkLocked(p[len(p)-1], opt); err != nil {
+		return err
+	}
+	value, err := pcfg.checkedValues(p[len(p)-1], opt, value)
+	if err != nil {
+		return err
+	}
+	if opt.locked() && !lockedComment(comment) {
+		comment = joinComments(comment, LockedAnnotation)
 	}
 	opt.comment = comment
 	opt.value = value
+	opt.source, opt.line = cfg.root().loading, 0
+	pcfg.record(OptionSet, pcfg.childPath(p[len(p)-1]), value)
 	return nil
 }
 
@@ -505,14 +963,16 @@ func (cfg *CFG) SetOption(name string, value string, comment string) error {
 	return cfg.SetOptionArray(name, []string{value}, comment)
 }
 
-//Get option value as a string array
+//Get option value as a string array. Secret references are resolved (see RegisterSecretResolver), if they cannot be the option is reported as missing
 func (cfg *CFG) GetOptionArray(name string) ([]string, bool) {
 	cfg.lock.RLock()
-	defer cfg.lock.RUnlock()
-	if _, opt := cfg.getString(name, true, 0); opt != nil {
-		return opt.value, true
+	values, err := cfg.lookupValues(name)
+	secrets := cfg.root().secrets
+	cfg.lock.RUnlock()
+	if err == nil && secrets != nil {
+		values, err = secrets.resolveAll(values)
 	}
-	return nil, false
+	return values, err == nil
 }
 
 //Get option value as a string
@@ -540,6 +1000,270 @@ func (cfg *CFG) GetValueArray(name string, defaultValue []string) []string {
 	return defaultValue
 }
 
+//Remove an option or a section (with all it's contents). Sections that are inherited by others cannot be removed
+func (cfg *CFG) Remove(name string) error {
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
+	return cfg.remove(SplitPath(name))
+}
+
+func (cfg *CFG) remove(p []string) error {
+	if len(p) == 0 {
+		return errors.New("What's the name of the option or section?")
+	}
+	parent := cfg
+	if len(p) > 1 {
+		if parent, _ = cfg.get(p, false, 1); parent == nil {
+			return newError(ErrNoParent, fmt.Sprintf("Parent %s section does not exist", strings.Join(p[:len(p)-1], SplitChar)))
+		}
+	}
+	name := p[len(p)-1]
+	if sec, ok := parent.sections[name]; ok {
+		if heir := sec.findHeir(sec.root()); heir != nil {
+			return errors.New(fmt.Sprintf("Section %s is inherited by %s", sec.path(), heir.path()))
+		}
+	}
+	if parent.removeChild(name) < 0 {
+		return newError(ErrNotFound, fmt.Sprintf("%s does not exist", strings.Join(p, SplitChar)))
+	}
+	return nil
+}
+
+//Find a section outside this one that inherits from this section or any of it's children
+func (cfg *CFG) findHeir(from *CFG) *CFG {
+	if from == cfg {
+		return nil
+	}
+	for _, target := range []*CFG{from.inheritance, from.alias} {
+		for in := target; in != nil; in = in.parent {
+			if in == cfg {
+				return from
+			}
+		}
+	}
+	for _, name := range from.order {
+		if sec, ok := from.sections[name]; ok {
+			if heir := cfg.findHeir(sec); heir != nil {
+				return heir
+			}
+		}
+	}
+	return nil
+}
+
+//Detach a direct child option or section. Returns the position it had in the order or -1 if it did not exist
+func (cfg *CFG) removeChild(name string) int {
+	pos := cfg.childIndex(name)
+	if pos < 0 {
+		return pos
+	}
+	if sec, ok := cfg.sections[name]; ok {
+		cfg.record(SectionRemoved, sec.path(), nil)
+		sec.parent = nil
+		delete(cfg.sections, name)
+	} else {
+		cfg.record(OptionRemoved, cfg.childPath(name), nil)
+	}
+	delete(cfg.options, name)
+	cfg.order = append(cfg.order[:pos], cfg.order[pos+1:]...)
+	return pos
+}
+
+//Attach an option (if opt is not nil) or a section as a direct child at the given position of the order. A negative position appends it.
+//The position is ignored if the children are sorted
+func (cfg *CFG) insertChild(name string, pos int, opt *option, sec *CFG) {
+	if opt != nil {
+		cfg.options[name] = opt
+	} else {
+		cfg.sections[name] = sec
+		sec.parent = cfg
+		sec.setLock(cfg.lock)
+	}
+	if cfg.sorted || pos < 0 || pos >= len(cfg.order) {
+		cfg.appendChild(name)
+	} else {
+		cfg.order = append(cfg.order, "")
+		copy(cfg.order[pos+1:], cfg.order[pos:])
+		cfg.order[pos] = name
+	}
+	if opt != nil {
+		cfg.record(OptionSet, cfg.childPath(name), opt.value)
+	} else {
+		sec.recordTree(sec.path(), sec)
+	}
+}
+
+func (cfg *CFG) setLock(lock *sync.RWMutex) {
+	cfg.lock = lock
+	for _, sec := range cfg.sections {
+		sec.setLock(lock)
+	}
+}
+
+func (opt *option) copy() *option {
+	dup := *opt
+	dup.value = make([]string, len(opt.value))
+	copy(dup.value, opt.value)
+	return &dup
+}
+
+//Deep copy of the tree under this section. The copy is detached and has no lock.
+//Inheritance links to sections inside the copied tree point to the copies. The rest keep pointing to the original targets
+func (cfg *CFG) copyTree() *CFG {
+	copies := make(map[*CFG]*CFG)
+	dup := cfg.copySections(copies)
+	if cfg.parent == nil {
+		dup.anchors = cfg.anchors.copy()
+	}
+	for orig, sec := range copies {
+		if in, ok := copies[orig.inheritance]; ok {
+			sec.inheritance = in
+		} else {
+			sec.inheritance = orig.inheritance
+		}
+		if target, ok := copies[orig.alias]; ok {
+			sec.alias = target
+		} else {
+			sec.alias = orig.alias
+		}
+	}
+	return dup
+}
+
+//Copy of the whole tree of this section, taken holding it's lock, and the section matching this one in the copy.
+//Comparing with the copy avoids holding the locks of two trees at once, which deadlocks if another goroutine locks them
+//in the opposite order while a writer waits on either
+func (cfg *CFG) snapshot() *CFG {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	root := cfg.root()
+	dup := root.copyTree()
+	if cfg == root {
+		return dup
+	}
+	sec, _ := dup.get(SplitPath(cfg.path()), false, 0)
+	return sec
+}
+
+func (cfg *CFG) copySections(copies map[*CFG]*CFG) *CFG {
+	dup := newCFG()
+	dup.comment = cfg.comment
+	dup.source, dup.line = cfg.source, cfg.line
+	dup.order = append(dup.order, cfg.order...)
+	dup.sorted = cfg.sorted
+	for name, opt := range cfg.options {
+		dup.options[name] = opt.copy()
+	}
+	for name, sec := range cfg.sections {
+		sub := sec.copySections(copies)
+		sub.parent = dup
+		dup.sections[name] = sub
+	}
+	copies[cfg] = dup
+	return dup
+}
+
+//Replace the contents of this section with the ones of src (a detached tree) keeping this *CFG and all the sub sections that exist in both alive,
+//so pointers to them and inheritance links from outside stay valid
+//Fail if replacing the contents of this section with src would drop or change a locked option or give an option a value that is
+//not valid for it's declared type. Options in src without a type take the declared one
+func (cfg *CFG) checkReplacement(src *CFG) error {
+	for name, opt := range cfg.options {
+		in, ok := src.options[name]
+		if !ok || !equalValues(opt.value, in.value) {
+			if err := cfg.checkLocked(name, opt); err != nil {
+				return err
+			}
+		}
+		if !ok || opt.typ == "" || in.typ != "" {
+			continue
+		}
+		if err := checkType(opt.typ, in.value); err != nil {
+			return errors.New(fmt.Sprintf("Cannot set %s: %s", cfg.childPath(name), err.Error()))
+		}
+		in.typ = opt.typ
+	}
+	for name, sec := range cfg.sections {
+		in, ok := src.sections[name]
+		if !ok {
+			in = newCFG()
+		}
+		if err := sec.checkReplacement(in); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *CFG) replaceContents(src *CFG) {
+	cfg.recordDiff(cfg.childPath(""), cfg, src)
+	replaced := make(map[*CFG]*CFG)
+	replaced[src] = cfg
+	cfg.adopt(src, replaced)
+	cfg.root().redirectInheritance(replaced)
+	if cfg.parent == nil {
+		cfg.anchors = src.anchors.copy()
+	}
+}
+
+//Move the contents of src into this section reusing the existing sub sections with the same name
+func (cfg *CFG) adopt(src *CFG, replaced map[*CFG]*CFG) {
+	oldSections := cfg.sections
+	for name, opt := range src.options {
+		old, ok := cfg.options[name]
+		if !ok || !equalValues(old.value, opt.value) {
+			continue
+		}
+		if old.comment == opt.comment && old.source == opt.source && old.line == opt.line && old.typ == opt.typ {
+			//Nothing changed, keep the option as it is
+			src.options[name] = old
+		} else {
+			opt.version = old.version
+		}
+	}
+	cfg.options = src.options
+	cfg.sections = src.sections
+	cfg.order = src.order
+	if cfg.sorted && !sort.StringsAreSorted(cfg.order) {
+		sort.Strings(cfg.order)
+	}
+	cfg.comment = src.comment
+	cfg.source, cfg.line = src.source, src.line
+	for name, sec := range cfg.sections {
+		if existing, ok := oldSections[name]; ok && existing != sec {
+			existing.inheritance = sec.inheritance
+			existing.alias = sec.alias
+			existing.adopt(sec, replaced)
+			replaced[sec] = existing
+			cfg.sections[name] = existing
+			delete(oldSections, name)
+		} else {
+			sec.parent = cfg
+			sec.setLock(cfg.lock)
+		}
+	}
+	for _, sec := range oldSections {
+		if sec.parent == cfg {
+			sec.parent = nil
+		}
+	}
+}
+
+//Make sections in this tree inheriting from a replaced section inherit from it's replacement
+func (cfg *CFG) redirectInheritance(replaced map[*CFG]*CFG) {
+	if to, ok := replaced[cfg.inheritance]; ok {
+		cfg.inheritance = to
+	}
+	if to, ok := replaced[cfg.alias]; ok {
+		cfg.alias = to
+	}
+	for _, sec := range cfg.sections {
+		sec.redirectInheritance(replaced)
+	}
+}
+
 //Clone a CFG. If it's not the root one it will just dup from that section downwards. Upper inheritance links will still point to their original sources. Lower ones will point to the new created sections
 func (cfg *CFG) Clone() (dup *CFG, err error) {
 	var buf bytes.Buffer
@@ -560,24 +1284,47 @@ func (cfg *CFG) Clone() (dup *CFG, err error) {
 func (cfg *CFG) RealEqual(other *CFG) bool {
 	cfg.lock.RLock()
 	defer cfg.lock.RUnlock()
-	return cfg.equal(other, true)
+	return cfg.equal(other, EqualOptions{Comments: true})
 }
 
 //Are the two CFGs equal (NOT including comments)
 func (cfg *CFG) Equal(other *CFG) bool {
 	cfg.lock.RLock()
 	defer cfg.lock.RUnlock()
-	return cfg.equal(other, false)
+	return cfg.equal(other, EqualOptions{})
+}
+
+//What Equal takes into account
+type EqualOptions struct {
+	//Compare the comments too, as RealEqual does
+	Comments bool
+	//Do not compare which sections the sections inherit from, only their own contents. Useful to compare a tree resolved
+	//from templates with the templates
+	IgnoreInheritance bool
+}
+
+//Are the two CFGs equal comparing what opts asks for
+func (cfg *CFG) EqualWithOptions(other *CFG, opts EqualOptions) bool {
+	if other.lock != cfg.lock {
+		other = other.snapshot()
+	}
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	return cfg.equal(other, opts)
 }
 
-func (cfg *CFG) equal(other *CFG, with_comments bool) bool {
-	if with_comments && cfg.comment != other.comment {
+func (cfg *CFG) equal(other *CFG, opts EqualOptions) bool {
+	if opts.Comments && cfg.comment != other.comment {
+		return false
+	}
+	if cfg.parent == nil && other.parent == nil && !cfg.anchors.equal(other.anchors) {
 		return false
 	}
 	if len(cfg.order) != len(other.order) {
 		return false
 	}
 	switch {
+	case opts.IgnoreInheritance:
 	case cfg.inheritance != nil:
 		if other.inheritance == nil {
 			return false
@@ -590,13 +1337,16 @@ func (cfg *CFG) equal(other *CFG, with_comments bool) bool {
 			return false
 		}
 	}
+	if (cfg.alias == nil) != (other.alias == nil) || cfg.alias != nil && cfg.alias.path() != other.alias.path() {
+		return false
+	}
 	for iPos, name := range cfg.order {
 		if other.order[iPos] != name {
 			return false
 		}
 		if sec, ok := cfg.sections[name]; ok {
 			if other_sec, ok2 := other.sections[name]; ok2 {
-				if !sec.equal(other_sec, with_comments) {
+				if !sec.equal(other_sec, opts) {
 					return false
 				}
 			} else {
@@ -605,7 +1355,7 @@ func (cfg *CFG) equal(other *CFG, with_comments bool) bool {
 		}
 		if opt, ok := cfg.options[name]; ok {
 			if other_opt, ok2 := other.options[name]; ok2 {
-				if with_comments && opt.comment != other_opt.comment {
+				if opts.Comments && opt.comment != other_opt.comment {
 					return false
 				}
 				if len(opt.value) != len(other_opt.value) {
@@ -672,27 +1422,86 @@ func (cfg *CFG) ListSections() <-chan string {
 	return c
 }
 
+//What happens to the comment of an option or section that already exists when InsertContentsWithOptions overwrites it
+type CommentPolicy int
+
+const (
+	//The comment of the inserted option or section replaces the existing one, as InsertContents does
+	CommentsReplace CommentPolicy = iota
+	//The existing comment is kept
+	CommentsKeep
+	//The inserted comment is appended after the existing one. Comments that are the same are only kept once
+	CommentsConcat
+)
+
+//How InsertContentsWithOptions merges the contents
+type MergeOptions struct {
+	Comments CommentPolicy
+}
+
+//Comment of an existing option or section overwritten by one with comment "in"
+func (opts MergeOptions) mergeComment(existing string, in string) string {
+	switch opts.Comments {
+	case CommentsKeep:
+		return existing
+	case CommentsConcat:
+		if existing == in {
+			return existing
+		}
+		return joinComments(existing, in)
+	}
+	return in
+}
+
 //Insert the contents of the "in" CFG into the current one
 func (cfg *CFG) InsertContents(in *CFG) (err error) {
-	cfg.lock.Lock()
-	defer cfg.lock.Unlock()
-	return cfg.insertContents(in)
+	return cfg.InsertContentsWithOptions(in, MergeOptions{})
+}
+
+//Like InsertContents merging the comments of the options and sections that already exist as opts asks
+func (cfg *CFG) InsertContentsWithOptions(in *CFG, opts MergeOptions) (err error) {
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
+	backup := cfg.copyTree()
+	mark := cfg.recordMark()
+	if err := cfg.insertContents(in, opts); err != nil {
+		cfg.replaceContents(backup)
+		cfg.dropRecorded(mark)
+		return err
+	}
+	return nil
 }
 
-func (cfg *CFG) insertContents(in *CFG) (err error) {
+func (cfg *CFG) insertContents(in *CFG, opts MergeOptions) (err error) {
 	for opt_name := range in.ListOptions() {
 		in_opt := in.getOption(opt_name, true)
 		if in_opt == nil {
 			return errors.New("Oops. Something changed while we were merging!")
 		}
+		if err := cfg.checkLocked(opt_name, cfg.options[opt_name]); err != nil {
+			return err
+		}
+		if _, ok := cfg.sections[opt_name]; ok {
+			return newError(ErrExists, fmt.Sprintf("Section %s already exists", cfg.childPath(opt_name)))
+		}
+		existing := cfg.options[opt_name]
+		values, err := cfg.checkedValues(opt_name, existing, append([]string{}, in_opt.value...))
+		if err != nil {
+			return err
+		}
 		opt := new(option)
 		opt.comment = in_opt.comment
-		opt.value = make([]string, len(in_opt.value))
-		copy(opt.value, in_opt.value)
-		if _, ok := cfg.options[opt_name]; !ok {
-			cfg.order = append(cfg.order, opt_name)
+		opt.value = values
+		if existing == nil {
+			cfg.appendChild(opt_name)
+		} else {
+			opt.comment = opts.mergeComment(existing.comment, in_opt.comment)
+			opt.typ = existing.typ
 		}
 		cfg.options[opt_name] = opt
+		cfg.record(OptionSet, cfg.childPath(opt_name), opt.value)
 	}
 	for sec_name := range in.ListSections() {
 		var sec *CFG
@@ -702,13 +1511,15 @@ func (cfg *CFG) insertContents(in *CFG) (err error) {
 			return errors.New("Oops. Something changed while we were merging!")
 		}
 		if sec, ok = cfg.sections[sec_name]; !ok {
-			sec, err = cfg.createSection(sec_name, in_sec.comment)
+			if sec, err = cfg.createSection(sec_name, in_sec.comment); err != nil {
+				return err
+			}
 		} else {
-			sec.comment = in_sec.comment
+			sec.comment = opts.mergeComment(sec.comment, in_sec.comment)
 		}
-		if err := sec.insertContents(in_sec); err != nil {
+		if err := sec.insertContents(in_sec, opts); err != nil {
 			return err
 		}
 	}
 	return nil
-}
\ No newline at end of file
+}
@@ -1,7 +1,9 @@
 package cfg
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -142,6 +144,21 @@ func TestCloneEqual(t *testing.T) {
 	}
 }
 
+func TestEqualIgnoringInheritance(t *testing.T) {
+	resolved, _ := NewCFGFromString("Base {\n\tA = 1\n}\nSite {\n\tB = 2\n}\n")
+	template, _ := NewCFGFromString("Base {\n\tA = 1\n}\nSite {< Base\n\tB = 2\n}\n")
+	if resolved.Equal(template) {
+		t.Error("Trees with different inheritance are equal")
+	}
+	if !resolved.EqualWithOptions(template, EqualOptions{IgnoreInheritance: true}) {
+		t.Error("Trees with the same contents are not equal ignoring inheritance")
+	}
+	template.SetOption("Site/B", "3", "")
+	if resolved.EqualWithOptions(template, EqualOptions{IgnoreInheritance: true}) {
+		t.Error("Trees with different contents are equal")
+	}
+}
+
 func TestExists(t *testing.T) {
 	data := "s1 {\nop1 = val1\nop1 += val1a\n}\ns2 {<s1\ns21{\nop211=val211\n}\ns22{\n}\n}\nop1=a"
 	cfg, err := NewCFGFromString(data)
@@ -202,3 +219,179 @@ func TestInsertContents(t *testing.T) {
 		}
 	}
 }
+
+func TestInsertContentsConflicts(t *testing.T) {
+	for _, test := range []struct{ target, in string }{
+		{"X = 1\n", "X {\n\ta = 1\n}\n"},
+		{"X {\n\ta = 1\n}\n", "X = 1\n"},
+	} {
+		cfg, _ := NewCFGFromString(test.target)
+		in, _ := NewCFGFromString(test.in)
+		if err := cfg.InsertContents(in); !errors.Is(err, ErrExists) {
+			t.Errorf("Merged %q into %q: %v", test.in, test.target, err)
+		}
+	}
+}
+
+func TestInsertContentsComments(t *testing.T) {
+	data1 := "#Owned by ops\ns {\n#Pager duty\nop = a\n#Same\nsame = a\nbare = a\n}"
+	data2 := "#Imported\ns {\n#Overridden in staging\nop = b\n#Same\nsame = b\n#New\nbare = b\n}"
+	for _, test := range []struct {
+		policy  CommentPolicy
+		sec     string
+		options map[string]string
+	}{
+		{CommentsReplace, "Imported", map[string]string{"op": "Overridden in staging", "same": "Same", "bare": "New"}},
+		{CommentsKeep, "Owned by ops", map[string]string{"op": "Pager duty", "same": "Same", "bare": ""}},
+		{CommentsConcat, "Owned by ops\nImported", map[string]string{"op": "Pager duty\nOverridden in staging", "same": "Same", "bare": "New"}},
+	} {
+		cfg, err := NewCFGFromString(data1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		in, err := NewCFGFromString(data2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.InsertContentsWithOptions(in, MergeOptions{Comments: test.policy}); err != nil {
+			t.Fatal(err)
+		}
+		sec, _ := cfg.GetSection("s")
+		if sec.comment != test.sec {
+			t.Errorf("Policy %d: unexpected section comment %q", test.policy, sec.comment)
+		}
+		for name, comment := range test.options {
+			if _, opt := sec.getString(name, false, 0); opt.comment != comment {
+				t.Errorf("Policy %d: unexpected comment %q for %s", test.policy, opt.comment, name)
+			}
+			if value, _ := sec.GetOption(name); value != "b" {
+				t.Errorf("Policy %d: unexpected value %s for %s", test.policy, value, name)
+			}
+		}
+	}
+}
+
+func TestNestedPaths(t *testing.T) {
+	cfg := NewCFG()
+	if _, err := cfg.CreateSection("a", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfg.CreateSection("a/b", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfg.CreateSection("a/b", ""); err == nil {
+		t.Error("Allowed to create a section twice")
+	}
+	for _, val := range []string{"1", "2"} {
+		if err := cfg.SetOption("a/b/op", val, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cfg.SetOption("a/b", "1", ""); err == nil {
+		t.Error("Allowed to set an option with the name of a section")
+	}
+	expected := "a {\n\tb {\n\t\top = 2\n\t}\n}\n"
+	if cfg.String() != expected {
+		t.Error("Unexpected dump:", cfg.String())
+	}
+}
+
+func TestMaxLineLength(t *testing.T) {
+	cfg := NewCFG()
+	cfg.SetMaxLineLength(16)
+	if err := cfg.LoadFromReader(strings.NewReader("a = 1\nb = 0123456789abcdef\n")); err == nil || err.Error() != "Line is longer than 16 bytes (line 2)" {
+		t.Error("Didn't receive expected error:", err)
+	}
+	cfg = NewCFG()
+	cfg.SetMaxLineLength(16)
+	if err := cfg.LoadFromReader(strings.NewReader("long\nsection\nname\nwithout\nbraces\n{\n}\n")); err == nil || err.Error() != "Name is longer than 16 bytes (line 4)" {
+		t.Error("Didn't receive expected error:", err)
+	}
+	cfg = NewCFG()
+	cfg.SetMaxLineLength(16)
+	if err := cfg.LoadFromReader(strings.NewReader("b = 0123456789a\nc = 1")); err != nil {
+		t.Error(err)
+	}
+	cfg = NewCFG()
+	cfg.SetMaxLineLength(-1)
+	if err := cfg.LoadFromReader(strings.NewReader("b = " + strings.Repeat("x", DefaultMaxLineLength) + "\n")); err != nil {
+		t.Error(err)
+	}
+	if _, err := NewCFGFromString("b = " + strings.Repeat("x", DefaultMaxLineLength)); err == nil {
+		t.Error("Loaded a line longer than the default limit")
+	}
+}
+
+func TestMergeSections(t *testing.T) {
+	data := "Base {\n}\nDB {\n\tHost = db1\n\tPort = 3306\n}\n#Second fragment\nDB {< Base\n\tPort = 3307\n\tUser = admin\n}\n"
+	if _, err := NewCFGFromString(data); err == nil {
+		t.Error("Loaded a repeated section without merging")
+	}
+	cfg := NewCFG()
+	cfg.SetMergeSections(true)
+	if err := cfg.LoadFromReader(strings.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Base {\n}\n#Second fragment\nDB {< Base\n\tHost = db1\n\tPort = 3307\n\tUser = admin\n}\n"
+	if cfg.String() != expected {
+		t.Error("Unexpected merge:", cfg.String())
+	}
+	if err := cfg.LoadFromReader(strings.NewReader("DB = 1\n")); err == nil {
+		t.Error("Merged an option into a section")
+	}
+	if err := cfg.LoadFromReader(strings.NewReader("DB {\n\tPort = 3308\n}\n")); err != nil {
+		t.Fatal(err)
+	}
+	if sec, _ := cfg.GetSection("DB"); sec.inheritance == nil {
+		t.Error("Merging a section lost it's inheritance")
+	}
+}
+
+const lookupData = "Base {\n\tPort = 3306\n}\nSystems {\n\tDatabase {< /Base\n\t\tHost = db1\n\t}\n}\n"
+
+func TestLookupPaths(t *testing.T) {
+	cfg, _ := NewCFGFromString(lookupData)
+	for _, path := range []string{"Systems/Database/Host", "/Systems/Database/Host", "Systems//Database/Host/"} {
+		if v, ok := cfg.GetOption(path); !ok || v != "db1" {
+			t.Errorf("Unexpected value for %s: %s", path, v)
+		}
+	}
+	for _, path := range []string{"", "/", "Systems/Host", "Systems/Database/Host/Name"} {
+		if cfg.ExistsOption(path) {
+			t.Error("Found option", path)
+		}
+	}
+}
+
+func TestLookupAllocs(t *testing.T) {
+	cfg, _ := NewCFGFromString(lookupData)
+	for _, path := range []string{"Systems/Database/Host", "Systems/Database/Port", "Systems/Database/User", "Systems/Missing/Host"} {
+		if allocs := testing.AllocsPerRun(100, func() { cfg.GetOption(path) }); allocs != 0 {
+			t.Errorf("Reading %s allocates %v times", path, allocs)
+		}
+	}
+}
+
+func BenchmarkGetOptionHit(b *testing.B) {
+	cfg, _ := NewCFGFromString(lookupData)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cfg.GetOption("Systems/Database/Host")
+	}
+}
+
+func BenchmarkGetOptionInherited(b *testing.B) {
+	cfg, _ := NewCFGFromString(lookupData)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cfg.GetOption("Systems/Database/Port")
+	}
+}
+
+func BenchmarkGetOptionMiss(b *testing.B) {
+	cfg, _ := NewCFGFromString(lookupData)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cfg.GetOption("Systems/Database/User")
+	}
+}
@@ -0,0 +1,87 @@
+//Package cfgtest provides helpers to test cfg files with the cfg package
+package cfgtest
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/acasajus/cfg"
+)
+
+//When set, Golden rewrites the .golden files instead of comparing against them.
+//A boolean "-update" flag defined by the test binary is honoured as well
+var Update = flag.Bool("cfgtest.update", false, "rewrite cfg golden files")
+
+const GoldenSuffix = ".golden"
+
+func updating() bool {
+	if *Update {
+		return true
+	}
+	if f := flag.Lookup("update"); f != nil {
+		if g, ok := f.Value.(flag.Getter); ok {
+			if b, ok := g.Get().(bool); ok {
+				return b
+			}
+		}
+	}
+	return false
+}
+
+//Golden loads the cfg file at path, dumps it and compares the dump against path+".golden" if it exists or against the file itself if it does not.
+//The dump is also parsed back to check that it produces an equal tree. When updating, the dump is written to path+".golden"
+func Golden(t testing.TB, path string) {
+	t.Helper()
+	c, err := cfg.NewCFGFromFile(path)
+	if err != nil {
+		t.Fatalf("Cannot load %s: %s", path, err)
+	}
+	dump := c.String()
+	reloaded, err := cfg.NewCFGFromString(dump)
+	if err != nil {
+		t.Fatalf("Cannot load the dump of %s: %s", path, err)
+	}
+	if !reloaded.RealEqual(c) {
+		t.Errorf("Dump of %s does not load back into an equal cfg", path)
+	}
+	golden := path + GoldenSuffix
+	if updating() {
+		if err := ioutil.WriteFile(golden, []byte(dump), 0644); err != nil {
+			t.Fatalf("Cannot write %s: %s", golden, err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(golden)
+	if os.IsNotExist(err) {
+		golden = path
+		expected, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		t.Fatalf("Cannot read %s: %s", golden, err)
+	}
+	if line, want, got, differ := firstDifference(string(expected), dump); differ {
+		t.Errorf("Dump of %s differs from %s at line %d:\n\twant: %q\n\tgot:  %q", path, golden, line, want, got)
+	}
+}
+
+//Find the first line where both texts differ
+func firstDifference(expected, got string) (int, string, string, bool) {
+	el := strings.Split(expected, "\n")
+	gl := strings.Split(got, "\n")
+	for iL := 0; iL < len(el) || iL < len(gl); iL++ {
+		var e, g string
+		if iL < len(el) {
+			e = el[iL]
+		}
+		if iL < len(gl) {
+			g = gl[iL]
+		}
+		if e != g || iL >= len(el) || iL >= len(gl) {
+			return iL + 1, e, g, true
+		}
+	}
+	return 0, "", "", false
+}
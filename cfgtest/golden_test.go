@@ -0,0 +1,21 @@
+package cfgtest
+
+import (
+	"testing"
+)
+
+func TestGolden(t *testing.T) {
+	Golden(t, "../examples/simple.cfg")
+}
+
+func TestFirstDifference(t *testing.T) {
+	if _, _, _, differ := firstDifference("a\nb\n", "a\nb\n"); differ {
+		t.Error("Equal texts differ")
+	}
+	if line, want, got, differ := firstDifference("a\nb\n", "a\nc\n"); !differ || line != 2 || want != "b" || got != "c" {
+		t.Error("Unexpected difference:", line, want, got)
+	}
+	if line, _, _, differ := firstDifference("a\n", "a\nb\n"); !differ || line != 2 {
+		t.Error("Extra lines not detected:", line)
+	}
+}
@@ -0,0 +1,127 @@
+package cfg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+//Kind of problem found by Check
+type FindingKind int
+
+const (
+	//The dump of the source cannot be parsed
+	DumpNotParsable FindingKind = iota
+	//The dump parses into a tree different from the source one
+	DumpNotEqual
+	//Dumping the tree parsed from the dump does not give the same dump
+	DumpNotStable
+	//The order of a section does not match it's options and sections
+	InconsistentOrder
+	//A name is both an option and a section
+	NameConflict
+	//A section does not point to the section containing it
+	BrokenParent
+	//Inheritance or an alias points to a section that is not in the tree
+	BrokenLink
+	//A name that cannot be set via the API
+	InvalidName
+)
+
+func (k FindingKind) String() string {
+	switch k {
+	case DumpNotParsable:
+		return "dump not parsable"
+	case DumpNotEqual:
+		return "dump not equal"
+	case DumpNotStable:
+		return "dump not stable"
+	case InconsistentOrder:
+		return "inconsistent order"
+	case NameConflict:
+		return "name conflict"
+	case BrokenParent:
+		return "broken parent"
+	case BrokenLink:
+		return "broken link"
+	case InvalidName:
+		return "invalid name"
+	}
+	return fmt.Sprintf("FindingKind(%d)", int(k))
+}
+
+//A broken invariant found by Check
+type Finding struct {
+	Kind FindingKind
+	//Section or option involved. Empty for the whole tree
+	Path string
+	Msg  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Path, f.Kind, f.Msg)
+}
+
+//Parse a source, dump it and parse the dump back checking that both trees are Equal (comments are normalized by dumps) and keep the invariants of the model.
+//Meant for fuzzing and CI: sources that cannot be parsed return an error and any finding is a bug in this package
+func Check(r io.Reader) ([]Finding, error) {
+	cfg, err := NewCFGFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+	findings := make([]Finding, 0)
+	cfg.checkInvariants(cfg, "", &findings)
+	dump := cfg.String()
+	reloaded, err := NewCFGFromString(dump)
+	if err != nil {
+		return append(findings, Finding{DumpNotParsable, "", err.Error()}), nil
+	}
+	if !reloaded.Equal(cfg) {
+		findings = append(findings, Finding{DumpNotEqual, "", fmt.Sprintf("%q parses into %q", dump, reloaded.String())})
+	}
+	var buf bytes.Buffer
+	reloaded.DumpToWriter(&buf)
+	if buf.String() != dump {
+		findings = append(findings, Finding{DumpNotStable, "", fmt.Sprintf("%q dumps as %q", dump, buf.String())})
+	}
+	return findings, nil
+}
+
+func (cfg *CFG) checkInvariants(root *CFG, path string, findings *[]Finding) {
+	add := func(kind FindingKind, path string, msg string) {
+		*findings = append(*findings, Finding{kind, path, msg})
+	}
+	if len(cfg.order) != len(cfg.options)+len(cfg.sections) {
+		add(InconsistentOrder, path, fmt.Sprintf("%d names in order but %d options and %d sections", len(cfg.order), len(cfg.options), len(cfg.sections)))
+	}
+	seen := make(map[string]bool, len(cfg.order))
+	for _, name := range cfg.order {
+		if seen[name] {
+			add(InconsistentOrder, joinEventPath(path, name), "Repeated in order")
+		}
+		seen[name] = true
+		sec, isSec := cfg.sections[name]
+		_, isOpt := cfg.options[name]
+		switch {
+		case isSec && isOpt:
+			add(NameConflict, joinEventPath(path, name), "Both an option and a section")
+		case !isSec && !isOpt:
+			add(InconsistentOrder, joinEventPath(path, name), "In order but neither an option nor a section")
+		}
+		if err := cfg.checkName(name, !isSec); err != nil {
+			add(InvalidName, joinEventPath(path, name), err.Error())
+		}
+		if !isSec {
+			continue
+		}
+		if sec.parent != cfg {
+			add(BrokenParent, joinEventPath(path, name), "Parent is not the section containing it")
+		}
+		sec.checkInvariants(root, joinEventPath(path, name), findings)
+	}
+	for _, link := range []*CFG{cfg.inheritance, cfg.alias} {
+		if link != nil && link.root() != root {
+			add(BrokenLink, path, link.path()+" is not in the tree")
+		}
+	}
+}
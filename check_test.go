@@ -0,0 +1,44 @@
+package cfg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	findings, err := Check(strings.NewReader("@define port = 80\n#Servers\nWeb {\n\tPort = &port\n\tHosts = a\n\tHosts += b\n}\nAPI {< Web\n}\n@alias W = Web\n"))
+	if err != nil || len(findings) != 0 {
+		t.Error("Unexpected check:", findings, err)
+	}
+	if _, err := Check(strings.NewReader("a = 1\na = 2\n")); err == nil {
+		t.Error("Checked an invalid source")
+	}
+	cfg, _ := NewCFGFromString("A {\n\tb = 1\n}\nC {\n}\n")
+	sec, _ := cfg.GetSection("A")
+	sec.order = append(sec.order, "b", "c")
+	other, _ := cfg.GetSection("C")
+	other.parent = sec
+	var found []Finding
+	cfg.checkInvariants(cfg, "", &found)
+	kinds := make([]string, len(found))
+	for iF, f := range found {
+		kinds[iF] = f.String()
+	}
+	expected := "A: inconsistent order: 3 names in order but 1 options and 0 sections\n" + "A/b: inconsistent order: Repeated in order\n" +
+		"A/c: inconsistent order: In order but neither an option nor a section\n" + "C: broken parent: Parent is not the section containing it"
+	if strings.Join(kinds, "\n") != expected {
+		t.Error("Unexpected findings:", strings.Join(kinds, "\n"))
+	}
+}
+
+func FuzzCheck(f *testing.F) {
+	for _, seed := range []string{"a = 1\n", "A {\n\tb = 1\n\tb += 2\n}\nB {< A\n}\n", "#c\nA {\n}\n@alias X = A\n", "@define x = 1\na = &x\n"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, source string) {
+		findings, err := Check(strings.NewReader(source))
+		if err == nil && len(findings) > 0 {
+			t.Errorf("%q: %v", source, findings)
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package cfg
+
+import (
+	"sort"
+)
+
+//Keep the children of this section sorted by name instead of in the order they were added. Their position is found with a binary
+//search, so adding, removing and finding the children of sections with tens of thousands of them (one per host, for instance)
+//does not scan them all, and dumps list them sorted. Enabling it sorts the existing children
+func (cfg *CFG) SetSortedChildren(sorted bool) {
+	cfg.lock.Lock()
+	defer cfg.lock.Unlock()
+	cfg.sorted = sorted
+	if sorted && !sort.StringsAreSorted(cfg.order) {
+		sort.Strings(cfg.order)
+	}
+}
+
+//Position of a direct child in the order or -1 if it does not exist
+func (cfg *CFG) childIndex(name string) int {
+	if cfg.sorted {
+		if pos := sort.SearchStrings(cfg.order, name); pos < len(cfg.order) && cfg.order[pos] == name {
+			return pos
+		}
+		return -1
+	}
+	for iP, oName := range cfg.order {
+		if oName == name {
+			return iP
+		}
+	}
+	return -1
+}
+
+//Add a new child to the order, at the end or in it's place if the children are sorted
+func (cfg *CFG) appendChild(name string) {
+	if !cfg.sorted {
+		cfg.order = append(cfg.order, name)
+		return
+	}
+	pos := sort.SearchStrings(cfg.order, name)
+	cfg.order = append(cfg.order, "")
+	copy(cfg.order[pos+1:], cfg.order[pos:])
+	cfg.order[pos] = name
+}
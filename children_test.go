@@ -0,0 +1,69 @@
+package cfg
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSortedChildren(t *testing.T) {
+	cfg, err := NewCFGFromString("Hosts {\n\tweb2 = b\n\tweb1 = a\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := cfg.getSection("Hosts", false)
+	hosts.SetSortedChildren(true)
+	for _, name := range []string{"web3", "db1", "web0"} {
+		if err := hosts.SetOption(name, "x", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := hosts.CreateSection("cache1", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := hosts.Remove("web1"); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Hosts {\n\tcache1 {\n\t}\n\tdb1 = x\n\tweb0 = x\n\tweb2 = b\n\tweb3 = x\n}\n"
+	if cfg.String() != expected {
+		t.Error("Unexpected dump:", cfg.String())
+	}
+	//Contents replaced by a reload are sorted too
+	reloaded, err := NewCFGFromString("Hosts {\n\tweb9 = z\n\tapp1 = y\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.writeLock()
+	cfg.replaceContents(reloaded)
+	cfg.unlock()
+	if len(hosts.order) != 2 || hosts.order[0] != "app1" {
+		t.Error("Reloaded children are not sorted:", hosts.order)
+	}
+}
+
+func wideSection(sorted bool, children int) *CFG {
+	cfg := NewCFG()
+	cfg.SetSortedChildren(sorted)
+	for iC := 0; iC < children; iC++ {
+		cfg.setOptionArray(fmt.Sprintf("host%06d", (iC*7919)%children), []string{"up"}, "")
+	}
+	return cfg
+}
+
+func benchmarkWideSection(b *testing.B, sorted bool) {
+	cfg := wideSection(sorted, 50000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		name := fmt.Sprintf("host%06d", (i*104729)%50000)
+		cfg.Remove(name)
+		cfg.SetOption(name, "up", "")
+	}
+}
+
+func BenchmarkWideSectionInOrder(b *testing.B) {
+	benchmarkWideSection(b, false)
+}
+
+func BenchmarkWideSectionSorted(b *testing.B) {
+	benchmarkWideSection(b, true)
+}
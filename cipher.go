@@ -0,0 +1,94 @@
+package cfg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+//Scheme of encrypted values. They are stored as "enc:<key id>:<base64 ciphertext>"
+const EncryptedScheme = "enc"
+
+//Encrypts and decrypts values with keys identified by an id, so keys can be rotated and kept in a KMS or HSM
+type ValueCipher interface {
+	Encrypt(keyID string, plaintext []byte) ([]byte, error)
+	Decrypt(keyID string, ciphertext []byte) ([]byte, error)
+}
+
+type cipherResolver struct {
+	cipher ValueCipher
+}
+
+func (r *cipherResolver) ResolveSecret(ref string) (string, error) {
+	pos := strings.LastIndexByte(ref, ':')
+	if pos < 0 {
+		return "", errors.New("Missing key id")
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(ref[pos+1:])
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := r.cipher.Decrypt(ref[:pos], ciphertext)
+	return string(plaintext), err
+}
+
+//Decrypt the encrypted values (see SetEncryptedOption) with c when they are read via GetOption and GetOptionArray
+func (cfg *CFG) RegisterCipher(c ValueCipher) {
+	cfg.RegisterSecretResolver(EncryptedScheme, &cipherResolver{c}, 0)
+}
+
+//Encrypt value with the key keyID of c and set it as the value of the option
+func (cfg *CFG) SetEncryptedOption(name string, value string, c ValueCipher, keyID string, comment string) error {
+	if strings.Contains(keyID, ":") {
+		return errors.New("Key ids cannot contain ':'")
+	}
+	ciphertext, err := c.Encrypt(keyID, []byte(value))
+	if err != nil {
+		return err
+	}
+	return cfg.SetOption(name, EncryptedScheme+":"+keyID+":"+base64.StdEncoding.EncodeToString(ciphertext), comment)
+}
+
+//ValueCipher using AES-GCM with a random nonce prepended to every ciphertext. Keys must be 16, 24 or 32 bytes long.
+//age and GPG need libraries outside the standard library, implement ValueCipher on top of them to use their keys
+type AESGCMCipher struct {
+	Keys map[string][]byte
+}
+
+func (c *AESGCMCipher) aead(keyID string) (cipher.AEAD, error) {
+	key, ok := c.Keys[keyID]
+	if !ok {
+		return nil, errors.New("Unknown key " + keyID)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (c *AESGCMCipher) Encrypt(keyID string, plaintext []byte) ([]byte, error) {
+	aead, err := c.aead(keyID)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, []byte(keyID)), nil
+}
+
+func (c *AESGCMCipher) Decrypt(keyID string, ciphertext []byte) ([]byte, error) {
+	aead, err := c.aead(keyID)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("Ciphertext too short")
+	}
+	return aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], []byte(keyID))
+}
@@ -0,0 +1,40 @@
+package cfg
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestValueCipher(t *testing.T) {
+	c := &AESGCMCipher{Keys: map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32), "k2": bytes.Repeat([]byte{2}, 16)}}
+	cfg := NewCFG()
+	if err := cfg.SetEncryptedOption("Password", "s3cr3t", c, "k1", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetEncryptedOption("Token", "t0k3n", c, "k2", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetEncryptedOption("Other", "x", c, "nope", ""); err == nil {
+		t.Error("Encrypted with an unknown key")
+	}
+	if strings.Contains(cfg.String(), "s3cr3t") || !strings.Contains(cfg.String(), "Password = enc:k1:") {
+		t.Error("Unexpected dump:\n" + cfg.String())
+	}
+	//Loaded back from the dump
+	loaded, _ := NewCFGFromString(cfg.String())
+	if v, _ := loaded.GetOption("Password"); !strings.HasPrefix(v, "enc:") {
+		t.Error("Value decrypted without a cipher")
+	}
+	loaded.RegisterCipher(c)
+	for name, expected := range map[string]string{"Password": "s3cr3t", "Token": "t0k3n"} {
+		if v, ok := loaded.GetOption(name); !ok || v != expected {
+			t.Error("Unexpected value of", name, v)
+		}
+	}
+	//Ciphertexts are bound to their key id
+	loaded.SetOption("Moved", strings.Replace(cfg.GetValue("Password", ""), "enc:k1:", "enc:k2:", 1), "")
+	if _, err := loaded.ResolveOption("Moved"); err == nil {
+		t.Error("Decrypted with the wrong key")
+	}
+}
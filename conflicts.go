@@ -0,0 +1,232 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//Side of a merge
+type Side int
+
+const (
+	//The tree the changes are merged into
+	Ours Side = iota
+	//The tree the changes come from
+	Theirs
+)
+
+func (s Side) String() string {
+	switch s {
+	case Ours:
+		return "ours"
+	case Theirs:
+		return "theirs"
+	}
+	return "unknown"
+}
+
+//Option defined with different values in both sides of a merge. Path is relative to the section the changes are merged into
+type Conflict struct {
+	Path   string
+	Ours   []string
+	Theirs []string
+	//Values chosen for the option, once resolved
+	Resolved bool
+	Result   []string
+}
+
+//Conflicts found merging a tree into a section, to be resolved one by one (with Accept or Edit) before applying the merge.
+//The options that only exist in the other tree or have the same values in both do not conflict and are merged as they are
+type ConflictSet struct {
+	target    *CFG
+	other     *CFG
+	hash      string
+	conflicts []*Conflict
+	byPath    map[string]*Conflict
+}
+
+//Compare other with this section and return the conflicts merging it would have. Options are compared without following inheritance.
+//Aliases of the other tree are not merged. Fails if a name is an option in one tree and a section in the other, as those cannot be
+//merged option by option
+func (cfg *CFG) MergeConflicts(other *CFG) (*ConflictSet, error) {
+	other.lock.RLock()
+	theirs := other.copyTree()
+	other.lock.RUnlock()
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	cs := &ConflictSet{target: cfg, other: theirs, hash: cfg.hash(), byPath: make(map[string]*Conflict)}
+	if err := cfg.collectConflicts("", theirs, cs); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+func (cfg *CFG) collectConflicts(prefix string, other *CFG, cs *ConflictSet) error {
+	for _, name := range other.order {
+		path := joinEventPath(prefix, name)
+		sub, isSec := other.sections[name]
+		mineSec, hasSec := cfg.sections[name]
+		mineOpt, hasOpt := cfg.options[name]
+		switch {
+		case isSec && hasOpt || !isSec && hasSec:
+			return errors.New(fmt.Sprintf("%s is both a section and an option", path))
+		case isSec && sub.alias != nil:
+		case isSec && hasSec:
+			if err := mineSec.resolveAlias().collectConflicts(path, sub, cs); err != nil {
+				return err
+			}
+		case !isSec && hasOpt && !equalValues(mineOpt.value, other.options[name].value):
+			c := &Conflict{Path: path, Ours: append([]string{}, mineOpt.value...), Theirs: append([]string{}, other.options[name].value...)}
+			cs.conflicts = append(cs.conflicts, c)
+			cs.byPath[path] = c
+		}
+	}
+	return nil
+}
+
+//Conflicts found, resolved or not
+func (cs *ConflictSet) Conflicts() []Conflict {
+	list := make([]Conflict, len(cs.conflicts))
+	for iC, c := range cs.conflicts {
+		list[iC] = *c
+	}
+	return list
+}
+
+//Paths of the conflicts that have not been resolved yet
+func (cs *ConflictSet) Unresolved() []string {
+	paths := make([]string, 0)
+	for _, c := range cs.conflicts {
+		if !c.Resolved {
+			paths = append(paths, c.Path)
+		}
+	}
+	return paths
+}
+
+func (cs *ConflictSet) conflict(path string) (*Conflict, error) {
+	c, ok := cs.byPath[strings.Join(SplitPath(path), SplitChar)]
+	if !ok {
+		return nil, newError(ErrNotFound, "There is no conflict in "+path)
+	}
+	return c, nil
+}
+
+//Resolve the conflict at path keeping the values of one side
+func (cs *ConflictSet) Accept(path string, side Side) error {
+	c, err := cs.conflict(path)
+	if err != nil {
+		return err
+	}
+	switch side {
+	case Ours:
+		c.Result = c.Ours
+	case Theirs:
+		c.Result = c.Theirs
+	default:
+		return errors.New("Unknown side " + side.String())
+	}
+	c.Resolved = true
+	return nil
+}
+
+//Resolve the conflict at path with values different from both sides
+func (cs *ConflictSet) Edit(path string, values []string) error {
+	c, err := cs.conflict(path)
+	if err != nil {
+		return err
+	}
+	c.Result = append([]string{}, values...)
+	c.Resolved = true
+	return nil
+}
+
+//Resolve all the conflicts that have not been resolved yet keeping the values of one side
+func (cs *ConflictSet) AcceptAll(side Side) error {
+	for _, path := range cs.Unresolved() {
+		if err := cs.Accept(path, side); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Render the conflicts for humans, one per path with the values of each side and the resolution if there is one
+func (cs *ConflictSet) String() string {
+	lines := make([]string, 0, len(cs.conflicts)*4)
+	for _, c := range cs.conflicts {
+		lines = append(lines, c.Path, "  ours:   "+strings.Join(c.Ours, ", "), "  theirs: "+strings.Join(c.Theirs, ", "))
+		if c.Resolved {
+			lines = append(lines, "  result: "+strings.Join(c.Result, ", "))
+		} else {
+			lines = append(lines, "  unresolved")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+//Merge the other tree into the section with the resolutions chosen. Fails without modifying anything if there are unresolved
+//conflicts or the section has changed since the conflicts were computed, so they have to be computed and resolved again
+func (cs *ConflictSet) Apply() error {
+	if unresolved := cs.Unresolved(); len(unresolved) > 0 {
+		sort.Strings(unresolved)
+		return errors.New("Unresolved conflicts in " + strings.Join(unresolved, ", "))
+	}
+	cfg := cs.target
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
+	if cfg.hash() != cs.hash {
+		return errors.New("The configuration changed since the conflicts were computed")
+	}
+	root := cfg.root()
+	backup := root.copyTree()
+	mark := cfg.recordMark()
+	if err := cfg.applyMerge("", cs.other, cs); err != nil {
+		root.replaceContents(backup)
+		cfg.dropRecorded(mark)
+		return err
+	}
+	return nil
+}
+
+func (cfg *CFG) applyMerge(prefix string, other *CFG, cs *ConflictSet) error {
+	for _, name := range other.order {
+		path := joinEventPath(prefix, name)
+		if sub, ok := other.sections[name]; ok {
+			if sub.alias != nil {
+				continue
+			}
+			sec, exists := cfg.sections[name]
+			if !exists {
+				var err error
+				if sec, err = cfg.createSection(name, sub.comment); err != nil {
+					return err
+				}
+			}
+			if err := sec.resolveAlias().applyMerge(path, sub, cs); err != nil {
+				return err
+			}
+			continue
+		}
+		opt := other.options[name]
+		if c, ok := cs.byPath[path]; ok {
+			if equalValues(c.Result, c.Ours) {
+				continue
+			}
+			if err := cfg.setOptionArray(name, append([]string{}, c.Result...), cfg.options[name].comment); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, exists := cfg.options[name]; !exists {
+			if err := cfg.setOptionArray(name, append([]string{}, opt.value...), opt.comment); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package cfg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConflictSet(t *testing.T) {
+	ours, _ := NewCFGFromString("DB {\n\tHost = db1\n\tPort = 3306\n\tUser = app\n}\nName = api\n")
+	theirs, _ := NewCFGFromString("DB {\n\tHost = db2\n\tPort = 3307\n\tUser = app\n\tPool = 10\n}\nName = web\nCache {\n\tTTL = 60\n}\n")
+	cs, err := ours.MergeConflicts(theirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conflicts := cs.Conflicts(); len(conflicts) != 3 || conflicts[0].Path != "DB/Host" || conflicts[0].Theirs[0] != "db2" {
+		t.Fatal("Unexpected conflicts:", conflicts)
+	}
+	if err := cs.Apply(); err == nil {
+		t.Error("Applied a merge with unresolved conflicts")
+	}
+	cs.Accept("DB/Host", Theirs)
+	cs.Accept("DB/Port", Ours)
+	if unresolved := cs.Unresolved(); len(unresolved) != 1 || unresolved[0] != "Name" {
+		t.Error("Unexpected unresolved conflicts:", unresolved)
+	}
+	if err := cs.Edit("Name", []string{"api-web"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.Accept("Missing", Ours); !errors.Is(err, ErrNotFound) {
+		t.Error("Resolved a conflict that does not exist:", err)
+	}
+	expected := "DB/Host\n  ours:   db1\n  theirs: db2\n  result: db2\nDB/Port\n  ours:   3306\n  theirs: 3307\n  result: 3306\nName\n  ours:   api\n  theirs: web\n  result: api-web"
+	if cs.String() != expected {
+		t.Error("Unexpected rendering:", cs.String())
+	}
+	if err := cs.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	expectedTree := "DB {\n\tHost = db2\n\tPort = 3306\n\tUser = app\n\tPool = 10\n}\nName = api-web\nCache {\n\tTTL = 60\n}\n"
+	if ours.String() != expectedTree {
+		t.Error("Unexpected merge:", ours.String())
+	}
+}
+
+func TestConflictSetStale(t *testing.T) {
+	ours, _ := NewCFGFromString("A = 1\n")
+	theirs, _ := NewCFGFromString("A = 2\nB = 3\n")
+	cs, err := ours.MergeConflicts(theirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs.AcceptAll(Theirs)
+	ours.SetOption("A", "4", "")
+	if err := cs.Apply(); err == nil {
+		t.Error("Applied conflicts computed for an older tree")
+	}
+	if ours.String() != "A = 4\n" {
+		t.Error("Stale merge modified the tree:", ours.String())
+	}
+	other, _ := NewCFGFromString("A {\n}\n")
+	if _, err := ours.MergeConflicts(other); err == nil {
+		t.Error("Merged a section into an option")
+	}
+}
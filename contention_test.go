@@ -0,0 +1,151 @@
+package cfg
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//Tree with sections sections of options options each, inheriting from a common Defaults section
+func contentionTree(sections int, options int, value string) string {
+	var b strings.Builder
+	b.WriteString("Defaults {\n\tTimeout = 5s\n\tRetries = 3\n}\n")
+	for iS := 0; iS < sections; iS++ {
+		fmt.Fprintf(&b, "Service%d {< /Defaults\n", iS)
+		for iO := 0; iO < options; iO++ {
+			fmt.Fprintf(&b, "\tOption%d = %s%d\n", iO, value, iO)
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+var contentionPaths = func() []string {
+	paths := make([]string, 0, 64)
+	for i := 0; i < 64; i++ {
+		if i%4 == 0 {
+			paths = append(paths, fmt.Sprintf("Service%d/Timeout", i%50))
+		} else {
+			paths = append(paths, fmt.Sprintf("Service%d/Option%d", i%50, i%20))
+		}
+	}
+	return paths
+}()
+
+//Run reads from every goroutine doing a write instead every writeEvery operations (0 means never) while background, if not nil,
+//runs concurrently until the benchmark ends. Run them with -cpu 1,4,16 to see how they scale
+func benchmarkReadMostly(b *testing.B, cfg *CFG, writeEvery int, background func(stop <-chan struct{})) {
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	if background != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			background(stop)
+		}()
+	}
+	var goroutines int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		counter := fmt.Sprintf("Service%d/Counter", atomic.AddInt64(&goroutines, 1)%50)
+		for i := 0; pb.Next(); i++ {
+			if writeEvery > 0 && i%writeEvery == 0 {
+				cfg.SetOption(counter, "1", "")
+			} else {
+				cfg.GetOption(contentionPaths[i%len(contentionPaths)])
+			}
+		}
+	})
+	b.StopTimer()
+	close(stop)
+	wg.Wait()
+}
+
+//Call fn every interval until stop is closed
+func every(interval time.Duration, stop <-chan struct{}, fn func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			fn()
+		}
+	}
+}
+
+func BenchmarkReadOnly(b *testing.B) {
+	cfg, _ := NewCFGFromString(contentionTree(50, 20, "v"))
+	benchmarkReadMostly(b, cfg, 0, nil)
+}
+
+//95% reads and 5% single option writes
+func BenchmarkReadMostlyWrites(b *testing.B) {
+	cfg, _ := NewCFGFromString(contentionTree(50, 20, "v"))
+	benchmarkReadMostly(b, cfg, 20, nil)
+}
+
+//Reads while another goroutine keeps writing to a tree with a slow mutation log
+func BenchmarkReadMostlyWritesLogged(b *testing.B) {
+	cfg, _ := NewCFGFromString(contentionTree(50, 20, "v"))
+	cfg.SetMutationLog(&slowWriter{delay: 50 * time.Microsecond})
+	benchmarkReadMostly(b, cfg, 0, func(stop <-chan struct{}) {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				cfg.SetOption("Service0/Counter", fmt.Sprint(i), "")
+			}
+		}
+	})
+}
+
+//Reads while the whole tree is reloaded every millisecond, as a Refresher or a Follow do
+func BenchmarkReadMostlyReloads(b *testing.B) {
+	cfg, _ := NewCFGFromString(contentionTree(50, 20, "v"))
+	sources := []string{contentionTree(50, 20, "v"), contentionTree(50, 20, "w")}
+	benchmarkReadMostly(b, cfg, 0, func(stop <-chan struct{}) {
+		reload := 0
+		every(time.Millisecond, stop, func() {
+			reload++
+			candidate, _ := NewCFGFromString(sources[reload%2])
+			cfg.writeLock()
+			cfg.replaceContents(candidate)
+			cfg.unlock()
+		})
+	})
+}
+
+//Reads while a fragment is merged into the tree every millisecond
+func BenchmarkReadMostlyMerges(b *testing.B) {
+	cfg, _ := NewCFGFromString(contentionTree(50, 20, "v"))
+	fragment, _ := NewCFGFromString(contentionTree(5, 20, "w"))
+	benchmarkReadMostly(b, cfg, 0, func(stop <-chan struct{}) {
+		every(time.Millisecond, stop, func() { cfg.InsertContents(fragment) })
+	})
+}
+
+//Reads while the tree is saved to a file every millisecond
+func BenchmarkReadMostlySaves(b *testing.B) {
+	cfg, _ := NewCFGFromString(contentionTree(50, 20, "v"))
+	filename := filepath.Join(b.TempDir(), "saved.cfg")
+	benchmarkReadMostly(b, cfg, 0, func(stop <-chan struct{}) {
+		every(time.Millisecond, stop, func() { cfg.SaveToFile(filename) })
+	})
+}
+
+//Writer taking some time for every write, like a file synced to disk
+type slowWriter struct {
+	delay time.Duration
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	return len(p), nil
+}
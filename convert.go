@@ -0,0 +1,140 @@
+package cfg
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+//Converters parsing the value of an option into a value of the type they are registered for
+var converters = struct {
+	lock   sync.RWMutex
+	byType map[reflect.Type]func(value string) (interface{}, error)
+}{byType: make(map[reflect.Type]func(string) (interface{}, error))}
+
+//Register the parser used to read values of type typ, like a decimal or a semantic version, by Get and by Unmarshal for the
+//fields (or elements of slice fields) of that type. fn must return a value of type typ. Registering a type again replaces it's converter
+func RegisterConverter(typ reflect.Type, fn func(value string) (interface{}, error)) {
+	converters.lock.Lock()
+	defer converters.lock.Unlock()
+	converters.byType[typ] = fn
+}
+
+func lookupConverter(typ reflect.Type) func(string) (interface{}, error) {
+	converters.lock.RLock()
+	defer converters.lock.RUnlock()
+	return converters.byType[typ]
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+//Parser of raw values into values of type typ: the converter registered for it or, if there is none and *typ implements
+//encoding.TextUnmarshaler, UnmarshalText. Returns nil if there is none of them
+func parserFor(typ reflect.Type) func(raw string) (reflect.Value, error) {
+	if fn := lookupConverter(typ); fn != nil {
+		return func(raw string) (reflect.Value, error) {
+			v, err := fn(raw)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			rv := reflect.ValueOf(v)
+			if !rv.IsValid() || !rv.Type().AssignableTo(typ) {
+				return reflect.Value{}, errors.New(fmt.Sprintf("The converter for %s returned a %T", typ, v))
+			}
+			return rv, nil
+		}
+	}
+	if reflect.PointerTo(typ).Implements(textUnmarshalerType) {
+		return func(raw string) (reflect.Value, error) {
+			rv := reflect.New(typ)
+			if err := rv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw)); err != nil {
+				return reflect.Value{}, err
+			}
+			return rv.Elem(), nil
+		}
+	}
+	return nil
+}
+
+//Getters of the tree used by Get for the types they handle
+var builtinGetters = map[reflect.Type]func(cfg *CFG, path string) (interface{}, error){
+	reflect.TypeOf(""): func(cfg *CFG, path string) (interface{}, error) {
+		return cfg.getSingleValue(path)
+	},
+	reflect.TypeOf([]string{}): func(cfg *CFG, path string) (interface{}, error) {
+		return cfg.ResolveOption(path)
+	},
+	reflect.TypeOf(0): func(cfg *CFG, path string) (interface{}, error) {
+		return cfg.GetInt(path)
+	},
+	reflect.TypeOf(0.0): func(cfg *CFG, path string) (interface{}, error) {
+		return cfg.GetFloat64(path)
+	},
+	reflect.TypeOf(false): func(cfg *CFG, path string) (interface{}, error) {
+		return cfg.GetBool(path)
+	},
+	durationType: func(cfg *CFG, path string) (interface{}, error) {
+		return cfg.GetDuration(path)
+	},
+	reflect.TypeOf(time.Time{}): func(cfg *CFG, path string) (interface{}, error) {
+		return cfg.GetTime(path)
+	},
+}
+
+//Get the value of the option at path as a T. string, []string (all the values), int, float64, bool, time.Duration and time.Time are
+//read with the getters of the tree. Any other type needs a converter registered with RegisterConverter, which takes precedence,
+//or to implement encoding.TextUnmarshaler (as netip.Addr does), which gets the value of the option.
+//Slices of such types get one element per value of the option
+func Get[T any](cfg *CFG, path string) (T, error) {
+	var zero T
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	var v interface{}
+	var err error
+	if getter := builtinGetters[typ]; getter != nil && lookupConverter(typ) == nil {
+		if v, err = getter(cfg, path); err != nil {
+			return zero, err
+		}
+	} else if parse := parserFor(typ); parse != nil {
+		var value string
+		if value, err = cfg.getSingleValue(path); err != nil {
+			return zero, err
+		}
+		rv, err := parse(value)
+		if err != nil {
+			return zero, errors.New(fmt.Sprintf("Option %s: %s", path, err.Error()))
+		}
+		v = rv.Interface()
+	} else if parse := elemParser(typ); parse != nil {
+		var values []string
+		if values, err = cfg.ResolveOption(path); err != nil {
+			return zero, err
+		}
+		slice := reflect.MakeSlice(typ, len(values), len(values))
+		for iV, value := range values {
+			rv, err := parse(value)
+			if err != nil {
+				return zero, &ArrayValueError{path, iV, err}
+			}
+			slice.Index(iV).Set(rv)
+		}
+		v = slice.Interface()
+	} else {
+		return zero, errors.New(fmt.Sprintf("There is no converter for %s to read option %s", typ, path))
+	}
+	converted, ok := v.(T)
+	if !ok {
+		return zero, errors.New(fmt.Sprintf("The converter for %s returned a %T for option %s", typ, v, path))
+	}
+	return converted, nil
+}
+
+//Parser of the elements of typ if it is a slice
+func elemParser(typ reflect.Type) func(string) (reflect.Value, error) {
+	if typ.Kind() != reflect.Slice {
+		return nil
+	}
+	return parserFor(typ.Elem())
+}
@@ -0,0 +1,137 @@
+package cfg
+
+import (
+	"errors"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type semver struct {
+	major, minor, patch int
+}
+
+func parseSemver(value string) (interface{}, error) {
+	parts := strings.Split(strings.TrimPrefix(value, "v"), ".")
+	if len(parts) != 3 {
+		return nil, errors.New("'" + value + "' is not a version")
+	}
+	var v [3]int
+	for iP, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, errors.New("'" + value + "' is not a version")
+		}
+		v[iP] = n
+	}
+	return semver{v[0], v[1], v[2]}, nil
+}
+
+func TestGetGeneric(t *testing.T) {
+	cfg, _ := NewCFGFromString("Name = api\nPort = 8080\nTimeout = 2s\nHosts = a\nHosts += b\nVersion = v1.2.3\nBad = 1.2\n")
+	if v, err := Get[string](cfg, "Name"); err != nil || v != "api" {
+		t.Error("Unexpected string:", v, err)
+	}
+	if v, err := Get[int](cfg, "Port"); err != nil || v != 8080 {
+		t.Error("Unexpected int:", v, err)
+	}
+	if v, err := Get[time.Duration](cfg, "Timeout"); err != nil || v != 2*time.Second {
+		t.Error("Unexpected duration:", v, err)
+	}
+	if v, err := Get[[]string](cfg, "Hosts"); err != nil || !reflect.DeepEqual(v, []string{"a", "b"}) {
+		t.Error("Unexpected values:", v, err)
+	}
+	if _, err := Get[semver](cfg, "Version"); err == nil {
+		t.Error("Read a type without converter")
+	}
+	RegisterConverter(reflect.TypeOf(semver{}), parseSemver)
+	if v, err := Get[semver](cfg, "Version"); err != nil || v != (semver{1, 2, 3}) {
+		t.Error("Unexpected version:", v, err)
+	}
+	if _, err := Get[semver](cfg, "Bad"); err == nil || !strings.Contains(err.Error(), "Bad") {
+		t.Error("Invalid version read:", err)
+	}
+	if _, err := Get[int](cfg, "Missing"); !errors.Is(err, ErrNotFound) {
+		t.Error("Missing option read:", err)
+	}
+}
+
+type semverTarget struct {
+	Version  semver
+	Previous []semver
+}
+
+func TestConverterDecoding(t *testing.T) {
+	RegisterConverter(reflect.TypeOf(semver{}), parseSemver)
+	cfg, _ := NewCFGFromString("Version = v1.2.3\nPrevious = 1.1.0\nPrevious += 1.0.9\nBad = 1.0.0\nBad += 1.0\n")
+	if v, err := Get[[]semver](cfg, "Previous"); err != nil || !reflect.DeepEqual(v, []semver{{1, 1, 0}, {1, 0, 9}}) {
+		t.Error("Unexpected versions:", v, err)
+	}
+	var arrErr *ArrayValueError
+	if _, err := Get[[]semver](cfg, "Bad"); !errors.As(err, &arrErr) || arrErr.Index != 1 {
+		t.Error("Invalid version read:", err)
+	}
+	var target semverTarget
+	if err := cfg.Unmarshal("/", &target); err != nil {
+		t.Fatal(err)
+	}
+	if target.Version != (semver{1, 2, 3}) || !reflect.DeepEqual(target.Previous, []semver{{1, 1, 0}, {1, 0, 9}}) {
+		t.Error("Unexpected unmarshalled versions:", target)
+	}
+	bad, _ := NewCFGFromString("Version = 1.x.0")
+	if err := bad.Unmarshal("/", &target); err == nil || !strings.Contains(err.Error(), "not a version") {
+		t.Error("Unmarshalled an invalid version:", err)
+	}
+	RegisterConverter(reflect.TypeOf(semver{}), func(string) (interface{}, error) { return "1.2.3", nil })
+	defer RegisterConverter(reflect.TypeOf(semver{}), parseSemver)
+	if err := cfg.Unmarshal("/", &target); err == nil {
+		t.Error("Unmarshalled the wrong type returned by a converter")
+	}
+}
+
+type logLevel int
+
+func (l *logLevel) UnmarshalText(text []byte) error {
+	switch strings.ToLower(string(text)) {
+	case "debug":
+		*l = 0
+	case "info":
+		*l = 1
+	default:
+		return errors.New("unknown level " + string(text))
+	}
+	return nil
+}
+
+type textTarget struct {
+	Addr    netip.Addr
+	Levels  []logLevel
+	Started time.Time
+}
+
+func TestTextUnmarshalerDecoding(t *testing.T) {
+	cfg, _ := NewCFGFromString("Addr = 10.0.0.1\nLevels = debug\nLevels += INFO\nStarted = 2024-01-02T03:04:05Z\nBad = trace\n")
+	if v, err := Get[netip.Addr](cfg, "Addr"); err != nil || v != netip.MustParseAddr("10.0.0.1") {
+		t.Error("Unexpected address:", v, err)
+	}
+	if v, err := Get[[]logLevel](cfg, "Levels"); err != nil || !reflect.DeepEqual(v, []logLevel{0, 1}) {
+		t.Error("Unexpected levels:", v, err)
+	}
+	if _, err := Get[logLevel](cfg, "Bad"); err == nil || !strings.Contains(err.Error(), "unknown level") {
+		t.Error("Invalid level read:", err)
+	}
+	var target textTarget
+	if err := cfg.Unmarshal("/", &target); err != nil {
+		t.Fatal(err)
+	}
+	if target.Addr.String() != "10.0.0.1" || len(target.Levels) != 2 || target.Started.Year() != 2024 {
+		t.Error("Unexpected unmarshalled values:", target)
+	}
+	bad, _ := NewCFGFromString("Addr = 10.0.0")
+	if err := bad.Unmarshal("/", &target); err == nil || !strings.Contains(err.Error(), "Addr") {
+		t.Error("Unmarshalled an invalid address:", err)
+	}
+}
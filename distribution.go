@@ -0,0 +1,270 @@
+package cfg
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+const (
+	ContentTypeMergePatch = "application/merge-patch+json"
+	//Generation of the tree served by a Distributor
+	HeaderGeneration = "X-Cfg-Generation"
+	//Generation a diff has to be applied to
+	HeaderBaseGeneration = "X-Cfg-Base-Generation"
+	//Random identifier of a Distributor instance. Generations are only meaningful within the same epoch
+	HeaderEpoch = "X-Cfg-Epoch"
+	//Hash of the tree a replica must have after applying the response
+	HeaderHash = "X-Cfg-Hash"
+	//Default number of past generations a Distributor keeps to generate diffs from
+	DefaultDistributorHistory = 16
+)
+
+type distSnapshot struct {
+	generation uint64
+	tree       *CFG
+	hash       string
+}
+
+//Serves a cfg to replicas as full snapshots or as merge patches (see MergePatch) from the generation the replica already has.
+//Every batch of changes done to the cfg gets a new generation number, so replicas can detect when they missed an update
+type Distributor struct {
+	cfg     *CFG
+	epoch   string
+	history int
+	lock    sync.Mutex
+	dirty   bool
+	next    uint64
+	snaps   []*distSnapshot
+	unwatch func()
+}
+
+//Create a Distributor for cfg keeping up to history past generations (DefaultDistributorHistory if <= 0) to generate diffs from.
+//Replicas with an older generation get a full snapshot
+func NewDistributor(cfg *CFG, history int) *Distributor {
+	if history <= 0 {
+		history = DefaultDistributorHistory
+	}
+	epoch := make([]byte, 8)
+	rand.Read(epoch)
+	d := &Distributor{cfg: cfg.Root(), epoch: hex.EncodeToString(epoch), history: history, dirty: true}
+	d.unwatch = d.cfg.Watch("", func(ChangeEvent) {
+		d.lock.Lock()
+		d.dirty = true
+		d.lock.Unlock()
+	})
+	return d
+}
+
+//Stop tracking changes of the cfg
+func (d *Distributor) Close() {
+	d.unwatch()
+}
+
+//Current generation of the cfg
+func (d *Distributor) Generation() uint64 {
+	return d.current().generation
+}
+
+//Latest snapshot, taking a new one if the cfg changed since the last one
+func (d *Distributor) current() *distSnapshot {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if !d.dirty {
+		return d.snaps[len(d.snaps)-1]
+	}
+	d.dirty = false
+	d.cfg.lock.RLock()
+	tree := d.cfg.copyTree()
+	hash := d.cfg.hash()
+	d.cfg.lock.RUnlock()
+	tree.setLock(new(sync.RWMutex))
+	if len(d.snaps) > 0 && d.snaps[len(d.snaps)-1].hash == hash {
+		return d.snaps[len(d.snaps)-1]
+	}
+	d.next++
+	snap := &distSnapshot{d.next, tree, hash}
+	d.snaps = append(d.snaps, snap)
+	if len(d.snaps) > d.history {
+		d.snaps = append(d.snaps[:0:0], d.snaps[len(d.snaps)-d.history:]...)
+	}
+	return snap
+}
+
+func (d *Distributor) find(generation uint64) *distSnapshot {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, snap := range d.snaps {
+		if snap.generation == generation {
+			return snap
+		}
+	}
+	return nil
+}
+
+//Diff between two snapshots. Returns nil if applying it to from does not lead exactly to to (comments and inheritance are not part of merge patches)
+func (d *Distributor) diff(from *distSnapshot, to *distSnapshot) []byte {
+	patch, err := from.tree.MergePatch(to.tree)
+	if err != nil {
+		return nil
+	}
+	check := from.tree.copyTree()
+	check.setLock(new(sync.RWMutex))
+	if err := check.ApplyMergePatch(patch); err != nil || check.Hash() != to.hash {
+		return nil
+	}
+	return patch
+}
+
+//Serve GET requests. Replicas pass the epoch and generation they have as query parameters and get
+//a 304 if they are up to date, a merge patch if their generation is still known or a full snapshot (text/cfg) otherwise
+func (d *Distributor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cur := d.current()
+	w.Header().Set(HeaderEpoch, d.epoch)
+	w.Header().Set(HeaderGeneration, strconv.FormatUint(cur.generation, 10))
+	w.Header().Set(HeaderHash, cur.hash)
+	var base *distSnapshot
+	if r.URL.Query().Get("epoch") == d.epoch {
+		if generation, err := strconv.ParseUint(r.URL.Query().Get("generation"), 10, 64); err == nil {
+			base = d.find(generation)
+		}
+	}
+	if base == cur {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	var body []byte
+	if base != nil {
+		body = d.diff(base, cur)
+	}
+	if body != nil {
+		w.Header().Set("Content-Type", ContentTypeMergePatch)
+		w.Header().Set(HeaderBaseGeneration, strconv.FormatUint(base.generation, 10))
+	} else {
+		w.Header().Set("Content-Type", ContentTypeCFG+"; charset=utf-8")
+		body = []byte(cur.tree.String())
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method != "HEAD" {
+		w.Write(body)
+	}
+}
+
+//Keeps a cfg in sync with the one served by a Distributor
+type Replica struct {
+	cfg        *CFG
+	url        string
+	lock       sync.Mutex
+	epoch      string
+	generation uint64
+	//Client to use. http.DefaultClient if nil
+	Client *http.Client
+}
+
+//Create a replica of the Distributor served at url. The contents of cfg are replaced with the distributed ones
+func NewReplica(cfg *CFG, url string) *Replica {
+	return &Replica{cfg: cfg.Root(), url: url}
+}
+
+//Generation of the distributed cfg the replica has. Zero until the first successful sync
+func (r *Replica) Generation() uint64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.generation
+}
+
+//Fetch the changes from the Distributor and apply them. If a diff cannot be applied or does not lead to the
+//expected tree (because the local cfg has been modified) a full snapshot is fetched instead
+func (r *Replica) Sync(ctx context.Context) (changed bool, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	changed, err = r.sync(ctx, true)
+	if err == errReplicaDiverged {
+		r.generation = 0
+		changed, err = r.sync(ctx, false)
+	}
+	return
+}
+
+var errReplicaDiverged = errors.New("Replica diverged from the distributed cfg")
+
+func (r *Replica) sync(ctx context.Context, allowDiff bool) (bool, error) {
+	u, err := url.Parse(r.url)
+	if err != nil {
+		return false, err
+	}
+	if allowDiff && r.generation > 0 {
+		q := u.Query()
+		q.Set("epoch", r.epoch)
+		q.Set("generation", strconv.FormatUint(r.generation, 10))
+		u.RawQuery = q.Encode()
+	}
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return false, err
+	}
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, errors.New(fmt.Sprintf("Unexpected status %s fetching %s", resp.Status, r.url))
+	}
+	generation, err := strconv.ParseUint(resp.Header.Get(HeaderGeneration), 10, 64)
+	if err != nil {
+		return false, errors.New("Invalid generation received: " + err.Error())
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	if base := resp.Header.Get(HeaderBaseGeneration); base != "" {
+		if base != strconv.FormatUint(r.generation, 10) || resp.Header.Get(HeaderEpoch) != r.epoch {
+			return false, errReplicaDiverged
+		}
+		if err := r.cfg.ApplyMergePatch(body); err != nil || r.cfg.Hash() != resp.Header.Get(HeaderHash) {
+			return false, errReplicaDiverged
+		}
+	} else {
+		candidate := NewCFG()
+		if err := candidate.LoadFromReader(bytes.NewReader(body)); err != nil {
+			return false, err
+		}
+		if err := r.cfg.writeLock(); err != nil {
+			return false, err
+		}
+		r.cfg.replaceContents(candidate)
+		r.cfg.unlock()
+	}
+	r.epoch = resp.Header.Get(HeaderEpoch)
+	r.generation = generation
+	return true, nil
+}
+
+//Create a Refresher that keeps the replica in sync periodically
+func (r *Replica) Refresher(opts RefresherOptions) *Refresher {
+	refresher := NewRefresher(r.cfg, nil, opts)
+	refresher.sync = r.Sync
+	return refresher
+}
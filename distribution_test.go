@@ -0,0 +1,67 @@
+package cfg
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDistribution(t *testing.T) {
+	primary, err := NewCFGFromString("Base\n{\n\tPort = 80\n}\nWeb\n{ < Base\n\tHost = a\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dist := NewDistributor(primary, 2)
+	defer dist.Close()
+	server := httptest.NewServer(dist)
+	defer server.Close()
+	ctx := context.Background()
+
+	replicated := NewCFG()
+	replica := NewReplica(replicated, server.URL)
+	if changed, err := replica.Sync(ctx); err != nil || !changed {
+		t.Fatal("First sync failed:", changed, err)
+	}
+	if !replicated.RealEqual(primary) || replica.Generation() != 1 {
+		t.Error("Unexpected replica after snapshot:\n" + replicated.String())
+	}
+	if changed, err := replica.Sync(ctx); err != nil || changed {
+		t.Error("Up to date replica changed:", changed, err)
+	}
+
+	primary.SetOption("Web/Host", "b", "")
+	primary.SetOption("Web/Extra", "1", "")
+	if changed, err := replica.Sync(ctx); err != nil || !changed {
+		t.Fatal("Diff sync failed:", changed, err)
+	}
+	if !replicated.RealEqual(primary) || replica.Generation() != 2 {
+		t.Error("Unexpected replica after diff:\n" + replicated.String())
+	}
+
+	//Local modifications are overwritten by a full snapshot
+	replicated.SetOption("Local", "x", "")
+	primary.SetOption("Web/Host", "c", "")
+	if _, err := replica.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !replicated.RealEqual(primary) || replica.Generation() != 3 {
+		t.Error("Diverged replica was not fixed:\n" + replicated.String())
+	}
+
+	//Generations out of the history get a snapshot
+	old := NewReplica(NewCFG(), server.URL)
+	old.Sync(ctx)
+	for _, host := range []string{"d", "e", "f"} {
+		primary.SetOption("Web/Host", host, "")
+		dist.Generation()
+	}
+	if _, err := old.Sync(ctx); err != nil || !old.cfg.RealEqual(primary) || old.Generation() != 6 {
+		t.Error("Old replica was not synced:", err, old.Generation())
+	}
+
+	//Replicas can be refreshed in the background
+	refresher := replica.Refresher(RefresherOptions{})
+	if changed, err := refresher.Refresh(ctx); err != nil || !changed || !replicated.RealEqual(primary) {
+		t.Error("Refresher did not sync the replica:", changed, err)
+	}
+}
@@ -0,0 +1,263 @@
+package cfg
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+//Options to customize dumps
+type DumpOptions struct {
+	//Leave out the options with the same values the section would inherit anyway, keeping only the local differences
+	OmitInheritedDuplicates bool
+	//Parse the dump before writing anything and fail if it cannot be loaded back or it does not give the same tree (without
+	//taking comments into account). Dumps of sections inheriting from outside of them cannot be loaded on their own, so they fail
+	Verify bool
+}
+
+//Dump this cfg customized by opts
+func (cfg *CFG) DumpWithOptions(w io.Writer, opts DumpOptions) error {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	if opts.Verify {
+		return cfg.dumpVerified(w, opts)
+	}
+	return bufferedDump(w, func(b *bufio.Writer) error {
+		return cfg.dump(b, 0, opts)
+	})
+}
+
+//Dump into memory and only write the dump to w once it has been loaded back. Must hold the read lock
+func (cfg *CFG) dumpVerified(w io.Writer, opts DumpOptions) error {
+	var buf bytes.Buffer
+	if err := bufferedDump(&buf, func(b *bufio.Writer) error { return cfg.dump(b, 0, opts) }); err != nil {
+		return err
+	}
+	loaded, err := NewCFGFromString(buf.String())
+	if err != nil {
+		return errors.New("The dump cannot be loaded back: " + err.Error())
+	}
+	expected := cfg.copyTree()
+	if opts.OmitInheritedDuplicates {
+		expected.minimize()
+	}
+	expected.inheritance, expected.alias = nil, nil
+	if !expected.equal(loaded, EqualOptions{}) {
+		return errors.New("The dump does not load back as the same tree")
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+//Dump only the options whose path (relative to this section, like "DB/Password") is accepted by match, with the sections
+//needed to hold them. Aliases are kept if their own path is accepted and anchors only if a kept option references them.
+//Useful to share a config without its secrets:
+//
+//	cfg.DumpFiltered(w, func(path string) bool { return !strings.HasPrefix(path, "Secrets/") })
+//
+//Inheritance links are written as they are, even if the inherited section has been left out
+func (cfg *CFG) DumpFiltered(w io.Writer, match func(path string) bool) error {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	return bufferedDump(w, func(b *bufio.Writer) error {
+		if cfg.parent == nil && cfg.anchors != nil {
+			used := make(map[string]bool)
+			cfg.collectFilteredAnchors("", match, used)
+			for _, name := range cfg.anchors.order {
+				if !used[name] {
+					continue
+				}
+				if err := writeStrings(b, defineDirective, " ", name, " = ", cfg.anchors.values[name], "\n"); err != nil {
+					return err
+				}
+			}
+		}
+		return cfg.dumpFiltered(b, 0, "", match)
+	})
+}
+
+func (cfg *CFG) dumpFiltered(b *bufio.Writer, indent_lvl int, prefix string, match func(string) bool) error {
+	indent := strings.Repeat("\t", indent_lvl)
+	for _, name := range cfg.order {
+		path := joinEventPath(prefix, name)
+		if sec, ok := cfg.sections[name]; ok {
+			if sec.alias != nil {
+				if !match(path) {
+					continue
+				}
+				if err := cfg.dumpCommentToWriter(b, sec.comment, indent); err != nil {
+					return err
+				}
+				if err := writeStrings(b, indent, aliasDirective, " ", name, " = ", sec.alias.path(), "\n"); err != nil {
+					return err
+				}
+				continue
+			}
+			if !sec.hasFiltered(path, match) {
+				continue
+			}
+			if err := cfg.dumpCommentToWriter(b, sec.comment, indent); err != nil {
+				return err
+			}
+			if err := writeStrings(b, indent, name, " {"); err != nil {
+				return err
+			}
+			if sec.inheritance != nil {
+				if err := writeStrings(b, "< ", sec.inheritance.path()); err != nil {
+					return err
+				}
+			}
+			if err := b.WriteByte('\n'); err != nil {
+				return err
+			}
+			if err := sec.dumpFiltered(b, indent_lvl+1, path, match); err != nil {
+				return err
+			}
+			if err := writeStrings(b, indent, "}\n"); err != nil {
+				return err
+			}
+		} else if opt := cfg.options[name]; match(path) {
+			if err := cfg.dumpOption(b, indent, name, opt.typ, opt.value, opt.comment); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//Does the section at path hold anything the filter accepts?
+func (cfg *CFG) hasFiltered(prefix string, match func(string) bool) bool {
+	for _, name := range cfg.order {
+		path := joinEventPath(prefix, name)
+		if sec, ok := cfg.sections[name]; ok && sec.alias == nil {
+			if sec.hasFiltered(path, match) {
+				return true
+			}
+		} else if match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+//Anchors referenced by the options accepted by the filter
+func (cfg *CFG) collectFilteredAnchors(prefix string, match func(string) bool, used map[string]bool) {
+	for _, name := range cfg.order {
+		path := joinEventPath(prefix, name)
+		if sec, ok := cfg.sections[name]; ok {
+			if sec.alias == nil {
+				sec.collectFilteredAnchors(path, match, used)
+			}
+			continue
+		}
+		if !match(path) {
+			continue
+		}
+		for _, value := range cfg.options[name].value {
+			if strings.HasPrefix(value, anchorPrefix) {
+				used[value[len(anchorPrefix):]] = true
+			}
+		}
+	}
+}
+
+//Remove all the options that have the same values the section inherits, so only the local differences are kept.
+//Reading any option gives the same result before and after minimizing
+func (cfg *CFG) Minimize() error {
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
+	cfg.minimize()
+	return nil
+}
+
+func (cfg *CFG) minimize() {
+	for _, name := range append([]string{}, cfg.order...) {
+		if sec, ok := cfg.sections[name]; ok {
+			if sec.alias == nil {
+				sec.minimize()
+			}
+		} else if cfg.inheritsValue(name) {
+			cfg.removeChild(name)
+		}
+	}
+}
+
+//Would the option name have the same values if it was removed from this section?
+func (cfg *CFG) inheritsValue(name string) bool {
+	for _, from := range cfg.effectiveChain()[1:] {
+		if inherited, ok := from.options[name]; ok {
+			return equalValues(inherited.value, cfg.options[name].value)
+		}
+	}
+	return false
+}
+
+//Dump the contents of this section as the code using it sees them: the options and sections inherited are written as if they
+//were defined here with a comment telling where they come from, and aliases are replaced by the contents of their targets
+func (cfg *CFG) DumpEffective(w io.Writer) error {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	return bufferedDump(w, func(b *bufio.Writer) error {
+		return cfg.dumpEffective(b, 0, map[*CFG]bool{})
+	})
+}
+
+//Sections being dumped are kept in visiting so an alias loop cannot recurse forever
+func (cfg *CFG) dumpEffective(b *bufio.Writer, indent_lvl int, visiting map[*CFG]bool) error {
+	if visiting[cfg] {
+		return errors.New(fmt.Sprintf("Section %s contains itself through an alias", cfg.path()))
+	}
+	visiting[cfg] = true
+	defer delete(visiting, cfg)
+	indent := strings.Repeat("\t", indent_lvl)
+	seen := make(map[string]bool)
+	for _, from := range cfg.effectiveChain() {
+		origin := ""
+		if from != cfg {
+			origin = "inherited from " + from.path()
+		}
+		for _, name := range from.order {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			sec, ok := from.sections[name]
+			if !ok {
+				opt := from.options[name]
+				if err := cfg.dumpOption(b, indent, name, opt.typ, opt.value, joinComments(opt.comment, origin)); err != nil {
+					return err
+				}
+				continue
+			}
+			comment := joinComments(sec.comment, origin)
+			if sec.alias != nil {
+				comment = joinComments(comment, "alias of "+sec.alias.path())
+			}
+			if err := cfg.dumpCommentToWriter(b, comment, indent); err != nil {
+				return err
+			}
+			if err := writeStrings(b, indent, name, " {\n"); err != nil {
+				return err
+			}
+			if err := sec.resolveAlias().dumpEffective(b, indent_lvl+1, visiting); err != nil {
+				return err
+			}
+			if err := writeStrings(b, indent, "}\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func joinComments(comment string, extra string) string {
+	if comment == "" || extra == "" {
+		return comment + extra
+	}
+	return comment + "\n" + extra
+}
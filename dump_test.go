@@ -0,0 +1,154 @@
+package cfg
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDumpOmitInheritedDuplicates(t *testing.T) {
+	data := "Base {\n\tHost = db1\n\tPort = 3306\n}\nSite {< Base\n\tHost = db1\n\tPort = 3307\n\tUser = admin\n}\nEdge {< Site\n\tPort = 3307\n\tHost = db2\n}\n"
+	cfg, err := NewCFGFromString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := cfg.DumpWithOptions(&b, DumpOptions{OmitInheritedDuplicates: true}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Base {\n\tHost = db1\n\tPort = 3306\n}\nSite {< Base\n\tPort = 3307\n\tUser = admin\n}\nEdge {< Site\n\tHost = db2\n}\n"
+	if b.String() != expected {
+		t.Error("Unexpected dump:", b.String())
+	}
+	if cfg.String() != data {
+		t.Error("Dump options changed the default dump:", cfg.String())
+	}
+	if err := cfg.Minimize(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.String() != expected {
+		t.Error("Unexpected minimized tree:", cfg.String())
+	}
+	for path, value := range map[string]string{"Site/Host": "db1", "Edge/Port": "3307", "Edge/User": "admin"} {
+		if v, _ := cfg.GetOption(path); v != value {
+			t.Errorf("Minimizing changed %s: %s", path, v)
+		}
+	}
+}
+
+func TestDumpEffective(t *testing.T) {
+	data := "Templates {\n\tBase {\n\t\t#Default host\n\t\tHost = db1\n\t\tPort = 3306\n\t\tPool {\n\t\t\tSize = 4\n\t\t}\n\t}\n}\nSystems {\n\tDatabase {\n\t\tName = main\n\t}\n}\nApp {< Templates/Base\n\tPort = 3307\n\t@alias DB = Systems/Database\n}\n"
+	cfg, err := NewCFGFromString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, _ := cfg.GetSection("App")
+	var b bytes.Buffer
+	if err := app.DumpEffective(&b); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Port = 3307\n#alias of Systems/Database\nDB {\n\tName = main\n}\n#Default host\n#inherited from Templates/Base\nHost = db1\n#inherited from Templates/Base\nPool {\n\tSize = 4\n}\n"
+	if b.String() != expected {
+		t.Error("Unexpected effective dump:", b.String())
+	}
+}
+
+//Tree of a few MB to measure dumps
+func largeTree() *CFG {
+	cfg := NewCFG()
+	for iS := 0; iS < 200; iS++ {
+		sec := fmt.Sprintf("Site%03d", iS)
+		for iH := 0; iH < 20; iH++ {
+			host := fmt.Sprintf("%s/Host%02d", sec, iH)
+			cfg.createSection(sec, "")
+			cfg.createSection(host, "Generated host")
+			for iO := 0; iO < 20; iO++ {
+				cfg.setOptionArray(fmt.Sprintf("%s/Option%02d", host, iO), []string{"value-of-the-option", "another"}, "")
+			}
+		}
+	}
+	return cfg
+}
+
+func BenchmarkDumpLargeTree(b *testing.B) {
+	cfg := largeTree()
+	b.SetBytes(int64(len(cfg.String())))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cfg.DumpToWriter(ioutil.Discard)
+	}
+}
+
+func BenchmarkDumpLargeTreeToFile(b *testing.B) {
+	cfg := largeTree()
+	fi, err := ioutil.TempFile("", "cfgdump")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.Remove(fi.Name())
+	defer fi.Close()
+	b.SetBytes(int64(len(cfg.String())))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fi.Seek(0, 0)
+		cfg.DumpToWriter(fi)
+	}
+}
+
+func TestDumpFiltered(t *testing.T) {
+	data := "@define host = db1\n@define pass = s3cr3t\nSecrets {\n\tToken = abc\n}\nDB {\n\t#Main database\n\tHost = &host\n\tCreds {\n\t\tPassword = &pass\n\t}\n}\n@alias Database = DB\n"
+	cfg, err := NewCFGFromString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	public := func(path string) bool {
+		return path != "Secrets" && !strings.HasPrefix(path, "Secrets/") && !strings.HasSuffix(path, "/Password")
+	}
+	if err := cfg.DumpFiltered(&b, public); err != nil {
+		t.Fatal(err)
+	}
+	expected := "@define host = db1\nDB {\n\t#Main database\n\tHost = &host\n}\n@alias Database = DB\n"
+	if b.String() != expected {
+		t.Error("Unexpected dump:", b.String())
+	}
+	if _, err := NewCFGFromString(b.String()); err != nil {
+		t.Error("Filtered dump cannot be loaded:", err)
+	}
+	b.Reset()
+	if err := cfg.DumpFiltered(&b, func(path string) bool { return false }); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "" {
+		t.Error("Nothing should have been dumped:", b.String())
+	}
+}
+
+func TestDumpVerify(t *testing.T) {
+	cfg, err := NewCFGFromString("Base {\n\tHost = db1\n}\nSite {< Base\n\tHost = db1\n\tPort = 3306\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []DumpOptions{{Verify: true}, {Verify: true, OmitInheritedDuplicates: true}} {
+		var b bytes.Buffer
+		if err := cfg.DumpWithOptions(&b, opts); err != nil {
+			t.Error("Valid dump rejected:", err)
+		}
+		if b.Len() == 0 {
+			t.Error("Nothing dumped")
+		}
+	}
+	cfg.SetOption("Site/Motd", "hello # world", "")
+	var b bytes.Buffer
+	if err := cfg.DumpWithOptions(&b, DumpOptions{Verify: true}); err == nil {
+		t.Error("A dump that does not load back was accepted")
+	}
+	if b.Len() != 0 {
+		t.Error("Unverified dump written:", b.String())
+	}
+}
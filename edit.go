@@ -0,0 +1,71 @@
+package cfg
+
+import (
+	"fmt"
+)
+
+//Set an option only if it is not defined in it's section (inherited values do not count). Returns whether the option was set
+func (cfg *CFG) EnsureOption(name string, value string, comment string) (bool, error) {
+	if err := cfg.writeLock(); err != nil {
+		return false, err
+	}
+	defer cfg.unlock()
+	if _, opt := cfg.getString(name, false, 0); opt != nil {
+		return false, nil
+	}
+	if err := cfg.setOptionArray(name, []string{value}, comment); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+//Replace every value of an option equal to old with new, keeping the rest of values and their order. Returns whether any was replaced
+func (cfg *CFG) ReplaceValueInOption(name string, old string, new string) (bool, error) {
+	if err := cfg.writeLock(); err != nil {
+		return false, err
+	}
+	defer cfg.unlock()
+	_, opt := cfg.getString(name, false, 0)
+	if opt == nil {
+		return false, newError(ErrNotFound, fmt.Sprintf("Option %s does not exist", name))
+	}
+	var values []string
+	for iV, value := range opt.value {
+		if value != old {
+			continue
+		}
+		if values == nil {
+			values = append([]string{}, opt.value...)
+		}
+		values[iV] = new
+	}
+	if values == nil || old == new {
+		return false, nil
+	}
+	if err := cfg.setOptionArray(name, values, opt.comment); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+//Set an option to new only if it's current values (inherited ones do not count) are expected, so concurrent writers can detect that
+//someone else changed it first and retry instead of overwriting it. A nil expected means the option must not exist.
+//The comment of the option is kept. Returns whether the option was set
+func (cfg *CFG) CompareAndSet(name string, expected []string, new []string) (bool, error) {
+	if err := cfg.writeLock(); err != nil {
+		return false, err
+	}
+	defer cfg.unlock()
+	_, opt := cfg.getString(name, false, 0)
+	comment := ""
+	switch {
+	case opt == nil && expected != nil, opt != nil && (expected == nil || !equalValues(opt.value, expected)):
+		return false, nil
+	case opt != nil:
+		comment = opt.comment
+	}
+	if err := cfg.setOptionArray(name, append([]string{}, new...), comment); err != nil {
+		return false, err
+	}
+	return true, nil
+}
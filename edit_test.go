@@ -0,0 +1,76 @@
+package cfg
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestEditHelpers(t *testing.T) {
+	cfg, _ := NewCFGFromString("DB {\n\tHost = db1\n\tReplicas = r1\n\tReplicas += r2\n\tReplicas += r1\n}\nApp {< DB\n}\n")
+	if changed, err := cfg.EnsureOption("DB/Host", "db2", ""); changed || err != nil || cfg.GetValue("DB/Host", "") != "db1" {
+		t.Error("Existing option changed:", changed, err)
+	}
+	if changed, err := cfg.EnsureOption("App/Host", "app1", "Own host"); !changed || err != nil || cfg.GetValue("App/Host", "") != "app1" {
+		t.Error("Inherited option not set:", changed, err)
+	}
+	if changed, err := cfg.EnsureOption("Missing/Host", "x", ""); changed || err == nil {
+		t.Error("Set option without parent:", changed, err)
+	}
+	if changed, err := cfg.ReplaceValueInOption("DB/Replicas", "r1", "r3"); !changed || err != nil {
+		t.Error("Value not replaced:", changed, err)
+	}
+	if v := cfg.GetValueArray("DB/Replicas", nil); !reflect.DeepEqual(v, []string{"r3", "r2", "r3"}) {
+		t.Error("Unexpected values:", v)
+	}
+	if changed, err := cfg.ReplaceValueInOption("DB/Replicas", "r1", "r4"); changed || err != nil {
+		t.Error("Missing value replaced:", changed, err)
+	}
+	if _, err := cfg.ReplaceValueInOption("DB/Missing", "a", "b"); !errors.Is(err, ErrNotFound) {
+		t.Error("Unexpected error:", err)
+	}
+}
+
+func TestCompareAndSet(t *testing.T) {
+	cfg, _ := NewCFGFromString("#Requests served\nCount = 0\n")
+	revision := cfg.Revision()
+	if ok, err := cfg.CompareAndSet("Count", []string{"1"}, []string{"2"}); ok || err != nil {
+		t.Error("Set with unexpected values:", ok, err)
+	}
+	if ok, err := cfg.CompareAndSet("New", nil, []string{"x"}); !ok || err != nil {
+		t.Error("Missing option not created:", ok, err)
+	}
+	if ok, _ := cfg.CompareAndSet("New", nil, []string{"y"}); ok {
+		t.Error("Existing option overwritten")
+	}
+	if cfg.Revision() <= revision {
+		t.Error("Revision not increased")
+	}
+	var wg sync.WaitGroup
+	for iW := 0; iW < 8; iW++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for iI := 0; iI < 50; {
+				current, _ := cfg.GetOptionArray("Count")
+				n, _ := strconv.Atoi(current[0])
+				if ok, err := cfg.CompareAndSet("Count", current, []string{strconv.Itoa(n + 1)}); err != nil {
+					t.Error(err)
+					return
+				} else if ok {
+					iI++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := cfg.GetOption("Count"); v != "400" {
+		t.Error("Updates were lost:", v)
+	}
+	if !strings.HasPrefix(cfg.String(), "#Requests served\n") {
+		t.Error("Comment lost:", cfg.String())
+	}
+}
@@ -0,0 +1,105 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+//Kinds of errors returned by the methods of CFG. Check them with errors.Is as the returned errors carry a more detailed message
+var (
+	//The option, section or inherited section does not exist
+	ErrNotFound = errors.New("Not found")
+	//There is already an option or section with the same name
+	ErrExists = errors.New("Already exists")
+	//The parent section of the path does not exist
+	ErrNoParent = errors.New("Parent section does not exist")
+	//The root section cannot inherit from other sections
+	ErrRootInheritance = errors.New("Root node cannot inherit from anyone")
+	//The inheritance would create a loop
+	ErrCircularInheritance = errors.New("Circular inheritance")
+	//The tree has been frozen and cannot be modified
+	ErrFrozen = errors.New("Configuration is frozen")
+	//The option is locked and can only be overwritten forcing it
+	ErrLocked = errors.New("Option is locked")
+	//The store can only be read
+	ErrReadOnly = errors.New("Read only store")
+)
+
+//Returned internally when an option does not exist to avoid building an error that may be discarded
+var errMissingOption = errors.New("Option does not exist")
+
+//Error of one of the kinds above with a detailed message
+type kindError struct {
+	kind error
+	msg  string
+}
+
+func (e *kindError) Error() string {
+	return e.msg
+}
+
+func (e *kindError) Unwrap() error {
+	return e.kind
+}
+
+func newError(kind error, msg string) error {
+	return &kindError{kind, msg}
+}
+
+//Error found while loading cfg contents
+type ParseError struct {
+	Msg string
+	//Error that caused this one, if any
+	Err error
+	//Line and column (in characters) where the error was found, both starting at 1
+	Line   int
+	Column int
+	//Text of the line. Empty if it was too long to keep it
+	Text string
+	//File being loaded, if known
+	Source string
+}
+
+func (e *ParseError) Error() string {
+	if e.Source != "" {
+		return fmt.Sprintf("%s (%s line %v)", e.Msg, e.Source, e.Line)
+	}
+	return fmt.Sprintf("%s (line %v)", e.Msg, e.Line)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+//Create a ParseError for the byte at pos of a line
+func newParseError(err error, line int, text string, pos int) *ParseError {
+	return &ParseError{Msg: err.Error(), Err: err, Line: line, Column: utf8.RuneCountInString(text[:pos]) + 1, Text: text}
+}
+
+//Render an error for humans. Parse errors include the offending line with a caret marking the column, like
+//
+//	a already exists (line 2)
+//	  2 | a = 1
+//	    | ^
+func FormatError(err error) string {
+	pErr, ok := err.(*ParseError)
+	if !ok || pErr.Text == "" {
+		return err.Error()
+	}
+	number := fmt.Sprint(pErr.Line)
+	//Keep the tabs before the column so the caret is aligned with the text
+	marker := make([]rune, 0, pErr.Column)
+	for iC, char := range []rune(pErr.Text) {
+		if iC >= pErr.Column-1 {
+			break
+		}
+		if char == '\t' {
+			marker = append(marker, '\t')
+		} else {
+			marker = append(marker, ' ')
+		}
+	}
+	return fmt.Sprintf("%s\n  %s | %s\n  %s | %s^", pErr.Error(), number, pErr.Text, strings.Repeat(" ", len(number)), string(marker))
+}
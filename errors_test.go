@@ -0,0 +1,95 @@
+package cfg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseErrorContext(t *testing.T) {
+	_, err := NewCFGFromString("s {\n\tb = 1\n}\n\ts {\n\t\t\ta = 1 # comment\n\t\t\ta = 2\n}\n")
+	pErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatal("Unexpected error:", err)
+	}
+	if pErr.Line != 4 || pErr.Column != 2 || pErr.Text != "\ts {" {
+		t.Errorf("Unexpected position: %+v", pErr)
+	}
+	_, err = NewCFGFromString("s2 {\n\t\t\ta = 1 # comment\n\t\t\ta = 2\n}\n")
+	expected := "a already exists (line 3)\n  3 | \t\t\ta = 2\n    | \t\t\t^"
+	if FormatError(err) != expected {
+		t.Errorf("Unexpected rendering:\n%s", FormatError(err))
+	}
+	cfg := NewCFG()
+	cfg.SetMaxLineLength(4)
+	err = cfg.LoadFromReader(strings.NewReader("ab\ncdef\nghi = 1\n"))
+	expected = "Name is longer than 4 bytes (line 2)\n  2 | cdef\n    |   ^"
+	if FormatError(err) != expected {
+		t.Errorf("Unexpected rendering:\n%s", FormatError(err))
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	cfg, _ := NewCFGFromString("a = 1\ns\n{\n}\n")
+	s, _ := cfg.GetSection("s")
+	_, errExists := cfg.CreateSection("a", "")
+	checks := []struct {
+		err  error
+		kind error
+	}{
+		{cfg.Remove("missing"), ErrNotFound},
+		{cfg.SetOption("missing/op", "1", ""), ErrNoParent},
+		{cfg.SetOption("s", "1", ""), ErrExists},
+		{cfg.SetInheritance("s"), ErrRootInheritance},
+		{s.SetInheritance("missing"), ErrNotFound},
+		{errExists, ErrExists},
+	}
+	for iC, check := range checks {
+		if !errors.Is(check.err, check.kind) {
+			t.Errorf("Check %d: %v is not %v", iC, check.err, check.kind)
+		}
+	}
+	_, err := NewCFGFromString("s1{<s2\n}\ns2{<s1\n}")
+	if !errors.Is(err, ErrCircularInheritance) {
+		t.Error("Unexpected error:", err)
+	}
+	_, err = NewCFGFromString("a = 1\na = 2\n")
+	if !errors.Is(err, ErrExists) {
+		t.Error("Parse error does not wrap the cause:", err)
+	}
+	if _, err := cfg.ResolveOption("missing"); !errors.Is(err, ErrNotFound) {
+		t.Error("Unexpected error:", err)
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	cfg, _ := NewCFGFromString("a = 1\ns\n{\n\tb = 2\n}\n")
+	cfg.Freeze()
+	if !cfg.Frozen() {
+		t.Fatal("Tree is not frozen")
+	}
+	s, _ := cfg.GetSection("s")
+	_, errCreate := cfg.CreateSection("t", "")
+	errs := []error{
+		errCreate,
+		cfg.SetOption("a", "2", ""),
+		s.SetOption("c", "3", ""),
+		cfg.Remove("s"),
+		cfg.LoadFromReader(strings.NewReader("c = 1")),
+		cfg.ApplyMergePatch([]byte(`{"a": "2"}`)),
+		cfg.SetOptionCtx(WithActor(context.Background(), "admin"), "a", "2", ""),
+	}
+	for iE, err := range errs {
+		if err != ErrFrozen {
+			t.Errorf("Modification %d was not rejected: %v", iE, err)
+		}
+	}
+	if cfg.String() != "a = 1\ns {\n\tb = 2\n}\n" {
+		t.Error("Frozen tree was modified:", cfg.String())
+	}
+	clone, _ := cfg.Clone()
+	if err := clone.SetOption("a", "2", ""); err != nil {
+		t.Error("Clone of a frozen tree is not modifiable:", err)
+	}
+}
@@ -0,0 +1,346 @@
+package cfg
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+//Kind of change notified to watchers
+type ChangeKind int
+
+const (
+	//An option has been created or it's values have changed
+	OptionSet ChangeKind = iota
+	OptionRemoved
+	SectionCreated
+	SectionRemoved
+	//The inheritance of a section has been defined or removed. Value holds the path of the inherited section if there is one
+	InheritanceSet
+	//Several changes delivered together to a debounced watcher. Paths holds the paths that changed
+	ChangesCoalesced
+	//A reload has been undone because the health check failed after it. The changes undoing it are notified before
+	RolledBack
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case OptionSet:
+		return "option set"
+	case OptionRemoved:
+		return "option removed"
+	case SectionCreated:
+		return "section created"
+	case SectionRemoved:
+		return "section removed"
+	case InheritanceSet:
+		return "inheritance set"
+	case ChangesCoalesced:
+		return "changes coalesced"
+	case RolledBack:
+		return "rolled back"
+	}
+	return "unknown"
+}
+
+//A change done to the tree. Path is relative to the root and refers to the place where the change was made,
+//so changes to a section are not notified again for the sections inheriting from it
+type ChangeEvent struct {
+	Path  string
+	Kind  ChangeKind
+	Value []string
+	//Paths changed, only for ChangesCoalesced events
+	Paths []string
+}
+
+type watcher struct {
+	path     []string
+	fn       func(ChangeEvent)
+	debounce time.Duration
+	//State of debounced watchers
+	lock    sync.Mutex
+	timer   *time.Timer
+	changed []string
+	seen    map[string]bool
+}
+
+//Option of a watcher
+type WatchOption func(w *watcher)
+
+//Deliver the changes of a burst as a single ChangesCoalesced event, once no change has happened for d.
+//The event has the watched path as Path and all the paths that changed in Paths. It is delivered from a different goroutine
+func WithDebounce(d time.Duration) WatchOption {
+	return func(w *watcher) {
+		w.debounce = d
+	}
+}
+
+//Set of watchers of a tree. It lives in the root
+type watchers struct {
+	lock sync.Mutex
+	list []*watcher
+}
+
+//Is the event of interest for someone watching path? It is if it happens under path or it changes one of path's parents
+func (w *watcher) matches(event []string) bool {
+	for iP := 0; iP < len(w.path) && iP < len(event); iP++ {
+		if w.path[iP] != event[iP] {
+			return false
+		}
+	}
+	return true
+}
+
+//Call fn for every change done under path (or to any of it's parents). Returns a function that stops watching.
+//Watchers are called by the goroutine that made the change once the lock of the tree has been released, so they can safely use the cfg
+func (cfg *CFG) Watch(path string, fn func(ChangeEvent), opts ...WatchOption) (cancel func()) {
+	cfg.lock.Lock()
+	root := cfg.root()
+	if root.watchers == nil {
+		root.watchers = new(watchers)
+	}
+	hub := root.watchers
+	w := &watcher{path: SplitPath(cfg.path() + SplitChar + path), fn: fn}
+	for _, opt := range opts {
+		opt(w)
+	}
+	cfg.lock.Unlock()
+	hub.lock.Lock()
+	hub.list = append(hub.list, w)
+	hub.lock.Unlock()
+	return func() {
+		hub.lock.Lock()
+		defer hub.lock.Unlock()
+		for iW, other := range hub.list {
+			if other == w {
+				hub.list = append(hub.list[:iW:iW], hub.list[iW+1:]...)
+				w.stop()
+				return
+			}
+		}
+	}
+}
+
+func (w *watcher) deliver(event ChangeEvent) {
+	if w.debounce <= 0 {
+		w.fn(event)
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.seen == nil {
+		w.seen = make(map[string]bool)
+	}
+	if !w.seen[event.Path] {
+		w.seen[event.Path] = true
+		w.changed = append(w.changed, event.Path)
+	}
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.timer = time.AfterFunc(w.debounce, w.flush)
+}
+
+//Deliver the changes accumulated by a debounced watcher
+func (w *watcher) flush() {
+	w.lock.Lock()
+	changed := w.changed
+	w.changed = nil
+	w.seen = nil
+	w.timer = nil
+	w.lock.Unlock()
+	if len(changed) > 0 {
+		w.fn(ChangeEvent{Path: strings.Join(w.path, SplitChar), Kind: ChangesCoalesced, Paths: changed})
+	}
+}
+
+//Drop the changes a debounced watcher has not delivered yet
+func (w *watcher) stop() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.changed = nil
+	w.seen = nil
+}
+
+//Record a change done while holding the write lock. It will be notified when the lock is released via unlock()
+func (cfg *CFG) record(kind ChangeKind, path string, value []string) {
+	root := cfg.root()
+	if !root.recording() {
+		return
+	}
+	if path == SplitChar {
+		path = ""
+	}
+	v := make([]string, len(value))
+	copy(v, value)
+	root.pending = append(root.pending, ChangeEvent{Path: path, Kind: kind, Value: v})
+}
+
+//Are changes being recorded? They are if someone watches the tree, it keeps versions of the options, it's history or it logs or
+//audits mutations
+func (cfg *CFG) recording() bool {
+	return cfg.watchers != nil || cfg.versioning != nil || cfg.mutationLog != nil || cfg.auditSink != nil || cfg.history != nil
+}
+
+//Path of a child of this section. The path of the root is empty
+func (cfg *CFG) childPath(name string) string {
+	if cfg.parent == nil {
+		return name
+	}
+	if name == "" {
+		return cfg.path()
+	}
+	return cfg.path() + SplitChar + name
+}
+
+func joinEventPath(prefix string, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + SplitChar + name
+}
+
+//Record the creation of the section sec at path and all it's contents
+func (cfg *CFG) recordTree(path string, sec *CFG) {
+	if !cfg.root().recording() {
+		return
+	}
+	cfg.record(SectionCreated, path, nil)
+	if sec.inheritance != nil {
+		cfg.record(InheritanceSet, path, []string{sec.inheritance.path()})
+	}
+	for _, name := range sec.order {
+		if sub, ok := sec.sections[name]; ok {
+			cfg.recordTree(joinEventPath(path, name), sub)
+		} else {
+			cfg.record(OptionSet, joinEventPath(path, name), sec.options[name].value)
+		}
+	}
+}
+
+//Changes needed to go from the contents of old to the contents of new, both living at path, without applying them
+func diffChanges(path string, old *CFG, new *CFG) []ChangeEvent {
+	scratch := newCFG()
+	scratch.watchers = &watchers{}
+	scratch.recordDiff(path, old, new)
+	return scratch.pending
+}
+
+//Changes needed to go from the contents of this section to the contents of other, with paths relative to this section.
+//Sections inheriting from a different section (or starting or stopping inheriting) get an InheritanceSet event with the path
+//of the section they inherit from in other as Value, or no value if they do not inherit from any
+func (cfg *CFG) Diff(other *CFG) []ChangeEvent {
+	if other.lock != cfg.lock {
+		other = other.snapshot()
+	}
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	scratch := newCFG()
+	scratch.watchers = &watchers{}
+	if cfg.parent != nil && other.parent != nil {
+		scratch.recordInheritanceDiff("", cfg, other)
+	}
+	scratch.recordDiff("", cfg, other)
+	return scratch.pending
+}
+
+//Record the change of the inheritance of a section between old and new, if any
+func (cfg *CFG) recordInheritanceDiff(path string, old *CFG, new *CFG) {
+	if (new.inheritance == nil) == (old.inheritance == nil) && (new.inheritance == nil || new.inheritance.path() == old.inheritance.path()) {
+		return
+	}
+	if new.inheritance != nil {
+		cfg.record(InheritanceSet, path, []string{new.inheritance.path()})
+	} else {
+		cfg.record(InheritanceSet, path, nil)
+	}
+}
+
+//Record the changes needed to go from the contents of old to the contents of new, both living at path
+func (cfg *CFG) recordDiff(path string, old *CFG, new *CFG) {
+	if !cfg.root().recording() {
+		return
+	}
+	for _, name := range old.order {
+		if _, ok := old.sections[name]; ok {
+			if _, ok := new.sections[name]; !ok {
+				cfg.record(SectionRemoved, joinEventPath(path, name), nil)
+			}
+		} else if _, ok := new.options[name]; !ok {
+			cfg.record(OptionRemoved, joinEventPath(path, name), nil)
+		}
+	}
+	for _, name := range new.order {
+		if sec, ok := new.sections[name]; ok {
+			if oldSec, ok := old.sections[name]; ok {
+				cfg.recordInheritanceDiff(joinEventPath(path, name), oldSec, sec)
+				cfg.recordDiff(joinEventPath(path, name), oldSec, sec)
+			} else {
+				cfg.recordTree(joinEventPath(path, name), sec)
+			}
+		} else if opt := old.options[name]; opt == nil || !equalValues(opt.value, new.options[name].value) {
+			cfg.record(OptionSet, joinEventPath(path, name), new.options[name].value)
+		}
+	}
+}
+
+//Release the write lock and notify the watchers of the changes recorded while holding it
+func (cfg *CFG) unlock() {
+	root := cfg.root()
+	events := root.pending
+	root.pending = nil
+	hub := root.watchers
+	logger := root.logger
+	if root.versioning != nil {
+		root.versioning.stamp(root, events)
+	}
+	var log *mutationLog
+	if root.mutationLog != nil && len(events) > 0 {
+		log = root.mutationLog
+		log.lock.Lock()
+	}
+	if root.history != nil && len(events) > 0 {
+		root.history.add(root, events)
+	}
+	root.revision++
+	cfg.lock.Unlock()
+	if log != nil {
+		log.write(events)
+		log.lock.Unlock()
+	}
+	if hub == nil || len(events) == 0 {
+		return
+	}
+	hub.lock.Lock()
+	list := make([]*watcher, len(hub.list))
+	copy(list, hub.list)
+	hub.lock.Unlock()
+	for _, event := range events {
+		p := SplitPath(event.Path)
+		notified := 0
+		for _, w := range list {
+			if w.matches(p) {
+				w.deliver(event)
+				notified++
+			}
+		}
+		if logger != nil && notified > 0 {
+			logger.Debug("Change notified", "path", event.Path, "kind", event.Kind.String(), "watchers", notified)
+		}
+	}
+}
+
+//Drop the changes recorded after the mark, used when a modification is rolled back
+func (cfg *CFG) dropRecorded(mark int) {
+	root := cfg.root()
+	if len(root.pending) > mark {
+		root.pending = root.pending[:mark]
+	}
+}
+
+func (cfg *CFG) recordMark() int {
+	return len(cfg.root().pending)
+}
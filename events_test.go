@@ -0,0 +1,156 @@
+package cfg
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	cfg, err := NewCFGFromString("s1 {\nop = a\n}\ns2 {\nop = b\n}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := make([]string, 0)
+	s1 := make([]string, 0)
+	cancel := cfg.Watch("", func(e ChangeEvent) {
+		all = append(all, e.Kind.String()+" "+e.Path+" "+strings.Join(e.Value, ","))
+	})
+	sec, _ := cfg.GetSection("s1")
+	sec.Watch("op", func(e ChangeEvent) {
+		s1 = append(s1, e.Path)
+		//Watchers can use the cfg
+		cfg.GetOption(e.Path)
+	})
+	cfg.SetOption("s1/op", "c", "")
+	cfg.SetOption("s2/op", "d", "")
+	cfg.CreateSection("s3", "")
+	cfg.ApplyJSONPatch([]byte(`[{"op": "add", "path": "/s3/op/-", "value": "x"}]`))
+	cfg.ApplyJSONPatch([]byte(`[{"op": "add", "path": "/s3/new", "value": ["x", "y"]}]`))
+	cfg.Remove("s1")
+	cancel()
+	cfg.Remove("s2")
+	expected := []string{"option set s1/op c", "option set s2/op d", "section created s3 ", "option set s3/new x,y", "section removed s1 "}
+	if strings.Join(all, "|") != strings.Join(expected, "|") {
+		t.Error("Unexpected events:", all)
+	}
+	if strings.Join(s1, "|") != "s1/op|s1" {
+		t.Error("Unexpected events for s1/op:", s1)
+	}
+}
+
+func TestWatchReplace(t *testing.T) {
+	cfg, err := NewCFGFromString("s1 {\nop1 = a\nop2 = b\ns11 {\n}\n}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := make([]string, 0)
+	cfg.Watch("/", func(e ChangeEvent) {
+		all = append(all, e.Kind.String()+" "+e.Path)
+	})
+	if err := cfg.ApplyMergePatch([]byte(`{"s1": {"op1": null, "op2": "c", "s11": {"op": "x"}, "s12": {}}}`)); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"option removed s1/op1", "option set s1/op2", "option set s1/s11/op", "section created s1/s12"}
+	if strings.Join(all, "|") != strings.Join(expected, "|") {
+		t.Error("Unexpected events:", all)
+	}
+}
+
+func TestWatchDebounce(t *testing.T) {
+	cfg, _ := NewCFGFromString("s1 {\nop1 = a\nop2 = b\n}\ns2 {\nop = c\n}")
+	events := make(chan ChangeEvent, 10)
+	cfg.Watch("s1", func(e ChangeEvent) { events <- e }, WithDebounce(50*time.Millisecond))
+	cfg.SetOption("s1/op1", "x", "")
+	cfg.SetOption("s2/op", "x", "")
+	cfg.ApplyMergePatch([]byte(`{"s1": {"op1": "y", "op2": null, "op3": "z"}}`))
+	select {
+	case e := <-events:
+		if e.Kind != ChangesCoalesced || e.Path != "s1" || strings.Join(e.Paths, "|") != "s1/op1|s1/op2|s1/op3" {
+			t.Error("Unexpected coalesced event:", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Coalesced event was not delivered")
+	}
+	select {
+	case e := <-events:
+		t.Error("Unexpected extra event:", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+	cancel := cfg.Watch("s2", func(e ChangeEvent) { events <- e }, WithDebounce(50*time.Millisecond))
+	cfg.SetOption("s2/op", "y", "")
+	cancel()
+	select {
+	case e := <-events:
+		t.Error("Cancelled watcher delivered:", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old, _ := NewCFGFromString("Base {\n\tA = 1\n}\nOther {\n}\nSite {< Base\n\tB = 2\n}\nEdge {\n}\n")
+	new, _ := NewCFGFromString("Base {\n\tA = 1\n}\nOther {\n}\nSite {< Other\n\tB = 3\n}\nEdge {< Base\n}\n")
+	changes := old.Diff(new)
+	expected := []string{"inheritance set Site [Other]", "option set Site/B [3]", "inheritance set Edge [Base]"}
+	if len(changes) != len(expected) {
+		t.Fatal("Unexpected changes:", changes)
+	}
+	for iC, change := range changes {
+		if got := change.Kind.String() + " " + change.Path + " " + fmt.Sprint(change.Value); got != expected[iC] {
+			t.Errorf("Unexpected change %d: %s", iC, got)
+		}
+	}
+	edge, _ := new.GetSection("Edge")
+	if changes := new.getSection("Site", false).Diff(edge); len(changes) != 2 || changes[0].Kind != InheritanceSet || changes[0].Path != "" {
+		t.Error("Unexpected changes between sections:", changes)
+	}
+}
+
+func TestDiffConcurrentTrees(t *testing.T) {
+	a, _ := NewCFGFromString("s {\n\top = a\n}\n")
+	b, _ := NewCFGFromString("s {\n\top = b\n}\n")
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, tree := range []*CFG{a, b} {
+		wg.Add(1)
+		go func(tree *CFG) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+					tree.SetOption("s/counter", fmt.Sprint(i), "")
+				}
+			}
+		}(tree)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var compared sync.WaitGroup
+		for _, pair := range [][2]*CFG{{a, b}, {b, a}} {
+			compared.Add(1)
+			go func(x, y *CFG) {
+				defer compared.Done()
+				for i := 0; i < 500; i++ {
+					x.Diff(y)
+					x.EqualWithOptions(y, EqualOptions{})
+				}
+			}(pair[0], pair[1])
+		}
+		compared.Wait()
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Comparing two trees in both directions deadlocked")
+	}
+	close(stop)
+	wg.Wait()
+	if events := a.Diff(b); len(events) == 0 {
+		t.Error("Different trees have no differences")
+	}
+}
@@ -0,0 +1,94 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//Comment line annotating when an option or section stops being valid, like "#expires: 2026-03-01" or
+//"#expires: 2026-03-01T18:00:00Z". Useful for temporary overrides that have to be cleaned up
+const ExpiresAnnotation = "expires:"
+
+//Expiry annotated in a comment, if any
+func parseExpires(comment string) (time.Time, bool, error) {
+	for _, line := range strings.Split(comment, "\n") {
+		line = strings.Trim(line, trimChars)
+		if len(line) < len(ExpiresAnnotation) || !strings.EqualFold(line[:len(ExpiresAnnotation)], ExpiresAnnotation) {
+			continue
+		}
+		value := strings.Trim(line[len(ExpiresAnnotation):], trimChars)
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if expires, err := time.Parse(layout, value); err == nil {
+				return expires, true, nil
+			}
+		}
+		return time.Time{}, false, errors.New("Invalid expiry " + value)
+	}
+	return time.Time{}, false, nil
+}
+
+//Paths (relative to this section) of the options and sections annotated with an expiry that is not after now.
+//The entries under an expired section are not reported on their own
+func (cfg *CFG) ExpiredEntries(now time.Time) ([]string, error) {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	expired := make([]string, 0)
+	if err := cfg.collectExpired("", now, &expired); err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
+
+func (cfg *CFG) collectExpired(prefix string, now time.Time, expired *[]string) error {
+	for _, name := range cfg.order {
+		path := joinEventPath(prefix, name)
+		sec, isSec := cfg.sections[name]
+		comment := ""
+		if isSec {
+			comment = sec.comment
+		} else {
+			comment = cfg.options[name].comment
+		}
+		expires, ok, err := parseExpires(comment)
+		if err != nil {
+			return errors.New(fmt.Sprintf("%s of %s", err.Error(), path))
+		}
+		if ok && !expires.After(now) {
+			*expired = append(*expired, path)
+			continue
+		}
+		if isSec && sec.alias == nil {
+			if err := sec.collectExpired(path, now, expired); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//Remove the entries reported by ExpiredEntries and return their paths. Nothing is removed if any expiry is invalid
+//or an expired section is inherited by a section that is not expired
+func (cfg *CFG) Expire(now time.Time) ([]string, error) {
+	if err := cfg.writeLock(); err != nil {
+		return nil, err
+	}
+	defer cfg.unlock()
+	expired := make([]string, 0)
+	if err := cfg.collectExpired("", now, &expired); err != nil {
+		return nil, err
+	}
+	root := cfg.root()
+	backup := root.copyTree()
+	mark := cfg.recordMark()
+	//Heirs are removed before the sections they inherit from if they come later
+	for iE := len(expired) - 1; iE >= 0; iE-- {
+		if err := cfg.remove(SplitPath(expired[iE])); err != nil {
+			root.replaceContents(backup)
+			cfg.dropRecorded(mark)
+			return nil, err
+		}
+	}
+	return expired, nil
+}
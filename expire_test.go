@@ -0,0 +1,34 @@
+package cfg
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpire(t *testing.T) {
+	data := "#Incident 42\n#expires: 2026-03-01\nDebug = yes\n" +
+		"DB {\n\t#Expires: 2026-03-01T18:00:00Z\n\tHost = failover\n\tPort = 3306\n}\n" +
+		"#expires: 2026-02-01\nTemp {\n\t#expires: 2027-01-01\n\tA = 1\n}\n" +
+		"Later {\n\t#expires: 2027-01-01\n\tB = 1\n}\n"
+	cfg, _ := NewCFGFromString(data)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if expired, err := cfg.ExpiredEntries(now); err != nil || !reflect.DeepEqual(expired, []string{"Debug", "Temp"}) {
+		t.Error("Unexpected expired entries:", expired, err)
+	}
+	removed, err := cfg.Expire(now.Add(12 * time.Hour))
+	if err != nil || !reflect.DeepEqual(removed, []string{"Debug", "DB/Host", "Temp"}) {
+		t.Error("Unexpected removed entries:", removed, err)
+	}
+	if expected := "DB {\n\tPort = 3306\n}\nLater {\n\t#expires: 2027-01-01\n\tB = 1\n}\n"; cfg.String() != expected {
+		t.Error("Unexpected tree:", cfg.String())
+	}
+	cfg, _ = NewCFGFromString("#expires: 2026-01-01\nBase {\n\tA = 1\n}\nChild {< Base\n}\n#expires: soon\nC = 1\n")
+	if _, err := cfg.Expire(now); err == nil || err.Error() != "Invalid expiry soon of C" {
+		t.Error("Unexpected error:", err)
+	}
+	cfg.Remove("C")
+	if _, err := cfg.Expire(now); err == nil || !cfg.Exists("Base/A") {
+		t.Error("Removed an inherited section:", err)
+	}
+}
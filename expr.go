@@ -0,0 +1,201 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//Get the value of an option with the references to other options ("${name}" relative to the section of the option or "${/path}" from the root)
+//replaced by their evaluated values. Values without references are returned untouched. If the result is an arithmetic expression (numbers, + - * / %, and parentheses) it is evaluated,
+//so "maxConns = ${baseConns} * 4" gives the product. Otherwise the interpolated text is returned. GetOption keeps returning the raw value
+func (cfg *CFG) GetEvaluated(name string) (string, error) {
+	values, err := cfg.GetEvaluatedArray(name)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(values, SplitChar), nil
+}
+
+//Evaluated values of an option. See GetEvaluated
+func (cfg *CFG) GetEvaluatedArray(name string) ([]string, error) {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	return cfg.evaluate(SplitPath(name), nil)
+}
+
+func (cfg *CFG) evaluate(p []string, stack []string) ([]string, error) {
+	if len(p) == 0 {
+		return nil, errors.New("What is the name of the option?")
+	}
+	sec := cfg
+	if len(p) > 1 {
+		sec, _ = cfg.get(p, true, 1)
+	}
+	var opt *option
+	if sec != nil {
+		opt = sec.getOption(p[len(p)-1], true)
+	}
+	if opt == nil {
+		return nil, newError(ErrNotFound, fmt.Sprintf("Option %s does not exist", strings.Join(p, SplitChar)))
+	}
+	id := sec.childPath(p[len(p)-1])
+	for iS, other := range stack {
+		if other == id {
+			return nil, errors.New("Circular reference: " + strings.Join(append(stack[iS:], id), " -> "))
+		}
+	}
+	stack = append(stack, id)
+	values := make([]string, len(opt.value))
+	for iV, raw := range cfg.root().anchors.resolve(opt.value) {
+		value, err := sec.evaluateValue(raw, stack)
+		if err != nil {
+			return nil, err
+		}
+		values[iV] = value
+	}
+	return values, nil
+}
+
+//Interpolate the references of a value and evaluate the result if it is an arithmetic expression
+func (cfg *CFG) evaluateValue(raw string, stack []string) (string, error) {
+	if !strings.Contains(raw, "${") {
+		return raw, nil
+	}
+	var out strings.Builder
+	for {
+		start := strings.Index(raw, "${")
+		if start < 0 {
+			out.WriteString(raw)
+			break
+		}
+		end := strings.IndexByte(raw[start:], '}')
+		if end < 0 {
+			return "", errors.New("Unterminated reference in " + raw)
+		}
+		out.WriteString(raw[:start])
+		ref := raw[start+2 : start+end]
+		from := cfg
+		if strings.HasPrefix(ref, SplitChar) {
+			from = cfg.root()
+		}
+		values, err := from.evaluate(SplitPath(ref), stack)
+		if err != nil {
+			return "", err
+		}
+		if len(values) != 1 {
+			return "", errors.New(fmt.Sprintf("Option %s referenced in an expression has %d values", ref, len(values)))
+		}
+		out.WriteString(values[0])
+		raw = raw[start+end+1:]
+	}
+	if result, ok := evalArithmetic(out.String()); ok {
+		return strconv.FormatFloat(result, 'f', -1, 64), nil
+	}
+	return out.String(), nil
+}
+
+//Recursive descent evaluator for + - * / % and parentheses over float64 numbers
+type arithmeticParser struct {
+	input string
+	pos   int
+}
+
+//Evaluate an arithmetic expression. ok is false if the text is not one (or divides by zero)
+func evalArithmetic(input string) (result float64, ok bool) {
+	p := &arithmeticParser{input: input}
+	result, ok = p.expression()
+	p.skipSpaces()
+	if !ok || p.pos != len(p.input) {
+		return 0, false
+	}
+	return result, true
+}
+
+func (p *arithmeticParser) skipSpaces() {
+	for p.pos < len(p.input) && strings.IndexByte(trimChars, p.input[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *arithmeticParser) peek() byte {
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+func (p *arithmeticParser) expression() (float64, bool) {
+	left, ok := p.term()
+	for ok {
+		switch op := p.peek(); op {
+		case '+', '-':
+			p.pos++
+			right, rok := p.term()
+			if !rok {
+				return 0, false
+			}
+			if op == '+' {
+				left += right
+			} else {
+				left -= right
+			}
+		default:
+			return left, true
+		}
+	}
+	return 0, false
+}
+
+func (p *arithmeticParser) term() (float64, bool) {
+	left, ok := p.factor()
+	for ok {
+		switch op := p.peek(); op {
+		case '*', '/', '%':
+			p.pos++
+			right, rok := p.factor()
+			if !rok || (op != '*' && right == 0) {
+				return 0, false
+			}
+			switch op {
+			case '*':
+				left *= right
+			case '/':
+				left /= right
+			default:
+				left = float64(int64(left) % int64(right))
+			}
+		default:
+			return left, true
+		}
+	}
+	return 0, false
+}
+
+func (p *arithmeticParser) factor() (float64, bool) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		value, ok := p.factor()
+		return -value, ok
+	case '+':
+		p.pos++
+		return p.factor()
+	case '(':
+		p.pos++
+		value, ok := p.expression()
+		if !ok || p.peek() != ')' {
+			return 0, false
+		}
+		p.pos++
+		return value, true
+	}
+	start := p.pos
+	for p.pos < len(p.input) && (p.input[p.pos] >= '0' && p.input[p.pos] <= '9' || p.input[p.pos] == '.') {
+		p.pos++
+	}
+	value, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+	return value, err == nil
+}
@@ -0,0 +1,35 @@
+package cfg
+
+import (
+	"testing"
+)
+
+func TestGetEvaluated(t *testing.T) {
+	data := "Base = 10\nPool\n{\n\tbaseConns = ${/Base} / 2\n\tmaxConns = ${baseConns} * 4\n\tratio = (${maxConns} - 1) % 7 + 0.5\n\taddress = ${host}:${port}\n\thost = db\n\tport = 5432\n\tnegative = -${port}\n\tplain = 1 + 1\n\tloopA = ${loopB}\n\tloopB = ${loopA} + 1\n\tmissing = ${nope}\n\tzero = 1 / 0\n}\n"
+	cfg, err := NewCFGFromString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]string{
+		"Pool/baseConns": "5",
+		"Pool/maxConns":  "20",
+		"Pool/ratio":     "5.5",
+		"Pool/address":   "db:5432",
+		"Pool/negative":  "-5432",
+		"Pool/plain":     "1 + 1",
+		"Pool/zero":      "1 / 0",
+	} {
+		if v, err := cfg.GetEvaluated(name); err != nil || v != expected {
+			t.Errorf("%s evaluated to '%s' (%v) instead of '%s'", name, v, err, expected)
+		}
+	}
+	if raw, _ := cfg.GetOption("Pool/maxConns"); raw != "${baseConns} * 4" {
+		t.Error("Raw value changed:", raw)
+	}
+	if _, err := cfg.GetEvaluated("Pool/loopA"); err == nil || err.Error() != "Circular reference: Pool/loopA -> Pool/loopB -> Pool/loopA" {
+		t.Error("Unexpected error for a cycle:", err)
+	}
+	if _, err := cfg.GetEvaluated("Pool/missing"); err == nil {
+		t.Error("Evaluated a missing reference")
+	}
+}
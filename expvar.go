@@ -0,0 +1,42 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"strings"
+)
+
+//Value shown instead of the real one for secret options
+const MaskedValue = "********"
+
+//Options which name contains any of these (case insensitive) are considered secret and their values are masked when exposed
+var SecretNames = []string{"password", "passwd", "secret", "token", "credential", "privatekey", "apikey"}
+
+//Is an option with this name considered secret?
+func IsSecretName(name string) bool {
+	lower := strings.ToLower(name)
+	for _, secret := range SecretNames {
+		if strings.Contains(lower, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+//Publish a JSON rendering of the cfg under name in expvar (and so in /debug/vars). Values of secret options are masked.
+//The rendering is done each time the variable is read so it always reflects the current contents
+func (cfg *CFG) PublishExpvar(name string) error {
+	if expvar.Get(name) != nil {
+		return errors.New("Expvar " + name + " is already published")
+	}
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		var buf bytes.Buffer
+		cfg.lock.RLock()
+		writeJSONSectionMasked(&buf, cfg, IsSecretName)
+		cfg.lock.RUnlock()
+		return json.RawMessage(buf.Bytes())
+	}))
+	return nil
+}
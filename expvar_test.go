@@ -0,0 +1,24 @@
+package cfg
+
+import (
+	"expvar"
+	"testing"
+)
+
+func TestPublishExpvar(t *testing.T) {
+	cfg, _ := NewCFGFromString("db {\nhost = db1\nPassword = hunter2\n}\napiToken = abc")
+	if err := cfg.PublishExpvar("cfgtest"); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"db":{"host":"db1","Password":"********"},"apiToken":"********"}`
+	if v := expvar.Get("cfgtest").String(); v != expected {
+		t.Error("Unexpected expvar:", v)
+	}
+	cfg.SetOption("db/host", "db2", "")
+	if v := expvar.Get("cfgtest").String(); v != `{"db":{"host":"db2","Password":"********"},"apiToken":"********"}` {
+		t.Error("Expvar does not reflect changes:", v)
+	}
+	if err := cfg.PublishExpvar("cfgtest"); err == nil {
+		t.Error("Published the same expvar twice")
+	}
+}
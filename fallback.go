@@ -0,0 +1,71 @@
+package cfg
+
+import (
+	"strings"
+)
+
+//Register old as a fallback of path: reading path via GetOption (and the rest of getters) when it does not exist returns the value of old.
+//Both paths are relative to the root. Several fallbacks can be registered for a path and fallbacks can have fallbacks themselves,
+//they are tried in the order they were added. Useful to keep renamed options working during transitions
+func (cfg *CFG) AddFallback(path string, old string) {
+	cfg.lock.Lock()
+	defer cfg.lock.Unlock()
+	root := cfg.root()
+	if root.fallbacks == nil {
+		root.fallbacks = make(map[string][]string)
+	}
+	key := strings.Join(SplitPath(path), SplitChar)
+	root.fallbacks[key] = append(root.fallbacks[key], strings.Join(SplitPath(old), SplitChar))
+}
+
+//Get the value of the first of the options that exists
+func (cfg *CFG) GetOptionFirst(paths ...string) (string, bool) {
+	for _, path := range paths {
+		if value, ok := cfg.GetOption(path); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+//Find an option asking the providers, following inheritance and, if it does not exist, the renames in RenamesSection and the fallbacks of it's path
+func (cfg *CFG) lookupOption(name string) *option {
+	root := cfg.root()
+	if len(root.providers) > 0 {
+		if opt := root.provided(SplitPath(cfg.childPath(name))); opt != nil {
+			return opt
+		}
+	}
+	if root.lookupStats != nil {
+		if opt := cfg.countedLookup(root.lookupStats, name); opt != nil {
+			return opt
+		}
+	} else if _, opt := cfg.getString(name, true, 0); opt != nil {
+		return opt
+	}
+	if renames, _ := root.getString(RenamesSection, false, 0); renames != nil {
+		if opt := root.lookupRenamed(strings.Join(SplitPath(cfg.childPath(name)), SplitChar), map[string]bool{}); opt != nil {
+			return opt
+		}
+	}
+	if root.fallbacks == nil {
+		return nil
+	}
+	return root.lookupFallbacks(strings.Join(SplitPath(cfg.childPath(name)), SplitChar), map[string]bool{})
+}
+
+func (cfg *CFG) lookupFallbacks(path string, visited map[string]bool) *option {
+	visited[path] = true
+	for _, old := range cfg.fallbacks[path] {
+		if visited[old] {
+			continue
+		}
+		if _, opt := cfg.getString(old, true, 0); opt != nil {
+			return opt
+		}
+		if opt := cfg.lookupFallbacks(old, visited); opt != nil {
+			return opt
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package cfg
+
+import (
+	"testing"
+)
+
+func TestFallbacks(t *testing.T) {
+	cfg, _ := NewCFGFromString("Old\n{\n\tHost = db1\n}\nLegacy\n{\n\tPort = 3306\n}\nTimeout = 5\n")
+	if v, ok := cfg.GetOptionFirst("New/Timeout", "Timeout"); !ok || v != "5" {
+		t.Error("Unexpected first option:", v, ok)
+	}
+	if _, ok := cfg.GetOptionFirst("a", "b"); ok {
+		t.Error("Found missing options")
+	}
+	cfg.AddFallback("/DB/Host", "/Old/Host")
+	cfg.AddFallback("DB/Port", "Old/Port")
+	cfg.AddFallback("Old/Port", "Legacy/Port")
+	cfg.AddFallback("Legacy/Port", "DB/Port")
+	if v, ok := cfg.GetOption("DB/Host"); !ok || v != "db1" {
+		t.Error("Fallback was not used:", v, ok)
+	}
+	db, _ := cfg.CreateSection("DB", "")
+	if v, ok := db.GetOption("Port"); !ok || v != "3306" {
+		t.Error("Fallback chain was not followed:", v, ok)
+	}
+	cfg.AddFallback("DB/User", "DB/User2")
+	cfg.AddFallback("DB/User2", "DB/User")
+	if _, ok := cfg.GetOption("DB/User"); ok {
+		t.Error("Found a missing option through a fallback loop")
+	}
+	db.SetOption("Host", "db2", "")
+	if v, _ := cfg.GetOption("DB/Host"); v != "db2" {
+		t.Error("Fallback used while the option exists:", v)
+	}
+}
@@ -0,0 +1,99 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+//Feature flags defined as the options of a section. Values are booleans (on/off, true/false...) or percentage rollouts like
+//"enabled=25%" (or just "25%"), which enable the flag for that share of the keys given to IsEnabledFor
+type FeatureFlags struct {
+	cfg  *CFG
+	path string
+}
+
+//Feature flags defined in the section at path (relative to this section). The section does not need to exist
+func (cfg *CFG) Flags(path string) *FeatureFlags {
+	return &FeatureFlags{cfg, strings.Join(SplitPath(path), SplitChar)}
+}
+
+//Percentage of keys a flag is enabled for: 100 or 0 for booleans
+func parseFlag(value string) (int, error) {
+	value = strings.Trim(value, trimChars)
+	if len(value) > 0 && value[len(value)-1] == '%' {
+		number := strings.Trim(value[:len(value)-1], trimChars)
+		if eq := strings.Index(number, "="); eq >= 0 {
+			if strings.ToLower(strings.Trim(number[:eq], trimChars)) != "enabled" {
+				return 0, errors.New(fmt.Sprintf("'%s' is not a feature flag", value))
+			}
+			number = strings.Trim(number[eq+1:], trimChars)
+		}
+		pct, err := strconv.ParseFloat(number, 64)
+		if err != nil || pct < 0 || pct > 100 {
+			return 0, errors.New(fmt.Sprintf("'%s' is not a valid percentage", value))
+		}
+		return int(pct), nil
+	}
+	enabled, err := parseBool(value)
+	if err != nil {
+		return 0, err
+	}
+	if enabled {
+		return 100, nil
+	}
+	return 0, nil
+}
+
+//Rollout percentage of a flag. Returns false if it is not defined or it's value is not valid
+func (f *FeatureFlags) Rollout(name string) (int, bool) {
+	value, ok := f.cfg.GetOption(joinEventPath(f.path, name))
+	if !ok {
+		return 0, false
+	}
+	pct, err := parseFlag(value)
+	return pct, err == nil
+}
+
+//Is the flag fully enabled? Partial rollouts are only enabled for some keys, see IsEnabledFor.
+//Returns defaultValue if the flag is not defined or it's value is not valid
+func (f *FeatureFlags) IsEnabled(name string, defaultValue bool) bool {
+	pct, ok := f.Rollout(name)
+	if !ok {
+		return defaultValue
+	}
+	return pct == 100
+}
+
+//Is the flag enabled for key (a user or host id, for instance)? The same key always gets the same answer for a given rollout,
+//and raising the percentage only enables more keys. Returns defaultValue if the flag is not defined or it's value is not valid
+func (f *FeatureFlags) IsEnabledFor(name string, key string, defaultValue bool) bool {
+	pct, ok := f.Rollout(name)
+	if !ok {
+		return defaultValue
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return int(h.Sum32()%100) < pct
+}
+
+//Call fn with the name of every flag that is set, changed or removed. Changes to the section itself or it's parents (like
+//changing it's inheritance) are notified with an empty name, as they may change any flag. Returns a function that stops watching
+func (f *FeatureFlags) Watch(fn func(name string)) (cancel func()) {
+	f.cfg.lock.RLock()
+	depth := len(SplitPath(f.cfg.childPath(f.path)))
+	f.cfg.lock.RUnlock()
+	return f.cfg.Watch(f.path, func(event ChangeEvent) {
+		p := SplitPath(event.Path)
+		switch {
+		case len(p) <= depth:
+			fn("")
+		case len(p) == depth+1 && event.Kind != SectionCreated && event.Kind != SectionRemoved:
+			fn(p[depth])
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package cfg
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	cfg, err := NewCFGFromString("Features {\n\tnew_ui = on\n\told_api = off\n\tsearch = enabled=25%\n\tbroken = maybe\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags := cfg.Flags("Features")
+	if !flags.IsEnabled("new_ui", false) || flags.IsEnabled("old_api", true) || flags.IsEnabled("search", false) {
+		t.Error("Unexpected flags")
+	}
+	if !flags.IsEnabled("missing", true) || !flags.IsEnabled("broken", true) {
+		t.Error("Defaults not used")
+	}
+	if pct, ok := flags.Rollout("search"); !ok || pct != 25 {
+		t.Error("Unexpected rollout:", pct, ok)
+	}
+	enabled := 0
+	for iK := 0; iK < 1000; iK++ {
+		key := fmt.Sprintf("user%d", iK)
+		on := flags.IsEnabledFor("search", key, false)
+		if on != flags.IsEnabledFor("search", key, false) {
+			t.Fatal("Rollout is not stable for", key)
+		}
+		if on {
+			enabled++
+		}
+	}
+	if enabled < 200 || enabled > 300 {
+		t.Error("Rollout enabled the flag for", enabled, "of 1000 keys")
+	}
+	changed := make([]string, 0)
+	cancel := flags.Watch(func(name string) { changed = append(changed, name) })
+	defer cancel()
+	cfg.SetOption("Features/search", "50%", "")
+	cfg.Remove("Features/old_api")
+	cfg.SetOption("Other", "x", "")
+	if !reflect.DeepEqual(changed, []string{"search", "old_api"}) {
+		t.Error("Unexpected notifications:", changed)
+	}
+	if pct, _ := flags.Rollout("search"); pct != 50 {
+		t.Error("Rollout not updated:", pct)
+	}
+}
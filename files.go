@@ -0,0 +1,115 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//Scheme of values referencing the contents of a file, like "@file:certs/server.pem"
+const FileScheme = "@file"
+
+//Default maximum size of a referenced file
+const DefaultFileReferenceLimit = 1 << 20
+
+//SecretResolver returning the contents of files under a root directory. References cannot escape the root
+type FileResolver struct {
+	Root string
+	//Maximum size of a file. DefaultFileReferenceLimit if <= 0
+	MaxSize int64
+}
+
+func (r *FileResolver) ResolveSecret(ref string) (string, error) {
+	if ref == "" || filepath.IsAbs(ref) {
+		return "", errors.New("File references must be relative paths")
+	}
+	clean := filepath.Clean(filepath.FromSlash(ref))
+	if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", errors.New("File reference " + ref + " is outside of the root directory")
+	}
+	limit := r.MaxSize
+	if limit <= 0 {
+		limit = DefaultFileReferenceLimit
+	}
+	f, err := os.Open(filepath.Join(r.Root, clean))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	data := make([]byte, limit+1)
+	n, err := io.ReadFull(f, data)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	if int64(n) > limit {
+		return "", errors.New(fmt.Sprintf("File %s is bigger than %d bytes", ref, limit))
+	}
+	return string(data[:n]), nil
+}
+
+//Replace the values of the form "@file:relative/path" with the contents of the file (relative to root) when they are read via GetOption
+//and GetOptionArray. Files bigger than maxSize (DefaultFileReferenceLimit if <= 0) are rejected. Contents are read on every access
+func (cfg *CFG) EnableFileReferences(root string, maxSize int64) {
+	cfg.RegisterSecretResolver(FileScheme, &FileResolver{root, maxSize}, 0)
+}
+
+//Replace all the "@file:relative/path" values under this section with the contents of the files (relative to root) right away.
+//Files bigger than maxSize (DefaultFileReferenceLimit if <= 0) are rejected. If any file cannot be read nothing is replaced.
+//Contents with several lines cannot be dumped back as cfg
+func (cfg *CFG) ResolveFiles(root string, maxSize int64) error {
+	resolver := &FileResolver{root, maxSize}
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
+	resolved := make(map[*option][]string)
+	if err := cfg.resolveFiles(resolver, resolved); err != nil {
+		return err
+	}
+	cfg.applyResolvedFiles(resolved)
+	return nil
+}
+
+func (cfg *CFG) resolveFiles(resolver *FileResolver, resolved map[*option][]string) error {
+	for _, name := range cfg.order {
+		if sec, ok := cfg.sections[name]; ok {
+			if err := sec.resolveFiles(resolver, resolved); err != nil {
+				return err
+			}
+			continue
+		}
+		opt := cfg.options[name]
+		var values []string
+		for iV, value := range opt.value {
+			if !strings.HasPrefix(value, FileScheme+":") {
+				continue
+			}
+			contents, err := resolver.ResolveSecret(value[len(FileScheme)+1:])
+			if err != nil {
+				return errors.New(fmt.Sprintf("Cannot resolve %s: %s", cfg.childPath(name), err.Error()))
+			}
+			if values == nil {
+				values = append([]string{}, opt.value...)
+			}
+			values[iV] = contents
+		}
+		if values != nil {
+			resolved[opt] = values
+		}
+	}
+	return nil
+}
+
+func (cfg *CFG) applyResolvedFiles(resolved map[*option][]string) {
+	for _, name := range cfg.order {
+		if sec, ok := cfg.sections[name]; ok {
+			sec.applyResolvedFiles(resolved)
+		} else if values, ok := resolved[cfg.options[name]]; ok {
+			cfg.options[name].value = values
+			cfg.record(OptionSet, cfg.childPath(name), values)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package cfg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileReferences(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgfiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "certs"), 0700)
+	ioutil.WriteFile(filepath.Join(dir, "certs", "server.pem"), []byte("-----BEGIN CERTIFICATE-----\nabc\n"), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "big"), []byte(strings.Repeat("x", 100)), 0600)
+	data := "TLS\n{\n\tCert = @file:certs/server.pem\n\tName = plain\n}\nBig = @file:big\nEscape = @file:../etc/passwd\nAbsolute = @file:/etc/passwd\n"
+	cfg, _ := NewCFGFromString(data)
+	cfg.EnableFileReferences(dir, 50)
+	if v, ok := cfg.GetOption("TLS/Cert"); !ok || !strings.HasPrefix(v, "-----BEGIN CERTIFICATE-----") {
+		t.Error("Unexpected certificate:", v, ok)
+	}
+	if v, _ := cfg.GetOption("TLS/Name"); v != "plain" {
+		t.Error("Plain value changed")
+	}
+	for _, name := range []string{"Big", "Escape", "Absolute"} {
+		if _, err := cfg.ResolveOption(name); err == nil {
+			t.Error("Resolved invalid reference", name)
+		}
+	}
+
+	explicit, _ := NewCFGFromString(data)
+	if err := explicit.ResolveFiles(dir, 50); err == nil {
+		t.Error("Resolved files with invalid references")
+	}
+	if v, _ := explicit.GetOption("TLS/Cert"); v != "@file:certs/server.pem" {
+		t.Error("Failed resolution changed values")
+	}
+	tls, _ := explicit.GetSection("TLS")
+	if err := tls.ResolveFiles(dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := explicit.GetOption("TLS/Cert"); v != "-----BEGIN CERTIFICATE-----\nabc\n" {
+		t.Error("Unexpected resolved certificate:", v)
+	}
+}
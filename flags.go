@@ -0,0 +1,64 @@
+package cfg
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+//flag.Value that writes the parsed values back into an option
+type optionFlag struct {
+	cfg     *CFG
+	name    string
+	comment string
+	parsed  bool
+}
+
+func (f *optionFlag) String() string {
+	if f.cfg == nil {
+		return ""
+	}
+	return f.cfg.GetValue(f.name, "")
+}
+
+//The first time the flag is set the option value is replaced. Repeating the flag appends values as += does
+func (f *optionFlag) Set(value string) error {
+	values := []string{value}
+	if f.parsed {
+		values = append(f.cfg.GetValueArray(f.name, nil), value)
+	}
+	f.parsed = true
+	return f.cfg.SetOptionArray(f.name, values, f.comment)
+}
+
+//Register one flag per option of the section under path (including inherited ones).
+//Default values come from the option value and usage from the option comment. Parsed flags are written back into the section
+func (cfg *CFG) FlagsFromSection(path string, fs *flag.FlagSet) error {
+	sec := cfg
+	if p := SplitPath(path); len(p) > 0 {
+		var ok bool
+		if sec, ok = cfg.GetSection(path); !ok {
+			return newError(ErrNotFound, fmt.Sprintf("Section %s does not exist", path))
+		}
+	}
+	cfg.lock.RLock()
+	flags := make([]*optionFlag, 0)
+	found := make(map[string]bool)
+	for me := sec; me != nil; me = me.inheritance {
+		for _, name := range me.order {
+			if opt, ok := me.options[name]; ok && !found[name] {
+				found[name] = true
+				flags = append(flags, &optionFlag{cfg: sec, name: name, comment: opt.comment})
+			}
+		}
+	}
+	cfg.lock.RUnlock()
+	for _, f := range flags {
+		if fs.Lookup(f.name) != nil {
+			return errors.New("Flag " + f.name + " is already defined")
+		}
+		fs.Var(f, f.name, strings.Replace(f.comment, "\n", " ", -1))
+	}
+	return nil
+}
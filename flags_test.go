@@ -0,0 +1,44 @@
+package cfg
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+)
+
+func TestFlagsFromSection(t *testing.T) {
+	data := "base {\n#Where to listen\nport = 80\n}\nsrv {< base\n#Name of the server\nname = web\nhosts = a\n}"
+	cfg, err := NewCFGFromString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := cfg.FlagsFromSection("srv", fs); err != nil {
+		t.Fatal(err)
+	}
+	if f := fs.Lookup("port"); f == nil || f.DefValue != "80" || f.Usage != "Where to listen" {
+		t.Error("Unexpected port flag:", f)
+	}
+	if f := fs.Lookup("name"); f == nil || f.DefValue != "web" || f.Usage != "Name of the server" {
+		t.Error("Unexpected name flag:", f)
+	}
+	if err := fs.Parse([]string{"-port", "8080", "-hosts", "b", "-hosts", "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if v := cfg.GetValue("srv/port", ""); v != "8080" {
+		t.Error("Flag value not written back:", v)
+	}
+	if v := cfg.GetValue("base/port", ""); v != "80" {
+		t.Error("Inherited option was modified:", v)
+	}
+	if v := cfg.GetValueArray("srv/hosts", nil); !equalSlices(v, []string{"b", "c"}) {
+		t.Error("Repeated flag not appended:", v)
+	}
+	if err := cfg.FlagsFromSection("srv", fs); err == nil {
+		t.Error("Allowed to redefine flags")
+	}
+	if err := cfg.FlagsFromSection("nope", fs); err == nil {
+		t.Error("Registered flags from a non existant section")
+	}
+}
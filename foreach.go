@@ -0,0 +1,128 @@
+package cfg
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//Directive repeating it's body for each value of a variable: "@foreach i in 1..8 {" or "@foreach dc in eu,us {".
+//"$i" and "${i}" are replaced by the value in the body, names cannot contain braces so they have to use "$i".
+//The body is expanded when loading, so dumps contain the resulting sections and options
+const foreachDirective = "@foreach"
+
+//Expand a @foreach directive which header has just been read. The body is read from source up to the closing brace
+func (cfg *CFG) processForeach(header string, source *bufio.Reader, line_counter *int, inheritance_links *[]inheritanceLink) error {
+	name, values, err := parseForeach(strings.Trim(header[len(foreachDirective):], trimChars))
+	if err != nil {
+		return &ParseError{Msg: err.Error(), Err: err, Line: *line_counter}
+	}
+	start := *line_counter
+	body, err := cfg.readBlock(source, line_counter)
+	if err != nil {
+		return err
+	}
+	end := *line_counter
+	for _, value := range values {
+		expanded := expandVariable(body, name, value)
+		*line_counter = start
+		if err := cfg.loadFromReader(bufio.NewReader(strings.NewReader(expanded)), line_counter, inheritance_links); err != nil {
+			return err
+		}
+	}
+	*line_counter = end
+	return nil
+}
+
+//Parse "i in 1..8" or "i in a,b,c" into the name of the variable and it's values. Ranges with leading zeros keep the width
+func parseForeach(header string) (string, []string, error) {
+	fields := strings.Fields(header)
+	if len(fields) != 3 || fields[1] != "in" {
+		return "", nil, errors.New(fmt.Sprintf("Expected '%s name in values' but '%s %s' found", foreachDirective, foreachDirective, header))
+	}
+	if pos := strings.Index(fields[2], ".."); pos >= 0 {
+		from, errFrom := strconv.Atoi(fields[2][:pos])
+		to, errTo := strconv.Atoi(fields[2][pos+2:])
+		if errFrom != nil || errTo != nil || to < from {
+			return "", nil, errors.New("Invalid range " + fields[2])
+		}
+		width := 0
+		if strings.HasPrefix(fields[2], "0") && pos > 1 {
+			width = pos
+		}
+		values := make([]string, 0, to-from+1)
+		for i := from; i <= to; i++ {
+			values = append(values, fmt.Sprintf("%0*d", width, i))
+		}
+		return fields[0], values, nil
+	}
+	return fields[0], strings.Split(fields[2], ","), nil
+}
+
+//Read the lines up to the brace closing the current block. The closing brace is consumed but not returned
+func (cfg *CFG) readBlock(source *bufio.Reader, line_counter *int) (string, error) {
+	maxLength := cfg.root().maxLineLength
+	if maxLength == 0 {
+		maxLength = DefaultMaxLineLength
+	}
+	var block strings.Builder
+	depth := 0
+	for {
+		raw, err := readLine(source, maxLength)
+		*line_counter++
+		if err == errLineTooLong {
+			return "", &ParseError{Line: *line_counter, Column: maxLength + 1, Msg: fmt.Sprintf("Line is longer than %d bytes", maxLength)}
+		}
+		line := raw
+		if pos := strings.IndexRune(line, '#'); pos > -1 {
+			line = line[:pos]
+		}
+	Scan:
+		for _, lChar := range line {
+			switch lChar {
+			case '{':
+				depth++
+				break Scan
+			case '}':
+				if depth == 0 {
+					return block.String(), nil
+				}
+				depth--
+				break Scan
+			case '=':
+				break Scan
+			}
+		}
+		block.WriteString(raw)
+		if err != nil {
+			return "", &ParseError{Line: *line_counter, Msg: "Missing closing brace of " + foreachDirective}
+		}
+	}
+}
+
+//Replace "${name}" and "$name" (when not followed by more letters of a name) by value
+func expandVariable(text string, name string, value string) string {
+	text = strings.Replace(text, "${"+name+"}", value, -1)
+	var out strings.Builder
+	for {
+		pos := strings.Index(text, "$"+name)
+		if pos < 0 {
+			out.WriteString(text)
+			return out.String()
+		}
+		end := pos + 1 + len(name)
+		out.WriteString(text[:pos])
+		if end < len(text) && isNameChar(text[end]) {
+			out.WriteString(text[pos:end])
+		} else {
+			out.WriteString(value)
+		}
+		text = text[end:]
+	}
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
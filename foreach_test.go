@@ -0,0 +1,37 @@
+package cfg
+
+import (
+	"testing"
+)
+
+func TestForeach(t *testing.T) {
+	data := "Base {\n\tTimeout = 30\n}\n@foreach i in 1..3 {\n\t#Worker number $i\n\tWorker$i {< Base\n\t\tPort = 90${i}0\n\t\tName = $id-$i\n\t}\n}\nQueues {\n\t@foreach dc in eu,us {\n\t\t$dc = queue.${dc}.example.com\n\t}\n}\n"
+	cfg, err := NewCFGFromString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, value := range map[string]string{"Worker1/Port": "9010", "Worker3/Port": "9030", "Worker2/Name": "$id-2",
+		"Worker2/Timeout": "30", "Queues/eu": "queue.eu.example.com", "Queues/us": "queue.us.example.com"} {
+		if v, _ := cfg.GetOption(path); v != value {
+			t.Errorf("Unexpected value for %s: %s", path, v)
+		}
+	}
+	if sec, _ := cfg.GetSection("Worker3"); sec.comment != "Worker number 3" {
+		t.Error("Unexpected comment:", sec.comment)
+	}
+	padded, err := NewCFGFromString("@foreach n in 08..10 {\n\tw$n = $n\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if padded.String() != "w08 = 08\nw09 = 09\nw10 = 10\n" {
+		t.Error("Unexpected padded range:", padded.String())
+	}
+	for _, bad := range []string{"@foreach i 1..3 {\n}\n", "@foreach i in 3..1 {\n}\n", "@foreach i in 1..3 {\n\tw = $i\n", "@foreach i in 1..3 {\n\tw = $i\n\tw$i {\n\t}\n}\n"} {
+		if _, err := NewCFGFromString(bad); err == nil {
+			t.Error("Loaded", bad)
+		}
+	}
+	if _, err := NewCFGFromString("@foreach i in 1..2 {\n\tw$i = 1\n\tw$i = 2\n}\n"); err == nil || err.(*ParseError).Line != 3 {
+		t.Error("Unexpected error:", err)
+	}
+}
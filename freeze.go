@@ -0,0 +1,26 @@
+package cfg
+
+//Make the whole tree read only. Any later modification fails with ErrFrozen, including reloads and updates received by followers.
+//Frozen trees cannot be unfrozen, Clone them to get a modifiable copy
+func (cfg *CFG) Freeze() {
+	cfg.lock.Lock()
+	defer cfg.lock.Unlock()
+	cfg.root().frozen = true
+}
+
+//Has the tree been frozen?
+func (cfg *CFG) Frozen() bool {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	return cfg.root().frozen
+}
+
+//Take the write lock to modify the tree. Fails with ErrFrozen without holding the lock if the tree has been frozen
+func (cfg *CFG) writeLock() error {
+	cfg.lock.Lock()
+	if cfg.root().frozen {
+		cfg.lock.Unlock()
+		return ErrFrozen
+	}
+	return nil
+}
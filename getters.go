@@ -0,0 +1,436 @@
+package cfg
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Get the value of an option that must have exactly one value
+func (cfg *CFG) getSingleValue(name string) (string, error) {
+	values, err := cfg.ResolveOption(name)
+	if err != nil {
+		return "", err
+	}
+	if len(values) != 1 {
+		return "", errors.New(fmt.Sprintf("Option %s has %d values instead of one", name, len(values)))
+	}
+	return values[0], nil
+}
+
+//Get the value of an option decoded from base64. Standard and URL alphabets are accepted, with or without padding
+func (cfg *CFG) GetBytesBase64(name string) ([]byte, error) {
+	value, err := cfg.getSingleValue(name)
+	if err != nil {
+		return nil, err
+	}
+	value = strings.Trim(value, trimChars)
+	encoding := base64.StdEncoding
+	if strings.ContainsAny(value, "-_") {
+		encoding = base64.URLEncoding
+	}
+	if !strings.HasSuffix(value, "=") && len(value)%4 != 0 {
+		encoding = encoding.WithPadding(base64.NoPadding)
+	}
+	data, err := encoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Option %s is not valid base64: %s", name, err.Error()))
+	}
+	return data, nil
+}
+
+//Get the value of an option decoded from hexadecimal. An optional "0x" prefix is accepted
+func (cfg *CFG) GetBytesHex(name string) ([]byte, error) {
+	value, err := cfg.getSingleValue(name)
+	if err != nil {
+		return nil, err
+	}
+	value = strings.Trim(value, trimChars)
+	if strings.HasPrefix(value, "0x") || strings.HasPrefix(value, "0X") {
+		value = value[2:]
+	}
+	data, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Option %s is not valid hex: %s", name, err.Error()))
+	}
+	return data, nil
+}
+
+//Parse a boolean written by a human: true/false, yes/no, on/off and 1/0 in any case
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.Trim(value, trimChars)) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0":
+		return false, nil
+	}
+	//Keep accepting the spellings of strconv
+	if b, err := strconv.ParseBool(value); err == nil {
+		return b, nil
+	}
+	return false, errors.New(fmt.Sprintf("'%s' is not a boolean", value))
+}
+
+//Get the value of an option as a boolean. true/false, yes/no, on/off and 1/0 are accepted in any case
+func (cfg *CFG) GetBool(name string) (bool, error) {
+	value, err := cfg.getSingleValue(name)
+	if err != nil {
+		return false, err
+	}
+	b, err := parseBool(value)
+	if err != nil {
+		return false, errors.New(fmt.Sprintf("Option %s: %s", name, err.Error()))
+	}
+	return b, nil
+}
+
+//Set a boolean option. It is written as "true" or "false"
+func (cfg *CFG) SetBool(name string, value bool, comment string) error {
+	return cfg.SetOption(name, strconv.FormatBool(value), comment)
+}
+
+//Get the value of a boolean option or defaultValue if it does not exist or it is not a boolean
+func (cfg *CFG) GetBoolValue(name string, defaultValue bool) bool {
+	b, err := cfg.GetBool(name)
+	if err != nil {
+		return defaultValue
+	}
+	return b
+}
+
+//Get the value of an option as an int. Decimal values are expected, "0x", "0o" and "0b" prefixes and '_' separators are accepted
+func (cfg *CFG) GetInt(name string) (int, error) {
+	value, err := cfg.getSingleValue(name)
+	if err != nil {
+		return 0, err
+	}
+	i, err := parseInt(value)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Option %s: %s", name, err.Error()))
+	}
+	return i, nil
+}
+
+func parseInt(value string) (int, error) {
+	i, err := parseInteger(strings.Trim(value, trimChars), strconv.IntSize)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("'%s' is not an integer", value))
+	}
+	return int(i), nil
+}
+
+//Parse an integer as written in a cfg: decimal even with leading zeros unless it has a "0x", "0o" or "0b" prefix
+func parseInteger(value string, bitSize int) (int64, error) {
+	i, err := strconv.ParseInt(decimalSyntax(value), 0, bitSize)
+	return i, numError(err, value)
+}
+
+//Like parseInteger for unsigned integers
+func parseUnsigned(value string, bitSize int) (uint64, error) {
+	u, err := strconv.ParseUint(decimalSyntax(value), 0, bitSize)
+	return u, numError(err, value)
+}
+
+//Drop the leading zeros of an integer without a base prefix so strconv does not take it as octal with base 0
+func decimalSyntax(value string) string {
+	sign := ""
+	if value != "" && (value[0] == '+' || value[0] == '-') {
+		sign, value = value[:1], value[1:]
+	}
+	if len(value) < 2 || value[0] != '0' {
+		return sign + value
+	}
+	switch value[1] {
+	case 'x', 'X', 'o', 'O', 'b', 'B':
+		return sign + value
+	}
+	if value = strings.TrimLeft(value, "0"); value == "" {
+		value = "0"
+	}
+	return sign + value
+}
+
+//Report the value as it was written in strconv errors
+func numError(err error, value string) error {
+	if numErr, ok := err.(*strconv.NumError); ok {
+		numErr.Num = value
+	}
+	return err
+}
+
+//Get the value of an int option or defaultValue if it does not exist or it is not an int
+func (cfg *CFG) GetIntValue(name string, defaultValue int) int {
+	i, err := cfg.GetInt(name)
+	if err != nil {
+		return defaultValue
+	}
+	return i
+}
+
+//Get the value of an option as a float64
+func (cfg *CFG) GetFloat64(name string) (float64, error) {
+	value, err := cfg.getSingleValue(name)
+	if err != nil {
+		return 0, err
+	}
+	f, err := parseFloat(value)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Option %s: %s", name, err.Error()))
+	}
+	return f, nil
+}
+
+func parseFloat(value string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.Trim(value, trimChars), 64)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("'%s' is not a number", value))
+	}
+	return f, nil
+}
+
+//Multipliers of the units accepted by GetBytes
+var byteUnits = map[string]float64{
+	"": 1, "b": 1,
+	"k": 1e3, "kb": 1e3, "m": 1e6, "mb": 1e6, "g": 1e9, "gb": 1e9, "t": 1e12, "tb": 1e12, "p": 1e15, "pb": 1e15,
+	"kib": 1 << 10, "mib": 1 << 20, "gib": 1 << 30, "tib": 1 << 40, "pib": 1 << 50,
+}
+
+//Parse a size like "10KB", "64MiB" or "1.5 GB". KB, MB... are powers of 1000 and KiB, MiB... powers of 1024, in any case
+func parseBytes(value string) (int64, error) {
+	value = strings.Trim(value, trimChars)
+	split := len(value)
+	for split > 0 && (value[split-1] < '0' || value[split-1] > '9') && value[split-1] != '.' {
+		split--
+	}
+	multiplier, ok := byteUnits[strings.ToLower(strings.Trim(value[split:], trimChars))]
+	number, err := strconv.ParseFloat(value[:split], 64)
+	if !ok || err != nil || number < 0 {
+		return 0, errors.New(fmt.Sprintf("'%s' is not a size", value))
+	}
+	size := number * multiplier
+	if size >= math.MaxInt64 {
+		return 0, errors.New(fmt.Sprintf("'%s' is too big", value))
+	}
+	return int64(size), nil
+}
+
+//Get the value of an option as a number of bytes. Sizes can have units like 10KB, 64MiB or 2GB: KB, MB... are powers of 1000 and
+//KiB, MiB... powers of 1024
+func (cfg *CFG) GetBytes(name string) (int64, error) {
+	value, err := cfg.getSingleValue(name)
+	if err != nil {
+		return 0, err
+	}
+	size, err := parseBytes(value)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Option %s: %s", name, err.Error()))
+	}
+	return size, nil
+}
+
+//Get the value of an option as a duration, like "1m30s" (see time.ParseDuration)
+func (cfg *CFG) GetDuration(name string) (time.Duration, error) {
+	value, err := cfg.getSingleValue(name)
+	if err != nil {
+		return 0, err
+	}
+	d, err := parseDuration(value)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Option %s: %s", name, err.Error()))
+	}
+	return d, nil
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(strings.Trim(value, trimChars))
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("'%s' is not a duration", value))
+	}
+	return d, nil
+}
+
+//Set the layouts GetTime tries, in order, to parse the values of the whole tree. RFC3339 is used if none is set
+func (cfg *CFG) SetTimeLayouts(layouts ...string) {
+	cfg.lock.Lock()
+	defer cfg.lock.Unlock()
+	cfg.root().timeLayouts = append([]string{}, layouts...)
+}
+
+//Get the value of an option as a time using the layouts set with SetTimeLayouts
+func (cfg *CFG) GetTime(name string) (time.Time, error) {
+	value, err := cfg.getSingleValue(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	cfg.lock.RLock()
+	layouts := cfg.root().timeLayouts
+	cfg.lock.RUnlock()
+	if len(layouts) == 0 {
+		layouts = []string{time.RFC3339}
+	}
+	value = strings.Trim(value, trimChars)
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New(fmt.Sprintf("Option %s: '%s' is not a time in any of the layouts %s", name, value, strings.Join(layouts, ", ")))
+}
+
+//Error returned by the array getters when one of the values of an option cannot be converted
+type ArrayValueError struct {
+	Option string
+	//Position of the value, starting at 0
+	Index int
+	Err   error
+}
+
+func (e *ArrayValueError) Error() string {
+	return fmt.Sprintf("Option %s: value %d: %s", e.Option, e.Index, e.Err.Error())
+}
+
+func (e *ArrayValueError) Unwrap() error {
+	return e.Err
+}
+
+//Convert every value of an option with parse
+func getArray[T any](cfg *CFG, name string, parse func(value string) (T, error)) ([]T, error) {
+	values, err := cfg.ResolveOption(name)
+	if err != nil {
+		return nil, err
+	}
+	converted := make([]T, len(values))
+	for iV, value := range values {
+		if converted[iV], err = parse(value); err != nil {
+			return nil, &ArrayValueError{name, iV, err}
+		}
+	}
+	return converted, nil
+}
+
+//Get every value of an option (like the ones built with +=) as an int. Fails with an ArrayValueError if any of them is not an int
+func (cfg *CFG) GetIntArray(name string) ([]int, error) {
+	return getArray(cfg, name, parseInt)
+}
+
+//Get every value of an option as a boolean. Fails with an ArrayValueError if any of them is not a boolean
+func (cfg *CFG) GetBoolArray(name string) ([]bool, error) {
+	return getArray(cfg, name, parseBool)
+}
+
+//Get every value of an option as a float64. Fails with an ArrayValueError if any of them is not a number
+func (cfg *CFG) GetFloatArray(name string) ([]float64, error) {
+	return getArray(cfg, name, parseFloat)
+}
+
+//Get every value of an option as a duration. Fails with an ArrayValueError if any of them is not a duration
+func (cfg *CFG) GetDurationArray(name string) ([]time.Duration, error) {
+	return getArray(cfg, name, parseDuration)
+}
+
+//Error returned when an option does not have one of the allowed values
+type EnumError struct {
+	Option  string
+	Value   string
+	Allowed []string
+}
+
+func (e *EnumError) Error() string {
+	return fmt.Sprintf("Option %s has value '%s' but it must be one of: %s", e.Option, e.Value, strings.Join(e.Allowed, ", "))
+}
+
+//Get the value of an option that must be one of the allowed ones. Otherwise an *EnumError is returned
+func (cfg *CFG) GetEnum(name string, allowed ...string) (string, error) {
+	return cfg.getEnum(name, false, allowed)
+}
+
+//Like GetEnum but comparing the values ignoring case. The allowed value is returned with the case it has in allowed
+func (cfg *CFG) GetEnumFold(name string, allowed ...string) (string, error) {
+	return cfg.getEnum(name, true, allowed)
+}
+
+func (cfg *CFG) getEnum(name string, fold bool, allowed []string) (string, error) {
+	value, err := cfg.getSingleValue(name)
+	if err != nil {
+		return "", err
+	}
+	for _, candidate := range allowed {
+		if candidate == value || fold && strings.EqualFold(candidate, value) {
+			return candidate, nil
+		}
+	}
+	return "", &EnumError{name, value, allowed}
+}
+
+//Get the value of an option as an IP address, v4 or v6
+func (cfg *CFG) GetIP(name string) (netip.Addr, error) {
+	value, err := cfg.getSingleValue(name)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	ip, err := netip.ParseAddr(strings.Trim(value, trimChars))
+	if err != nil {
+		return netip.Addr{}, errors.New(fmt.Sprintf("Option %s: '%s' is not an IP address", name, value))
+	}
+	return ip, nil
+}
+
+//Get the value of an option as a network in CIDR notation, like 10.0.0.0/8. Addresses with bits set outside of the mask are rejected
+func (cfg *CFG) GetCIDR(name string) (netip.Prefix, error) {
+	value, err := cfg.getSingleValue(name)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	prefix, err := netip.ParsePrefix(strings.Trim(value, trimChars))
+	if err != nil {
+		return netip.Prefix{}, errors.New(fmt.Sprintf("Option %s: '%s' is not a network in CIDR notation", name, value))
+	}
+	if prefix.Masked() != prefix {
+		return netip.Prefix{}, errors.New(fmt.Sprintf("Option %s: '%s' has bits set after the prefix, did you mean %s?", name, value, prefix.Masked()))
+	}
+	return prefix, nil
+}
+
+//Get the value of an option as an absolute URL, with scheme and host
+func (cfg *CFG) GetURL(name string) (*url.URL, error) {
+	value, err := cfg.getSingleValue(name)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(strings.Trim(value, trimChars))
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Option %s: '%s' is not a URL: %s", name, value, err.Error()))
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.New(fmt.Sprintf("Option %s: '%s' is not an absolute URL", name, value))
+	}
+	return u, nil
+}
+
+//Get the value of an option as a host and port, like "db1:5432" or "[::1]:5432". The port must be a number between 1 and 65535
+func (cfg *CFG) GetHostPort(name string) (string, uint16, error) {
+	value, err := cfg.getSingleValue(name)
+	if err != nil {
+		return "", 0, err
+	}
+	host, portValue, err := net.SplitHostPort(strings.Trim(value, trimChars))
+	if err != nil {
+		return "", 0, errors.New(fmt.Sprintf("Option %s: '%s' is not a host:port", name, value))
+	}
+	port, err := strconv.ParseUint(portValue, 10, 16)
+	if err != nil || port == 0 {
+		return "", 0, errors.New(fmt.Sprintf("Option %s: '%s' is not a valid port", name, portValue))
+	}
+	if host == "" {
+		return "", 0, errors.New(fmt.Sprintf("Option %s: '%s' has no host", name, value))
+	}
+	return host, uint16(port), nil
+}
@@ -0,0 +1,210 @@
+package cfg
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetBytes(t *testing.T) {
+	data := "std = aGVsbG8/Pz4+\nraw = aGVsbG8\nurl = aGVsbG8_Pz4-\nhex = 68656c6c6f\nprefixed = 0x68656C6C6F\nbad = zz\nmulti = aa\nmulti += bb\n"
+	cfg, err := NewCFGFromString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]string{"std": "hello??>>", "raw": "hello", "url": "hello??>>"} {
+		if v, err := cfg.GetBytesBase64(name); err != nil || !bytes.Equal(v, []byte(expected)) {
+			t.Errorf("Unexpected base64 decoding of %s: '%s' %v", name, v, err)
+		}
+	}
+	for _, name := range []string{"hex", "prefixed"} {
+		if v, err := cfg.GetBytesHex(name); err != nil || string(v) != "hello" {
+			t.Errorf("Unexpected hex decoding of %s: '%s' %v", name, v, err)
+		}
+	}
+	for _, name := range []string{"bad", "multi", "missing"} {
+		if _, err := cfg.GetBytesHex(name); err == nil {
+			t.Error("Decoded invalid hex option", name)
+		}
+	}
+	if _, err := cfg.GetBytesBase64("hex!"); err == nil {
+		t.Error("Decoded a missing option")
+	}
+	cfg.SetOption("bad64", "a$b=", "")
+	if _, err := cfg.GetBytesBase64("bad64"); err == nil {
+		t.Error("Decoded invalid base64")
+	}
+}
+
+func TestGetBool(t *testing.T) {
+	cfg, _ := NewCFGFromString("a = Yes\nb = off\nc = 1\nd = FALSE\ne = maybe\nf = on\nf += off\n")
+	for name, expected := range map[string]bool{"a": true, "b": false, "c": true, "d": false} {
+		if v, err := cfg.GetBool(name); err != nil || v != expected {
+			t.Errorf("Unexpected value for %s: %v %v", name, v, err)
+		}
+	}
+	for _, name := range []string{"e", "f", "missing"} {
+		if _, err := cfg.GetBool(name); err == nil {
+			t.Error("Read an invalid boolean from", name)
+		}
+	}
+	cfg.SetBool("a", false, "")
+	if v, _ := cfg.GetOption("a"); v != "false" {
+		t.Error("Unexpected canonical form:", v)
+	}
+}
+
+func TestGetNumbers(t *testing.T) {
+	cfg, _ := NewCFGFromString("a = 42\nb = -0x10\nc = 1_000\nd = 2.5\ne = ten\nf = 1e3\ng = 010\nh = 08080\ni = 0o10\nj = -007\n")
+	for name, expected := range map[string]int{"a": 42, "b": -16, "c": 1000, "g": 10, "h": 8080, "i": 8, "j": -7} {
+		if v, err := cfg.GetInt(name); err != nil || v != expected {
+			t.Errorf("Unexpected int for %s: %v %v", name, v, err)
+		}
+	}
+	for _, name := range []string{"d", "e", "missing"} {
+		if _, err := cfg.GetInt(name); err == nil {
+			t.Error("Read an invalid int from", name)
+		}
+	}
+	for name, expected := range map[string]float64{"a": 42, "d": 2.5, "f": 1000} {
+		if v, err := cfg.GetFloat64(name); err != nil || v != expected {
+			t.Errorf("Unexpected float for %s: %v %v", name, v, err)
+		}
+	}
+	if _, err := cfg.GetFloat64("e"); err == nil {
+		t.Error("Read an invalid float")
+	}
+	if cfg.GetIntValue("a", 7) != 42 || cfg.GetIntValue("e", 7) != 7 || cfg.GetIntValue("missing", 7) != 7 {
+		t.Error("Unexpected int values")
+	}
+	if !cfg.GetBoolValue("missing", true) || cfg.GetBoolValue("e", false) {
+		t.Error("Unexpected bool values")
+	}
+}
+
+func TestGetBytesSize(t *testing.T) {
+	cfg, _ := NewCFGFromString("a = 512\nb = 10KB\nc = 64MiB\nd = 2 gb\ne = 1.5KiB\nf = 10XB\ng = -1KB\nh = KB\ni = 100000PB\n")
+	for name, expected := range map[string]int64{"a": 512, "b": 10000, "c": 64 << 20, "d": 2000000000, "e": 1536} {
+		if v, err := cfg.GetBytes(name); err != nil || v != expected {
+			t.Errorf("Unexpected size for %s: %v %v", name, v, err)
+		}
+	}
+	for _, name := range []string{"f", "g", "h", "i", "missing"} {
+		if _, err := cfg.GetBytes(name); err == nil {
+			t.Error("Read an invalid size from", name)
+		}
+	}
+}
+
+func TestGetArrays(t *testing.T) {
+	cfg, _ := NewCFGFromString("ports = 80\nports += 443\nflags = on\nflags += no\nratios = 0.5\nratios += 1\nwaits = 1s\nwaits += 2m\nbad = 1\nbad += two\nbad += 3\n")
+	if v, err := cfg.GetIntArray("ports"); err != nil || !reflect.DeepEqual(v, []int{80, 443}) {
+		t.Error("Unexpected ints:", v, err)
+	}
+	if v, err := cfg.GetBoolArray("flags"); err != nil || !reflect.DeepEqual(v, []bool{true, false}) {
+		t.Error("Unexpected bools:", v, err)
+	}
+	if v, err := cfg.GetFloatArray("ratios"); err != nil || !reflect.DeepEqual(v, []float64{0.5, 1}) {
+		t.Error("Unexpected floats:", v, err)
+	}
+	if v, err := cfg.GetDurationArray("waits"); err != nil || !reflect.DeepEqual(v, []time.Duration{time.Second, 2 * time.Minute}) {
+		t.Error("Unexpected durations:", v, err)
+	}
+	_, err := cfg.GetIntArray("bad")
+	var valueErr *ArrayValueError
+	if !errors.As(err, &valueErr) || valueErr.Index != 1 || valueErr.Option != "bad" {
+		t.Fatal("Unexpected error:", err)
+	}
+	if err.Error() != "Option bad: value 1: 'two' is not an integer" {
+		t.Error("Unexpected message:", err)
+	}
+	if _, err := cfg.GetIntArray("missing"); !errors.Is(err, ErrNotFound) {
+		t.Error("Read a missing option:", err)
+	}
+}
+
+func TestGetNetwork(t *testing.T) {
+	cfg, _ := NewCFGFromString("ip = 10.0.0.1\nip6 = ::1\nnet = 10.0.0.0/8\nhost = 10.1.0.0/8\nurl = https://example.com/api\nrel = /api\naddr = db1:5432\naddr6 = [::1]:80\nnoport = db1\nbigport = db1:70000\n")
+	if ip, err := cfg.GetIP("ip"); err != nil || ip != netip.MustParseAddr("10.0.0.1") {
+		t.Error("Unexpected IP:", ip, err)
+	}
+	if ip, err := cfg.GetIP("ip6"); err != nil || !ip.Is6() {
+		t.Error("Unexpected IPv6:", ip, err)
+	}
+	if _, err := cfg.GetIP("net"); err == nil || !strings.Contains(err.Error(), "Option net") {
+		t.Error("Read a network as an IP:", err)
+	}
+	if prefix, err := cfg.GetCIDR("net"); err != nil || prefix.Bits() != 8 {
+		t.Error("Unexpected network:", prefix, err)
+	}
+	if _, err := cfg.GetCIDR("host"); err == nil || !strings.Contains(err.Error(), "10.0.0.0/8") {
+		t.Error("Read a network with host bits:", err)
+	}
+	if u, err := cfg.GetURL("url"); err != nil || u.Host != "example.com" || u.Path != "/api" {
+		t.Error("Unexpected URL:", u, err)
+	}
+	if _, err := cfg.GetURL("rel"); err == nil {
+		t.Error("Read a relative URL")
+	}
+	if host, port, err := cfg.GetHostPort("addr"); err != nil || host != "db1" || port != 5432 {
+		t.Error("Unexpected address:", host, port, err)
+	}
+	if host, port, err := cfg.GetHostPort("addr6"); err != nil || host != "::1" || port != 80 {
+		t.Error("Unexpected IPv6 address:", host, port, err)
+	}
+	for _, name := range []string{"noport", "bigport", "missing"} {
+		if _, _, err := cfg.GetHostPort(name); err == nil {
+			t.Error("Read an invalid address from", name)
+		}
+	}
+}
+
+func TestGetDurationAndTime(t *testing.T) {
+	cfg, _ := NewCFGFromString("timeout = 1m30s\nstart = 2026-03-01T18:00:00Z\nday = 2026-03-01\nbad = soon\n")
+	if d, err := cfg.GetDuration("timeout"); err != nil || d != 90*time.Second {
+		t.Error("Unexpected duration:", d, err)
+	}
+	if _, err := cfg.GetDuration("bad"); err == nil {
+		t.Error("Read an invalid duration")
+	}
+	start := time.Date(2026, 3, 1, 18, 0, 0, 0, time.UTC)
+	if v, err := cfg.GetTime("start"); err != nil || !v.Equal(start) {
+		t.Error("Unexpected time:", v, err)
+	}
+	if _, err := cfg.GetTime("day"); err == nil {
+		t.Error("Read a time in a layout that is not set")
+	}
+	cfg.SetTimeLayouts(time.RFC3339, "2006-01-02")
+	if v, err := cfg.GetTime("day"); err != nil || !v.Equal(start.Truncate(24*time.Hour)) {
+		t.Error("Unexpected day:", v, err)
+	}
+	if v, err := cfg.GetTime("start"); err != nil || !v.Equal(start) {
+		t.Error("Unexpected time with several layouts:", v, err)
+	}
+}
+
+func TestGetEnum(t *testing.T) {
+	cfg, _ := NewCFGFromString("Level = Debug\nPolicy = fifo\n")
+	if v, err := cfg.GetEnum("Policy", "fifo", "lifo"); err != nil || v != "fifo" {
+		t.Error("Unexpected value:", v, err)
+	}
+	_, err := cfg.GetEnum("Level", "debug", "info", "error")
+	enumErr, ok := err.(*EnumError)
+	if !ok || enumErr.Value != "Debug" || len(enumErr.Allowed) != 3 {
+		t.Fatal("Unexpected error:", err)
+	}
+	if err.Error() != "Option Level has value 'Debug' but it must be one of: debug, info, error" {
+		t.Error("Unexpected message:", err)
+	}
+	if v, err := cfg.GetEnumFold("Level", "debug", "info", "error"); err != nil || v != "debug" {
+		t.Error("Unexpected value:", v, err)
+	}
+	allowed := []string{"fifo", "lifo"}
+	if _, err := cfg.GetEnum("Missing", allowed...); !errors.Is(err, ErrNotFound) {
+		t.Error("Missing option read:", err)
+	}
+}
@@ -0,0 +1,93 @@
+package cfg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+//Access to the files of a git repository. Implement it on top of go-git or any other library to avoid depending on the git binary
+type GitReader interface {
+	//Resolve a reference (branch, tag or hash) to a commit hash
+	ResolveRef(ref string) (string, error)
+	//Read a file as it is in a commit
+	ReadFile(commit string, path string) ([]byte, error)
+}
+
+//GitReader using the git command line tool on a local clone
+type GitCLI struct {
+	//Directory of the repository
+	Dir string
+	//Git binary to run. "git" if empty
+	Binary string
+}
+
+func (g *GitCLI) run(args ...string) ([]byte, error) {
+	binary := g.Binary
+	if binary == "" {
+		binary = "git"
+	}
+	cmd := exec.Command(binary, append([]string{"-C", g.Dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.Trim(stderr.String(), trimChars); msg != "" {
+			return nil, errors.New("git " + args[0] + " failed: " + msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+func (g *GitCLI) ResolveRef(ref string) (string, error) {
+	if strings.HasPrefix(ref, "-") {
+		return "", errors.New("Invalid git reference " + ref)
+	}
+	out, err := g.run("rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", errors.New("Cannot resolve git reference " + ref)
+	}
+	return strings.Trim(string(out), trimChars), nil
+}
+
+func (g *GitCLI) ReadFile(commit string, path string) ([]byte, error) {
+	return g.run("show", commit+":"+strings.TrimPrefix(path, "/"))
+}
+
+//Load the cfg file at path as it is in the given git reference. The commit the reference resolved to is returned as provenance
+func LoadFromGit(reader GitReader, ref string, path string) (cfg *CFG, commit string, err error) {
+	if commit, err = reader.ResolveRef(ref); err != nil {
+		return nil, "", err
+	}
+	data, err := reader.ReadFile(commit, path)
+	if err != nil {
+		return nil, commit, err
+	}
+	cfg, err = NewCFGFromReader(bytes.NewReader(data))
+	return cfg, commit, err
+}
+
+//Source reading a cfg file from a git reference, so a Refresher can follow a branch
+type GitSource struct {
+	Reader GitReader
+	Ref    string
+	Path   string
+	//Commit the contents were last fetched from
+	Commit string
+}
+
+func (s *GitSource) Fetch(ctx context.Context) ([]byte, error) {
+	commit, err := s.Reader.ResolveRef(s.Ref)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.Reader.ReadFile(commit, s.Path)
+	if err != nil {
+		return nil, err
+	}
+	s.Commit = commit
+	return data, nil
+}
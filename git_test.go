@@ -0,0 +1,53 @@
+package cfg
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadFromGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	dir, err := ioutil.TempDir("", "cfggit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatal(string(out))
+		}
+	}
+	git("init", "-q")
+	ioutil.WriteFile(filepath.Join(dir, "app.cfg"), []byte("op = v1"), 0644)
+	git("add", "app.cfg")
+	git("commit", "-q", "-m", "v1")
+	git("tag", "v1")
+	ioutil.WriteFile(filepath.Join(dir, "app.cfg"), []byte("op = v2"), 0644)
+	git("commit", "-q", "-a", "-m", "v2")
+	reader := &GitCLI{Dir: dir}
+	cfg, commit, err := LoadFromGit(reader, "v1", "app.cfg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := cfg.GetValue("op", ""); v != "v1" || len(commit) < 40 {
+		t.Error("Unexpected contents or commit:", v, commit)
+	}
+	head, _ := reader.ResolveRef("HEAD")
+	source := &GitSource{Reader: reader, Ref: "HEAD", Path: "/app.cfg"}
+	if data, err := source.Fetch(context.Background()); err != nil || string(data) != "op = v2" || source.Commit != head {
+		t.Error("Unexpected fetch:", string(data), err, source.Commit)
+	}
+	if _, _, err := LoadFromGit(reader, "nope", "app.cfg"); err == nil {
+		t.Error("Loaded from an unknown ref")
+	}
+	if _, _, err := LoadFromGit(reader, "v1", "nope.cfg"); err == nil {
+		t.Error("Loaded an unknown file")
+	}
+}
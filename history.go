@@ -0,0 +1,101 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+//Changes done to a tree in a single modification
+type historyBatch struct {
+	at     time.Time
+	events []ChangeEvent
+}
+
+//Snapshot of a tree and the journal of the changes done since. It lives in the root
+type history struct {
+	retention time.Duration
+	//Tree as it was at baseTime
+	base     *CFG
+	baseTime time.Time
+	batches  []historyBatch
+	clock    func() time.Time
+}
+
+//Keep the changes done to the tree during retention so At can rebuild it as it was at any instant since then.
+//Older changes are folded into a snapshot of the tree as they expire. Comments are not kept. A retention of 0 disables the history
+func (cfg *CFG) EnableHistory(retention time.Duration) {
+	cfg.lock.Lock()
+	defer cfg.lock.Unlock()
+	root := cfg.root()
+	if retention <= 0 {
+		root.history = nil
+		return
+	}
+	if root.history != nil {
+		root.history.retention = retention
+		return
+	}
+	base := root.copyTree()
+	base.setLock(new(sync.RWMutex))
+	root.history = &history{retention: retention, base: base, baseTime: time.Now(), clock: time.Now}
+}
+
+//Journal the changes of a modification of root and fold the ones that are too old into the snapshot
+func (h *history) add(root *CFG, events []ChangeEvent) {
+	now := h.clock()
+	h.batches = append(h.batches, historyBatch{now, events})
+	limit := now.Add(-h.retention)
+	expired := 0
+	for expired < len(h.batches) && h.batches[expired].at.Before(limit) {
+		if err := h.base.replayBatch(h.batches[expired].events); err != nil {
+			//The snapshot cannot be rolled forward, start again from the current tree
+			h.base = root.copyTree()
+			h.base.setLock(new(sync.RWMutex))
+			h.baseTime = now
+			h.batches = nil
+			return
+		}
+		h.baseTime = h.batches[expired].at
+		expired++
+	}
+	h.batches = append(h.batches[:0:0], h.batches[expired:]...)
+}
+
+func (cfg *CFG) replayBatch(events []ChangeEvent) error {
+	for _, event := range events {
+		if event.Kind == ChangesCoalesced || event.Kind == RolledBack {
+			continue
+		}
+		if err := cfg.replayChange(event.Kind, SplitPath(event.Path), event.Value); err != nil {
+			return errors.New(fmt.Sprintf("Cannot replay %s %s: %s", event.Kind, event.Path, err.Error()))
+		}
+	}
+	return nil
+}
+
+//Rebuild the whole tree as it was at t, which has to be within the retention set with EnableHistory.
+//The tree returned is independent of this one and has no comments for the options changed since the history was enabled
+func (cfg *CFG) At(t time.Time) (*CFG, error) {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	h := cfg.root().history
+	if h == nil {
+		return nil, errors.New("History is not enabled")
+	}
+	if t.Before(h.baseTime) {
+		return nil, errors.New(fmt.Sprintf("%s is before the oldest time kept (%s)", t.Format(time.RFC3339Nano), h.baseTime.Format(time.RFC3339Nano)))
+	}
+	tree := h.base.copyTree()
+	tree.setLock(new(sync.RWMutex))
+	for _, batch := range h.batches {
+		if batch.at.After(t) {
+			break
+		}
+		if err := tree.replayBatch(batch.events); err != nil {
+			return nil, err
+		}
+	}
+	return tree, nil
+}
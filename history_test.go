@@ -0,0 +1,55 @@
+package cfg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryAt(t *testing.T) {
+	cfg, _ := NewCFGFromString("DB {\n\tHost = db1\n}\nBase {\n}\n")
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	now := start
+	cfg.EnableHistory(time.Hour)
+	h := cfg.root().history
+	h.clock = func() time.Time { return now }
+	h.baseTime = start
+	now = start.Add(10 * time.Minute)
+	cfg.SetOption("DB/Host", "db2", "")
+	now = start.Add(20 * time.Minute)
+	cfg.CreateSection("Site", "")
+	cfg.getSection("Site", false).SetInheritance("Base")
+	now = start.Add(30 * time.Minute)
+	cfg.Remove("DB")
+	for offset, expected := range map[time.Duration]string{
+		5 * time.Minute:  "DB {\n\tHost = db1\n}\nBase {\n}\n",
+		15 * time.Minute: "DB {\n\tHost = db2\n}\nBase {\n}\n",
+		25 * time.Minute: "DB {\n\tHost = db2\n}\nBase {\n}\nSite {< Base\n}\n",
+		time.Hour:        "Base {\n}\nSite {< Base\n}\n",
+	} {
+		tree, err := cfg.At(start.Add(offset))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tree.String() != expected {
+			t.Errorf("Unexpected tree %v after the start: %s", offset, tree.String())
+		}
+	}
+	if _, err := cfg.At(start.Add(-time.Minute)); err == nil {
+		t.Error("Rebuilt a tree from before the history")
+	}
+	//Changes older than the retention are folded into the snapshot
+	now = start.Add(75 * time.Minute)
+	cfg.SetOption("New", "x", "")
+	if len(h.batches) != 4 || !h.baseTime.Equal(start.Add(10*time.Minute)) {
+		t.Error("Expired changes not folded:", len(h.batches), h.baseTime)
+	}
+	if _, err := cfg.At(start.Add(5 * time.Minute)); err == nil {
+		t.Error("Rebuilt a tree older than the retention")
+	}
+	if tree, _ := cfg.At(start.Add(15 * time.Minute)); tree.String() != "DB {\n\tHost = db2\n}\nBase {\n}\n" {
+		t.Error("Unexpected tree from the snapshot:", tree.String())
+	}
+	if tree, _ := cfg.At(now); !tree.Equal(cfg) {
+		t.Error("Current tree not rebuilt:", tree.String())
+	}
+}
@@ -0,0 +1,275 @@
+package cfg
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	ContentTypeCFG  = "text/cfg"
+	ContentTypeJSON = "application/json"
+	ContentTypeText = "text/plain"
+)
+
+//Options for the HTTP handler
+type HandlerOptions struct {
+	//URL prefix under which paths are served. Defaults to "/cfg/"
+	Prefix string
+	//Called before serving any request with the cfg path being accessed. Returning an error denies the request with a 403
+	Authorize func(r *http.Request, path string) error
+	//Reject PUT and DELETE requests
+	ReadOnly bool
+	//Identity of whoever makes a request. If set, PUT and DELETE requests are sent to the audit sink of the cfg with it (see SetAuditSink)
+	Actor func(r *http.Request) string
+}
+
+type handler struct {
+	cfg  *CFG
+	opts HandlerOptions
+}
+
+//Create an http.Handler serving the cfg under opts.Prefix:
+//GET returns a section (text/cfg or application/json as negotiated via Accept) or an option (text/plain with a value per line or application/json),
+//PUT replaces a section (text/cfg or JSON object body) or an option (JSON string or array, or text/plain with a value per line),
+//DELETE removes a section or option.
+//Responses carry the hash of the whole tree as ETag and PUT/DELETE honour If-Match for optimistic concurrency
+func NewHandler(cfg *CFG, opts HandlerOptions) http.Handler {
+	if opts.Prefix == "" {
+		opts.Prefix = "/cfg/"
+	}
+	return &handler{cfg, opts}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, h.opts.Prefix) && r.URL.Path+"/" != h.opts.Prefix {
+		http.NotFound(w, r)
+		return
+	}
+	p := SplitPath(strings.TrimPrefix(r.URL.Path, h.opts.Prefix))
+	path := SplitChar + strings.Join(p, SplitChar)
+	if h.opts.Authorize != nil {
+		if err := h.opts.Authorize(r, path); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+	switch r.Method {
+	case "GET", "HEAD":
+		h.get(w, r, p)
+	case "PUT", "DELETE":
+		if h.opts.Actor != nil {
+			r = r.WithContext(WithActor(r.Context(), h.opts.Actor(r)))
+		}
+		if h.opts.ReadOnly {
+			http.Error(w, "Read only configuration", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Method == "PUT" {
+			h.put(w, r, p)
+		} else {
+			h.delete(w, r, p)
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+//Choose the response content type from the Accept header
+func negotiate(r *http.Request, offers ...string) string {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return offers[0]
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.Trim(part, trimChars))
+		if err != nil {
+			continue
+		}
+		for _, offer := range offers {
+			if mediaType == offer || mediaType == "*/*" || (strings.HasSuffix(mediaType, "/*") && strings.HasPrefix(offer, mediaType[:len(mediaType)-1])) {
+				return offer
+			}
+		}
+	}
+	return ""
+}
+
+var errPreconditionFailed = errors.New("Configuration has been modified")
+
+func setETag(w http.ResponseWriter, hash string) {
+	w.Header().Set("ETag", `"`+hash+`"`)
+}
+
+//Check the If-Match header against the current tree hash
+func checkPrecondition(w http.ResponseWriter, r *http.Request, hash string) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" || ifMatch == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if strings.Trim(strings.Trim(tag, trimChars), `"`) == hash {
+			return true
+		}
+	}
+	setETag(w, hash)
+	http.Error(w, errPreconditionFailed.Error(), http.StatusPreconditionFailed)
+	return false
+}
+
+func (h *handler) get(w http.ResponseWriter, r *http.Request, p []string) {
+	h.cfg.lock.RLock()
+	defer h.cfg.lock.RUnlock()
+	var sec *CFG
+	var opt *option
+	if len(p) == 0 {
+		sec = h.cfg
+	} else {
+		sec, opt = h.cfg.get(p, true, 0)
+	}
+	if sec == nil && opt == nil {
+		http.NotFound(w, r)
+		return
+	}
+	var buf bytes.Buffer
+	var contentType string
+	if sec != nil {
+		contentType = negotiate(r, ContentTypeCFG, ContentTypeJSON)
+		switch contentType {
+		case ContentTypeCFG:
+			sec.dumpToWriter(&buf, 0)
+		case ContentTypeJSON:
+			writeJSONSection(&buf, sec)
+		}
+	} else {
+		contentType = negotiate(r, ContentTypeText, ContentTypeJSON)
+		switch contentType {
+		case ContentTypeText:
+			buf.WriteString(strings.Join(opt.value, "\n") + "\n")
+		case ContentTypeJSON:
+			writeJSONOption(&buf, opt)
+		}
+	}
+	if contentType == "" {
+		http.Error(w, "Not acceptable", http.StatusNotAcceptable)
+		return
+	}
+	setETag(w, h.cfg.root().hash())
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != "HEAD" {
+		w.Write(buf.Bytes())
+	}
+}
+
+//Decode a PUT body into a section or option value
+func decodeBody(r *http.Request) (*patchValue, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	contentType := ContentTypeCFG
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if contentType, _, err = mime.ParseMediaType(ct); err != nil {
+			return nil, err
+		}
+	}
+	switch contentType {
+	case ContentTypeCFG:
+		sec, err := NewCFGFromReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		return &patchValue{section: sec}, nil
+	case ContentTypeJSON:
+		return decodeJSONValue(body)
+	case ContentTypeText:
+		return &patchValue{values: strings.Split(strings.TrimRight(string(body), "\r\n"), "\n")}, nil
+	}
+	return nil, errors.New("Unsupported content type " + contentType)
+}
+
+func (h *handler) put(w http.ResponseWriter, r *http.Request, p []string) {
+	value, err := decodeBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = h.cfg.audited(r.Context(), "PUT", strings.Join(p, SplitChar), func() error {
+		root := h.cfg.root()
+		if !checkPrecondition(w, r, root.hash()) {
+			return errPreconditionFailed
+		}
+		status := http.StatusNoContent
+		if len(p) == 0 {
+			if value.section == nil {
+				http.Error(w, "The root can only be replaced by a section", http.StatusBadRequest)
+				return errors.New("The root can only be replaced by a section")
+			}
+			if err := root.checkNames(value.section); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return err
+			}
+			root.normalizeSection("", value.section)
+			if err := h.cfg.checkReplacement(value.section); err != nil {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return err
+			}
+			h.cfg.replaceContents(value.section)
+		} else {
+			//Do not leave behind the sections created for the value if it cannot be set
+			backup := h.cfg.copyTree()
+			mark := h.cfg.recordMark()
+			parent, err := h.cfg.ensureSection(p[:len(p)-1])
+			if err == nil {
+				if parent.sections[p[len(p)-1]] == nil && parent.options[p[len(p)-1]] == nil {
+					status = http.StatusCreated
+				}
+				err = parent.patchAdd(p[len(p)-1:], value, false)
+			}
+			if err != nil {
+				h.cfg.replaceContents(backup)
+				h.cfg.dropRecorded(mark)
+				http.Error(w, err.Error(), http.StatusConflict)
+				return err
+			}
+		}
+		setETag(w, root.hash())
+		w.WriteHeader(status)
+		return nil
+	})
+	if err == ErrFrozen {
+		http.Error(w, err.Error(), http.StatusForbidden)
+	}
+}
+
+func (h *handler) delete(w http.ResponseWriter, r *http.Request, p []string) {
+	if len(p) == 0 {
+		http.Error(w, "The root cannot be removed", http.StatusBadRequest)
+		return
+	}
+	err := h.cfg.audited(r.Context(), "DELETE", strings.Join(p, SplitChar), func() error {
+		root := h.cfg.root()
+		if sec, opt := h.cfg.get(p, false, 0); sec == nil && opt == nil {
+			http.NotFound(w, r)
+			return newError(ErrNotFound, strings.Join(p, SplitChar)+" does not exist")
+		}
+		if !checkPrecondition(w, r, root.hash()) {
+			return errPreconditionFailed
+		}
+		if err := h.cfg.remove(p); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return err
+		}
+		setETag(w, root.hash())
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	if err == ErrFrozen {
+		http.Error(w, err.Error(), http.StatusForbidden)
+	}
+}
@@ -0,0 +1,117 @@
+package cfg
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func doRequest(h http.Handler, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestHandler(t *testing.T) {
+	cfg, err := NewCFGFromString("s1 {\nop1 = a\nop1 += b\n}\ns2 {< s1\n}\nsecret = x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(cfg, HandlerOptions{Authorize: func(r *http.Request, path string) error {
+		if path == "/secret" {
+			return errors.New("Forbidden")
+		}
+		return nil
+	}})
+	w := doRequest(h, "GET", "/cfg/s1", "", nil)
+	if w.Code != 200 || w.Body.String() != "op1 = a\nop1 += b\n" || w.Header().Get("Content-Type") != "text/cfg; charset=utf-8" {
+		t.Error("Unexpected section response:", w.Code, w.Body.String())
+	}
+	etag := w.Header().Get("ETag")
+	if etag != `"`+cfg.Hash()+`"` {
+		t.Error("Unexpected ETag:", etag)
+	}
+	w = doRequest(h, "GET", "/cfg/s2/op1", "", map[string]string{"Accept": "application/json"})
+	if w.Code != 200 || w.Body.String() != `["a","b"]` {
+		t.Error("Unexpected option response:", w.Code, w.Body.String())
+	}
+	w = doRequest(h, "GET", "/cfg/", "", map[string]string{"Accept": "application/json, text/cfg"})
+	if w.Code != 200 || w.Body.String() != `{"s1":{"op1":["a","b"]},"s2":{},"secret":"x"}` {
+		t.Error("Unexpected root response:", w.Code, w.Body.String())
+	}
+	for path, code := range map[string]int{"/cfg/nope": 404, "/cfg/secret": 403, "/other": 404} {
+		if w = doRequest(h, "GET", path, "", nil); w.Code != code {
+			t.Errorf("Unexpected status for %s: %d", path, w.Code)
+		}
+	}
+	if w = doRequest(h, "GET", "/cfg/s1", "", map[string]string{"Accept": "image/png"}); w.Code != 406 {
+		t.Error("Unexpected status for an unacceptable type:", w.Code)
+	}
+	w = doRequest(h, "PUT", "/cfg/s1/op2", `"c"`, map[string]string{"Content-Type": "application/json", "If-Match": etag})
+	if w.Code != 201 {
+		t.Error("Unexpected PUT status:", w.Code, w.Body.String())
+	}
+	if w = doRequest(h, "PUT", "/cfg/s1/op2", `d`, map[string]string{"Content-Type": "text/plain", "If-Match": etag}); w.Code != 412 {
+		t.Error("Modification with a stale ETag was accepted:", w.Code)
+	}
+	w = doRequest(h, "PUT", "/cfg/s1", "op3 = e\n", map[string]string{"Content-Type": "text/cfg", "If-Match": w.Header().Get("ETag")})
+	if w.Code != 204 {
+		t.Error("Unexpected PUT status:", w.Code, w.Body.String())
+	}
+	if v := cfg.GetValue("s2/op3", ""); v != "e" || cfg.Exists("s1/op1") {
+		t.Error("Section was not replaced keeping inheritance:", cfg)
+	}
+	if w = doRequest(h, "PUT", "/cfg/s3/s31/op", "v1\nv2\n", map[string]string{"Content-Type": "text/plain"}); w.Code != 201 {
+		t.Error("Unexpected PUT status:", w.Code, w.Body.String())
+	}
+	if v := cfg.GetValueArray("s3/s31/op", nil); !equalSlices(v, []string{"v1", "v2"}) {
+		t.Error("Unexpected values:", v)
+	}
+	if w = doRequest(h, "PUT", "/cfg/s4", `{"a=b{c":"x"}`, map[string]string{"Content-Type": "application/json"}); w.Code != 409 || cfg.Exists("s4") {
+		t.Error("PUT created an invalid name:", w.Code, w.Body.String())
+	}
+	if w = doRequest(h, "PUT", "/cfg/", `{"x/y":"z"}`, map[string]string{"Content-Type": "application/json"}); w.Code != 400 || !cfg.Exists("s2") {
+		t.Error("PUT replaced the root with an invalid name:", w.Code, w.Body.String())
+	}
+	if w = doRequest(h, "DELETE", "/cfg/s1", "", nil); w.Code != 409 {
+		t.Error("Removed an inherited section:", w.Code)
+	}
+	if w = doRequest(h, "DELETE", "/cfg/s3", "", nil); w.Code != 204 || cfg.Exists("s3") {
+		t.Error("Unexpected DELETE status:", w.Code)
+	}
+	if w = doRequest(h, "POST", "/cfg/s1", "", nil); w.Code != 405 {
+		t.Error("Unexpected POST status:", w.Code)
+	}
+	ro := NewHandler(cfg, HandlerOptions{ReadOnly: true, Prefix: "/conf/"})
+	if w = doRequest(ro, "DELETE", "/conf/s2", "", nil); w.Code != 405 {
+		t.Error("Read only handler allowed a DELETE:", w.Code)
+	}
+}
+
+func TestHandlerPutChecks(t *testing.T) {
+	data := "DB {\n\t#locked\n\tPool = 40\n}\nPort:int = 1\n"
+	cfg, _ := NewCFGFromString(data)
+	h := NewHandler(cfg, HandlerOptions{})
+	text := map[string]string{"Content-Type": "text/plain"}
+	if w := doRequest(h, "PUT", "/cfg/N1/N2/a:int", "x", text); w.Code != 409 || cfg.Exists("N1") {
+		t.Error("Failed PUT left sections behind:", w.Code, cfg.String())
+	}
+	tree := map[string]string{"Content-Type": "text/cfg"}
+	for _, body := range []string{"DB {\n\tPool = 10\n}\nPort = 1\n", "Port = 1\n", "DB {\n\t#locked\n\tPool = 40\n}\nPort = abc\n"} {
+		if w := doRequest(h, "PUT", "/cfg/", body, tree); w.Code != 409 || cfg.String() != data {
+			t.Errorf("PUT of %q replaced the root: %d %s", body, w.Code, cfg.String())
+		}
+	}
+	if w := doRequest(h, "PUT", "/cfg/", "DB {\n\t#locked\n\tPool = 40\n}\nPort = 2\n", tree); w.Code != 204 {
+		t.Error("Unexpected PUT status:", w.Code, w.Body.String())
+	}
+	if expected := "DB {\n\t#locked\n\tPool = 40\n}\nPort:int = 2\n"; cfg.String() != expected {
+		t.Error("Root replacement lost the declared type:", cfg.String())
+	}
+}
@@ -0,0 +1,66 @@
+package cfg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//Directives loading the contents of another file into the current section: "@include common.cfg" fails if the file does not exist
+//while "@include? local.cfg" skips it recording a warning that can be read with LoadWarnings. Relative paths are relative to the
+//directory of the file being loaded, or the working directory when loading from a reader. Dumps contain the included contents
+const (
+	includeDirective         = "@include"
+	optionalIncludeDirective = "@include?"
+)
+
+//Is the line an include directive?
+func isInclude(line string) bool {
+	return strings.HasPrefix(line, includeDirective+" ") || strings.HasPrefix(line, optionalIncludeDirective+" ")
+}
+
+//Load the file of an include directive into this section
+func (cfg *CFG) processInclude(line string, line_number int, inheritance_links *[]inheritanceLink) error {
+	optional := strings.HasPrefix(line, optionalIncludeDirective+" ")
+	path := strings.Trim(line[len(includeDirective):], trimChars)
+	if optional {
+		path = strings.Trim(line[len(optionalIncludeDirective):], trimChars)
+	}
+	root := cfg.root()
+	if !filepath.IsAbs(path) && root.loading != "" {
+		path = filepath.Join(filepath.Dir(root.loading), path)
+	}
+	//Sources being loaded, from the outermost one to the one with the directive
+	loading := append(root.including, root.loading)
+	for _, source := range loading {
+		if source == path {
+			return errors.New("Include loop found: " + strings.Join(append(loading, path), " > "))
+		}
+	}
+	fi, err := os.Open(path)
+	if err != nil {
+		if optional && os.IsNotExist(err) {
+			root.warnings = append(root.warnings, "Optional include "+path+" does not exist")
+			if root.logger != nil {
+				root.logger.Warn("Optional include does not exist", "path", path, "origin", root.loading, "line", line_number)
+			}
+			return nil
+		}
+		return err
+	}
+	defer fi.Close()
+	root.including = loading
+	defer func() {
+		root.loading = loading[len(loading)-1]
+		root.including = loading[:len(loading)-1]
+	}()
+	return cfg.loadSource(fi, path, inheritance_links)
+}
+
+//Warnings recorded while loading contents into this tree, like the optional includes that were skipped
+func (cfg *CFG) LoadWarnings() []string {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	return append([]string{}, cfg.root().warnings...)
+}
@@ -0,0 +1,74 @@
+package cfg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfginclude")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "conf"), 0755)
+	files := map[string]string{
+		"main.cfg":        "Name = app\nDB {\n\t@include conf/db.cfg\n\tUser = app\n}\n@include? local.cfg\n",
+		"conf/db.cfg":     "Host = db1\n@include port.cfg\n",
+		"conf/port.cfg":   "Port = 3306\n",
+		"loop.cfg":        "@include loop2.cfg\n",
+		"loop2.cfg":       "a = 1\n@include loop.cfg\n",
+		"missing.cfg":     "a = 1\n@include nothere.cfg\n",
+		"badinclude.cfg":  "A {\n\t@include bad.cfg\n}\n",
+		"bad.cfg":         "a = 1\na = 2\n",
+		"conf/extra.cfg":  "Extra = yes\n",
+		"conf/nested.cfg": "@include? extra.cfg\n",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg, err := NewCFGFromFile(filepath.Join(dir, "main.cfg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "Name = app\nDB {\n\tHost = db1\n\tPort = 3306\n\tUser = app\n}\n"; cfg.String() != expected {
+		t.Error("Unexpected contents:", cfg.String())
+	}
+	if source, _ := cfg.OptionSource("DB/Port"); source != filepath.Join(dir, "conf", "port.cfg") {
+		t.Error("Unexpected source:", source)
+	}
+	if source, _ := cfg.OptionSource("DB/User"); source != filepath.Join(dir, "main.cfg") {
+		t.Error("Unexpected source:", source)
+	}
+	if warnings := cfg.LoadWarnings(); len(warnings) != 1 || warnings[0] != "Optional include "+filepath.Join(dir, "local.cfg")+" does not exist" {
+		t.Error("Unexpected warnings:", warnings)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "local.cfg"), []byte("Debug = yes\n"), 0644)
+	cfg, _ = NewCFGFromFile(filepath.Join(dir, "main.cfg"))
+	if cfg.GetValue("Debug", "") != "yes" || len(cfg.LoadWarnings()) != 0 {
+		t.Error("Optional include not loaded:", cfg.String())
+	}
+	_, err = NewCFGFromFile(filepath.Join(dir, "missing.cfg"))
+	if pErr, ok := err.(*ParseError); !ok || pErr.Line != 2 || pErr.Source != filepath.Join(dir, "missing.cfg") || !os.IsNotExist(pErr.Err) {
+		t.Error("Unexpected error:", err)
+	}
+	_, err = NewCFGFromFile(filepath.Join(dir, "loop.cfg"))
+	if pErr, ok := err.(*ParseError); !ok || pErr.Msg != "Include loop found: "+filepath.Join(dir, "loop.cfg")+" > "+filepath.Join(dir, "loop2.cfg")+" > "+filepath.Join(dir, "loop.cfg") {
+		t.Error("Unexpected error:", err)
+	}
+	_, err = NewCFGFromFile(filepath.Join(dir, "badinclude.cfg"))
+	if pErr, ok := err.(*ParseError); !ok || pErr.Line != 2 || pErr.Source != filepath.Join(dir, "bad.cfg") {
+		t.Error("Unexpected error:", err)
+	}
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(filepath.Join(dir, "conf"))
+	cfg, err = NewCFGFromString("@include nested.cfg\n")
+	if err != nil || cfg.GetValue("Extra", "") != "yes" {
+		t.Error("Unexpected include from a reader:", err, cfg.String())
+	}
+}
@@ -0,0 +1,55 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+//Write an option as JSON. Single values are strings and multiple values are arrays of strings
+func writeJSONOption(buf *bytes.Buffer, opt *option) {
+	if len(opt.value) == 1 {
+		writeJSONString(buf, opt.value[0])
+		return
+	}
+	buf.WriteByte('[')
+	for iV, val := range opt.value {
+		if iV > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONString(buf, val)
+	}
+	buf.WriteByte(']')
+}
+
+//Write a section as a JSON object keeping the order of it's contents. Comments and inheritance are not included
+func writeJSONSection(buf *bytes.Buffer, sec *CFG) {
+	writeJSONSectionMasked(buf, sec, nil)
+}
+
+//Write a section as a JSON object replacing the values of the options for which mask returns true with MaskedValue
+func writeJSONSectionMasked(buf *bytes.Buffer, sec *CFG, mask func(name string) bool) {
+	buf.WriteByte('{')
+	for iN, name := range sec.order {
+		if iN > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONString(buf, name)
+		buf.WriteByte(':')
+		if sub, ok := sec.sections[name]; ok {
+			writeJSONSectionMasked(buf, sub, mask)
+		} else if mask != nil && mask(name) {
+			writeJSONString(buf, MaskedValue)
+		} else {
+			writeJSONOption(buf, sec.options[name])
+		}
+	}
+	buf.WriteByte('}')
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	//Encode always appends a new line
+	buf.Truncate(buf.Len() - 1)
+}
@@ -0,0 +1,402 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+//A JSON Patch (RFC 6902) operation
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+//Value of an option (values) or a section (section) detached from any tree
+type patchValue struct {
+	values  []string
+	section *CFG
+}
+
+//Apply a JSON Patch (RFC 6902) to the cfg. Paths are JSON pointers mapped to slash separated cfg paths.
+//JSON objects are sections, strings (or numbers and booleans) are single valued options and arrays are multi valued options.
+//An extra level in a path addresses a single value of an option ("/op/0", or "/op/-" to append one).
+//The patch is atomic: if any operation fails the cfg is left untouched
+func (cfg *CFG) ApplyJSONPatch(patch []byte) error {
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
+	return cfg.applyJSONPatch(patch)
+}
+
+func (cfg *CFG) applyJSONPatch(patch []byte) error {
+	ops := make([]jsonPatchOperation, 0)
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return errors.New("Invalid JSON patch: " + err.Error())
+	}
+	backup := cfg.copyTree()
+	mark := cfg.recordMark()
+	for iOp, op := range ops {
+		if err := cfg.applyPatchOperation(op); err != nil {
+			cfg.replaceContents(backup)
+			cfg.dropRecorded(mark)
+			return errors.New(fmt.Sprintf("JSON patch operation %d (%s %s) failed: %s", iOp, op.Op, op.Path, err.Error()))
+		}
+	}
+	return nil
+}
+
+func (cfg *CFG) applyPatchOperation(op jsonPatchOperation) error {
+	path, err := parseJSONPointer(op.Path)
+	if err != nil {
+		return err
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return errors.New("Missing value")
+		}
+		value, err := decodeJSONValue(op.Value)
+		if err != nil {
+			return err
+		}
+		switch op.Op {
+		case "add":
+			return cfg.patchAdd(path, value, false)
+		case "replace":
+			return cfg.patchAdd(path, value, true)
+		default:
+			current, err := cfg.patchGet(path)
+			if err != nil {
+				return err
+			}
+			if !current.equal(value) {
+				return errors.New("Test failed")
+			}
+			return nil
+		}
+	case "remove":
+		if value, err := cfg.patchGet(path); err == nil && value.section != nil {
+			if heir := value.section.findHeir(cfg.root()); heir != nil {
+				return errors.New("Section is inherited by " + heir.path())
+			}
+		}
+		_, err := cfg.patchRemove(path)
+		return err
+	case "move", "copy":
+		from, err := parseJSONPointer(op.From)
+		if err != nil {
+			return err
+		}
+		var value *patchValue
+		if op.Op == "move" {
+			if len(from) < len(path) && strings.Join(path[:len(from)], SplitChar) == strings.Join(from, SplitChar) {
+				return errors.New("Cannot move a section into one of it's children")
+			}
+			value, err = cfg.patchRemove(from)
+		} else {
+			value, err = cfg.patchGet(from)
+			if err == nil && value.section != nil {
+				value = &patchValue{section: value.section.copyTree()}
+			}
+		}
+		if err != nil {
+			return err
+		}
+		return cfg.patchAdd(path, value, false)
+	}
+	return errors.New("Unknown operation " + op.Op)
+}
+
+//Convert a JSON pointer into a cfg path
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, errors.New("Cannot operate on the whole document")
+	}
+	if pointer[0] != '/' {
+		return nil, errors.New("Invalid JSON pointer " + pointer)
+	}
+	p := strings.Split(pointer[1:], "/")
+	for iP, token := range p {
+		p[iP] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+	return p, nil
+}
+
+//Find the section holding the last element of the path. If the path addresses a single value of an option, the option is returned too
+func (cfg *CFG) patchParent(path []string) (*CFG, *option, error) {
+	parent := cfg
+	for iP, name := range path[:len(path)-1] {
+		if sec, ok := parent.sections[name]; ok {
+			parent = sec
+			continue
+		}
+		if opt, ok := parent.options[name]; ok && iP == len(path)-2 {
+			return parent, opt, nil
+		}
+		return nil, nil, newError(ErrNotFound, fmt.Sprintf("%s does not exist", strings.Join(path[:iP+1], SplitChar)))
+	}
+	return parent, nil, nil
+}
+
+//Index of a value inside an option. "-" is the index after the last value
+func valueIndex(opt *option, token string, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return len(opt.value), nil
+	}
+	idx, err := strconv.Atoi(token)
+	max := len(opt.value) - 1
+	if allowEnd {
+		max++
+	}
+	if err != nil || idx < 0 || idx > max || (len(token) > 1 && token[0] == '0') {
+		return 0, errors.New("Invalid value index " + token)
+	}
+	return idx, nil
+}
+
+func (cfg *CFG) patchGet(path []string) (*patchValue, error) {
+	parent, opt, err := cfg.patchParent(path)
+	if err != nil {
+		return nil, err
+	}
+	name := path[len(path)-1]
+	if opt != nil {
+		idx, err := valueIndex(opt, name, false)
+		if err != nil {
+			return nil, err
+		}
+		return &patchValue{values: []string{opt.value[idx]}}, nil
+	}
+	if sec, ok := parent.sections[name]; ok {
+		return &patchValue{section: sec}, nil
+	}
+	if opt, ok := parent.options[name]; ok {
+		return &patchValue{values: opt.value}, nil
+	}
+	return nil, newError(ErrNotFound, strings.Join(path, SplitChar)+" does not exist")
+}
+
+func (cfg *CFG) patchRemove(path []string) (*patchValue, error) {
+	parent, opt, err := cfg.patchParent(path)
+	if err != nil {
+		return nil, err
+	}
+	name := path[len(path)-1]
+	if opt != nil {
+		if err := parent.checkLocked(path[len(path)-2], opt); err != nil {
+			return nil, err
+		}
+		idx, err := valueIndex(opt, name, false)
+		if err != nil {
+			return nil, err
+		}
+		value := opt.value[idx]
+		opt.value = append(opt.value[:idx:idx], opt.value[idx+1:]...)
+		parent.record(OptionSet, parent.childPath(path[len(path)-2]), opt.value)
+		return &patchValue{values: []string{value}}, nil
+	}
+	value := &patchValue{}
+	if sec, ok := parent.sections[name]; ok {
+		value.section = sec
+	} else if opt, ok := parent.options[name]; ok {
+		if err := parent.checkLocked(name, opt); err != nil {
+			return nil, err
+		}
+		value.values = opt.value
+	} else {
+		return nil, newError(ErrNotFound, strings.Join(path, SplitChar)+" does not exist")
+	}
+	parent.removeChild(name)
+	return value, nil
+}
+
+//Add (or replace if mustExist) the value at path. Existing options or sections in the path are replaced keeping their position
+func (cfg *CFG) patchAdd(path []string, value *patchValue, mustExist bool) error {
+	parent, opt, err := cfg.patchParent(path)
+	if err != nil {
+		return err
+	}
+	name := path[len(path)-1]
+	if opt != nil {
+		if value.section != nil || len(value.values) != 1 {
+			return errors.New("Option values can only be replaced by a single value")
+		}
+		if err := parent.checkLocked(path[len(path)-2], opt); err != nil {
+			return err
+		}
+		idx, err := valueIndex(opt, name, !mustExist)
+		if err != nil {
+			return err
+		}
+		values := append(opt.value[:idx:idx], value.values...)
+		if mustExist {
+			idx++
+		}
+		values = append(values, opt.value[idx:]...)
+		if values, err = parent.checkedValues(path[len(path)-2], opt, values); err != nil {
+			return err
+		}
+		opt.value = values
+		parent.record(OptionSet, parent.childPath(path[len(path)-2]), opt.value)
+		return nil
+	}
+	if _, exists := parent.sections[name]; !exists && parent.options[name] == nil {
+		if err := parent.checkName(name, value.section == nil); err != nil {
+			return err
+		}
+	}
+	if value.section != nil {
+		if err := parent.checkNames(value.section); err != nil {
+			return err
+		}
+		parent.root().normalizeSection(parent.childPath(name), value.section)
+	}
+	//Options keep their declared type when they are replaced
+	if value.section == nil {
+		if current, ok := parent.options[name]; ok {
+			if err := parent.checkLocked(name, current); err != nil {
+				return err
+			}
+			values, err := parent.checkedValues(name, current, value.values)
+			if err != nil {
+				return err
+			}
+			current.value = values
+			parent.record(OptionSet, parent.childPath(name), current.value)
+			return nil
+		}
+	}
+	if sec, ok := parent.sections[name]; ok {
+		if value.section != nil {
+			if err := sec.checkReplacement(value.section); err != nil {
+				return err
+			}
+			//Keep the section alive so sections inheriting from it are still valid
+			sec.replaceContents(value.section)
+			return nil
+		}
+		if heir := sec.findHeir(sec.root()); heir != nil {
+			return errors.New("Section is inherited by " + heir.path())
+		}
+	}
+	if err := parent.checkLocked(name, parent.options[name]); err != nil {
+		return err
+	}
+	pos := parent.removeChild(name)
+	if mustExist && pos < 0 {
+		return newError(ErrNotFound, strings.Join(path, SplitChar)+" does not exist")
+	}
+	if value.section != nil {
+		parent.insertChild(name, pos, nil, value.section)
+	} else {
+		values, err := parent.checkedValues(name, nil, value.values)
+		if err != nil {
+			return err
+		}
+		parent.insertChild(name, pos, &option{value: values}, nil)
+	}
+	return nil
+}
+
+func (v *patchValue) equal(other *patchValue) bool {
+	if v.section != nil || other.section != nil {
+		return v.section != nil && other.section != nil && v.section.equal(other.section, EqualOptions{})
+	}
+	return equalValues(v.values, other.values)
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for iV, val := range a {
+		if b[iV] != val {
+			return false
+		}
+	}
+	return true
+}
+
+//Decode a JSON value keeping the order of the object members
+func decodeJSONValue(raw []byte) (*patchValue, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	value, err := decodeJSONToken(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("Unexpected data after JSON value")
+	}
+	return value, nil
+}
+
+func decodeJSONToken(dec *json.Decoder) (*patchValue, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSONFrom(dec, token)
+}
+
+//Decode a JSON value which first token has already been read
+func decodeJSONFrom(dec *json.Decoder, token json.Token) (*patchValue, error) {
+	var err error
+	switch t := token.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			sec := newCFG()
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				name := key.(string)
+				if _, ok := sec.sections[name]; ok || sec.options[name] != nil || name == "" {
+					return nil, errors.New(fmt.Sprintf("Invalid or duplicated name '%s'", name))
+				}
+				value, err := decodeJSONToken(dec)
+				if err != nil {
+					return nil, err
+				}
+				if value.section != nil {
+					sec.insertChild(name, -1, nil, value.section)
+				} else {
+					sec.insertChild(name, -1, &option{value: value.values}, nil)
+				}
+			}
+			_, err = dec.Token()
+			return &patchValue{section: sec}, err
+		case '[':
+			values := make([]string, 0)
+			for dec.More() {
+				value, err := decodeJSONToken(dec)
+				if err != nil {
+					return nil, err
+				}
+				if value.section != nil || len(value.values) != 1 {
+					return nil, errors.New("Only scalar values are allowed inside arrays")
+				}
+				values = append(values, value.values[0])
+			}
+			_, err = dec.Token()
+			return &patchValue{values: values}, err
+		}
+	case string:
+		return &patchValue{values: []string{t}}, nil
+	case json.Number:
+		return &patchValue{values: []string{t.String()}}, nil
+	case bool:
+		return &patchValue{values: []string{strconv.FormatBool(t)}}, nil
+	}
+	return nil, errors.New(fmt.Sprintf("Unsupported JSON value %v", token))
+}
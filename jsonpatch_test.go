@@ -0,0 +1,82 @@
+package cfg
+
+import (
+	"testing"
+)
+
+func TestApplyJSONPatch(t *testing.T) {
+	data := "s1 {\nop1 = a\nop1 += b\n}\ns2 {< s1\nop2 = c\n}\nop3 = d"
+	cfg, err := NewCFGFromString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch := `[
+		{"op": "add", "path": "/s1/op1/-", "value": "c"},
+		{"op": "replace", "path": "/s1/op1/0", "value": "A"},
+		{"op": "remove", "path": "/s1/op1/1"},
+		{"op": "add", "path": "/s3", "value": {"op4": 1, "s31": {"op5": ["x", "y"]}, "on": true}},
+		{"op": "replace", "path": "/op3", "value": "e"},
+		{"op": "copy", "from": "/s3/s31", "path": "/s2/s21"},
+		{"op": "move", "from": "/s3/on", "path": "/s2/on"},
+		{"op": "remove", "path": "/s3/s31"},
+		{"op": "test", "path": "/s2/s21", "value": {"op5": ["x", "y"]}},
+		{"op": "add", "path": "/a~0b", "value": "tilde"}
+	]`
+	if err := cfg.ApplyJSONPatch([]byte(patch)); err != nil {
+		t.Fatal(err)
+	}
+	expected := "s1 {\n\top1 = A\n\top1 += c\n}\ns2 {< s1\n\top2 = c\n\ts21 {\n\t\top5 = x\n\t\top5 += y\n\t}\n\ton = true\n}\nop3 = e\ns3 {\n\top4 = 1\n}\na~b = tilde\n"
+	if cfg.String() != expected {
+		t.Error("Unexpected result:\n" + cfg.String())
+	}
+	for _, bad := range []string{
+		`[{"op": "remove", "path": "/s1"}]`,
+		`[{"op": "replace", "path": "/nope", "value": "x"}]`,
+		`[{"op": "add", "path": "/nope/op", "value": "x"}]`,
+		`[{"op": "move", "from": "/s2", "path": "/s2/s22"}]`,
+		`[{"op": "add", "path": "/s1/op1/5", "value": "x"}]`,
+		`[{"op": "test", "path": "/op3", "value": "x"}]`,
+		`[{"op": "add", "path": "/s3/op4", "value": [{"a": "b"}]}]`,
+		`[{"op": "explode", "path": "/op3"}]`,
+		`[{"op": "add", "path": "/new", "value": "x"}, {"op": "remove", "path": "/nope"}]`,
+		`{"op": "remove"}`,
+	} {
+		if err := cfg.ApplyJSONPatch([]byte(bad)); err == nil {
+			t.Error("Patch didn't fail:", bad)
+		}
+	}
+	if cfg.String() != expected {
+		t.Error("Failed patches modified the cfg:\n" + cfg.String())
+	}
+	if v := cfg.GetValue("s2/op1", ""); v != "A/c" {
+		t.Error("Inheritance was lost after the rollback:", v)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	cfg, err := NewCFGFromString("s1 {\ns11 {\nop = 1\n}\n}\ns2 {< s1/s11\n}\nop = 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Remove("s1"); err == nil {
+		t.Error("Removed an inherited section")
+	}
+	if err := cfg.Remove("s1/s11/op"); err != nil {
+		t.Error(err)
+	}
+	if err := cfg.Remove("op"); err != nil {
+		t.Error(err)
+	}
+	if err := cfg.Remove("op"); err == nil {
+		t.Error("Removed a non existant option")
+	}
+	if err := cfg.Remove("s2"); err != nil {
+		t.Error(err)
+	}
+	if err := cfg.Remove("s1"); err != nil {
+		t.Error(err)
+	}
+	if cfg.String() != "" {
+		t.Error("Unexpected contents:", cfg.String())
+	}
+}
@@ -0,0 +1,160 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//Load several files one after the other into this cfg. Sections defined in several files are merged and options defined again
+//replace the previous values (as with SetMergeSections). Inheritance can refer to sections defined in any of the files.
+//The file every option comes from is kept and can be queried with OptionSource and Provenance
+func (cfg *CFG) LoadFiles(filenames ...string) (err error) {
+	if err = cfg.writeLock(); err != nil {
+		return
+	}
+	root := cfg.root()
+	if len(filenames) > 1 {
+		merge := root.mergeSections
+		root.mergeSections = true
+		defer func() { root.mergeSections = merge }()
+	}
+	inheritance_links := make([]inheritanceLink, 0)
+	for _, filename := range filenames {
+		var fi *os.File
+		if fi, err = os.Open(filename); err == nil {
+			err = cfg.loadSource(fi, filename, &inheritance_links)
+			fi.Close()
+		}
+		if err != nil {
+			cfg.unlock()
+			return
+		}
+	}
+	return cfg.linkLoaded(inheritance_links)
+}
+
+//Load every *.cfg file in a directory in lexical order, conf.d style. See LoadFiles
+func (cfg *CFG) LoadDir(dir string) error {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.cfg"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(filenames)
+	return cfg.LoadFiles(filenames...)
+}
+
+//Option of a fragment setting it's priority for MergeByPriority. It takes precedence over the prefix of the file name
+const PriorityOption = "Meta/Priority"
+
+//Priority of the fragments without one
+const DefaultPriority = 50
+
+type fragment struct {
+	path     string
+	priority int
+}
+
+//Load fragment files from the lowest priority to the highest one, so the values of the fragments with higher priority win.
+//The priority of a fragment is the PriorityOption set in it or the number prefixing it's file name, like "10-" in "10-base.cfg",
+//and DefaultPriority otherwise. Fragments with the same priority are loaded in the order of paths. See LoadFiles.
+//Returns the fragment every option under this section finally comes from
+func (cfg *CFG) MergeByPriority(paths ...string) (map[string]string, error) {
+	fragments := make([]fragment, len(paths))
+	for iP, path := range paths {
+		priority, err := fragmentPriority(path)
+		if err != nil {
+			return nil, err
+		}
+		fragments[iP] = fragment{path, priority}
+	}
+	sort.SliceStable(fragments, func(i, j int) bool { return fragments[i].priority < fragments[j].priority })
+	ordered := make([]string, len(fragments))
+	for iF, f := range fragments {
+		ordered[iF] = f.path
+	}
+	if err := cfg.LoadFiles(ordered...); err != nil {
+		return nil, err
+	}
+	return cfg.Provenance(), nil
+}
+
+func fragmentPriority(path string) (int, error) {
+	fi, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer fi.Close()
+	tree, err := NewCFGFromReader(fi)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("%s (%s)", err.Error(), path))
+	}
+	if value, ok := tree.GetOption(PriorityOption); ok {
+		priority, err := strconv.Atoi(strings.Trim(value, trimChars))
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("Invalid priority %s in %s", value, path))
+		}
+		return priority, nil
+	}
+	name := filepath.Base(path)
+	if pos := strings.IndexByte(name, '-'); pos > 0 {
+		if priority, err := strconv.Atoi(name[:pos]); err == nil {
+			return priority, nil
+		}
+	}
+	return DefaultPriority, nil
+}
+
+//Files of the overlay convention used by LoadOverlay
+const (
+	OverlayBase  = "base.cfg"
+	OverlayDir   = "overlays"
+	OverlayLocal = "local.cfg"
+)
+
+//Load the layers of a directory following the overlay convention: base.cfg, then overlays/<env>.cfg and then local.cfg (for host specific changes).
+//base.cfg and the overlay of env (if env is not empty) must exist while local.cfg is optional. See LoadFiles.
+//Returns the file every option under this section finally comes from
+func (cfg *CFG) LoadOverlay(dir string, env string) (map[string]string, error) {
+	layers := []string{filepath.Join(dir, OverlayBase)}
+	if env != "" {
+		layers = append(layers, filepath.Join(dir, OverlayDir, env+".cfg"))
+	}
+	if _, err := os.Stat(filepath.Join(dir, OverlayLocal)); err == nil {
+		layers = append(layers, filepath.Join(dir, OverlayLocal))
+	}
+	if err := cfg.LoadFiles(layers...); err != nil {
+		return nil, err
+	}
+	return cfg.Provenance(), nil
+}
+
+//File an option was loaded from. Options set programmatically or loaded from readers have no source
+func (cfg *CFG) OptionSource(name string) (string, bool) {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	opt := cfg.lookupOption(name)
+	if opt == nil || opt.source == "" {
+		return "", false
+	}
+	return opt.source, true
+}
+
+//Files all the options under this section (by path relative to it) were loaded from. Options without source are not included
+func (cfg *CFG) Provenance() map[string]string {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	options := make(map[string]*option)
+	cfg.collectOptions("", options, new([]string))
+	provenance := make(map[string]string)
+	for path, opt := range options {
+		if opt.source != "" {
+			provenance[path] = opt.source
+		}
+	}
+	return provenance
+}
@@ -0,0 +1,118 @@
+package cfg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"10-base.cfg":  "DB {\n\tHost = db1\n\tPort = 3306\n}\n",
+		"20-site.cfg":  "DB {\n\tPort = 3307\n}\nApp {< DB\n}\n",
+		"05-early.cfg": "Log {\n\tLevel = info\n}\n",
+		"README":       "Not a cfg",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := NewCFG()
+	if err := cfg.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Log {\n\tLevel = info\n}\nDB {\n\tHost = db1\n\tPort = 3307\n}\nApp {< DB\n}\n"
+	if cfg.String() != expected {
+		t.Error("Unexpected contents:", cfg.String())
+	}
+	if source, ok := cfg.OptionSource("App/Port"); !ok || source != filepath.Join(dir, "20-site.cfg") {
+		t.Error("Unexpected source:", source, ok)
+	}
+	provenance := cfg.Provenance()
+	if len(provenance) != 3 || provenance["DB/Host"] != filepath.Join(dir, "10-base.cfg") || provenance["Log/Level"] != filepath.Join(dir, "05-early.cfg") {
+		t.Error("Unexpected provenance:", provenance)
+	}
+	cfg.SetOption("DB/Host", "db2", "")
+	if _, ok := cfg.OptionSource("DB/Host"); ok {
+		t.Error("Option set programmatically kept it's source")
+	}
+	ioutil.WriteFile(filepath.Join(dir, "30-bad.cfg"), []byte("a = 1\nb += 2\n"), 0644)
+	err = NewCFG().LoadDir(dir)
+	if err == nil || err.Error() != "Option b was not previously defined ("+filepath.Join(dir, "30-bad.cfg")+" line 2)" {
+		t.Error("Unexpected error:", err)
+	}
+}
+
+func TestMergeByPriority(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgpriority")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"99-local.cfg": "DB {\n\tHost = db3\n}\n",
+		"10-base.cfg":  "DB {\n\tHost = db1\n\tPort = 3306\n\tUser = app\n}\n",
+		"site.cfg":     "DB {\n\tHost = db2\n\tPort = 3307\n}\n",
+		"urgent.cfg":   "Meta {\n\tPriority = 5\n}\nDB {\n\tUser = root\n}\n",
+	}
+	var paths []string
+	for _, name := range []string{"99-local.cfg", "10-base.cfg", "site.cfg", "urgent.cfg"} {
+		paths = append(paths, filepath.Join(dir, name))
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(files[name]), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := NewCFG()
+	winners, err := cfg.MergeByPriority(paths...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetValue("DB/Host", "") != "db3" || cfg.GetValue("DB/Port", "") != "3307" || cfg.GetValue("DB/User", "") != "app" {
+		t.Error("Unexpected contents:", cfg.String())
+	}
+	if winners["DB/Host"] != paths[0] || winners["DB/Port"] != paths[2] || winners["DB/User"] != paths[1] || winners[PriorityOption] != paths[3] {
+		t.Error("Unexpected winners:", winners)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "bad.cfg"), []byte("Meta {\n\tPriority = high\n}\n"), 0644)
+	if _, err := NewCFG().MergeByPriority(filepath.Join(dir, "bad.cfg")); err == nil || err.Error() != "Invalid priority high in "+filepath.Join(dir, "bad.cfg") {
+		t.Error("Unexpected error:", err)
+	}
+}
+
+func TestLoadOverlay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgoverlay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, OverlayDir), 0755)
+	ioutil.WriteFile(filepath.Join(dir, OverlayBase), []byte("DB {\n\tHost = localhost\n\tPort = 3306\n}\nDebug = yes\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, OverlayDir, "prod.cfg"), []byte("DB {\n\tHost = db.prod\n}\nDebug = no\n"), 0644)
+	cfg := NewCFG()
+	provenance, err := cfg.LoadOverlay(dir, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prod := filepath.Join(dir, OverlayDir, "prod.cfg")
+	if provenance["DB/Host"] != prod || provenance["Debug"] != prod || provenance["DB/Port"] != filepath.Join(dir, OverlayBase) {
+		t.Error("Unexpected provenance:", provenance)
+	}
+	ioutil.WriteFile(filepath.Join(dir, OverlayLocal), []byte("DB {\n\tPort = 3307\n}\n"), 0644)
+	cfg = NewCFG()
+	if provenance, err = cfg.LoadOverlay(dir, "prod"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := cfg.GetOption("DB/Port"); v != "3307" || provenance["DB/Port"] != filepath.Join(dir, OverlayLocal) {
+		t.Error("Local layer not applied:", v, provenance)
+	}
+	if _, err := NewCFG().LoadOverlay(dir, "staging"); err == nil {
+		t.Error("Loaded a missing environment")
+	}
+}
@@ -0,0 +1,91 @@
+package cfg
+
+import (
+	"strings"
+)
+
+//Comment line locking an option, like "#locked". Locked options can only be overwritten with SetOptionArrayForce, setting or
+//merging them any other way fails with ErrLocked. Useful to protect hand tuned values from automated changes
+const LockedAnnotation = "locked"
+
+//Is the comment annotated as locked?
+func lockedComment(comment string) bool {
+	for _, line := range strings.Split(comment, "\n") {
+		if strings.EqualFold(strings.Trim(line, trimChars), LockedAnnotation) {
+			return true
+		}
+	}
+	return false
+}
+
+func (opt *option) locked() bool {
+	return opt != nil && lockedComment(opt.comment)
+}
+
+//Fail if opt, the option name of this section, is locked and the tree is not being forced to overwrite it
+func (cfg *CFG) checkLocked(name string, opt *option) error {
+	if opt.locked() && !cfg.root().forcing {
+		return newError(ErrLocked, "Option "+cfg.childPath(name)+" is locked")
+	}
+	return nil
+}
+
+//Lock or unlock an option. The lock is kept as a LockedAnnotation line in the comment of the option, so it is dumped and loaded back
+func (cfg *CFG) LockOption(name string, locked bool) error {
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
+	_, opt := cfg.getString(name, false, 0)
+	if opt == nil {
+		return newError(ErrNotFound, "Option "+name+" does not exist")
+	}
+	if opt.locked() == locked {
+		return nil
+	}
+	if locked {
+		opt.comment = joinComments(opt.comment, LockedAnnotation)
+		return nil
+	}
+	lines := make([]string, 0)
+	for _, line := range strings.Split(opt.comment, "\n") {
+		if !strings.EqualFold(strings.Trim(line, trimChars), LockedAnnotation) {
+			lines = append(lines, line)
+		}
+	}
+	opt.comment = strings.Join(lines, "\n")
+	return nil
+}
+
+//Is the option locked? Inherited options are not taken into account
+func (cfg *CFG) IsLocked(name string) bool {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	_, opt := cfg.getString(name, false, 0)
+	return opt.locked()
+}
+
+//Like SetOptionArray but overwriting the option even if it is locked. The option stays locked
+func (cfg *CFG) SetOptionArrayForce(name string, value []string, comment string) error {
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
+	return cfg.forced(func() error {
+		return cfg.setOptionArray(name, value, comment)
+	})
+}
+
+//Like SetOption but overwriting the option even if it is locked. The option stays locked
+func (cfg *CFG) SetOptionForce(name string, value string, comment string) error {
+	return cfg.SetOptionArrayForce(name, []string{value}, comment)
+}
+
+//Run fn allowing it to overwrite locked options. Must hold the write lock
+func (cfg *CFG) forced(fn func() error) error {
+	root := cfg.root()
+	previous := root.forcing
+	root.forcing = true
+	defer func() { root.forcing = previous }()
+	return fn()
+}
@@ -0,0 +1,87 @@
+package cfg
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLockedOptions(t *testing.T) {
+	cfg, err := NewCFGFromString("DB {\n\t#Tuned by hand\n\t#locked\n\tPool = 40\n\tTimeout = 5\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.IsLocked("DB/Pool") || cfg.IsLocked("DB/Timeout") {
+		t.Fatal("Unexpected locks")
+	}
+	if err := cfg.SetOption("DB/Pool", "10", ""); !errors.Is(err, ErrLocked) {
+		t.Error("Locked option was overwritten:", err)
+	}
+	if err := cfg.ApplyMergePatch([]byte(`{"DB": {"Pool": "10"}}`)); err == nil || !strings.Contains(err.Error(), "is locked") {
+		t.Error("Merge patch overwrote a locked option:", err)
+	}
+	if v, _ := cfg.GetOption("DB/Pool"); v != "40" {
+		t.Error("Locked option changed:", v)
+	}
+	if err := cfg.SetOptionForce("DB/Pool", "50", "Tuned again"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := cfg.GetOption("DB/Pool"); v != "50" || !cfg.IsLocked("DB/Pool") {
+		t.Error("Forced set failed or unlocked the option:", v)
+	}
+	if err := cfg.LockOption("DB/Timeout", true); err != nil {
+		t.Fatal(err)
+	}
+	expected := "DB {\n\t#Tuned again\n\t#locked\n\tPool = 50\n\t#locked\n\tTimeout = 5\n}\n"
+	if cfg.String() != expected {
+		t.Error("Unexpected dump:", cfg.String())
+	}
+	if err := cfg.LockOption("DB/Pool", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetOption("DB/Pool", "10", ""); err != nil {
+		t.Error("Unlocked option cannot be set:", err)
+	}
+	if err := cfg.LockOption("DB/Missing", true); !errors.Is(err, ErrNotFound) {
+		t.Error("Missing option locked:", err)
+	}
+}
+
+func TestLockedOptionsPatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		apply func(cfg *CFG) error
+	}{
+		{"merge null", func(cfg *CFG) error { return cfg.ApplyMergePatch([]byte(`{"DB": {"Pool": null}}`)) }},
+		{"merge section", func(cfg *CFG) error { return cfg.ApplyMergePatch([]byte(`{"DB": {"Pool": {"Size": "1"}}}`)) }},
+		{"remove", func(cfg *CFG) error { return cfg.ApplyJSONPatch([]byte(`[{"op": "remove", "path": "/DB/Pool"}]`)) }},
+		{"remove value", func(cfg *CFG) error { return cfg.ApplyJSONPatch([]byte(`[{"op": "remove", "path": "/DB/Pool/0"}]`)) }},
+		{"move", func(cfg *CFG) error {
+			return cfg.ApplyJSONPatch([]byte(`[{"op": "move", "from": "/DB/Pool", "path": "/DB/Size"}]`))
+		}},
+		{"add section", func(cfg *CFG) error {
+			return cfg.ApplyJSONPatch([]byte(`[{"op": "add", "path": "/DB/Pool", "value": {"Size": "1"}}]`))
+		}},
+	}
+	for _, test := range tests {
+		cfg, _ := NewCFGFromString("DB {\n\t#locked\n\tPool = 40\n}\n")
+		if err := test.apply(cfg); err == nil || !strings.Contains(err.Error(), "is locked") {
+			t.Error(test.name, "changed a locked option:", err)
+		}
+		if v, _ := cfg.GetOption("DB/Pool"); v != "40" || !cfg.IsLocked("DB/Pool") {
+			t.Error(test.name, "changed a locked option:", cfg.String())
+		}
+	}
+}
+
+func TestLockedOptionsInsertContents(t *testing.T) {
+	data := "DB {\n\t#locked\n\tPool = 40\n}\n"
+	cfg, _ := NewCFGFromString(data)
+	in, _ := NewCFGFromString("New = x\nDB {\n\tTimeout = 5\n\tPool = 10\n}\n")
+	if err := cfg.InsertContents(in); !errors.Is(err, ErrLocked) {
+		t.Error("Merged over a locked option:", err)
+	}
+	if cfg.String() != data {
+		t.Error("Failed merge was not rolled back:", cfg.String())
+	}
+}
@@ -0,0 +1,20 @@
+package cfg
+
+import (
+	"log/slog"
+)
+
+//Set the logger the tree reports to: reloads and their rollbacks, the changes notified to watchers, the deprecated paths read,
+//the options redefined when merging sources and the optional includes skipped. Records have the path, origin (file) and line
+//involved when they are known. Pass nil to stop logging
+func (cfg *CFG) SetLogger(logger *slog.Logger) {
+	cfg.lock.Lock()
+	defer cfg.lock.Unlock()
+	cfg.root().logger = logger
+}
+
+func (cfg *CFG) getLogger() *slog.Logger {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	return cfg.root().logger
+}
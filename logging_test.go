@@ -0,0 +1,61 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfglog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	base, local := filepath.Join(dir, "base.cfg"), filepath.Join(dir, "local.cfg")
+	ioutil.WriteFile(base, []byte("Meta {\n\tRenames {\n\t\tHost = DB/Host\n\t}\n}\nDB {\n\tHost = db1\n}\n@include? missing.cfg\n"), 0644)
+	ioutil.WriteFile(local, []byte("DB {\n\n\tHost = db2\n}\n"), 0644)
+	var buf bytes.Buffer
+	cfg := NewCFG()
+	cfg.SetLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	cfg.Watch("DB", func(ChangeEvent) {})
+	if err := cfg.LoadFiles(base, local); err != nil {
+		t.Fatal(err)
+	}
+	cfg.GetOption("Host")
+	rm := NewReloadManager(cfg, local)
+	ioutil.WriteFile(local, []byte("a = 1\na = 2\n"), 0644)
+	rm.Reload()
+	var records []map[string]interface{}
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		record := make(map[string]interface{})
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Fatal(err)
+		}
+		delete(record, "time")
+		records = append(records, record)
+	}
+	expected := []map[string]interface{}{
+		{"level": "WARN", "msg": "Optional include does not exist", "path": filepath.Join(dir, "missing.cfg"), "origin": base, "line": 9.0},
+		{"level": "DEBUG", "msg": "Option redefined", "path": "DB/Host", "origin": local, "line": 3.0, "previous": base},
+		{"level": "DEBUG", "msg": "Change notified", "path": "DB", "kind": "section created", "watchers": 1.0},
+		{"level": "DEBUG", "msg": "Change notified", "path": "DB/Host", "kind": "option set", "watchers": 1.0},
+		{"level": "DEBUG", "msg": "Change notified", "path": "DB/Host", "kind": "option set", "watchers": 1.0},
+		{"level": "WARN", "msg": "Deprecated path", "path": "Host", "replacement": "DB/Host"},
+		{"level": "ERROR", "msg": "Reload failed", "origin": local, "line": 2.0, "error": "a already exists (" + local + " line 2)"},
+	}
+	if len(records) != len(expected) {
+		t.Fatal("Unexpected records:", buf.String())
+	}
+	for iR, record := range records {
+		for key, value := range expected[iR] {
+			if record[key] != value {
+				t.Error("Unexpected", key, "in", record)
+			}
+		}
+	}
+}
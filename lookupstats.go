@@ -0,0 +1,112 @@
+package cfg
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+//How the lookups of options in a section were resolved, as returned by LookupStats
+type LookupStats struct {
+	//Options found in the section itself
+	Local int64
+	//Options found following inheritance (wildcard sections included)
+	Inherited int64
+	//Options not found
+	Missed int64
+	//Inheritance links followed by all the lookups and by the longest one
+	Steps    int64
+	MaxSteps int64
+}
+
+type lookupCounters struct {
+	local, inherited, missed, steps, maxSteps atomic.Int64
+}
+
+//Counters of the lookups of a tree by the section they are done in
+type lookupStats struct {
+	sections sync.Map
+}
+
+//Count how option lookups (GetOption and the rest of getters) are resolved in every section, to find the lookups going through long
+//inheritance chains. Counting slows lookups down, so it is disabled by default. Enabling it again resets the counters
+func (cfg *CFG) EnableLookupStats(enabled bool) {
+	cfg.lock.Lock()
+	defer cfg.lock.Unlock()
+	root := cfg.root()
+	root.lookupStats = nil
+	if enabled {
+		root.lookupStats = new(lookupStats)
+	}
+}
+
+//Lookup statistics by the path of the section the options were looked up in. Empty if they are not enabled
+func (cfg *CFG) LookupStats() map[string]LookupStats {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	root := cfg.root()
+	stats := make(map[string]LookupStats)
+	if root.lookupStats == nil {
+		return stats
+	}
+	root.lookupStats.sections.Range(func(key, value interface{}) bool {
+		sec, counters := key.(*CFG), value.(*lookupCounters)
+		//Sections removed from the tree are left out
+		if sec.root() == root {
+			stats[sec.path()] = LookupStats{counters.local.Load(), counters.inherited.Load(), counters.missed.Load(), counters.steps.Load(), counters.maxSteps.Load()}
+		}
+		return true
+	})
+	return stats
+}
+
+//Look up an option counting how it is resolved. Must hold the read lock
+func (cfg *CFG) countedLookup(stats *lookupStats, name string) *option {
+	p := SplitPath(name)
+	if len(p) == 0 {
+		return nil
+	}
+	sec := cfg
+	if len(p) > 1 {
+		if sec, _ = cfg.get(p, true, 1); sec == nil {
+			return nil
+		}
+	}
+	opt, steps := sec.getOptionSteps(p[len(p)-1])
+	value, _ := stats.sections.LoadOrStore(sec, new(lookupCounters))
+	counters := value.(*lookupCounters)
+	switch {
+	case opt == nil:
+		counters.missed.Add(1)
+	case steps == 0:
+		counters.local.Add(1)
+	default:
+		counters.inherited.Add(1)
+	}
+	counters.steps.Add(int64(steps))
+	for {
+		max := counters.maxSteps.Load()
+		if int64(steps) <= max || counters.maxSteps.CompareAndSwap(max, int64(steps)) {
+			return opt
+		}
+	}
+}
+
+//getOption following inheritance that also returns the number of links followed
+func (cfg *CFG) getOptionSteps(name string) (*option, int) {
+	if opt, ok := cfg.options[name]; ok {
+		return opt, 0
+	}
+	steps := 0
+	if cfg.inheritance != nil {
+		opt, inherited := cfg.inheritance.getOptionSteps(name)
+		if opt != nil {
+			return opt, inherited + 1
+		}
+		steps += inherited + 1
+	}
+	if wildcard := cfg.wildcard(); wildcard != nil {
+		opt, inherited := wildcard.getOptionSteps(name)
+		return opt, steps + inherited + 1
+	}
+	return nil, steps
+}
@@ -0,0 +1,31 @@
+package cfg
+
+import (
+	"testing"
+)
+
+func TestLookupStats(t *testing.T) {
+	cfg, _ := NewCFGFromString("Base {\n\tPort = 80\n}\nMid {< Base\n\tHost = mid\n}\nLeaf {< Mid\n\tName = leaf\n}\n")
+	cfg.GetOption("Leaf/Name")
+	if stats := cfg.LookupStats(); len(stats) != 0 {
+		t.Error("Counted lookups while disabled:", stats)
+	}
+	cfg.EnableLookupStats(true)
+	leaf, _ := cfg.GetSection("Leaf")
+	leaf.GetOption("Name")
+	cfg.GetOption("Leaf/Host")
+	cfg.GetOption("Leaf/Port")
+	cfg.GetOption("Leaf/Missing")
+	cfg.GetOption("Base/Port")
+	stats := cfg.LookupStats()
+	if s := stats["Leaf"]; s != (LookupStats{Local: 1, Inherited: 2, Missed: 1, Steps: 5, MaxSteps: 2}) {
+		t.Error("Unexpected stats:", s)
+	}
+	if s := stats["Base"]; s != (LookupStats{Local: 1}) {
+		t.Error("Unexpected stats:", s)
+	}
+	cfg.EnableLookupStats(true)
+	if stats := cfg.LookupStats(); len(stats) != 0 {
+		t.Error("Counters not reset:", stats)
+	}
+}
@@ -0,0 +1,139 @@
+package cfg
+
+import (
+	"strings"
+)
+
+//Contents of this section as nested maps: sections are map[string]interface{}, options with one value are strings and options with several values are []string.
+//Only the contents defined in each section are included. Aliases are left out, use ToEffectiveMap to get the contents as they are read
+func (cfg *CFG) ToMap() map[string]interface{} {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	return cfg.toMap()
+}
+
+func (cfg *CFG) toMap() map[string]interface{} {
+	m := make(map[string]interface{}, len(cfg.order))
+	for _, name := range cfg.order {
+		if sec, ok := cfg.sections[name]; ok {
+			if sec.alias == nil {
+				m[name] = sec.toMap()
+			}
+		} else {
+			m[name] = mapValue(cfg.options[name].value)
+		}
+	}
+	return m
+}
+
+//Like ToMap but with the inherited options and sections included, aliases replaced by the contents of their targets and anchors resolved
+func (cfg *CFG) ToEffectiveMap() map[string]interface{} {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	return cfg.toEffectiveMap(map[*CFG]bool{})
+}
+
+func (cfg *CFG) toEffectiveMap(visiting map[*CFG]bool) map[string]interface{} {
+	visiting[cfg] = true
+	defer delete(visiting, cfg)
+	m := make(map[string]interface{})
+	for _, from := range cfg.effectiveChain() {
+		for _, name := range from.order {
+			if _, ok := m[name]; ok {
+				continue
+			}
+			if sec, ok := from.sections[name]; ok {
+				//An alias pointing to one of it's parents would never end
+				if target := sec.resolveAlias(); !visiting[target] {
+					m[name] = target.toEffectiveMap(visiting)
+				}
+			} else {
+				m[name] = mapValue(cfg.root().anchors.resolve(from.options[name].value))
+			}
+		}
+	}
+	return m
+}
+
+func mapValue(values []string) interface{} {
+	if len(values) == 1 {
+		return values[0]
+	}
+	return append([]string{}, values...)
+}
+
+//Values of the options of this section (including the inherited ones) by name, joined as GetOption does. If recursive the options
+//of the sub sections are included too keyed by their path relative to this section. Options with secrets that cannot be resolved are left out
+func (cfg *CFG) OptionsMap(recursive bool) map[string]string {
+	cfg.lock.RLock()
+	raw := make(map[string][]string)
+	cfg.collectEffective("", recursive, "", raw, map[*CFG]bool{})
+	secrets := cfg.root().secrets
+	cfg.lock.RUnlock()
+	m := make(map[string]string, len(raw))
+	for path, values := range raw {
+		if secrets != nil {
+			var err error
+			if values, err = secrets.resolveAll(values); err != nil {
+				continue
+			}
+		}
+		m[path] = strings.Join(values, SplitChar)
+	}
+	return m
+}
+
+//Values of every option (including the inherited ones) under this section whose path relative to it starts with prefix, with anchors,
+//references and secrets resolved. Options that cannot be resolved are left out
+func (cfg *CFG) OptionsWithPrefix(prefix string) map[string][]string {
+	prefix = strings.TrimLeft(prefix, SplitChar)
+	cfg.lock.RLock()
+	raw := make(map[string][]string)
+	cfg.collectEffective("", true, prefix, raw, map[*CFG]bool{})
+	m := make(map[string][]string, len(raw))
+	for path := range raw {
+		if values, err := cfg.readValues(path); err == nil {
+			m[path] = values
+		}
+	}
+	secrets := cfg.root().secrets
+	cfg.lock.RUnlock()
+	if secrets == nil {
+		return m
+	}
+	for path, values := range m {
+		var err error
+		if m[path], err = secrets.resolveAll(values); err != nil {
+			delete(m, path)
+		}
+	}
+	return m
+}
+
+//Could path or the paths under it start with prefix?
+func underPrefix(path string, prefix string) bool {
+	return strings.HasPrefix(path, prefix) || strings.HasPrefix(prefix, path+SplitChar)
+}
+
+//Collect the values of the options under this section with paths starting with match
+func (cfg *CFG) collectEffective(prefix string, recursive bool, match string, values map[string][]string, visiting map[*CFG]bool) {
+	visiting[cfg] = true
+	defer delete(visiting, cfg)
+	seen := make(map[string]bool)
+	for _, from := range cfg.effectiveChain() {
+		for _, name := range from.order {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			path := joinEventPath(prefix, name)
+			if sec, ok := from.sections[name]; ok {
+				if target := sec.resolveAlias(); recursive && !visiting[target] && underPrefix(path, match) {
+					target.collectEffective(path, true, match, values, visiting)
+				}
+			} else if strings.HasPrefix(path, match) {
+				values[path] = cfg.root().anchors.resolve(from.options[name].value)
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package cfg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToMap(t *testing.T) {
+	cfg, err := NewCFGFromString("@define host = db1\nBase {\n\tPort = 3306\n\tPool {\n\t\tSize = 4\n\t}\n}\nApp {< Base\n\tHost = &host\n\tTags = a\n\tTags += b\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"Base": map[string]interface{}{"Port": "3306", "Pool": map[string]interface{}{"Size": "4"}},
+		"App":  map[string]interface{}{"Host": "&host", "Tags": []string{"a", "b"}},
+	}
+	if m := cfg.ToMap(); !reflect.DeepEqual(m, expected) {
+		t.Error("Unexpected map:", m)
+	}
+	app, _ := cfg.GetSection("App")
+	expected = map[string]interface{}{"Host": "db1", "Tags": []string{"a", "b"}, "Port": "3306", "Pool": map[string]interface{}{"Size": "4"}}
+	if m := app.ToEffectiveMap(); !reflect.DeepEqual(m, expected) {
+		t.Error("Unexpected effective map:", m)
+	}
+}
+
+func TestOptionsMap(t *testing.T) {
+	cfg, _ := NewCFGFromString("Base {\n\tPort = 3306\n\tPool {\n\t\tSize = 4\n\t}\n}\nApp {< Base\n\tHost = db1\n\tTags = a\n\tTags += b\n\tCache {\n\t\tTTL = 10\n\t}\n}\n")
+	app, _ := cfg.GetSection("App")
+	expected := map[string]string{"Host": "db1", "Tags": "a/b", "Port": "3306"}
+	if m := app.OptionsMap(false); !reflect.DeepEqual(m, expected) {
+		t.Error("Unexpected map:", m)
+	}
+	expected["Cache/TTL"] = "10"
+	expected["Pool/Size"] = "4"
+	if m := app.OptionsMap(true); !reflect.DeepEqual(m, expected) {
+		t.Error("Unexpected recursive map:", m)
+	}
+}
+
+func TestOptionsWithPrefix(t *testing.T) {
+	cfg, _ := NewCFGFromString("Flags {\n\tnew_ui = on\n\tnew_api = @{/Defaults/Enabled}\n\tnewsletter = off\n\tBeta {\n\t\tnew_search = on\n\t}\n}\nDefaults {\n\tEnabled = yes\n}\nNews {\n\tnew_feed = on\n}\n")
+	expected := map[string][]string{"Flags/new_ui": {"on"}, "Flags/new_api": {"yes"}}
+	if m := cfg.OptionsWithPrefix("/Flags/new_"); !reflect.DeepEqual(m, expected) {
+		t.Error("Unexpected options:", m)
+	}
+	expected["Flags/newsletter"] = []string{"off"}
+	expected["Flags/Beta/new_search"] = []string{"on"}
+	if m := cfg.OptionsWithPrefix("Flags"); !reflect.DeepEqual(m, expected) {
+		t.Error("Unexpected options:", m)
+	}
+	flags, _ := cfg.GetSection("Flags")
+	if m := flags.OptionsWithPrefix("Beta/"); !reflect.DeepEqual(m, map[string][]string{"Beta/new_search": {"on"}}) {
+		t.Error("Unexpected options relative to the section:", m)
+	}
+	if m := cfg.OptionsWithPrefix("Missing"); len(m) != 0 {
+		t.Error("Unexpected options:", m)
+	}
+}
@@ -0,0 +1,182 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+//Generate a JSON Merge Patch (RFC 7386) that transforms this cfg into other.
+//Sections are JSON objects, options are strings or arrays of strings and removed entries are null. Comments and inheritance are not included
+func (cfg *CFG) MergePatch(other *CFG) ([]byte, error) {
+	if other.lock != cfg.lock {
+		other = other.snapshot()
+	}
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	var buf bytes.Buffer
+	writeMergePatch(&buf, cfg, other)
+	return buf.Bytes(), nil
+}
+
+//Write the merge patch between two sections. Returns false if there are no differences
+func writeMergePatch(buf *bytes.Buffer, from *CFG, to *CFG) bool {
+	buf.WriteByte('{')
+	members := 0
+	member := func(name string) {
+		if members > 0 {
+			buf.WriteByte(',')
+		}
+		members++
+		writeJSONString(buf, name)
+		buf.WriteByte(':')
+	}
+	for _, name := range to.order {
+		if sec, ok := to.sections[name]; ok {
+			if fromSec, ok := from.sections[name]; ok {
+				mark := buf.Len()
+				member(name)
+				if !writeMergePatch(buf, fromSec, sec) {
+					buf.Truncate(mark)
+					members--
+				}
+				continue
+			}
+			member(name)
+			writeJSONSection(buf, sec)
+			continue
+		}
+		opt := to.options[name]
+		if fromOpt, ok := from.options[name]; ok && equalValues(fromOpt.value, opt.value) {
+			continue
+		}
+		member(name)
+		writeJSONOption(buf, opt)
+	}
+	for _, name := range from.order {
+		if _, ok := to.sections[name]; ok {
+			continue
+		}
+		if _, ok := to.options[name]; ok {
+			continue
+		}
+		member(name)
+		buf.WriteString("null")
+	}
+	buf.WriteByte('}')
+	return members > 0
+}
+
+//Apply a JSON Merge Patch (RFC 7386) as generated by MergePatch. The patch is atomic: if it fails the cfg is left untouched
+func (cfg *CFG) ApplyMergePatch(patch []byte) error {
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
+	return cfg.applyMergePatchDocument(patch)
+}
+
+func (cfg *CFG) applyMergePatchDocument(patch []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(patch))
+	dec.UseNumber()
+	token, err := dec.Token()
+	if err != nil {
+		return errors.New("Invalid merge patch: " + err.Error())
+	}
+	if token != json.Delim('{') {
+		return errors.New("Merge patches for a cfg have to be JSON objects")
+	}
+	backup := cfg.copyTree()
+	mark := cfg.recordMark()
+	err = cfg.applyMergePatch(dec)
+	if err == nil {
+		dec.Token()
+		if _, eofErr := dec.Token(); eofErr != io.EOF {
+			err = errors.New("Unexpected data after JSON object")
+		}
+	}
+	if err != nil {
+		cfg.replaceContents(backup)
+		cfg.dropRecorded(mark)
+		return errors.New("Invalid merge patch: " + err.Error())
+	}
+	return nil
+}
+
+//Apply the members of a JSON object which opening token has already been read
+func (cfg *CFG) applyMergePatch(dec *json.Decoder) error {
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name := key.(string)
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if token == nil {
+			if sec, ok := cfg.sections[name]; ok {
+				if heir := sec.findHeir(sec.root()); heir != nil {
+					return errors.New(fmt.Sprintf("Section %s is inherited by %s", sec.path(), heir.path()))
+				}
+			}
+			if err := cfg.checkLocked(name, cfg.options[name]); err != nil {
+				return err
+			}
+			cfg.removeChild(name)
+			continue
+		}
+		if _, exists := cfg.sections[name]; !exists && cfg.options[name] == nil {
+			if err := cfg.checkName(name, token != json.Delim('{')); err != nil {
+				return err
+			}
+		}
+		if token == json.Delim('{') {
+			sec, ok := cfg.sections[name]
+			if !ok {
+				if err := cfg.checkLocked(name, cfg.options[name]); err != nil {
+					return err
+				}
+				sec = newCFG()
+				cfg.insertChild(name, cfg.removeChild(name), nil, sec)
+			}
+			if err := sec.applyMergePatch(dec); err != nil {
+				return err
+			}
+			if _, err := dec.Token(); err != nil {
+				return err
+			}
+			continue
+		}
+		value, err := decodeJSONFrom(dec, token)
+		if err != nil {
+			return err
+		}
+		if opt, ok := cfg.options[name]; ok {
+			if err := cfg.checkLocked(name, opt); err != nil {
+				return err
+			}
+			values, err := cfg.checkedValues(name, opt, value.values)
+			if err != nil {
+				return err
+			}
+			opt.value = values
+			cfg.record(OptionSet, cfg.childPath(name), opt.value)
+			continue
+		}
+		if sec, ok := cfg.sections[name]; ok {
+			if heir := sec.findHeir(sec.root()); heir != nil {
+				return errors.New(fmt.Sprintf("Section %s is inherited by %s", sec.path(), heir.path()))
+			}
+		}
+		values, err := cfg.checkedValues(name, nil, value.values)
+		if err != nil {
+			return err
+		}
+		cfg.insertChild(name, cfg.removeChild(name), &option{value: values}, nil)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package cfg
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMergePatch(t *testing.T) {
+	from, err := NewCFGFromString("s1 {\nop1 = a\nop2 = b\ns11 {\nop = x\n}\n}\ns2 {\nop = c\n}\nop3 = d\nop4 = e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := NewCFGFromString("s1 {\nop1 = a\nop2 = B\nop2 += <C>\ns11 {\nop = x\n}\n}\nop3 = d\nop4 {\nop = f\n}\ns3 {\nop = g\n}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch, err := from.MergePatch(to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"s1":{"op2":["B","<C>"]},"op4":{"op":"f"},"s3":{"op":"g"},"s2":null}`
+	if string(patch) != expected {
+		t.Error("Unexpected patch:", string(patch))
+	}
+	if err := from.ApplyMergePatch(patch); err != nil {
+		t.Fatal(err)
+	}
+	if !from.Equal(to) {
+		t.Error("Patched cfg differs:\n" + from.String())
+	}
+	if patch, _ := from.MergePatch(to); string(patch) != "{}" {
+		t.Error("Unexpected patch between equal cfgs:", string(patch))
+	}
+	for _, bad := range []string{`[]`, `{"op3": {"a": [{}]}}`, `{"": "x"}`, `{"a": "b"} x`} {
+		if err := from.ApplyMergePatch([]byte(bad)); err == nil {
+			t.Error("Invalid patch applied:", bad)
+		}
+	}
+	if !from.Equal(to) {
+		t.Error("Failed patches modified the cfg:\n" + from.String())
+	}
+}
+
+func TestMergePatchConcurrentTrees(t *testing.T) {
+	a, _ := NewCFGFromString("s {\n\top = a\n}\n")
+	b, _ := NewCFGFromString("s {\n\top = b\n}\n")
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, tree := range []*CFG{a, b} {
+		wg.Add(1)
+		go func(tree *CFG) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+					tree.SetOption("s/counter", fmt.Sprint(i), "")
+				}
+			}
+		}(tree)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var patched sync.WaitGroup
+		for _, pair := range [][2]*CFG{{a, b}, {b, a}} {
+			patched.Add(1)
+			go func(x, y *CFG) {
+				defer patched.Done()
+				for i := 0; i < 500; i++ {
+					x.MergePatch(y)
+				}
+			}(pair[0], pair[1])
+		}
+		patched.Wait()
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Generating merge patches between two trees in both directions deadlocked")
+	}
+	close(stop)
+	wg.Wait()
+	s, _ := b.GetSection("s")
+	if patch, _ := a.MergePatch(s); string(patch) == "{}" {
+		t.Error("Patch to a section is empty")
+	}
+}
@@ -0,0 +1,131 @@
+package cfg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Collector of config-derived metrics exposed in the Prometheus text exposition format.
+//It does not depend on the Prometheus client library: mount it as an http.Handler or write it from a custom collector
+type Metrics struct {
+	cfg       *CFG
+	namespace string
+	lock      sync.Mutex
+	reloads   uint64
+	failures  uint64
+	lastTime  time.Time
+	lastOK    bool
+	gauges    map[string]string
+}
+
+//Create a metrics collector for the cfg. Metric names are prefixed with namespace ("cfg" if empty)
+func NewMetrics(cfg *CFG, namespace string) *Metrics {
+	if namespace == "" {
+		namespace = "cfg"
+	}
+	return &Metrics{cfg: cfg, namespace: namespace, gauges: make(map[string]string)}
+}
+
+//Record the outcome of a reload of the cfg
+func (m *Metrics) ObserveReload(err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.reloads++
+	if err != nil {
+		m.failures++
+	}
+	m.lastTime = time.Now()
+	m.lastOK = err == nil
+}
+
+//Export the numeric value of the option at path as a gauge named <namespace>_option_<name>
+func (m *Metrics) ExportOption(path string, name string) error {
+	if !validMetricName(name) {
+		return errors.New("Invalid metric name " + name)
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.gauges[name] = path
+	return nil
+}
+
+func validMetricName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for iC, c := range name {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || iC > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+//Count the sections and options of the tree
+func (cfg *CFG) countNodes() (sections int, options int) {
+	for _, sec := range cfg.sections {
+		s, o := sec.countNodes()
+		sections += s + 1
+		options += o
+	}
+	return sections, options + len(cfg.options)
+}
+
+//Write the metrics in the Prometheus text exposition format
+func (m *Metrics) WriteMetrics(w io.Writer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	metric := func(name, kind, help string, value string) {
+		fullName := m.namespace + "_" + name
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", fullName, help, fullName, kind, fullName, value)
+	}
+	metric("reloads_total", "counter", "Number of reloads of the configuration.", strconv.FormatUint(m.reloads, 10))
+	metric("reload_failures_total", "counter", "Number of failed reloads of the configuration.", strconv.FormatUint(m.failures, 10))
+	lastTime, lastOK := "0", "0"
+	if !m.lastTime.IsZero() {
+		lastTime = strconv.FormatFloat(float64(m.lastTime.UnixNano())/1e9, 'f', 3, 64)
+	}
+	if m.lastOK {
+		lastOK = "1"
+	}
+	metric("last_reload_timestamp_seconds", "gauge", "Time of the last reload of the configuration.", lastTime)
+	metric("last_reload_success", "gauge", "Whether the last reload of the configuration succeeded.", lastOK)
+	m.cfg.lock.RLock()
+	sections, options := m.cfg.countNodes()
+	names := make([]string, 0, len(m.gauges))
+	for name := range m.gauges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make(map[string]float64)
+	for _, name := range names {
+		if _, opt := m.cfg.get(SplitPath(m.gauges[name]), true, 0); opt != nil {
+			if f, err := strconv.ParseFloat(strings.Join(opt.value, SplitChar), 64); err == nil {
+				values[name] = f
+			}
+		}
+	}
+	m.cfg.lock.RUnlock()
+	metric("sections", "gauge", "Number of sections in the configuration.", strconv.Itoa(sections))
+	metric("options", "gauge", "Number of options in the configuration.", strconv.Itoa(options))
+	for _, name := range names {
+		if value, ok := values[name]; ok {
+			metric("option_"+name, "gauge", "Value of the configuration option "+m.gauges[name]+".", strconv.FormatFloat(value, 'g', -1, 64))
+		}
+	}
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	m.WriteMetrics(&buf)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,33 @@
+package cfg
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	cfg, _ := NewCFGFromString("s1 {\nlimit = 10\ns11 {\nop = x\n}\n}\nname = srv")
+	m := NewMetrics(cfg, "app")
+	m.ObserveReload(nil)
+	m.ObserveReload(errors.New("broken"))
+	if err := m.ExportOption("/s1/limit", "limit"); err != nil {
+		t.Fatal(err)
+	}
+	m.ExportOption("name", "name")
+	if err := m.ExportOption("name", "bad-name"); err == nil {
+		t.Error("Invalid metric name accepted")
+	}
+	var buf bytes.Buffer
+	m.WriteMetrics(&buf)
+	out := buf.String()
+	for _, expected := range []string{"app_reloads_total 2\n", "app_reload_failures_total 1\n", "app_last_reload_success 0\n", "app_sections 2\n", "app_options 3\n", "# TYPE app_option_limit gauge\napp_option_limit 10\n"} {
+		if !strings.Contains(out, expected) {
+			t.Error("Missing metric:", expected)
+		}
+	}
+	if strings.Contains(out, "app_option_name") {
+		t.Error("Non numeric option exported")
+	}
+}
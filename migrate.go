@@ -0,0 +1,72 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+//Option holding the layout version of a cfg. Sources without it are considered to be version 0
+const SchemaVersionOption = "Meta/SchemaVersion"
+
+//Register a function that upgrades a cfg from fromVersion to fromVersion+1.
+//Once migrations are registered in a root cfg, every load runs them through Migrate
+func (cfg *CFG) RegisterMigration(fromVersion int, migration func(*CFG) error) {
+	cfg.lock.Lock()
+	defer cfg.lock.Unlock()
+	root := cfg.root()
+	if root.migrations == nil {
+		root.migrations = make(map[int]func(*CFG) error)
+	}
+	root.migrations[fromVersion] = migration
+}
+
+//Get the schema version of the cfg as defined in SchemaVersionOption
+func (cfg *CFG) SchemaVersion() (int, error) {
+	value, ok := cfg.Root().GetOption(SchemaVersionOption)
+	if !ok {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Invalid schema version %s", value))
+	}
+	return version, nil
+}
+
+//Upgrade the cfg step by step, running the registered migration for the current schema version until there is none left.
+//After every step SchemaVersionOption is updated so a failed migration leaves the cfg at the last successful version
+func (cfg *CFG) Migrate() error {
+	root := cfg.Root()
+	for {
+		version, err := root.SchemaVersion()
+		if err != nil {
+			return err
+		}
+		root.lock.RLock()
+		migration, ok := root.migrations[version]
+		root.lock.RUnlock()
+		if !ok {
+			return nil
+		}
+		if err := migration(root); err != nil {
+			return errors.New(fmt.Sprintf("Migration from schema version %d failed: %s", version, err.Error()))
+		}
+		if err := root.setSchemaVersion(version + 1); err != nil {
+			return err
+		}
+	}
+}
+
+func (cfg *CFG) setSchemaVersion(version int) error {
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
+	p := SplitPath(SchemaVersionOption)
+	sec, err := cfg.ensureSection(p[:len(p)-1])
+	if err != nil {
+		return err
+	}
+	return sec.setOptionArray(p[len(p)-1], []string{strconv.Itoa(version)}, "")
+}
@@ -0,0 +1,44 @@
+package cfg
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	cfg := NewCFG()
+	cfg.RegisterMigration(0, func(c *CFG) error {
+		v, _ := c.GetOption("host")
+		return c.SetOption("address", v, "")
+	})
+	cfg.RegisterMigration(1, func(c *CFG) error {
+		if _, err := c.CreateSection("DB", ""); err != nil {
+			return err
+		}
+		v, _ := c.GetOption("address")
+		return c.SetOption("DB/Host", v, "")
+	})
+	if err := cfg.LoadFromReader(strings.NewReader("host = db1")); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := cfg.SchemaVersion(); v != 2 {
+		t.Error("Unexpected schema version", v)
+	}
+	if v := cfg.GetValue("DB/Host", ""); v != "db1" {
+		t.Error("Migrations were not applied:", cfg)
+	}
+	cfg.RegisterMigration(2, func(c *CFG) error {
+		return errors.New("broken")
+	})
+	if err := cfg.Migrate(); err == nil || err.Error() != "Migration from schema version 2 failed: broken" {
+		t.Error("Unexpected error:", err)
+	}
+	if v, _ := cfg.SchemaVersion(); v != 2 {
+		t.Error("Failed migration changed the schema version to", v)
+	}
+	bad, _ := NewCFGFromString("Meta {\nSchemaVersion = one\n}")
+	if _, err := bad.SchemaVersion(); err == nil {
+		t.Error("Invalid schema version accepted")
+	}
+}
@@ -0,0 +1,115 @@
+package cfg
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+//Where the entry at name, or the deepest section of it's path that exists, was defined. Empty if it is not known
+func (cfg *CFG) provenance(name string) string {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	source, line := "", 0
+	sec := cfg
+	for _, part := range SplitPath(name) {
+		if opt := sec.getOption(part, true); opt != nil {
+			source, line = opt.source, opt.line
+			break
+		}
+		if sec = sec.getSection(part, true); sec == nil {
+			break
+		}
+		if sec.line > 0 {
+			source, line = sec.source, sec.line
+		}
+	}
+	switch {
+	case line == 0:
+		return ""
+	case source == "":
+		return fmt.Sprintf(" (line %d)", line)
+	}
+	return fmt.Sprintf(" (%s line %d)", source, line)
+}
+
+//Panic because the entry at name could not be read. The message has the path from the root and where the entry (or the section
+//that should hold it) was defined
+func (cfg *CFG) mustPanic(name string, err error) {
+	cfg.lock.RLock()
+	path := cfg.childPath(strings.Join(SplitPath(name), SplitChar))
+	cfg.lock.RUnlock()
+	panic(fmt.Sprintf("cfg: %s: %s%s", path, err.Error(), cfg.provenance(name)))
+}
+
+//Like ResolveOption but panics if the option does not exist. Meant for configuration read at init time, where a missing option is a bug
+func (cfg *CFG) MustGetOptionArray(name string) []string {
+	values, err := cfg.ResolveOption(name)
+	if err != nil {
+		cfg.mustPanic(name, err)
+	}
+	return values
+}
+
+//Like GetOption but panics if the option does not exist
+func (cfg *CFG) MustGetOption(name string) string {
+	value, err := cfg.getSingleValue(name)
+	if err != nil {
+		cfg.mustPanic(name, err)
+	}
+	return value
+}
+
+//Like GetSection but panics if the section does not exist
+func (cfg *CFG) MustGetSection(name string) *CFG {
+	sec, ok := cfg.GetSection(name)
+	if !ok {
+		cfg.mustPanic(name, newError(ErrNotFound, "Section "+name+" does not exist"))
+	}
+	return sec
+}
+
+//Like GetInt but panics if the option does not exist or it is not an int
+func (cfg *CFG) MustGetInt(name string) int {
+	i, err := cfg.GetInt(name)
+	if err != nil {
+		cfg.mustPanic(name, err)
+	}
+	return i
+}
+
+//Like GetFloat64 but panics if the option does not exist or it is not a number
+func (cfg *CFG) MustGetFloat64(name string) float64 {
+	f, err := cfg.GetFloat64(name)
+	if err != nil {
+		cfg.mustPanic(name, err)
+	}
+	return f
+}
+
+//Like GetBool but panics if the option does not exist or it is not a boolean
+func (cfg *CFG) MustGetBool(name string) bool {
+	b, err := cfg.GetBool(name)
+	if err != nil {
+		cfg.mustPanic(name, err)
+	}
+	return b
+}
+
+//Like GetDuration but panics if the option does not exist or it is not a duration
+func (cfg *CFG) MustGetDuration(name string) time.Duration {
+	d, err := cfg.GetDuration(name)
+	if err != nil {
+		cfg.mustPanic(name, err)
+	}
+	return d
+}
+
+//Like Get but panics if the option does not exist or cannot be converted
+func MustGet[T any](cfg *CFG, name string) T {
+	v, err := Get[T](cfg, name)
+	if err != nil {
+		cfg.mustPanic(name, err)
+	}
+	return v
+}
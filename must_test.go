@@ -0,0 +1,45 @@
+package cfg
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func mustPanicMessage(fn func()) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprint(r)
+		}
+	}()
+	fn()
+	return ""
+}
+
+func TestMustGetters(t *testing.T) {
+	cfg, err := NewCFGFromString("Server {\n\tPort = 8080\n\tTimeout = 5s\n\tDebug = maybe\n\tDB {\n\t\tHost = db1\n\t}\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := cfg.MustGetSection("Server")
+	if server.MustGetInt("Port") != 8080 || server.MustGetDuration("Timeout") != 5*time.Second || cfg.MustGetOption("Server/DB/Host") != "db1" {
+		t.Error("Unexpected values")
+	}
+	if MustGet[int](cfg, "Server/Port") != 8080 {
+		t.Error("Unexpected generic value")
+	}
+	cases := []struct {
+		fn       func()
+		expected string
+	}{
+		{func() { server.MustGetOption("DB/User") }, "cfg: Server/DB/User: Option DB/User does not exist (line 5)"},
+		{func() { server.MustGetBool("Debug") }, "cfg: Server/Debug: Option Debug: 'maybe' is not a boolean (line 4)"},
+		{func() { cfg.MustGetSection("Client") }, "cfg: Client: Section Client does not exist"},
+		{func() { cfg.MustGetFloat64("Server/Port/X") }, "cfg: Server/Port/X: Option Server/Port/X does not exist (line 2)"},
+	}
+	for _, c := range cases {
+		if msg := mustPanicMessage(c.fn); msg != c.expected {
+			t.Errorf("Unexpected panic: %q instead of %q", msg, c.expected)
+		}
+	}
+}
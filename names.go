@@ -0,0 +1,87 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+//Characters that cannot be part of names because the name would not be parsed back from a dump
+const forbiddenNameChars = "{}=#" + SplitChar + "\n\r"
+
+//Rules names of new sections and options have to follow on top of the ones imposed by the syntax
+type NameRules struct {
+	//Is the character allowed in names? Any character is allowed if nil
+	Allowed func(r rune) bool
+	//Maximum length of names in characters. 0 means unlimited
+	MaxLength int
+	//Names that cannot be used
+	Reserved []string
+}
+
+//Enforce rules for the names of the sections and options created from now on in this tree (via CreateSection, SetOption or loading
+//contents). Names that cannot be parsed back (with blanks around them, any of "{}=#/" or starting with '@') are always rejected
+func (cfg *CFG) SetNameRules(rules NameRules) {
+	cfg.lock.Lock()
+	defer cfg.lock.Unlock()
+	cfg.root().nameRules = &rules
+}
+
+//Check if name can be used for a new section or option
+func (cfg *CFG) checkName(name string, isOption bool) error {
+	if strings.Trim(name, trimChars) == "" {
+		return errors.New("Names cannot be empty")
+	}
+	if strings.Trim(name, trimChars) != name {
+		return errors.New(fmt.Sprintf("Name '%s' cannot start or end with blanks", name))
+	}
+	if strings.ContainsAny(name, forbiddenNameChars) {
+		return errors.New(fmt.Sprintf("Name '%s' cannot contain any of %q", name, forbiddenNameChars))
+	}
+	if name[0] == '@' {
+		return errors.New(fmt.Sprintf("Name '%s' cannot start with '@', it is reserved for directives", name))
+	}
+	if isOption && name[len(name)-1] == '+' {
+		return errors.New(fmt.Sprintf("Option name '%s' cannot end with '+'", name))
+	}
+	if _, typ := splitTypeHint(name); isOption && typ != "" {
+		return errors.New(fmt.Sprintf("Option name '%s' cannot end with '%s%s', it would be read as a type", name, typeHintChar, typ))
+	}
+	rules := cfg.root().nameRules
+	if rules == nil {
+		return nil
+	}
+	if rules.MaxLength > 0 && utf8.RuneCountInString(name) > rules.MaxLength {
+		return errors.New(fmt.Sprintf("Name '%s' is longer than %d characters", name, rules.MaxLength))
+	}
+	if rules.Allowed != nil {
+		for _, r := range name {
+			if !rules.Allowed(r) {
+				return errors.New(fmt.Sprintf("Character %q is not allowed in name '%s'", r, name))
+			}
+		}
+	}
+	for _, reserved := range rules.Reserved {
+		if name == reserved {
+			return errors.New(fmt.Sprintf("Name '%s' is reserved", name))
+		}
+	}
+	return nil
+}
+
+//Check the names of everything under sec, a detached section about to be attached to this tree
+func (cfg *CFG) checkNames(sec *CFG) error {
+	for _, name := range sec.order {
+		child, isSec := sec.sections[name]
+		if err := cfg.checkName(name, !isSec); err != nil {
+			return err
+		}
+		if isSec {
+			if err := cfg.checkNames(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package cfg
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestNameValidation(t *testing.T) {
+	cfg := NewCFG()
+	for _, name := range []string{"a=b{c", "a}", "a#b", "@define", " padded"} {
+		if _, err := cfg.CreateSection(name, ""); err == nil {
+			t.Error("Created a section with an invalid name:", name)
+		}
+		if err := cfg.SetOption(name, "1", ""); err == nil {
+			t.Error("Created an option with an invalid name:", name)
+		}
+	}
+	if err := cfg.SetOption("a+", "1", ""); err == nil {
+		t.Error("Created an option that would be parsed as an append")
+	}
+	if err := cfg.SetOption("port:int", "abc", ""); err == nil {
+		t.Error("Created an option that would be parsed as typed")
+	}
+	if err := cfg.SetOption("url:x", "y", ""); err != nil {
+		t.Error(err)
+	}
+	if _, err := cfg.CreateSection("C++", ""); err != nil {
+		t.Error(err)
+	}
+	if err := cfg.ApplyMergePatch([]byte(`{"a=b": "1"}`)); err == nil {
+		t.Error("Merge patch created an option with an invalid name")
+	}
+	for _, patch := range []string{
+		`[{"op":"add","path":"/a=b{c","value":"x"}]`,
+		`[{"op":"add","path":"/x~1y","value":"z"}]`,
+		`[{"op":"add","path":"/s","value":{"ok":{"a#b":"1"}}}]`,
+		`[{"op":"add","path":"/port:int","value":"abc"}]`,
+	} {
+		if err := cfg.ApplyJSONPatch([]byte(patch)); err == nil {
+			t.Error("JSON patch created an invalid name:", patch)
+		}
+	}
+	if _, err := NewCFGFromString(cfg.String()); err != nil {
+		t.Error("Dump cannot be parsed back:", err)
+	}
+	cfg.SetNameRules(NameRules{
+		Allowed: func(r rune) bool {
+			return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+		},
+		MaxLength: 8,
+		Reserved:  []string{"Meta"},
+	})
+	if err := cfg.SetOption("Valid_1", "1", ""); err != nil {
+		t.Error(err)
+	}
+	for _, name := range []string{"dash-name", "VeryLongName", "Meta"} {
+		if _, err := cfg.CreateSection(name, ""); err == nil {
+			t.Error("Created a section breaking the rules:", name)
+		}
+	}
+	if err := cfg.LoadFromReader(strings.NewReader("Bad-Name = 1\n")); err == nil || err.Error() != "Character '-' is not allowed in name 'Bad-Name' (line 1)" {
+		t.Error("Unexpected error parsing an invalid name:", err)
+	}
+}
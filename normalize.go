@@ -0,0 +1,69 @@
+package cfg
+
+import (
+	"path"
+	"strings"
+)
+
+type normalizer struct {
+	glob string
+	fn   func(value string) string
+}
+
+//Normalize the values of the options matching pathGlob (relative to the root, '*' does not match SplitChar) with fn when they
+//are set or loaded, for instance lowercasing hostnames, so they are stored in canonical form. Normalizers run in the order they were
+//added and only affect the values written after adding them. fn is called while the tree is locked so it must not use it
+func (cfg *CFG) AddNormalizer(pathGlob string, fn func(value string) string) error {
+	glob := strings.Join(SplitPath(pathGlob), SplitChar)
+	if _, err := path.Match(glob, ""); err != nil {
+		return err
+	}
+	cfg.lock.Lock()
+	defer cfg.lock.Unlock()
+	root := cfg.root()
+	root.normalizers = append(root.normalizers, normalizer{glob, fn})
+	return nil
+}
+
+//Values of the option at path (relative to the root) once normalized
+func (cfg *CFG) normalize(optionPath string, values []string) []string {
+	if len(cfg.normalizers) == 0 {
+		return values
+	}
+	optionPath = strings.Join(SplitPath(optionPath), SplitChar)
+	normalized := values
+	for _, n := range cfg.normalizers {
+		if matched, _ := path.Match(n.glob, optionPath); !matched {
+			continue
+		}
+		//The values may belong to the caller
+		if len(normalized) > 0 && &normalized[0] == &values[0] {
+			normalized = append([]string{}, values...)
+		}
+		for iV, value := range normalized {
+			normalized[iV] = n.fn(value)
+		}
+	}
+	return normalized
+}
+
+//Normalize the values of the options under sec, a detached section about to be attached at path (relative to the root)
+func (cfg *CFG) normalizeSection(path string, sec *CFG) {
+	if len(cfg.normalizers) == 0 {
+		return
+	}
+	for name, opt := range sec.options {
+		opt.value = cfg.normalize(path+SplitChar+name, opt.value)
+	}
+	for name, child := range sec.sections {
+		cfg.normalizeSection(path+SplitChar+name, child)
+	}
+}
+
+//Copy the settings of this root that change how contents are loaded to tree, so trees parsed on the side load as they would here
+func (cfg *CFG) copyParseSettings(tree *CFG) {
+	tree.normalizers = append([]normalizer{}, cfg.normalizers...)
+	tree.nameRules = cfg.nameRules
+	tree.maxLineLength = cfg.maxLineLength
+	tree.mergeSections = cfg.mergeSections
+}
@@ -0,0 +1,82 @@
+package cfg
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizers(t *testing.T) {
+	cfg := NewCFG()
+	if err := cfg.AddNormalizer("*/Host", strings.ToLower); err != nil {
+		t.Fatal(err)
+	}
+	cfg.AddNormalizer("Services/*/URL", func(value string) string { return strings.TrimRight(value, "/") })
+	cfg.AddNormalizer("Services/*/URL", strings.ToLower)
+	if err := cfg.AddNormalizer("[", strings.ToLower); err == nil {
+		t.Error("Added invalid glob")
+	}
+	if err := cfg.LoadFromReader(strings.NewReader("DB {\n\tHost = DB1.Example.com\n}\nServices {\n\tAPI {\n\t\tURL = HTTP://api/\n\t\tURL += http://API2//\n\t}\n}\nHost = KEEP\n")); err != nil {
+		t.Fatal(err)
+	}
+	if v := cfg.GetValue("DB/Host", ""); v != "db1.example.com" {
+		t.Error("Unexpected host:", v)
+	}
+	if v := cfg.GetValueArray("Services/API/URL", nil); !reflect.DeepEqual(v, []string{"http://api", "http://api2"}) {
+		t.Error("Unexpected URLs:", v)
+	}
+	if v := cfg.GetValue("Host", ""); v != "KEEP" {
+		t.Error("Option not matching was normalized:", v)
+	}
+	values := []string{"A.B"}
+	cfg.SetOptionArray("DB/Host", values, "")
+	if v := cfg.GetValue("DB/Host", ""); v != "a.b" || values[0] != "A.B" {
+		t.Error("Unexpected normalization:", v, values)
+	}
+}
+
+func TestNormalizersOtherWrites(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.cfg")
+	if err := ioutil.WriteFile(filename, []byte("Name = abc\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	newTree := func() *CFG {
+		cfg := NewCFG()
+		cfg.AddNormalizer("*", strings.ToUpper)
+		cfg.AddNormalizer("S/*", strings.ToUpper)
+		return cfg
+	}
+	cfg := newTree()
+	if err := NewReloadManager(cfg, filename).Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if v := cfg.GetValue("Name", ""); v != "ABC" {
+		t.Error("Reload did not normalize:", v)
+	}
+	cfg = newTree()
+	if err := cfg.LoadFilesParallel([]string{filename}, MergeLastWins); err != nil {
+		t.Fatal(err)
+	}
+	if v := cfg.GetValue("Name", ""); v != "ABC" {
+		t.Error("Parallel load did not normalize:", v)
+	}
+	if err := cfg.ApplyMergePatch([]byte(`{"Name":"low","New":"new","S":{"a":"x"}}`)); err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string]string{"Name": "LOW", "New": "NEW", "S/a": "X"} {
+		if v := cfg.GetValue(path, ""); v != expected {
+			t.Errorf("Merge patch did not normalize %s: %s", path, v)
+		}
+	}
+	if err := cfg.ApplyJSONPatch([]byte(`[{"op":"replace","path":"/Name","value":"j"},{"op":"add","path":"/New/-","value":"k"},{"op":"add","path":"/Other","value":"l"},{"op":"add","path":"/S","value":{"b":"m"}}]`)); err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string]string{"Name": "J", "New": "NEW/K", "Other": "L", "S/b": "M"} {
+		if v := cfg.GetValue(path, ""); v != expected {
+			t.Errorf("JSON patch did not normalize %s: %s", path, v)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package cfg
+
+import (
+	"context"
+	"strings"
+)
+
+type overridesKey struct{}
+
+//Values overriding the options of a tree in a context. Overrides for other trees or set in outer contexts are in parent
+type ctxOverrides struct {
+	root   *CFG
+	values map[string]string
+	parent *ctxOverrides
+}
+
+//Attach overrides of options of this tree to a context. Keys are option paths relative to this section. The *Ctx getters return
+//the overrides in ctx before looking up the tree, which is not modified, so tests and requests can change values just for them.
+//Overrides set in inner contexts win over the ones set in outer contexts
+func (cfg *CFG) WithOverrides(ctx context.Context, overrides map[string]string) context.Context {
+	cfg.lock.RLock()
+	root := cfg.root()
+	values := make(map[string]string, len(overrides))
+	for name, value := range overrides {
+		values[strings.Join(SplitPath(cfg.childPath(name)), SplitChar)] = value
+	}
+	cfg.lock.RUnlock()
+	parent, _ := ctx.Value(overridesKey{}).(*ctxOverrides)
+	return context.WithValue(ctx, overridesKey{}, &ctxOverrides{root, values, parent})
+}
+
+//Value overriding an option in ctx, if any
+func (cfg *CFG) overridden(ctx context.Context, name string) (string, bool) {
+	layer, _ := ctx.Value(overridesKey{}).(*ctxOverrides)
+	if layer == nil {
+		return "", false
+	}
+	cfg.lock.RLock()
+	root := cfg.root()
+	path := strings.Join(SplitPath(cfg.childPath(name)), SplitChar)
+	cfg.lock.RUnlock()
+	for ; layer != nil; layer = layer.parent {
+		if layer.root != root {
+			continue
+		}
+		if value, ok := layer.values[path]; ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+//GetOptionArray returning the override in ctx if there is one
+func (cfg *CFG) GetOptionArrayCtx(ctx context.Context, name string) ([]string, bool) {
+	if value, ok := cfg.overridden(ctx, name); ok {
+		return []string{value}, true
+	}
+	return cfg.GetOptionArray(name)
+}
+
+//GetOption returning the override in ctx if there is one
+func (cfg *CFG) GetOptionCtx(ctx context.Context, name string) (string, bool) {
+	if value, ok := cfg.overridden(ctx, name); ok {
+		return value, true
+	}
+	return cfg.GetOption(name)
+}
+
+//GetValue returning the override in ctx if there is one
+func (cfg *CFG) GetValueCtx(ctx context.Context, name string, defaultValue string) string {
+	if value, ok := cfg.GetOptionCtx(ctx, name); ok {
+		return value
+	}
+	return defaultValue
+}
+
+//GetValueArray returning the override in ctx if there is one
+func (cfg *CFG) GetValueArrayCtx(ctx context.Context, name string, defaultValue []string) []string {
+	if values, ok := cfg.GetOptionArrayCtx(ctx, name); ok {
+		return values
+	}
+	return defaultValue
+}
@@ -0,0 +1,37 @@
+package cfg
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestOverrides(t *testing.T) {
+	cfg, _ := NewCFGFromString("DB {\n\tHost = db1\n\tPort = 3306\n}\nName = app\n")
+	other, _ := NewCFGFromString("Name = other\n")
+	db, _ := cfg.GetSection("DB")
+	ctx := db.WithOverrides(context.Background(), map[string]string{"Host": "tenant1"})
+	ctx = cfg.WithOverrides(ctx, map[string]string{"Name": "test"})
+	inner := cfg.WithOverrides(ctx, map[string]string{"DB/Host": "tenant2"})
+	if v := cfg.GetValueCtx(ctx, "DB/Host", ""); v != "tenant1" {
+		t.Error("Unexpected override:", v)
+	}
+	if v, _ := db.GetOptionCtx(inner, "Host"); v != "tenant2" {
+		t.Error("Inner override does not win:", v)
+	}
+	if v, _ := cfg.GetOptionCtx(inner, "Name"); v != "test" {
+		t.Error("Outer override lost:", v)
+	}
+	if v := cfg.GetValueArrayCtx(ctx, "DB/Port", nil); !reflect.DeepEqual(v, []string{"3306"}) {
+		t.Error("Unexpected value without override:", v)
+	}
+	if v, _ := other.GetOptionCtx(ctx, "Name"); v != "other" {
+		t.Error("Overrides leaked to another tree:", v)
+	}
+	if v := cfg.GetValue("DB/Host", ""); v != "db1" {
+		t.Error("Overrides changed the tree:", v)
+	}
+	if v, ok := cfg.GetOptionArrayCtx(ctx, "Name"); !ok || !reflect.DeepEqual(v, []string{"test"}) {
+		t.Error("Unexpected override:", v)
+	}
+}
@@ -0,0 +1,148 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+//How LoadFilesParallel resolves the options and anchors defined in more than one file
+type MergePolicy int
+
+const (
+	//The value of the last file wins, like with LoadFiles
+	MergeLastWins MergePolicy = iota
+	//The value of the first file wins
+	MergeFirstWins
+	//Defining an option or anchor in more than one file is an error
+	MergeStrict
+)
+
+//Like LoadFiles but parsing the files concurrently. The parsed files are merged in the order of paths with policy deciding
+//which value an option defined in several of them gets, so the result does not depend on which file is parsed first.
+//Each file is parsed on it's own, so "+=" only appends to values defined in the same file. Nothing is loaded if any file fails
+func (cfg *CFG) LoadFilesParallel(paths []string, policy MergePolicy) error {
+	settings := newCFG()
+	cfg.lock.RLock()
+	cfg.root().copyParseSettings(settings)
+	cfg.lock.RUnlock()
+	type parsed struct {
+		tree  *CFG
+		links []inheritanceLink
+		err   error
+	}
+	results := make([]parsed, len(paths))
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		go func(res *parsed, path string) {
+			defer wg.Done()
+			res.tree = newCFG()
+			res.tree.lock = new(sync.RWMutex)
+			settings.copyParseSettings(res.tree)
+			fi, err := os.Open(path)
+			if err != nil {
+				res.err = err
+				return
+			}
+			defer fi.Close()
+			res.err = res.tree.loadSource(fi, path, &res.links)
+		}(&results[i], path)
+	}
+	wg.Wait()
+	for _, res := range results {
+		if res.err != nil {
+			return res.err
+		}
+	}
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	backup := cfg.copyTree()
+	mark := cfg.recordMark()
+	inheritance_links := make([]inheritanceLink, 0)
+	for iR, res := range results {
+		err := cfg.mergeParsed(res.tree, policy, paths[iR])
+		if err == nil {
+			err = cfg.root().mergeAnchors(res.tree.anchors, policy, paths[iR])
+		}
+		if err != nil {
+			cfg.replaceContents(backup)
+			cfg.dropRecorded(mark)
+			cfg.unlock()
+			return err
+		}
+		//Sections of the parsed tree are now the ones at the same path
+		for _, link := range res.links {
+			sec := cfg
+			if path := link.section.path(); path != "/" {
+				sec, _ = cfg.getString(path, false, 0)
+			}
+			inheritance_links = append(inheritance_links, inheritanceLink{sec, link.inheritance, link.alias})
+		}
+	}
+	return cfg.linkLoaded(inheritance_links)
+}
+
+//Merge the contents of a parsed tree into this section
+func (cfg *CFG) mergeParsed(in *CFG, policy MergePolicy, source string) error {
+	if in.comment != "" && (cfg.comment == "" || policy == MergeLastWins) {
+		cfg.comment = in.comment
+	}
+	for _, name := range in.order {
+		inSec, isSec := in.sections[name]
+		sec, hasSec := cfg.sections[name]
+		opt, hasOpt := cfg.options[name]
+		switch {
+		case isSec && hasOpt || !isSec && hasSec:
+			return errors.New(fmt.Sprintf("%s in %s is both a section and an option", cfg.childPath(name), source))
+		case isSec:
+			if !hasSec {
+				var err error
+				if sec, err = cfg.createSection(name, ""); err != nil {
+					return err
+				}
+			}
+			if err := sec.mergeParsed(inSec, policy, source); err != nil {
+				return err
+			}
+		case hasOpt && policy == MergeStrict:
+			return newError(ErrExists, fmt.Sprintf("Option %s in %s is already defined in %s", cfg.childPath(name), source, opt.source))
+		case hasOpt && policy == MergeFirstWins:
+		default:
+			if err := cfg.checkLocked(name, opt); err != nil {
+				return err
+			}
+			if !hasOpt {
+				cfg.appendChild(name)
+			}
+			cfg.options[name] = in.options[name].copy()
+			cfg.record(OptionSet, cfg.childPath(name), in.options[name].value)
+		}
+	}
+	return nil
+}
+
+//Merge the anchors of a parsed tree into the ones of this root
+func (cfg *CFG) mergeAnchors(in *anchors, policy MergePolicy, source string) error {
+	if in == nil {
+		return nil
+	}
+	for _, name := range in.order {
+		if cfg.anchors != nil {
+			if _, ok := cfg.anchors.values[name]; ok {
+				if policy == MergeStrict {
+					return newError(ErrExists, fmt.Sprintf("Anchor %s in %s is already defined", name, source))
+				}
+				if policy == MergeFirstWins {
+					continue
+				}
+			}
+		}
+		if err := cfg.define(name, in.values[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
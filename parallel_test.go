@@ -0,0 +1,87 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadFilesParallel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgparallel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var paths []string
+	for i, data := range []string{
+		"@define host = db1\nDB {\n\tHost = &host\n\tPort = 3306\n}\n",
+		"DB {\n\tPort = 3307\n}\nApp {< DB\n\tName = app\n}\n",
+		"Log {\n\tLevel = info\n}\n",
+	} {
+		paths = append(paths, filepath.Join(dir, fmt.Sprintf("%d.cfg", i)))
+		if err := ioutil.WriteFile(paths[i], []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := NewCFG()
+	if err := cfg.LoadFilesParallel(paths, MergeLastWins); err != nil {
+		t.Fatal(err)
+	}
+	serial := NewCFG()
+	if err := serial.LoadFiles(paths...); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Equal(serial) {
+		t.Error("Parallel load differs from the serial one:", cfg.String())
+	}
+	if v, _ := cfg.GetOption("App/Host"); v != "db1" {
+		t.Error("Inheritance or anchors not applied:", v)
+	}
+	if source, _ := cfg.OptionSource("DB/Port"); source != paths[1] {
+		t.Error("Unexpected source:", source)
+	}
+	first := NewCFG()
+	if err := first.LoadFilesParallel(paths, MergeFirstWins); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := first.GetOption("App/Port"); v != "3306" {
+		t.Error("First value did not win:", v)
+	}
+	strict := NewCFG()
+	strict.SetOption("Keep", "yes", "")
+	if err := strict.LoadFilesParallel(paths, MergeStrict); !errors.Is(err, ErrExists) {
+		t.Error("Unexpected error:", err)
+	}
+	if strict.String() != "Keep = yes\n" {
+		t.Error("Failed load modified the tree:", strict.String())
+	}
+	if err := NewCFG().LoadFilesParallel(append(paths, filepath.Join(dir, "missing.cfg")), MergeLastWins); err == nil {
+		t.Error("Loaded a missing file")
+	}
+}
+
+func TestLoadFilesParallelMergeSections(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "a.cfg"), filepath.Join(dir, "b.cfg")}
+	ioutil.WriteFile(paths[0], []byte("A {\n\tx = 1\n}\nA {\n\ty = 2\n}\n"), 0644)
+	ioutil.WriteFile(paths[1], []byte("A {\n\tz = 3\n}\n"), 0644)
+	if err := NewCFG().LoadFilesParallel(paths, MergeLastWins); err == nil {
+		t.Error("Loaded a repeated section without merging sections")
+	}
+	cfg := NewCFG()
+	cfg.SetMergeSections(true)
+	if err := cfg.LoadFilesParallel(paths, MergeLastWins); err != nil {
+		t.Fatal(err)
+	}
+	serial := NewCFG()
+	serial.SetMergeSections(true)
+	if err := serial.LoadFiles(paths...); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Equal(serial) || cfg.GetValue("A/y", "") != "2" {
+		t.Error("Parallel load differs from the serial one:", cfg.String())
+	}
+}
@@ -0,0 +1,37 @@
+package cfg
+
+import (
+	"sort"
+	"strings"
+)
+
+type provider struct {
+	prefix []string
+	fn     func(path string) ([]string, bool)
+}
+
+//Answer the lookups of options under pathPrefix (relative to the root) calling fn with the path of the option relative to the prefix.
+//If fn returns false the option is looked up in the tree as usual. The most specific prefix is asked first.
+//fn is called while the tree is locked so it must not use it
+func (cfg *CFG) RegisterProvider(pathPrefix string, fn func(path string) ([]string, bool)) {
+	cfg.lock.Lock()
+	defer cfg.lock.Unlock()
+	root := cfg.root()
+	root.providers = append(root.providers, provider{SplitPath(pathPrefix), fn})
+	sort.SliceStable(root.providers, func(i, j int) bool {
+		return len(root.providers[i].prefix) > len(root.providers[j].prefix)
+	})
+}
+
+//Ask the providers for the option at path
+func (cfg *CFG) provided(path []string) *option {
+	for _, p := range cfg.providers {
+		if len(path) <= len(p.prefix) || strings.Join(path[:len(p.prefix)], SplitChar) != strings.Join(p.prefix, SplitChar) {
+			continue
+		}
+		if values, ok := p.fn(strings.Join(path[len(p.prefix):], SplitChar)); ok {
+			return &option{value: values}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package cfg
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestProviders(t *testing.T) {
+	cfg, _ := NewCFGFromString("Runtime {\n\tRegion = eu\n}\nApp {\n\tCPUs = @{/Runtime/NumCPU}\n}\n")
+	calls := 0
+	cfg.RegisterProvider("/Runtime", func(path string) ([]string, bool) {
+		calls++
+		switch path {
+		case "NumCPU":
+			return []string{strconv.Itoa(calls)}, true
+		case "Hostname":
+			return []string{"host1"}, true
+		}
+		return nil, false
+	})
+	cfg.RegisterProvider("Runtime/Env", func(path string) ([]string, bool) {
+		return []string{"env:" + path}, true
+	})
+	if v, _ := cfg.GetOption("Runtime/Hostname"); v != "host1" {
+		t.Error("Unexpected value:", v)
+	}
+	if v, _ := cfg.GetOption("App/CPUs"); v != "2" {
+		t.Error("Provided value not computed on read:", v)
+	}
+	if v, _ := cfg.GetOption("App/CPUs"); v != "3" {
+		t.Error("Provided value not computed on read:", v)
+	}
+	if v, _ := cfg.GetOption("Runtime/Region"); v != "eu" {
+		t.Error("Option not provided was not read from the tree:", v)
+	}
+	runtime, _ := cfg.GetSection("Runtime")
+	if v, _ := runtime.GetOption("Env/HOME"); v != "env:HOME" {
+		t.Error("Most specific provider not used:", v)
+	}
+}
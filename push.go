@@ -0,0 +1,119 @@
+package cfg
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"net/http"
+)
+
+//Options for the push handler
+type PushOptions struct {
+	//Authenticates and authorizes every push. Returning an error denies it with a 403. Pushes are always denied if nil
+	Authorize func(r *http.Request) error
+	//Have to accept the resulting tree before it replaces the current one. They get the candidate tree while the cfg is locked
+	Validators []Validator
+	//File the tree is saved to after every push. Nothing is saved if empty
+	Filename string
+	//Identity of whoever pushes, sent to the audit sink of the cfg (see SetAuditSink)
+	Actor func(r *http.Request) string
+}
+
+type pushHandler struct {
+	cfg  *CFG
+	opts PushOptions
+}
+
+//Create an http.Handler receiving new contents for the whole tree of cfg: PUT replaces the tree with a text/cfg body and POST merges
+//a text/cfg body (sections defined again are merged and options redefined are replaced) or a JSON merge patch into it.
+//The result is validated, swapped in and saved in a single step, so either everything is applied or nothing is.
+//Watchers get the changes as usual. If-Match is honoured against the hash of the tree, sent back as ETag
+func NewPushHandler(cfg *CFG, opts PushOptions) http.Handler {
+	return &pushHandler{cfg, opts}
+}
+
+func (h *pushHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PUT" && r.Method != "POST" {
+		w.Header().Set("Allow", "PUT, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.opts.Authorize == nil {
+		http.Error(w, "Pushes are not allowed", http.StatusForbidden)
+		return
+	}
+	if err := h.opts.Authorize(r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if h.opts.Actor != nil {
+		r = r.WithContext(WithActor(r.Context(), h.opts.Actor(r)))
+	}
+	contentType := ContentTypeCFG
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		var err error
+		if contentType, _, err = mime.ParseMediaType(ct); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if contentType != ContentTypeCFG && (contentType != ContentTypeMergePatch || r.Method != "POST") {
+		http.Error(w, "Unsupported content type "+contentType, http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = h.cfg.audited(r.Context(), "PUSH", "", func() error {
+		root := h.cfg.root()
+		if !checkPrecondition(w, r, root.hash()) {
+			return errPreconditionFailed
+		}
+		candidate := root.newVersion()
+		var err error
+		switch {
+		case contentType == ContentTypeMergePatch:
+			err = candidate.ApplyMergePatch(body)
+		case r.Method == "PUT":
+			candidate.options = make(map[string]*option)
+			candidate.sections = make(map[string]*CFG)
+			candidate.order = make([]string, 0)
+			candidate.anchors = nil
+			err = candidate.LoadFromReader(bytes.NewReader(body))
+		default:
+			candidate.mergeSections = true
+			err = candidate.LoadFromReader(bytes.NewReader(body))
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return err
+		}
+		for _, v := range h.opts.Validators {
+			if err := v(candidate); err != nil {
+				err = errors.New("Invalid configuration: " + err.Error())
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return err
+			}
+		}
+		backup := root.copyTree()
+		mark := root.recordMark()
+		root.replaceContents(candidate)
+		if h.opts.Filename != "" {
+			if err := root.saveToFile(h.opts.Filename); err != nil {
+				root.replaceContents(backup)
+				root.dropRecorded(mark)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return err
+			}
+		}
+		setETag(w, root.hash())
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	if err == ErrFrozen {
+		http.Error(w, err.Error(), http.StatusForbidden)
+	}
+}
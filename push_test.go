@@ -0,0 +1,73 @@
+package cfg
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPushHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgpush")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "pushed.cfg")
+	cfg, _ := NewCFGFromString("Base {\n\tTimeout = 30\n}\nDB {\n\tHost = db1\n}\n")
+	events := make(chan ChangeEvent, 10)
+	defer cfg.Watch("", func(e ChangeEvent) { events <- e })()
+	h := NewPushHandler(cfg, PushOptions{
+		Authorize: func(r *http.Request) error {
+			if r.Header.Get("Authorization") != "Bearer admin" {
+				return errors.New("Not an admin")
+			}
+			return nil
+		},
+		Validators: []Validator{func(c *CFG) error {
+			if !c.ExistsOption("DB/Host") {
+				return errors.New("DB/Host is required")
+			}
+			return nil
+		}},
+		Filename: filename,
+	})
+	auth := map[string]string{"Authorization": "Bearer admin"}
+	if w := doRequest(h, "POST", "/push", "DB {\n\tPort = 3306\n}\n", nil); w.Code != 403 {
+		t.Error("Unexpected status without credentials:", w.Code)
+	}
+	w := doRequest(h, "POST", "/push", "DB {< Base\n\tPort = 3306\n}\n", auth)
+	if w.Code != 204 || w.Header().Get("ETag") != `"`+cfg.Hash()+`"` {
+		t.Error("Unexpected push response:", w.Code, w.Body.String())
+	}
+	if v, _ := cfg.GetOption("DB/Timeout"); v != "30" || cfg.GetValue("DB/Host", "") != "db1" {
+		t.Error("Partial push not merged:", cfg.String())
+	}
+	if data, _ := ioutil.ReadFile(filename); string(data) != cfg.String() {
+		t.Error("Push not saved:", string(data))
+	}
+	if len(events) != 2 {
+		t.Error("Unexpected events:", len(events))
+	}
+	dump := cfg.String()
+	if w = doRequest(h, "PUT", "/push", "DB {\n\tPort = 1\n}\n", auth); w.Code != 422 {
+		t.Error("Invalid tree accepted:", w.Code)
+	}
+	if w = doRequest(h, "POST", "/push", "DB {< Missing\n}\n", auth); w.Code != 400 {
+		t.Error("Broken tree accepted:", w.Code)
+	}
+	if w = doRequest(h, "POST", "/push", `{"DB":{"Port":"3307"}}`, map[string]string{"Authorization": "Bearer admin", "If-Match": `"old"`, "Content-Type": ContentTypeMergePatch}); w.Code != 412 {
+		t.Error("Unexpected status for a stale push:", w.Code)
+	}
+	if cfg.String() != dump {
+		t.Error("Rejected pushes modified the tree:", cfg.String())
+	}
+	if w = doRequest(h, "POST", "/push", `{"DB":{"Port":"3307"}}`, map[string]string{"Authorization": "Bearer admin", "Content-Type": ContentTypeMergePatch}); w.Code != 204 || cfg.GetValue("DB/Port", "") != "3307" {
+		t.Error("Merge patch not applied:", w.Code, w.Body.String())
+	}
+	if w = doRequest(h, "PUT", "/push", "DB {\n\tHost = db2\n}\n", auth); w.Code != 204 || cfg.String() != "DB {\n\tHost = db2\n}\n" {
+		t.Error("Tree not replaced:", w.Code, cfg.String())
+	}
+}
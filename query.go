@@ -0,0 +1,78 @@
+package cfg
+
+import (
+	"net/url"
+	"sort"
+)
+
+//Options of this section and it's sub sections as query values keyed by their path relative to this section, with every value
+//of an option kept as a repeated key. Only the options defined in each section are included and aliases are left out, as in ToMap
+func (cfg *CFG) ToQuery() url.Values {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	values := make(url.Values)
+	cfg.toQuery("", values)
+	return values
+}
+
+func (cfg *CFG) toQuery(prefix string, values url.Values) {
+	for _, name := range cfg.order {
+		path := joinEventPath(prefix, name)
+		if sec, ok := cfg.sections[name]; ok {
+			if sec.alias == nil {
+				sec.toQuery(path, values)
+			}
+		} else {
+			values[path] = append([]string{}, cfg.options[name].value...)
+		}
+	}
+}
+
+//Set the options in values under the section basePath, creating the missing sections. Keys are option paths relative to basePath
+//as ToQuery writes them and every value of a key becomes a value of the option. Existing options keep their comments.
+//Nothing is changed if any option cannot be set
+func (cfg *CFG) FromQuery(values url.Values, basePath string) error {
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
+	root := cfg.root()
+	backup := root.copyTree()
+	mark := cfg.recordMark()
+	if err := cfg.fromQuery(values, SplitPath(basePath)); err != nil {
+		root.replaceContents(backup)
+		cfg.dropRecorded(mark)
+		return err
+	}
+	return nil
+}
+
+func (cfg *CFG) fromQuery(values url.Values, base []string) error {
+	sec, err := cfg.ensureSection(base)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		p := SplitPath(key)
+		if len(p) == 0 {
+			continue
+		}
+		parent, err := sec.ensureSection(p[:len(p)-1])
+		if err != nil {
+			return err
+		}
+		comment := ""
+		if opt, ok := parent.options[p[len(p)-1]]; ok {
+			comment = opt.comment
+		}
+		if err := parent.setOptionArray(p[len(p)-1], append([]string{}, values[key]...), comment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
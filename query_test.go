@@ -0,0 +1,34 @@
+package cfg
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	cfg, _ := NewCFGFromString("Name = job\nInput {\n\tFiles = a.txt\n\tFiles += b.txt\n}\n@alias Data = Input\n")
+	query := cfg.ToQuery()
+	expected := url.Values{"Name": {"job"}, "Input/Files": {"a.txt", "b.txt"}}
+	if !reflect.DeepEqual(query, expected) {
+		t.Error("Unexpected query:", query)
+	}
+	if encoded := query.Encode(); encoded != "Input%2FFiles=a.txt&Input%2FFiles=b.txt&Name=job" {
+		t.Error("Unexpected encoding:", encoded)
+	}
+	dest, _ := NewCFGFromString("Jobs {\n\t#The name\n\tName = old\n}\n")
+	if err := dest.FromQuery(query, "Jobs"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dest.GetValueArray("Jobs/Input/Files", nil), []string{"a.txt", "b.txt"}) || dest.GetValue("Jobs/Name", "") != "job" {
+		t.Error("Unexpected tree:", dest.String())
+	}
+	if _, opt := dest.getString("Jobs/Name", false, 0); opt == nil || opt.comment != "The name" {
+		t.Error("Lost comment:", dest.String())
+	}
+	if err := dest.FromQuery(url.Values{"A": {"1"}, "Jobs/Input/Files/X": {"1"}}, ""); err == nil {
+		t.Error("Set an option under another option")
+	} else if dest.Exists("A") {
+		t.Error("Failed query was partially applied")
+	}
+}
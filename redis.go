@@ -0,0 +1,148 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//Minimal Redis client API needed to store a cfg in a Redis hash. Adapt your Redis client to it
+type RedisClient interface {
+	HGetAll(key string) (map[string]string, error)
+	//Replace the whole hash with fields atomically (for instance DEL and HSET inside MULTI/EXEC)
+	HReplace(key string, fields map[string]string) error
+	//Subscribe to a pub/sub channel. Messages are delivered to the returned channel until cancel is called
+	Subscribe(channel string) (messages <-chan string, cancel func(), err error)
+}
+
+//The tree is stored flattened in a single hash. Every section has a field with it's path followed by SplitChar holding
+//the path of the section it inherits from (or empty), and every option a field with it's path holding the values separated by new lines
+const redisValueSeparator = "\n"
+
+//Store the cfg in the Redis hash under key, replacing any previous contents. Comments are not stored
+func (cfg *CFG) SaveToRedis(client RedisClient, key string) error {
+	cfg.lock.RLock()
+	fields := make(map[string]string)
+	cfg.flattenRedis("", fields)
+	cfg.lock.RUnlock()
+	return client.HReplace(key, fields)
+}
+
+func (cfg *CFG) flattenRedis(prefix string, fields map[string]string) {
+	for _, name := range cfg.order {
+		if sec, ok := cfg.sections[name]; ok {
+			inheritance := ""
+			if sec.inheritance != nil {
+				inheritance = sec.inheritance.path()
+			}
+			fields[prefix+name+SplitChar] = inheritance
+			sec.flattenRedis(prefix+name+SplitChar, fields)
+		} else {
+			fields[prefix+name] = strings.Join(cfg.options[name].value, redisValueSeparator)
+		}
+	}
+}
+
+//Load a cfg from the Redis hash under key as stored by SaveToRedis. As hashes are not ordered, sections and options are sorted by name
+func LoadFromRedis(client RedisClient, key string) (*CFG, error) {
+	fields, err := client.HGetAll(key)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	cfg := NewCFG()
+	links := make([]inheritanceLink, 0)
+	cfg.lock.Lock()
+	for _, name := range names {
+		p := SplitPath(name)
+		if len(p) == 0 {
+			cfg.lock.Unlock()
+			return nil, errors.New(fmt.Sprintf("Invalid field '%s' in %s", name, key))
+		}
+		if strings.HasSuffix(name, SplitChar) {
+			sec, err := cfg.ensureSection(p)
+			if err != nil {
+				cfg.lock.Unlock()
+				return nil, err
+			}
+			if fields[name] != "" {
+				links = append(links, inheritanceLink{sec, fields[name], false})
+			}
+			continue
+		}
+		if len(p) > 1 {
+			if _, err := cfg.ensureSection(p[:len(p)-1]); err != nil {
+				cfg.lock.Unlock()
+				return nil, err
+			}
+		}
+		if err := cfg.setOptionArray(name, strings.Split(fields[name], redisValueSeparator), ""); err != nil {
+			cfg.lock.Unlock()
+			return nil, err
+		}
+	}
+	cfg.lock.Unlock()
+	for _, link := range links {
+		if err := link.section.SetInheritance(link.inheritance); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+//Store keeping the tree in a Redis hash as SaveToRedis does. Watch uses keyspace notifications (notify-keyspace-events must include
+//hash and generic events) of the database DB
+type RedisStore struct {
+	Client RedisClient
+	Key    string
+	DB     int
+}
+
+func (s *RedisStore) Load() (*CFG, error) {
+	return LoadFromRedis(s.Client, s.Key)
+}
+
+func (s *RedisStore) Save(cfg *CFG) error {
+	return cfg.SaveToRedis(s.Client, s.Key)
+}
+
+func (s *RedisStore) Watch(changed func()) (func(), error) {
+	messages, cancel, err := s.Client.Subscribe(fmt.Sprintf("__keyspace@%d__:%s", s.DB, s.Key))
+	if err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case _, ok := <-messages:
+				if !ok {
+					return
+				}
+			}
+			changed()
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			cancel()
+		})
+	}, nil
+}
+
+//Keep cfg in sync with the Redis hash under key using keyspace notifications (notify-keyspace-events must include hash and generic events).
+//db is the Redis database holding the key. Errors loading the hash are sent to onError (if not nil) and the current contents are kept.
+//Call the returned function to stop following Redis
+func FollowRedis(cfg *CFG, client RedisClient, key string, db int, onError func(error)) (stop func(), err error) {
+	return Follow(cfg, &RedisStore{client, key, db}, onError)
+}
@@ -0,0 +1,86 @@
+package cfg
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+//In memory Redis hashes with keyspace notifications
+type fakeRedis struct {
+	lock        sync.Mutex
+	hashes      map[string]map[string]string
+	subscribers map[string][]chan string
+}
+
+func (r *fakeRedis) HGetAll(key string) (map[string]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	fields := make(map[string]string)
+	for k, v := range r.hashes[key] {
+		fields[k] = v
+	}
+	return fields, nil
+}
+
+func (r *fakeRedis) HReplace(key string, fields map[string]string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.hashes[key] = fields
+	for _, c := range r.subscribers["__keyspace@0__:"+key] {
+		c <- "hset"
+	}
+	return nil
+}
+
+func (r *fakeRedis) Subscribe(channel string) (<-chan string, func(), error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	c := make(chan string, 10)
+	r.subscribers[channel] = append(r.subscribers[channel], c)
+	return c, func() {}, nil
+}
+
+func TestRedis(t *testing.T) {
+	redis := &fakeRedis{hashes: make(map[string]map[string]string), subscribers: make(map[string][]chan string)}
+	data := "Base\n{\n\tPort = 80\n}\nName = test\nWeb\n{ < Base\n\tEmpty\n\t{\n\t}\n\tHosts = a\n\tHosts += b\n}\n"
+	cfg, err := NewCFGFromString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SaveToRedis(redis, "app"); err != nil {
+		t.Fatal(err)
+	}
+	if redis.hashes["app"]["Web/"] != "Base" || redis.hashes["app"]["Web/Hosts"] != "a\nb" {
+		t.Error("Unexpected hash contents:", redis.hashes["app"])
+	}
+	loaded, err := LoadFromRedis(redis, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Equal(cfg) {
+		t.Error("Loaded tree differs:\n" + loaded.String())
+	}
+	if port, _ := loaded.GetOption("Web/Port"); port != "80" {
+		t.Error("Inheritance was not restored")
+	}
+
+	followed := NewCFG()
+	changes := make(chan ChangeEvent, 10)
+	stop, err := FollowRedis(followed, redis, "app", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	followed.Watch("Name", func(e ChangeEvent) { changes <- e })
+	cfg.SetOption("Name", "changed", "")
+	cfg.SaveToRedis(redis, "app")
+	select {
+	case e := <-changes:
+		if e.Kind != OptionSet || e.Value[0] != "changed" {
+			t.Error("Unexpected change:", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Change was not followed")
+	}
+}
@@ -0,0 +1,175 @@
+package cfg
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//A place the contents of a cfg can be fetched from. Backends like etcd or Consul only need to implement Fetch to be refreshed periodically
+type Source interface {
+	Fetch(ctx context.Context) ([]byte, error)
+}
+
+//Source fetching a cfg via HTTP GET
+type URLSource struct {
+	URL string
+	//Client to use. http.DefaultClient if nil
+	Client *http.Client
+}
+
+func (s *URLSource) Fetch(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequest("GET", s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(fmt.Sprintf("Unexpected status %s fetching %s", resp.Status, s.URL))
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+//Options of a Refresher
+type RefresherOptions struct {
+	//Time between fetches. Defaults to one minute
+	Interval time.Duration
+	//Random variation applied to every wait as a fraction of it (0.1 means +-10%)
+	Jitter float64
+	//Upper limit of the wait after consecutive failures, which doubles with each one. Defaults to ten times the interval
+	MaxBackoff time.Duration
+	//Called with every failed refresh
+	OnError func(error)
+}
+
+//Periodically fetches a cfg from a Source and replaces the contents of a cfg with it when they change.
+//Watchers of the cfg only get events for the paths that actually changed
+type Refresher struct {
+	cfg      *CFG
+	source   Source
+	sync     func(ctx context.Context) (bool, error)
+	opts     RefresherOptions
+	lock     sync.Mutex
+	lastHash [sha256.Size]byte
+	fetched  bool
+	failures uint
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func NewRefresher(cfg *CFG, source Source, opts RefresherOptions) *Refresher {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Minute
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 10 * opts.Interval
+	}
+	r := &Refresher{cfg: cfg, source: source, opts: opts}
+	r.sync = r.refresh
+	return r
+}
+
+//Fetch the source now and update the cfg if the contents changed since the last fetch
+func (r *Refresher) Refresh(ctx context.Context) (changed bool, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	changed, err = r.sync(ctx)
+	if err != nil {
+		r.failures++
+	} else {
+		r.failures = 0
+	}
+	return
+}
+
+func (r *Refresher) refresh(ctx context.Context) (bool, error) {
+	data, err := r.source.Fetch(ctx)
+	if err != nil {
+		return false, err
+	}
+	hash := sha256.Sum256(data)
+	if r.fetched && hash == r.lastHash {
+		return false, nil
+	}
+	candidate := NewCFG()
+	if err := candidate.LoadFromReader(bytes.NewReader(data)); err != nil {
+		return false, err
+	}
+	if err := r.cfg.writeLock(); err != nil {
+		return false, err
+	}
+	r.cfg.replaceContents(candidate)
+	r.cfg.unlock()
+	r.lastHash = hash
+	r.fetched = true
+	return true, nil
+}
+
+//Time to wait before the next fetch
+func (r *Refresher) nextWait() time.Duration {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	wait := r.opts.Interval
+	for iF := uint(0); iF < r.failures && wait < r.opts.MaxBackoff; iF++ {
+		wait *= 2
+	}
+	if wait > r.opts.MaxBackoff {
+		wait = r.opts.MaxBackoff
+	}
+	if r.opts.Jitter > 0 {
+		wait += time.Duration((rand.Float64()*2 - 1) * r.opts.Jitter * float64(wait))
+	}
+	return wait
+}
+
+//Start refreshing in the background. The first fetch happens right away
+func (r *Refresher) Start() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	go func(done chan struct{}) {
+		defer close(done)
+		for {
+			if _, err := r.Refresh(ctx); err != nil && r.opts.OnError != nil && ctx.Err() == nil {
+				r.opts.OnError(err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(r.nextWait()):
+			}
+		}
+	}(r.done)
+}
+
+//Stop refreshing and wait for the background fetch to finish
+func (r *Refresher) Stop() {
+	r.lock.Lock()
+	cancel, done := r.cancel, r.done
+	r.cancel = nil
+	r.lock.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
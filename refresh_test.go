@@ -0,0 +1,97 @@
+package cfg
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testSource struct {
+	lock sync.Mutex
+	data string
+	err  error
+}
+
+func (s *testSource) Fetch(ctx context.Context) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return []byte(s.data), s.err
+}
+
+func TestRefresher(t *testing.T) {
+	cfg := NewCFG()
+	events := make([]string, 0)
+	cfg.Watch("", func(e ChangeEvent) { events = append(events, e.Path) })
+	source := &testSource{data: "s1 {\nop1 = a\nop2 = b\n}"}
+	r := NewRefresher(cfg, source, RefresherOptions{})
+	if changed, err := r.Refresh(context.Background()); !changed || err != nil {
+		t.Fatal("First refresh failed", err)
+	}
+	if changed, _ := r.Refresh(context.Background()); changed {
+		t.Error("Refreshed unchanged contents")
+	}
+	source.data = "s1 {\nop1 = a\nop2 = c\n}"
+	events = events[:0]
+	if changed, err := r.Refresh(context.Background()); !changed || err != nil {
+		t.Fatal("Refresh failed", err)
+	}
+	if len(events) != 1 || events[0] != "s1/op2" {
+		t.Error("Unexpected events:", events)
+	}
+	source.err = errors.New("down")
+	if _, err := r.Refresh(context.Background()); err == nil {
+		t.Error("Fetch error not reported")
+	}
+	source.err = nil
+	source.data = "a = 1\na = 2"
+	if _, err := r.Refresh(context.Background()); err == nil {
+		t.Error("Parse error not reported")
+	}
+	if v := cfg.GetValue("s1/op2", ""); v != "c" {
+		t.Error("Failed refreshes modified the cfg")
+	}
+}
+
+func TestRefresherBackoff(t *testing.T) {
+	r := NewRefresher(NewCFG(), &testSource{}, RefresherOptions{Interval: time.Second, MaxBackoff: 5 * time.Second})
+	for failures, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		r.failures = uint(failures)
+		if wait := r.nextWait(); wait != expected {
+			t.Errorf("Unexpected wait after %d failures: %s", failures, wait)
+		}
+	}
+	r = NewRefresher(NewCFG(), &testSource{}, RefresherOptions{Interval: time.Second, Jitter: 0.5})
+	for iT := 0; iT < 20; iT++ {
+		if wait := r.nextWait(); wait < 500*time.Millisecond || wait > 1500*time.Millisecond {
+			t.Error("Jitter out of range:", wait)
+		}
+	}
+}
+
+func TestURLSourceRefresher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("op = remote"))
+	}))
+	defer server.Close()
+	cfg := NewCFG()
+	updated := make(chan struct{}, 1)
+	cfg.Watch("op", func(e ChangeEvent) { updated <- struct{}{} })
+	r := NewRefresher(cfg, &URLSource{URL: server.URL}, RefresherOptions{Interval: time.Hour})
+	r.Start()
+	defer r.Stop()
+	select {
+	case <-updated:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Refresher didn't fetch the source")
+	}
+	if v := cfg.GetValue("op", ""); v != "remote" {
+		t.Error("Unexpected value:", v)
+	}
+	if _, err := (&URLSource{URL: server.URL + "/%zz"}).Fetch(context.Background()); err == nil {
+		t.Error("Invalid URL accepted")
+	}
+}
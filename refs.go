@@ -0,0 +1,177 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//Start of a reference to another option: "@{/Systems/DB/Host}" from the root or "@{Host}" relative to the section the option is read from
+const referencePrefix = "@{"
+
+//Read the values of an option with anchors and references to other options resolved. Must hold the read lock
+func (cfg *CFG) readValues(name string) ([]string, error) {
+	values, err := cfg.lookupValues(name)
+	if err == errMissingOption {
+		return nil, newError(ErrNotFound, fmt.Sprintf("Option %s does not exist", name))
+	}
+	return values, err
+}
+
+//Same as readValues but failing with errMissingOption, so reading options that do not exist does not allocate
+func (cfg *CFG) lookupValues(name string) ([]string, error) {
+	opt := cfg.lookupOption(name)
+	if opt == nil {
+		return nil, errMissingOption
+	}
+	values := cfg.root().anchors.resolve(opt.value)
+	if !hasReferences(values) {
+		return values, nil
+	}
+	p := SplitPath(name)
+	sec := cfg
+	if len(p) > 1 {
+		if sec, _ = cfg.get(p, true, 1); sec == nil {
+			//Found via a fallback or rename
+			sec = cfg
+		}
+	}
+	return sec.expandReferences(values, []string{sec.childPath(p[len(p)-1])})
+}
+
+func hasReferences(values []string) bool {
+	for _, value := range values {
+		if strings.Contains(value, referencePrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+//Replace the references in values. A value that is a single reference gets all the values of the referenced option.
+//References within text must point to options with one value. stack holds the options being resolved to detect cycles
+func (cfg *CFG) expandReferences(values []string, stack []string) ([]string, error) {
+	var expanded []string
+	for iV, value := range values {
+		if !strings.Contains(value, referencePrefix) {
+			if expanded != nil {
+				expanded = append(expanded, value)
+			}
+			continue
+		}
+		if expanded == nil {
+			expanded = append([]string{}, values[:iV]...)
+		}
+		var out strings.Builder
+		spliced := false
+		for {
+			start := strings.Index(value, referencePrefix)
+			if start < 0 {
+				out.WriteString(value)
+				break
+			}
+			end := strings.IndexByte(value[start:], '}')
+			if end < 0 {
+				return nil, errors.New("Unterminated reference in " + value)
+			}
+			ref := value[start+len(referencePrefix) : start+end]
+			refValues, err := cfg.resolveReference(ref, stack)
+			if err != nil {
+				return nil, err
+			}
+			if start == 0 && end == len(value)-1 && out.Len() == 0 {
+				expanded = append(expanded, refValues...)
+				spliced = true
+				break
+			}
+			if len(refValues) != 1 {
+				return nil, errors.New(fmt.Sprintf("Option %s referenced within a value has %d values", ref, len(refValues)))
+			}
+			out.WriteString(value[:start])
+			out.WriteString(refValues[0])
+			value = value[start+end+1:]
+		}
+		if !spliced {
+			expanded = append(expanded, out.String())
+		}
+	}
+	if expanded == nil {
+		return values, nil
+	}
+	return expanded, nil
+}
+
+func (cfg *CFG) resolveReference(ref string, stack []string) ([]string, error) {
+	from := cfg
+	if strings.HasPrefix(ref, SplitChar) {
+		from = cfg.root()
+	}
+	p := SplitPath(ref)
+	if len(p) == 0 {
+		return nil, errors.New("Empty reference in " + stack[len(stack)-1])
+	}
+	sec := from
+	if len(p) > 1 {
+		sec, _ = from.get(p, true, 1)
+	}
+	var opt *option
+	if sec != nil {
+		opt = from.lookupOption(strings.Join(p, SplitChar))
+	}
+	if opt == nil {
+		return nil, newError(ErrNotFound, fmt.Sprintf("Option %s referenced by %s does not exist", ref, stack[len(stack)-1]))
+	}
+	id := sec.childPath(p[len(p)-1])
+	for iS, other := range stack {
+		if other == id {
+			return nil, errors.New("Circular reference: " + strings.Join(append(stack[iS:], id), " -> "))
+		}
+	}
+	return sec.expandReferences(cfg.root().anchors.resolve(opt.value), append(stack, id))
+}
+
+//Replace the references to other options in all the options under this section by the values they point to.
+//Nothing is changed if any reference cannot be resolved
+func (cfg *CFG) Resolve() error {
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
+	type resolved struct {
+		sec    *CFG
+		name   string
+		values []string
+	}
+	var changes []resolved
+	var walk func(sec *CFG) error
+	walk = func(sec *CFG) error {
+		for _, name := range sec.order {
+			if sub, ok := sec.sections[name]; ok {
+				if sub.alias == nil {
+					if err := walk(sub); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			values := sec.options[name].value
+			expanded, err := sec.expandReferences(values, []string{sec.childPath(name)})
+			if err != nil {
+				return err
+			}
+			if !equalValues(values, expanded) {
+				changes = append(changes, resolved{sec, name, expanded})
+			}
+		}
+		return nil
+	}
+	if err := walk(cfg); err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if err := change.sec.setOptionArray(change.name, change.values, change.sec.options[change.name].comment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
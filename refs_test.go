@@ -0,0 +1,46 @@
+package cfg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReferences(t *testing.T) {
+	data := "Systems {\n\tDB {\n\t\tHost = db1\n\t\tReplicas = r1\n\t\tReplicas += r2\n\t}\n}\nBase {\n\tURL = mysql://@{Host}:@{/Systems/DB/Port}\n}\nApp {< Base\n\tHost = @{/Systems/DB/Host}\n\tReplicas = @{/Systems/DB/Replicas}\n\tReplicas += r3\n}\nLoop {\n\ta = @{b}\n\tb = x@{c}\n\tc = @{a}\n}\n"
+	cfg, err := NewCFGFromString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := cfg.GetOption("App/Host"); !ok || v != "db1" {
+		t.Error("Unexpected value:", v, ok)
+	}
+	if v, _ := cfg.GetOptionArray("App/Replicas"); !equalSlices(v, []string{"r1", "r2", "r3"}) {
+		t.Error("Unexpected values:", v)
+	}
+	if _, err := cfg.ResolveOption("App/URL"); !errors.Is(err, ErrNotFound) {
+		t.Error("Unexpected error:", err)
+	}
+	cfg.SetOption("Systems/DB/Port", "3306", "")
+	if v, _ := cfg.GetOption("App/URL"); v != "mysql://db1:3306" {
+		t.Error("Reference not resolved relative to the section read:", v)
+	}
+	if _, err := cfg.ResolveOption("Loop/a"); err == nil || err.Error() != "Circular reference: Loop/a -> Loop/b -> Loop/c -> Loop/a" {
+		t.Error("Unexpected error:", err)
+	}
+	if err := cfg.Resolve(); err == nil {
+		t.Error("Resolved a loop")
+	}
+	if v, _ := cfg.GetSection("App"); v.String() != "Host = @{/Systems/DB/Host}\nReplicas = @{/Systems/DB/Replicas}\nReplicas += r3\n" {
+		t.Error("Failed resolution changed the tree:", v.String())
+	}
+	if err := cfg.Resolve(); err == nil {
+		t.Error("Resolved a missing reference")
+	}
+	app, _ := cfg.GetSection("App")
+	if err := app.Resolve(); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := cfg.GetSection("App"); v.String() != "Host = db1\nReplicas = r1\nReplicas += r2\nReplicas += r3\n" {
+		t.Error("Unexpected resolution:", v.String())
+	}
+}
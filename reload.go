@@ -0,0 +1,313 @@
+package cfg
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+//Checks a candidate cfg before it replaces the current one. Returning an error aborts the reload
+type Validator func(candidate *CFG) error
+
+//Ties a cfg to a file and reloads it when asked to or when the process receives a signal (SIGHUP by default).
+//The new contents are parsed and validated before replacing the current ones in a single step, so readers never see a partial tree
+type ReloadManager struct {
+	cfg        *CFG
+	filename   string
+	lock       sync.Mutex
+	validators []Validator
+	required   []string
+	callbacks  []func(error)
+	metrics    *Metrics
+	signals    chan os.Signal
+	done       chan struct{}
+	polling    chan struct{}
+	health     func() error
+	grace      time.Duration
+	interval   time.Duration
+	//Closed to stop checking the health after the last reload
+	monitoring chan struct{}
+}
+
+//Create a reload manager for cfg using the contents of filename
+func NewReloadManager(cfg *CFG, filename string) *ReloadManager {
+	return &ReloadManager{cfg: cfg, filename: filename}
+}
+
+//Add a validator that has to accept the candidate tree before it is swapped in
+func (rm *ReloadManager) AddValidator(v Validator) {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	rm.validators = append(rm.validators, v)
+}
+
+//Require the options at paths (relative to the managed cfg) to exist in every reloaded tree
+func (rm *ReloadManager) Require(paths ...string) {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	rm.required = append(rm.required, paths...)
+}
+
+//Call check every interval during grace after each reload. If it fails, the contents the cfg had before the reload are restored,
+//watchers get a RolledBack event and the reload callbacks get the error. Nothing is restored if the cfg changed after the reload
+func (rm *ReloadManager) SetHealthCheck(check func() error, grace time.Duration, interval time.Duration) {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	rm.health = check
+	rm.grace = grace
+	rm.interval = interval
+}
+
+//Register a callback that receives the outcome (nil on success) of every reload
+func (rm *ReloadManager) OnReload(fn func(error)) {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	rm.callbacks = append(rm.callbacks, fn)
+}
+
+//Record every reload in m
+func (rm *ReloadManager) SetMetrics(m *Metrics) {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	rm.metrics = m
+}
+
+//Load a candidate tree from the file applying the migrations registered in the managed cfg
+func (rm *ReloadManager) loadCandidate() (*CFG, error) {
+	candidate := NewCFG()
+	rm.cfg.lock.RLock()
+	root := rm.cfg.root()
+	candidate.migrations = root.migrations
+	root.copyParseSettings(candidate)
+	rm.cfg.lock.RUnlock()
+	if err := candidate.LoadFiles(rm.filename); err != nil {
+		return nil, err
+	}
+	return candidate, nil
+}
+
+//Reload the file now. The cfg is only modified if the file parses and all validators accept it
+func (rm *ReloadManager) Reload() error {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	err := rm.reload()
+	rm.logReload(err)
+	if rm.metrics != nil {
+		rm.metrics.ObserveReload(err)
+	}
+	for _, fn := range rm.callbacks {
+		fn(err)
+	}
+	return err
+}
+
+//Load the candidate tree and check it with the validators and required options
+func (rm *ReloadManager) check() (*CFG, error) {
+	candidate, err := rm.loadCandidate()
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range rm.required {
+		if !candidate.ExistsOption(path) {
+			return nil, newError(ErrNotFound, "Invalid configuration: required option "+path+" does not exist")
+		}
+	}
+	for _, v := range rm.validators {
+		if err := v(candidate); err != nil {
+			return nil, errors.New("Invalid configuration: " + err.Error())
+		}
+	}
+	return candidate, nil
+}
+
+//Check the file as Reload would and return the changes reloading it would make, without modifying the cfg
+func (rm *ReloadManager) DryRun() ([]ChangeEvent, error) {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	candidate, err := rm.check()
+	if err != nil {
+		return nil, err
+	}
+	rm.cfg.lock.RLock()
+	defer rm.cfg.lock.RUnlock()
+	return diffChanges(rm.cfg.childPath(""), rm.cfg, candidate), nil
+}
+
+func (rm *ReloadManager) reload() error {
+	candidate, err := rm.check()
+	if err != nil {
+		return err
+	}
+	if err := rm.cfg.writeLock(); err != nil {
+		return err
+	}
+	var previous *CFG
+	if rm.health != nil {
+		previous = rm.cfg.copyTree()
+	}
+	rm.cfg.replaceContents(candidate)
+	reloaded := rm.cfg.hash()
+	rm.cfg.unlock()
+	if previous != nil {
+		rm.monitor(previous, reloaded)
+	}
+	return nil
+}
+
+//Report the result of a reload to the logger of the cfg, if any
+func (rm *ReloadManager) logReload(err error) {
+	logger := rm.cfg.getLogger()
+	if logger == nil {
+		return
+	}
+	if err == nil {
+		logger.Info("Reloaded", "origin", rm.filename)
+		return
+	}
+	if pErr, ok := err.(*ParseError); ok {
+		logger.Error("Reload failed", "origin", rm.filename, "line", pErr.Line, "error", err.Error())
+		return
+	}
+	logger.Error("Reload failed", "origin", rm.filename, "error", err.Error())
+}
+
+//Check the health during the grace period after a reload, replacing the check of the previous one
+func (rm *ReloadManager) monitor(previous *CFG, reloaded string) {
+	if rm.monitoring != nil {
+		close(rm.monitoring)
+	}
+	done := make(chan struct{})
+	rm.monitoring = done
+	go func(check func() error, grace time.Duration, interval time.Duration) {
+		deadline := time.After(grace)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := check(); err != nil {
+					rm.rollback(done, previous, reloaded, err)
+					return
+				}
+			case <-deadline:
+				return
+			case <-done:
+				return
+			}
+		}
+	}(rm.health, rm.grace, rm.interval)
+}
+
+func (rm *ReloadManager) rollback(done chan struct{}, previous *CFG, reloaded string, cause error) {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	select {
+	case <-done:
+		//A newer reload or Stop took over
+		return
+	default:
+	}
+	close(done)
+	rm.monitoring = nil
+	err := rm.restore(previous, reloaded)
+	if err == nil {
+		err = errors.New("Reload rolled back: " + cause.Error())
+	}
+	if logger := rm.cfg.getLogger(); logger != nil {
+		logger.Warn("Reload rolled back", "origin", rm.filename, "cause", cause.Error(), "error", err.Error())
+	}
+	for _, fn := range rm.callbacks {
+		fn(err)
+	}
+}
+
+//Put back the contents the cfg had before a reload if it has not changed since
+func (rm *ReloadManager) restore(previous *CFG, reloaded string) error {
+	if err := rm.cfg.writeLock(); err != nil {
+		return err
+	}
+	defer rm.cfg.unlock()
+	if rm.cfg.hash() != reloaded {
+		return errors.New("Cannot roll back the reload, the configuration changed after it")
+	}
+	rm.cfg.replaceContents(previous)
+	rm.cfg.record(RolledBack, rm.cfg.childPath(""), nil)
+	return nil
+}
+
+//Start reloading when any of the signals (SIGHUP if none is given) is received
+func (rm *ReloadManager) Start(signals ...os.Signal) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	if rm.signals != nil {
+		return
+	}
+	rm.signals = make(chan os.Signal, 1)
+	rm.done = make(chan struct{})
+	signal.Notify(rm.signals, signals...)
+	go func(sigs chan os.Signal, done chan struct{}) {
+		for {
+			select {
+			case <-sigs:
+				rm.Reload()
+			case <-done:
+				return
+			}
+		}
+	}(rm.signals, rm.done)
+}
+
+//Reload whenever the modification time or size of the file changes, checking them every interval.
+//Reloads are incremental: only the options and sections that actually changed are modified and announced to the watchers
+func (rm *ReloadManager) WatchFile(interval time.Duration) {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	if rm.polling != nil {
+		return
+	}
+	rm.polling = make(chan struct{})
+	last, _ := os.Stat(rm.filename)
+	go func(done chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				info, err := os.Stat(rm.filename)
+				if err != nil || last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+					continue
+				}
+				last = info
+				rm.Reload()
+			case <-done:
+				return
+			}
+		}
+	}(rm.polling)
+}
+
+//Stop listening for signals, watching the file and checking the health after the last reload
+func (rm *ReloadManager) Stop() {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	if rm.polling != nil {
+		close(rm.polling)
+		rm.polling = nil
+	}
+	if rm.monitoring != nil {
+		close(rm.monitoring)
+		rm.monitoring = nil
+	}
+	if rm.signals == nil {
+		return
+	}
+	signal.Stop(rm.signals)
+	close(rm.done)
+	rm.signals = nil
+}
@@ -0,0 +1,232 @@
+package cfg
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReloadManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgreload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.cfg")
+	ioutil.WriteFile(filename, []byte("s1 {\nop = a\n}\ns2 {< s1\n}"), 0644)
+	cfg, err := NewCFGFromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, _ := cfg.GetSection("s2")
+	rm := NewReloadManager(cfg, filename)
+	rm.AddValidator(func(c *CFG) error {
+		if !c.ExistsSection("s1") {
+			return errors.New("s1 is required")
+		}
+		return nil
+	})
+	outcomes := make(chan error, 10)
+	rm.OnReload(func(err error) { outcomes <- err })
+	m := NewMetrics(cfg, "")
+	rm.SetMetrics(m)
+	ioutil.WriteFile(filename, []byte("s1 {\nop = b\n}\ns2 {< s1\n}"), 0644)
+	if err := rm.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if <-outcomes != nil {
+		t.Error("Unexpected outcome")
+	}
+	if v := s2.GetValue("op", ""); v != "b" {
+		t.Error("Reload didn't update existing sections:", v)
+	}
+	ioutil.WriteFile(filename, []byte("s3 {\n}"), 0644)
+	if err := rm.Reload(); err == nil || <-outcomes == nil {
+		t.Error("Invalid configuration accepted")
+	}
+	ioutil.WriteFile(filename, []byte("s1 {"), 0644)
+	ioutil.WriteFile(filename, []byte("s1 {\nop = a\nop = b\n}"), 0644)
+	if err := rm.Reload(); err == nil || <-outcomes == nil {
+		t.Error("Broken configuration accepted")
+	}
+	if v := cfg.GetValue("s2/op", ""); v != "b" {
+		t.Error("Failed reloads modified the cfg:", v)
+	}
+	if m.reloads != 3 || m.failures != 2 {
+		t.Error("Unexpected metrics:", m.reloads, m.failures)
+	}
+	ioutil.WriteFile(filename, []byte("s1 {\nop = c\n}"), 0644)
+	rm.Start(syscall.SIGUSR1)
+	defer rm.Stop()
+	syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+	select {
+	case err := <-outcomes:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Signal didn't trigger a reload")
+	}
+	if v := cfg.GetValue("s1/op", ""); v != "c" {
+		t.Error("Signal reload didn't update the cfg:", v)
+	}
+}
+
+func TestReloadIncremental(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgreload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.cfg")
+	ioutil.WriteFile(filename, []byte("DB {\n\tHost = db1\n\tPort = 3306\n}\nLog {\n\tLevel = info\n}\n"), 0644)
+	cfg, err := NewCFGFromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, _ := cfg.GetSection("DB")
+	host := db.options["Host"]
+	events := make(chan ChangeEvent, 10)
+	defer cfg.Watch("", func(e ChangeEvent) { events <- e })()
+	rm := NewReloadManager(cfg, filename)
+	outcomes := make(chan error, 10)
+	rm.OnReload(func(err error) { outcomes <- err })
+	rm.WatchFile(10 * time.Millisecond)
+	defer rm.Stop()
+	ioutil.WriteFile(filename, []byte("DB {\n\tHost = db1\n\tPort = 3307\n}\nLog {\n\tLevel = info\n}\nApp {\n}\n"), 0644)
+	select {
+	case err := <-outcomes:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Changing the file didn't trigger a reload")
+	}
+	var got []string
+	for len(events) > 0 {
+		e := <-events
+		got = append(got, e.Kind.String()+" "+e.Path)
+	}
+	if len(got) != 2 || got[0] != "option set DB/Port" || got[1] != "section created App" {
+		t.Error("Unexpected events:", got)
+	}
+	if sec, _ := cfg.GetSection("DB"); sec != db || db.options["Host"] != host {
+		t.Error("Unchanged contents were replaced")
+	}
+}
+
+func TestReloadDryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgreload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.cfg")
+	ioutil.WriteFile(filename, []byte("DB {\n\tHost = db1\n\tPort = 3306\n}\n"), 0644)
+	cfg, _ := NewCFGFromFile(filename)
+	rm := NewReloadManager(cfg, filename)
+	rm.Require("DB/Host")
+	ioutil.WriteFile(filename, []byte("DB {\n\tHost = db2\n}\nLog {\n}\n"), 0644)
+	changes, err := rm.DryRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range changes {
+		got = append(got, e.Kind.String()+" "+e.Path)
+	}
+	if strings.Join(got, ",") != "option removed DB/Port,option set DB/Host,section created Log" {
+		t.Error("Unexpected changes:", got)
+	}
+	if v := cfg.GetValue("DB/Host", ""); v != "db1" {
+		t.Error("Dry run modified the cfg:", v)
+	}
+	ioutil.WriteFile(filename, []byte("DB {\n\tPort = 3306\n}\n"), 0644)
+	if _, err := rm.DryRun(); !errors.Is(err, ErrNotFound) {
+		t.Error("Missing required option accepted:", err)
+	}
+	if err := rm.Reload(); err == nil {
+		t.Error("Reloaded without a required option")
+	}
+	changes, err = cfg.SaveToFileDryRun(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Kind != OptionSet || changes[0].Path != "DB/Host" {
+		t.Error("Unexpected changes saving:", changes)
+	}
+	if changes, err = cfg.SaveToFileDryRun(filepath.Join(dir, "new.cfg")); err != nil || len(changes) != 3 {
+		t.Error("Unexpected changes saving a new file:", changes, err)
+	}
+}
+
+func TestReloadRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgreload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.cfg")
+	ioutil.WriteFile(filename, []byte("Workers = 4\n"), 0644)
+	cfg, _ := NewCFGFromFile(filename)
+	events := make(chan ChangeEvent, 10)
+	defer cfg.Watch("", func(e ChangeEvent) { events <- e })()
+	rm := NewReloadManager(cfg, filename)
+	defer rm.Stop()
+	outcomes := make(chan error, 10)
+	rm.OnReload(func(err error) { outcomes <- err })
+	rm.SetHealthCheck(func() error {
+		if cfg.GetValue("Workers", "") == "0" {
+			return errors.New("No workers")
+		}
+		return nil
+	}, time.Second, 5*time.Millisecond)
+	ioutil.WriteFile(filename, []byte("Workers = 8\n"), 0644)
+	if err := rm.Reload(); err != nil || <-outcomes != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filename, []byte("Workers = 0\n"), 0644)
+	if err := rm.Reload(); err != nil || <-outcomes != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-outcomes:
+		if err == nil {
+			t.Error("Rollback not reported")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Failed health check didn't roll back")
+	}
+	if v := cfg.GetValue("Workers", ""); v != "8" {
+		t.Error("Previous contents not restored:", v)
+	}
+	var kinds []string
+	for len(events) > 0 {
+		kinds = append(kinds, (<-events).Kind.String())
+	}
+	if strings.Join(kinds, ",") != "option set,option set,option set,rolled back" {
+		t.Error("Unexpected events:", kinds)
+	}
+}
+
+func TestReloadMergeSections(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.cfg")
+	ioutil.WriteFile(filename, []byte("A {\n\tx = 1\n}\nA {\n\ty = 2\n}\n"), 0644)
+	cfg := NewCFG()
+	cfg.SetMergeSections(true)
+	if err := cfg.LoadFiles(filename); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filename, []byte("A {\n\tx = 3\n}\nA {\n\ty = 4\n}\n"), 0644)
+	if err := NewReloadManager(cfg, filename).Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if x, y := cfg.GetValue("A/x", ""), cfg.GetValue("A/y", ""); x != "3" || y != "4" {
+		t.Error("Unexpected reloaded values:", x, y)
+	}
+}
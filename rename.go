@@ -0,0 +1,113 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+type renaming struct {
+	old    string
+	new    []string
+	parent *CFG
+	name   string
+	opt    *option
+	sec    *CFG
+}
+
+//Rename every option and section which path (relative to this section, '*' does not match SplitChar) matches matchGlob to the path
+//returned by rewrite, for refactors like moving "Queue*/MaxJobs" to "Queue*/Limits/MaxJobs". Sections are moved with their contents,
+//so nothing under a matching section is matched on it's own. The sections needed by the new paths are created.
+//All the renames are checked before doing any: if two entries would get the same path, one would replace an existing entry or be moved
+//inside itself nothing is renamed and the error lists every conflict. Returns the renames done, old path to new path
+func (cfg *CFG) RenameByPattern(matchGlob string, rewrite func(path string) string) (map[string]string, error) {
+	glob := strings.Join(SplitPath(matchGlob), SplitChar)
+	if _, err := path.Match(glob, ""); err != nil {
+		return nil, err
+	}
+	if err := cfg.writeLock(); err != nil {
+		return nil, err
+	}
+	defer cfg.unlock()
+	renames := make([]*renaming, 0)
+	cfg.collectRenames("", glob, rewrite, &renames)
+	if err := cfg.checkRenames(renames); err != nil {
+		return nil, err
+	}
+	root := cfg.root()
+	backup := root.copyTree()
+	mark := cfg.recordMark()
+	report := make(map[string]string, len(renames))
+	//Detach everything first so entries can swap their paths
+	for _, r := range renames {
+		r.parent.removeChild(r.name)
+	}
+	for _, r := range renames {
+		parent, err := cfg.ensureSection(r.new[:len(r.new)-1])
+		if err == nil {
+			err = parent.checkName(r.new[len(r.new)-1], r.opt != nil)
+		}
+		if err == nil {
+			if _, exists := parent.sections[r.new[len(r.new)-1]]; exists || parent.options[r.new[len(r.new)-1]] != nil {
+				err = newError(ErrExists, strings.Join(r.new, SplitChar)+" already exists")
+			}
+		}
+		if err != nil {
+			root.replaceContents(backup)
+			cfg.dropRecorded(mark)
+			return nil, errors.New(fmt.Sprintf("Cannot rename %s: %s", r.old, err.Error()))
+		}
+		parent.insertChild(r.new[len(r.new)-1], -1, r.opt, r.sec)
+		report[r.old] = strings.Join(r.new, SplitChar)
+	}
+	return report, nil
+}
+
+func (cfg *CFG) collectRenames(prefix string, glob string, rewrite func(string) string, renames *[]*renaming) {
+	for _, name := range cfg.order {
+		p := joinEventPath(prefix, name)
+		sec, isSec := cfg.sections[name]
+		if matched, _ := path.Match(glob, p); matched {
+			if newPath := SplitPath(rewrite(p)); strings.Join(newPath, SplitChar) != p {
+				*renames = append(*renames, &renaming{p, newPath, cfg, name, cfg.options[name], sec})
+			}
+			continue
+		}
+		if isSec && sec.alias == nil {
+			sec.collectRenames(p, glob, rewrite, renames)
+		}
+	}
+}
+
+//Find the renames that cannot be done
+func (cfg *CFG) checkRenames(renames []*renaming) error {
+	moved := make(map[string]bool, len(renames))
+	for _, r := range renames {
+		moved[r.old] = true
+	}
+	targets := make(map[string]string, len(renames))
+	conflicts := make([]string, 0)
+	for _, r := range renames {
+		target := strings.Join(r.new, SplitChar)
+		switch {
+		case len(r.new) == 0:
+			conflicts = append(conflicts, r.old+" would be renamed to an empty path")
+		case strings.HasPrefix(target, r.old+SplitChar):
+			conflicts = append(conflicts, fmt.Sprintf("%s would be moved inside itself to %s", r.old, target))
+		case targets[target] != "":
+			conflicts = append(conflicts, fmt.Sprintf("%s and %s would be renamed to %s", targets[target], r.old, target))
+		default:
+			targets[target] = r.old
+			if sec, opt := cfg.get(r.new, false, 0); (sec != nil || opt != nil) && !moved[target] {
+				conflicts = append(conflicts, fmt.Sprintf("%s would replace %s", r.old, target))
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return newError(ErrExists, "Conflicting renames: "+strings.Join(conflicts, ", "))
+	}
+	return nil
+}
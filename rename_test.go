@@ -0,0 +1,73 @@
+package cfg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRenameByPattern(t *testing.T) {
+	data := "Base {\n\tMaxJobs = 1\n}\nQueueA {< Base\n\tMaxJobs = 10\n\tName = a\n}\nQueueB {\n\tMaxJobs = 20\n}\nOther {\n\tMaxJobs = 5\n}\n"
+	cfg, err := NewCFGFromString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := cfg.RenameByPattern("Queue*/MaxJobs", func(path string) string {
+		return path[:len(path)-len("MaxJobs")] + "Limits/MaxJobs"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report) != 2 || report["QueueA/MaxJobs"] != "QueueA/Limits/MaxJobs" || report["QueueB/MaxJobs"] != "QueueB/Limits/MaxJobs" {
+		t.Error("Unexpected report:", report)
+	}
+	expected := "Base {\n\tMaxJobs = 1\n}\nQueueA {< Base\n\tName = a\n\tLimits {\n\t\tMaxJobs = 10\n\t}\n}\nQueueB {\n\tLimits {\n\t\tMaxJobs = 20\n\t}\n}\nOther {\n\tMaxJobs = 5\n}\n"
+	if cfg.String() != expected {
+		t.Error("Unexpected tree:", cfg.String())
+	}
+	//Sections are moved with their contents and inheritance follows them
+	if _, err := cfg.RenameByPattern("Base", func(string) string { return "Defaults/Base" }); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := cfg.GetOption("QueueA/MaxJobs"); v != "1" {
+		t.Error("Inheritance lost after moving the inherited section:", v)
+	}
+	//Swaps are allowed
+	if _, err := cfg.RenameByPattern("Queue?", func(path string) string {
+		if path == "QueueA" {
+			return "QueueB"
+		}
+		return "QueueA"
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := cfg.GetOption("QueueB/Name"); v != "a" {
+		t.Error("Swap failed:", v)
+	}
+}
+
+func TestRenameByPatternConflicts(t *testing.T) {
+	data := "A {\n\tX = 1\n\tY = 2\n\tZ = 3\n}\nB {\n\tX = 4\n}\n"
+	cfg, err := NewCFGFromString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cfg.RenameByPattern("A/*", func(path string) string {
+		if path == "A/Z" {
+			return "B/X"
+		}
+		return "A/W"
+	})
+	if !errors.Is(err, ErrExists) {
+		t.Fatal("Conflicts not detected:", err)
+	}
+	expected := "Conflicting renames: A/X and A/Y would be renamed to A/W, A/Z would replace B/X"
+	if err.Error() != expected {
+		t.Error("Unexpected error:", err)
+	}
+	if _, err := cfg.RenameByPattern("A", func(string) string { return "A/Sub" }); err == nil {
+		t.Error("Section moved inside itself")
+	}
+	if cfg.String() != data {
+		t.Error("Failed renames modified the tree:", cfg.String())
+	}
+}
@@ -0,0 +1,49 @@
+package cfg
+
+import (
+	"log"
+	"strings"
+)
+
+//Reserved section with the renamed paths. Options in it are placed at the old path (relative to this section) and have the new path as value,
+//for instance "Meta { Renames { DB { Host = Database/Host } } }". Renaming a section is done with an option named as the old section
+const RenamesSection = "Meta/Renames"
+
+//Called the first time a tree remaps each deprecated path found in RenamesSection. By default it logs a warning with the standard logger.
+//Trees with a logger set via SetLogger report them to it instead
+var DeprecationWarning = func(old string, new string) {
+	log.Printf("cfg: %s is deprecated, use %s instead", old, new)
+}
+
+//Find an option that does not exist in path via the renames defined in RenamesSection. Renamed paths can be renamed again
+func (cfg *CFG) lookupRenamed(path string, visited map[string]bool) *option {
+	if visited[path] {
+		return nil
+	}
+	visited[path] = true
+	renames, _ := cfg.getString(RenamesSection, false, 0)
+	if renames == nil {
+		return nil
+	}
+	p := SplitPath(path)
+	//The longest renamed prefix wins, so options can be renamed within a renamed section
+	for iP := len(p); iP > 0; iP-- {
+		_, rename := renames.get(p[:iP], false, 0)
+		if rename == nil || len(rename.value) == 0 {
+			continue
+		}
+		target := strings.Join(append(SplitPath(rename.value[0]), p[iP:]...), SplitChar)
+		if _, warned := cfg.warnedRenames.LoadOrStore(path, true); !warned {
+			if cfg.logger != nil {
+				cfg.logger.Warn("Deprecated path", "path", path, "replacement", target)
+			} else {
+				DeprecationWarning(path, target)
+			}
+		}
+		if _, opt := cfg.getString(target, true, 0); opt != nil {
+			return opt
+		}
+		return cfg.lookupRenamed(target, visited)
+	}
+	return nil
+}
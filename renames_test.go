@@ -0,0 +1,35 @@
+package cfg
+
+import (
+	"testing"
+)
+
+func TestRenames(t *testing.T) {
+	cfg, _ := NewCFGFromString("Meta\n{\n\tRenames\n\t{\n\t\tDB = Database\n\t\tOld\n\t\t{\n\t\t\tPort = Database/Port\n\t\t\tUser = Older/User\n\t\t}\n\t\tOlder\n\t\t{\n\t\t\tUser = Database/User\n\t\t}\n\t\tLoop = Loop2\n\t\tLoop2 = Loop\n\t}\n}\nDatabase\n{\n\tHost = db1\n\tPort = 3306\n\tUser = admin\n}\n")
+	warned := map[string]string{}
+	defer func(orig func(string, string)) { DeprecationWarning = orig }(DeprecationWarning)
+	DeprecationWarning = func(old string, new string) {
+		warned[old] = new
+	}
+	if v, ok := cfg.GetOption("DB/Host"); !ok || v != "db1" {
+		t.Error("Renamed section was not remapped:", v, ok)
+	}
+	cfg.GetOption("DB/Host")
+	if len(warned) != 1 || warned["DB/Host"] != "Database/Host" {
+		t.Error("Unexpected deprecation warnings:", warned)
+	}
+	old, _ := cfg.CreateSection("Old", "")
+	if v, ok := old.GetOption("Port"); !ok || v != "3306" {
+		t.Error("Renamed option was not remapped:", v, ok)
+	}
+	if v, ok := cfg.GetOption("Old/User"); !ok || v != "admin" {
+		t.Error("Chained rename was not followed:", v, ok)
+	}
+	if _, ok := cfg.GetOption("Loop/Host"); ok {
+		t.Error("Found a missing option through a rename loop")
+	}
+	old.SetOption("Port", "1", "")
+	if v, _ := cfg.GetOption("Old/Port"); v != "1" {
+		t.Error("Rename used while the option exists:", v)
+	}
+}
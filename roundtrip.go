@@ -0,0 +1,214 @@
+package cfg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+//Kind of change a source construct suffers when it is parsed and dumped back
+type NormalizationKind int
+
+const (
+	//Indentation, spacing around tokens or path spelling changed. Nothing is lost
+	NormalizedFormatting NormalizationKind = iota
+	//Blank lines are not kept
+	DroppedBlankLine
+	//Comments without text are not kept
+	DroppedEmptyComment
+	//A comment after an option or section on the same line is dumped on its own line before it
+	MovedTrailingComment
+	//A comment not attached to an option or section (before a '}', a '+=' or at the end of the source) is lost
+	DroppedComment
+	//Anything after a '}' in the same line is ignored by the parser
+	DroppedContent
+)
+
+func (k NormalizationKind) String() string {
+	switch k {
+	case NormalizedFormatting:
+		return "formatting"
+	case DroppedBlankLine:
+		return "dropped blank line"
+	case DroppedEmptyComment:
+		return "dropped empty comment"
+	case MovedTrailingComment:
+		return "moved trailing comment"
+	case DroppedComment:
+		return "dropped comment"
+	case DroppedContent:
+		return "dropped content"
+	}
+	return fmt.Sprintf("NormalizationKind(%d)", int(k))
+}
+
+//Lossy kinds of normalization lose information from the source
+func (k NormalizationKind) Lossy() bool {
+	return k == DroppedComment || k == DroppedContent
+}
+
+//A source construct that is not dumped verbatim
+type Normalization struct {
+	Line int
+	Kind NormalizationKind
+	Text string
+}
+
+func (n Normalization) String() string {
+	return fmt.Sprintf("line %d: %s: %q", n.Line, n.Kind, n.Text)
+}
+
+//Result of checking the parse -> dump -> parse cycle of a source
+type RoundTripReport struct {
+	//The dump of the source parses back into a tree equal to the source one, comments included
+	Equal bool
+	//The dump is exactly the source
+	Identical bool
+	//Constructs of the source that the dump changes, in source order
+	Normalizations []Normalization
+}
+
+//Is it safe to replace the source with its dump? It is if nothing but formatting, blank lines and empty comments changes
+func (r *RoundTripReport) Safe() bool {
+	if !r.Equal {
+		return false
+	}
+	for _, n := range r.Normalizations {
+		if n.Kind.Lossy() {
+			return false
+		}
+	}
+	return true
+}
+
+//Check that the source parses, dumps and parses back into an equal tree and report which constructs get normalized in the dump.
+//An error is returned only if the source cannot be parsed
+func VerifyRoundTrip(r io.Reader) (*RoundTripReport, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	source := string(data)
+	cfg, err := NewCFGFromString(source)
+	if err != nil {
+		return nil, err
+	}
+	dump := cfg.String()
+	report := &RoundTripReport{Identical: dump == source}
+	if reloaded, err := NewCFGFromString(dump); err == nil {
+		report.Equal = reloaded.RealEqual(cfg)
+	}
+	report.Normalizations = findNormalizations(source)
+	return report, nil
+}
+
+//Scan the source the same way loadFromReader does, comparing every line with the way the dumper would write it
+func findNormalizations(source string) []Normalization {
+	found := make([]Normalization, 0)
+	add := func(line int, kind NormalizationKind, text string) {
+		found = append(found, Normalization{line, kind, text})
+	}
+	lines := strings.Split(source, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	} else if len(source) > 0 {
+		add(len(lines), NormalizedFormatting, lines[len(lines)-1])
+	}
+	depth := 0
+	pending := make([]int, 0)
+	dropPending := func() {
+		for _, nL := range pending {
+			add(nL, DroppedComment, lines[nL-1])
+		}
+		pending = pending[:0]
+	}
+	for iL, raw := range lines {
+		nL := iL + 1
+		raw = strings.TrimRight(raw, "\r")
+		content := raw
+		trailing := false
+		if commentPos := strings.IndexRune(raw, '#'); commentPos > -1 {
+			text := strings.Trim(raw[commentPos+1:], trimChars)
+			content = strings.Trim(raw[:commentPos], trimChars)
+			switch {
+			case text == "":
+				add(nL, DroppedEmptyComment, raw)
+			case content != "":
+				trailing = true
+				pending = append(pending, nL)
+			default:
+				pending = append(pending, nL)
+				if raw != strings.Repeat("\t", depth)+"#"+text {
+					add(nL, NormalizedFormatting, raw)
+				}
+			}
+			if content == "" {
+				continue
+			}
+		}
+		content = strings.Trim(content, trimChars)
+		if content == "" {
+			add(nL, DroppedBlankLine, raw)
+			continue
+		}
+		tokenPos := strings.IndexAny(content, "{}=")
+		if tokenPos == -1 {
+			//The parser keeps accumulating the name into the next line
+			add(nL, NormalizedFormatting, raw)
+			continue
+		}
+		name := strings.Trim(content[:tokenPos], trimChars)
+		remainder := strings.Trim(content[tokenPos+1:], trimChars)
+		indent := strings.Repeat("\t", depth)
+		canonical := ""
+		switch content[tokenPos] {
+		case '{':
+			if trailing {
+				add(nL, MovedTrailingComment, raw)
+			}
+			pending = pending[:0]
+			canonical = indent + name + " {"
+			if len(remainder) > 0 && remainder[0] == '<' {
+				canonical += "< " + strings.Join(SplitPath(strings.Trim(remainder[1:], trimChars)), SplitChar)
+			}
+			depth++
+		case '}':
+			dropPending()
+			if depth > 0 {
+				depth--
+			}
+			canonical = strings.Repeat("\t", depth) + "}"
+			if remainder != "" {
+				add(nL, DroppedContent, raw)
+				continue
+			}
+		case '=':
+			if strings.HasSuffix(name, "+") {
+				dropPending()
+				canonical = indent + strings.Trim(name[:len(name)-1], trimChars) + " += " + remainder
+			} else {
+				if trailing {
+					add(nL, MovedTrailingComment, raw)
+				}
+				pending = pending[:0]
+				canonical = indent + name + " = " + remainder
+			}
+		}
+		if !trailing && raw != canonical {
+			add(nL, NormalizedFormatting, raw)
+		}
+	}
+	dropPending()
+	return found
+}
+
+//Check the round trip of a CFG dump. Useful to know if a tree built programmatically can be written and loaded back
+func (cfg *CFG) Fidelity() (*RoundTripReport, error) {
+	var buf bytes.Buffer
+	if err := cfg.DumpToWriter(&buf); err != nil {
+		return nil, err
+	}
+	return VerifyRoundTrip(&buf)
+}
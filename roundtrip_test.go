@@ -0,0 +1,65 @@
+package cfg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyRoundTrip(t *testing.T) {
+	data := "#Option\nop1 = a\nop1 += b\ns1 {\n\t#Two lines\n\t#of comment\n\top2 = c\n}\n"
+	report, err := VerifyRoundTrip(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Equal || !report.Identical || !report.Safe() || len(report.Normalizations) != 0 {
+		t.Error("Canonical source not reported as identical:", report)
+	}
+	data = "op1=a # trailing\n\n#\ns1{<s2\n  op2 = c\n#lost\n} lost too\ns2 {\n#ignored\nop3 = d\n#append\nop3 += e\n}\n#end"
+	report, err = VerifyRoundTrip(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Normalization{
+		{14, NormalizedFormatting, "#end"},
+		{1, MovedTrailingComment, "op1=a # trailing"},
+		{2, DroppedBlankLine, ""},
+		{3, DroppedEmptyComment, "#"},
+		{4, NormalizedFormatting, "s1{<s2"},
+		{5, NormalizedFormatting, "  op2 = c"},
+		{6, NormalizedFormatting, "#lost"},
+		{6, DroppedComment, "#lost"},
+		{7, DroppedContent, "} lost too"},
+		{9, NormalizedFormatting, "#ignored"},
+		{10, NormalizedFormatting, "op3 = d"},
+		{11, NormalizedFormatting, "#append"},
+		{11, DroppedComment, "#append"},
+		{12, NormalizedFormatting, "op3 += e"},
+		{14, DroppedComment, "#end"},
+	}
+	if len(report.Normalizations) != len(expected) {
+		t.Fatal("Unexpected normalizations:", report.Normalizations)
+	}
+	for iN, n := range report.Normalizations {
+		if n != expected[iN] {
+			t.Errorf("Unexpected normalization %s. Expected %s", n, expected[iN])
+		}
+	}
+	if !report.Equal || report.Identical || report.Safe() {
+		t.Error("Unexpected report:", report)
+	}
+	if _, err := VerifyRoundTrip(strings.NewReader("a=1\na=2")); err == nil {
+		t.Error("Invalid source didn't fail")
+	}
+}
+
+func TestFidelity(t *testing.T) {
+	cfg := NewCFG()
+	cfg.SetOption("op", "val", "comment\nin two lines")
+	report, err := cfg.Fidelity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Equal || !report.Identical || !report.Safe() {
+		t.Error("Dump of a simple tree is not reported as safe:", report)
+	}
+}
@@ -0,0 +1,368 @@
+package cfg
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Fields of the sections describing options in a schema. A section with a Type field describes the option with it's name,
+//any other section describes a section and may have Description and Required fields. A "*" section describes every section
+//in it's parent that is not described on it's own. For instance:
+//
+//	Server {
+//		Port {
+//			Type = int
+//			Required = yes
+//			Description = Port to listen on
+//		}
+//		Mode {
+//			Type = enum
+//			Values = dev
+//			Values += prod
+//			Default = prod
+//		}
+//	}
+const (
+	SchemaType        = "Type"
+	SchemaRequired    = "Required"
+	SchemaDefault     = "Default"
+	SchemaDescription = "Description"
+	//Allowed values of enums, one per value
+	SchemaValues = "Values"
+	//Can the option have more than one value?
+	SchemaMultiple = "Multiple"
+)
+
+//Types of options known by schemas
+var schemaTypes = map[string]func(value string) error{
+	"string": func(value string) error { return nil },
+	"int": func(value string) error {
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err
+	},
+	"float": func(value string) error {
+		_, err := strconv.ParseFloat(value, 64)
+		return err
+	},
+	"bool": func(value string) error {
+		_, err := parseBool(value)
+		return err
+	},
+	"duration": func(value string) error {
+		_, err := time.ParseDuration(value)
+		return err
+	},
+	"enum": nil,
+}
+
+//Contract of a cfg tree written in cfg syntax itself
+type Schema struct {
+	root *schemaSection
+	//Report the options and sections not described in the schema when validating
+	Strict bool
+}
+
+type schemaSection struct {
+	description string
+	required    bool
+	options     map[string]*schemaOption
+	sections    map[string]*schemaSection
+	order       []string
+}
+
+type schemaOption struct {
+	typ         string
+	required    bool
+	multiple    bool
+	def         []string
+	description string
+	values      []string
+}
+
+//Errors found validating a tree against a schema
+type SchemaError struct {
+	Problems []string
+}
+
+func (e *SchemaError) Error() string {
+	return strings.Join(e.Problems, "\n")
+}
+
+//Load a schema from r
+func LoadSchema(r io.Reader) (*Schema, error) {
+	tree, err := NewCFGFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseSchemaSection(tree)
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+func parseSchemaSection(sec *CFG) (*schemaSection, error) {
+	ss := &schemaSection{options: make(map[string]*schemaOption), sections: make(map[string]*schemaSection)}
+	for _, name := range sec.order {
+		sub, ok := sec.sections[name]
+		if !ok {
+			switch name {
+			case SchemaDescription:
+				ss.description = strings.Join(sec.options[name].value, "\n")
+			case SchemaRequired:
+				required, err := parseSchemaBool(sec, name)
+				if err != nil {
+					return nil, err
+				}
+				ss.required = required
+			default:
+				return nil, errors.New(fmt.Sprintf("Unexpected field %s in schema section %s", name, sec.path()))
+			}
+			continue
+		}
+		sub = sub.resolveAlias()
+		if _, isOption := sub.options[SchemaType]; !isOption {
+			child, err := parseSchemaSection(sub)
+			if err != nil {
+				return nil, err
+			}
+			ss.sections[name] = child
+		} else {
+			opt, err := parseSchemaOption(sub)
+			if err != nil {
+				return nil, err
+			}
+			ss.options[name] = opt
+		}
+		ss.order = append(ss.order, name)
+	}
+	return ss, nil
+}
+
+func parseSchemaOption(sec *CFG) (*schemaOption, error) {
+	opt := &schemaOption{typ: strings.Join(sec.options[SchemaType].value, "")}
+	if _, ok := schemaTypes[opt.typ]; !ok {
+		return nil, errors.New(fmt.Sprintf("Unknown type %s for option %s in schema", opt.typ, sec.path()))
+	}
+	var err error
+	for _, name := range sec.order {
+		o, ok := sec.options[name]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("Unexpected section %s in schema of option %s", name, sec.path()))
+		}
+		switch name {
+		case SchemaType:
+		case SchemaRequired:
+			opt.required, err = parseSchemaBool(sec, name)
+		case SchemaMultiple:
+			opt.multiple, err = parseSchemaBool(sec, name)
+		case SchemaDefault:
+			opt.def = o.value
+		case SchemaDescription:
+			opt.description = strings.Join(o.value, "\n")
+		case SchemaValues:
+			opt.values = o.value
+		default:
+			err = errors.New(fmt.Sprintf("Unexpected field %s in schema of option %s", name, sec.path()))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opt.typ == "enum" && len(opt.values) == 0 {
+		return nil, errors.New(fmt.Sprintf("Enum option %s in schema has no %s", sec.path(), SchemaValues))
+	}
+	if len(opt.def) > 0 {
+		if problem := opt.check(sec.path(), opt.def); problem != "" {
+			return nil, errors.New("Invalid default in schema: " + problem)
+		}
+	}
+	return opt, nil
+}
+
+func parseSchemaBool(sec *CFG, name string) (bool, error) {
+	value, err := parseBool(strings.Join(sec.options[name].value, ""))
+	if err != nil {
+		return false, errors.New(fmt.Sprintf("Invalid %s in schema section %s", name, sec.path()))
+	}
+	return value, nil
+}
+
+//Problem with the values of an option, if any
+func (opt *schemaOption) check(path string, values []string) string {
+	if len(values) > 1 && !opt.multiple {
+		return fmt.Sprintf("%s has %d values but only one is allowed", path, len(values))
+	}
+	for _, value := range values {
+		if opt.typ == "enum" {
+			allowed := false
+			for _, v := range opt.values {
+				allowed = allowed || v == value
+			}
+			if !allowed {
+				return fmt.Sprintf("%s is %s but it must be one of %s", path, value, strings.Join(opt.values, ", "))
+			}
+		} else if err := schemaTypes[opt.typ](value); err != nil {
+			return fmt.Sprintf("%s is %s which is not a valid %s", path, value, opt.typ)
+		}
+	}
+	return ""
+}
+
+//Check cfg against the schema. All the problems found are returned together as a *SchemaError
+func (s *Schema) Validate(cfg *CFG) error {
+	cfg.lock.RLock()
+	var problems []string
+	s.root.validate(cfg, "", s.Strict, &problems)
+	cfg.lock.RUnlock()
+	if len(problems) > 0 {
+		return &SchemaError{problems}
+	}
+	return nil
+}
+
+func (ss *schemaSection) validate(sec *CFG, path string, strict bool, problems *[]string) {
+	for _, name := range ss.order {
+		if name == WildcardSection {
+			continue
+		}
+		if opt, ok := ss.options[name]; ok {
+			values, err := sec.readValues(name)
+			if err != nil {
+				if opt.required {
+					*problems = append(*problems, fmt.Sprintf("Required option %s is missing", joinEventPath(path, name)))
+				}
+				continue
+			}
+			if problem := opt.check(joinEventPath(path, name), values); problem != "" {
+				*problems = append(*problems, problem)
+			}
+			continue
+		}
+		child := sec.getSection(name, true)
+		if child == nil {
+			if ss.sections[name].required {
+				*problems = append(*problems, fmt.Sprintf("Required section %s is missing", joinEventPath(path, name)))
+			}
+			continue
+		}
+		ss.sections[name].validate(child, joinEventPath(path, name), strict, problems)
+	}
+	wildcard := ss.sections[WildcardSection]
+	for _, name := range sec.order {
+		if _, described := ss.options[name]; described {
+			continue
+		}
+		if _, described := ss.sections[name]; described {
+			continue
+		}
+		if child, isSection := sec.sections[name]; isSection && wildcard != nil {
+			wildcard.validate(child.resolveAlias(), joinEventPath(path, name), strict, problems)
+			continue
+		}
+		if strict {
+			*problems = append(*problems, fmt.Sprintf("%s is not described in the schema", joinEventPath(path, name)))
+		}
+	}
+}
+
+//Write a cfg with every option in the schema set to it's default, or an example value of it's type, and it's description as comment
+func (s *Schema) GenerateExample(w io.Writer) error {
+	example := NewCFG()
+	s.root.example(example)
+	return example.DumpToWriter(w)
+}
+
+func (ss *schemaSection) example(sec *CFG) {
+	for _, name := range ss.order {
+		if opt, ok := ss.options[name]; ok {
+			comment := opt.description
+			if opt.required {
+				comment = joinComments(comment, "Required")
+			}
+			sec.setOptionArray(name, opt.exampleValues(), comment)
+			continue
+		}
+		child := ss.sections[name]
+		comment := child.description
+		if child.required {
+			comment = joinComments(comment, "Required")
+		}
+		sub, _ := sec.createSection(name, comment)
+		child.example(sub)
+	}
+}
+
+func (opt *schemaOption) exampleValues() []string {
+	if len(opt.def) > 0 {
+		return opt.def
+	}
+	switch opt.typ {
+	case "int":
+		return []string{"0"}
+	case "float":
+		return []string{"0.0"}
+	case "bool":
+		return []string{"false"}
+	case "duration":
+		return []string{"1s"}
+	case "enum":
+		return opt.values[:1]
+	}
+	return []string{""}
+}
+
+//Write the documentation of every option in the schema as plain text, one paragraph per option
+func (s *Schema) GenerateDocs(w io.Writer) error {
+	return bufferedDump(w, func(b *bufio.Writer) error {
+		return s.root.docs(b, "")
+	})
+}
+
+func (ss *schemaSection) docs(b *bufio.Writer, path string) error {
+	for _, name := range ss.order {
+		opt, ok := ss.options[name]
+		if !ok {
+			child := ss.sections[name]
+			if child.description != "" {
+				if err := writeStrings(b, joinEventPath(path, name), SplitChar, "\n\t", strings.Replace(child.description, "\n", "\n\t", -1), "\n\n"); err != nil {
+					return err
+				}
+			}
+			if err := child.docs(b, joinEventPath(path, name)); err != nil {
+				return err
+			}
+			continue
+		}
+		attrs := opt.typ
+		if opt.typ == "enum" {
+			attrs += " of " + strings.Join(opt.values, ", ")
+		}
+		if opt.multiple {
+			attrs += ", multiple values"
+		}
+		if opt.required {
+			attrs += ", required"
+		}
+		if len(opt.def) > 0 {
+			attrs += ", default " + strings.Join(opt.def, SplitChar)
+		}
+		if err := writeStrings(b, joinEventPath(path, name), " (", attrs, ")\n"); err != nil {
+			return err
+		}
+		if opt.description != "" {
+			if err := writeStrings(b, "\t", strings.Replace(opt.description, "\n", "\n\t", -1), "\n"); err != nil {
+				return err
+			}
+		}
+		if err := b.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package cfg
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testSchema = `#Network settings
+Server {
+	Description = Network settings
+	Required = yes
+	Port {
+		Type = int
+		Required = yes
+		Description = Port to listen on
+	}
+	Mode {
+		Type = enum
+		Values = dev
+		Values += prod
+		Default = prod
+	}
+	Timeout {
+		Type = duration
+		Default = 5s
+	}
+}
+Queues {
+	* {
+		MaxJobs {
+			Type = int
+			Default = 10
+		}
+		Hosts {
+			Type = string
+			Multiple = yes
+		}
+	}
+}
+`
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := LoadSchema(strings.NewReader(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, _ := NewCFGFromString("Server {\n\tPort = 8080\n\tMode = dev\n}\nQueues {\n\tShort {\n\t\tMaxJobs = 100\n\t\tHosts = a\n\t\tHosts += b\n\t}\n}\n")
+	if err := schema.Validate(cfg); err != nil {
+		t.Error("Valid tree rejected:", err)
+	}
+	cfg, _ = NewCFGFromString("Server {\n\tMode = test\n\tTimeout = 5\n\tExtra = 1\n}\nQueues {\n\tShort {\n\t\tMaxJobs = many\n\t}\n}\n")
+	schema.Strict = true
+	err = schema.Validate(cfg)
+	expected := []string{"Required option Server/Port is missing", "Server/Mode is test but it must be one of dev, prod",
+		"Server/Timeout is 5 which is not a valid duration", "Server/Extra is not described in the schema", "Queues/Short/MaxJobs is many which is not a valid int"}
+	if se, ok := err.(*SchemaError); !ok || strings.Join(se.Problems, "\n") != strings.Join(expected, "\n") {
+		t.Error("Unexpected problems:", err)
+	}
+	if err := schema.Validate(NewCFG()); err == nil || err.Error() != "Required section Server is missing" {
+		t.Error("Unexpected error:", err)
+	}
+	for _, bad := range []string{"A {\n\tType = number\n}\n", "A {\n\tType = enum\n}\n", "A {\n\tType = int\n\tDefault = x\n}\n", "A {\n\tType = int\n\tColor = red\n}\n", "A {\n\tOther = 1\n}\n"} {
+		if _, err := LoadSchema(strings.NewReader(bad)); err == nil {
+			t.Error("Loaded schema", bad)
+		}
+	}
+}
+
+func TestSchemaGenerate(t *testing.T) {
+	schema, err := LoadSchema(strings.NewReader(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := schema.GenerateExample(&b); err != nil {
+		t.Fatal(err)
+	}
+	expected := "#Network settings\n#Required\nServer {\n\t#Port to listen on\n\t#Required\n\tPort = 0\n\tMode = prod\n\tTimeout = 5s\n}\nQueues {\n\t* {\n\t\tMaxJobs = 10\n\t\tHosts = \n\t}\n}\n"
+	if b.String() != expected {
+		t.Error("Unexpected example:", b.String())
+	}
+	example, err := NewCFGFromString(b.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.Validate(example); err != nil {
+		t.Error("Example does not follow the schema:", err)
+	}
+	b.Reset()
+	if err := schema.GenerateDocs(&b); err != nil {
+		t.Fatal(err)
+	}
+	expected = "Server/\n\tNetwork settings\n\nServer/Port (int, required)\n\tPort to listen on\n\nServer/Mode (enum of dev, prod, default prod)\n\nServer/Timeout (duration, default 5s)\n\n" +
+		"Queues/*/MaxJobs (int, default 10)\n\nQueues/*/Hosts (string, multiple values)\n\n"
+	if b.String() != expected {
+		t.Error("Unexpected docs:", b.String())
+	}
+}
@@ -0,0 +1,177 @@
+package cfg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Resolves references to secrets kept outside the cfg files. Values of the form "scheme:reference" are resolved
+//at read time by the resolver registered for the scheme
+type SecretResolver interface {
+	ResolveSecret(ref string) (string, error)
+}
+
+type cachedSecret struct {
+	value   string
+	expires time.Time
+}
+
+//Secret resolvers of a tree and their cache. It lives in the root
+type secretResolvers struct {
+	lock      sync.Mutex
+	resolvers map[string]SecretResolver
+	ttls      map[string]time.Duration
+	cache     map[string]cachedSecret
+}
+
+//Resolve the values with the "scheme:" prefix (for instance "vault:secret/data/db#password") with resolver when they are read
+//via GetOption and GetOptionArray. Resolved secrets are cached for ttl (0 disables the cache).
+//If a secret cannot be resolved again once expired, the cached value is kept until it can
+func (cfg *CFG) RegisterSecretResolver(scheme string, resolver SecretResolver, ttl time.Duration) {
+	cfg.lock.Lock()
+	defer cfg.lock.Unlock()
+	root := cfg.root()
+	if root.secrets == nil {
+		root.secrets = &secretResolvers{resolvers: make(map[string]SecretResolver), ttls: make(map[string]time.Duration), cache: make(map[string]cachedSecret)}
+	}
+	root.secrets.lock.Lock()
+	defer root.secrets.lock.Unlock()
+	root.secrets.resolvers[scheme] = resolver
+	root.secrets.ttls[scheme] = ttl
+	for key := range root.secrets.cache {
+		if strings.HasPrefix(key, scheme+":") {
+			delete(root.secrets.cache, key)
+		}
+	}
+}
+
+//Get the values of an option resolving the secret references in them. Unlike GetOptionArray, errors resolving them are returned
+func (cfg *CFG) ResolveOption(name string) ([]string, error) {
+	cfg.lock.RLock()
+	values, err := cfg.readValues(name)
+	secrets := cfg.root().secrets
+	cfg.lock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if secrets == nil {
+		return values, nil
+	}
+	return secrets.resolveAll(values)
+}
+
+func (s *secretResolvers) resolveAll(values []string) ([]string, error) {
+	var resolved []string
+	for iV, value := range values {
+		secret, isSecret, err := s.resolve(value)
+		if err != nil {
+			return nil, err
+		}
+		if !isSecret {
+			continue
+		}
+		if resolved == nil {
+			resolved = make([]string, len(values))
+			copy(resolved, values)
+		}
+		resolved[iV] = secret
+	}
+	if resolved == nil {
+		return values, nil
+	}
+	return resolved, nil
+}
+
+func (s *secretResolvers) resolve(value string) (string, bool, error) {
+	pos := strings.IndexByte(value, ':')
+	if pos < 0 {
+		return "", false, nil
+	}
+	s.lock.Lock()
+	resolver, ok := s.resolvers[value[:pos]]
+	ttl := s.ttls[value[:pos]]
+	cached, isCached := s.cache[value]
+	s.lock.Unlock()
+	if !ok {
+		return "", false, nil
+	}
+	now := time.Now()
+	if isCached && now.Before(cached.expires) {
+		return cached.value, true, nil
+	}
+	secret, err := resolver.ResolveSecret(value[pos+1:])
+	if err != nil {
+		if isCached {
+			return cached.value, true, nil
+		}
+		return "", true, errors.New(fmt.Sprintf("Cannot resolve secret %s: %s", value, err.Error()))
+	}
+	if ttl > 0 {
+		s.lock.Lock()
+		s.cache[value] = cachedSecret{secret, now.Add(ttl)}
+		s.lock.Unlock()
+	}
+	return secret, true, nil
+}
+
+//SecretResolver reading secrets from HashiCorp Vault via it's HTTP API. References are "path#field", for instance
+//"secret/data/db#password". As '#' starts a comment in cfg files, "path:field" can be used in them instead.
+//Both KV version 1 and 2 responses are understood
+type VaultResolver struct {
+	//Address of the server, like "https://vault:8200"
+	Address string
+	Token   string
+	//Client to use. http.DefaultClient if nil
+	Client *http.Client
+}
+
+func (v *VaultResolver) ResolveSecret(ref string) (string, error) {
+	pos := strings.LastIndexAny(ref, "#:")
+	if pos < 0 {
+		return "", errors.New("Missing #field in " + ref)
+	}
+	req, err := http.NewRequest("GET", strings.TrimRight(v.Address, "/")+"/v1/"+strings.TrimLeft(ref[:pos], "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New(fmt.Sprintf("Unexpected status %s reading %s", resp.Status, ref[:pos]))
+	}
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	data := body.Data
+	//KV version 2 nests the secret in data.data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, hasMeta := data["metadata"]; hasMeta {
+			data = nested
+		}
+	}
+	value, ok := data[ref[pos+1:]]
+	if !ok {
+		return "", errors.New(fmt.Sprintf("Field %s not found in %s", ref[pos+1:], ref[:pos]))
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	encoded, err := json.Marshal(value)
+	return string(encoded), err
+}
@@ -0,0 +1,89 @@
+package cfg
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testResolver struct {
+	calls   int
+	secrets map[string]string
+}
+
+func (r *testResolver) ResolveSecret(ref string) (string, error) {
+	r.calls++
+	if secret, ok := r.secrets[ref]; ok {
+		return secret, nil
+	}
+	return "", errors.New("unknown secret")
+}
+
+func TestSecretResolver(t *testing.T) {
+	cfg, _ := NewCFGFromString("DB\n{\n\tPassword = vault:secret/data/db:password\n\tHosts = a\n\tHosts += vault:hosts:b\n\tBroken = vault:nope#x\n\tURL = http://host\n}\n")
+	resolver := &testResolver{secrets: map[string]string{"secret/data/db:password": "s3cr3t", "hosts:b": "b"}}
+	cfg.RegisterSecretResolver("vault", resolver, time.Hour)
+	if v, ok := cfg.GetOption("DB/Password"); !ok || v != "s3cr3t" {
+		t.Error("Unexpected password:", v, ok)
+	}
+	if v, ok := cfg.GetOptionArray("DB/Hosts"); !ok || len(v) != 2 || v[1] != "b" {
+		t.Error("Unexpected hosts:", v, ok)
+	}
+	cfg.GetOption("DB/Password")
+	if resolver.calls != 2 {
+		t.Error("Secrets were not cached:", resolver.calls)
+	}
+	if _, ok := cfg.GetOption("DB/Broken"); ok {
+		t.Error("Unresolvable secret was returned")
+	}
+	if _, err := cfg.ResolveOption("DB/Broken"); err == nil {
+		t.Error("No error for an unresolvable secret")
+	}
+	if v, _ := cfg.GetOption("DB/URL"); v != "http://host" {
+		t.Error("Value with an unregistered scheme was changed:", v)
+	}
+	if !strings.Contains(cfg.String(), "Password = vault:secret/data/db:password") {
+		t.Error("Secret was dumped:\n" + cfg.String())
+	}
+
+	//Expired secrets are served from the cache while they cannot be resolved
+	cfg.RegisterSecretResolver("vault", resolver, time.Nanosecond)
+	cfg.GetOption("DB/Password")
+	time.Sleep(time.Millisecond)
+	delete(resolver.secrets, "secret/data/db:password")
+	if v, ok := cfg.GetOption("DB/Password"); !ok || v != "s3cr3t" {
+		t.Error("Stale secret was not kept:", v, ok)
+	}
+}
+
+func TestVaultResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			http.Error(w, "denied", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/db":
+			w.Write([]byte(`{"data":{"data":{"password":"s3cr3t","port":5432},"metadata":{"version":1}}}`))
+		case "/v1/kv/db":
+			w.Write([]byte(`{"data":{"password":"old"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	vault := &VaultResolver{Address: server.URL, Token: "token"}
+	for ref, expected := range map[string]string{"secret/data/db#password": "s3cr3t", "secret/data/db#port": "5432", "kv/db:password": "old"} {
+		if v, err := vault.ResolveSecret(ref); err != nil || v != expected {
+			t.Error("Unexpected secret for", ref, v, err)
+		}
+	}
+	for _, ref := range []string{"secret/data/db", "secret/data/db#user", "missing#x"} {
+		if _, err := vault.ResolveSecret(ref); err == nil {
+			t.Error("Resolved invalid reference", ref)
+		}
+	}
+}
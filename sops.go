@@ -0,0 +1,394 @@
+package cfg
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//Provides the data key of SOPS files. Implement it on top of the key management used to encrypt them (age, PGP, KMS...)
+type SOPSKeyProvider interface {
+	//Decrypt the data key of a file using the entries of it's "sops" metadata
+	DataKey(metadata map[string]interface{}) ([]byte, error)
+	//Create a data key for a new file, adding to the metadata the entries needed to decrypt it later (like the "age" or "kms" lists)
+	NewDataKey(metadata map[string]interface{}) ([]byte, error)
+}
+
+//SOPSKeyProvider using a fixed data key, for keys distributed out of band or tests
+type SOPSStaticKey []byte
+
+func (k SOPSStaticKey) DataKey(metadata map[string]interface{}) ([]byte, error) {
+	return k, nil
+}
+
+func (k SOPSStaticKey) NewDataKey(metadata map[string]interface{}) ([]byte, error) {
+	return k, nil
+}
+
+//Layout of a SOPS file written by EncryptForSave
+type SOPSFormat int
+
+const (
+	//The whole cfg dump encrypted as a single value (SOPS binary format). Comments and inheritance are kept
+	SOPSBinary SOPSFormat = iota
+	//Sections as JSON objects with every value encrypted (SOPS JSON format). Comments and inheritance are lost
+	SOPSJSON
+)
+
+const (
+	sopsVersion           = "3.7.3"
+	sopsUnencryptedSuffix = "_unencrypted"
+)
+
+var sopsValueRegexp = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.*),tag:(.*),type:(.*)\]$`)
+
+//Metadata key of a SOPS YAML file
+var sopsYAMLRegexp = regexp.MustCompile(`(?m)^sops:\s*$`)
+
+//Does data look like a SOPS encrypted file? Only JSON (and SOPS binary) files are detected
+func IsSOPS(data []byte) bool {
+	var doc struct {
+		SOPS map[string]interface{} `json:"sops"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return false
+	}
+	_, ok := doc.SOPS["mac"]
+	return ok
+}
+
+//Decrypt a SOPS encrypted JSON or binary payload and load it. The MAC of the file is verified.
+//JSON objects are loaded as sections and arrays as multi valued options. YAML, dotenv and ini files are not supported: this package
+//has no YAML parser, so YAML files fail with an error asking for them to be encrypted with "sops --output-type json" instead
+func NewCFGFromSOPS(data []byte, provider SOPSKeyProvider) (*CFG, error) {
+	if !IsSOPS(data) {
+		if sopsYAMLRegexp.Match(data) {
+			return nil, errors.New("YAML SOPS files are not supported, encrypt the file with sops --output-type json")
+		}
+		return nil, errors.New("Not a SOPS encrypted file")
+	}
+	var doc struct {
+		SOPS map[string]interface{} `json:"sops"`
+	}
+	json.Unmarshal(data, &doc)
+	key, err := provider.DataKey(doc.SOPS)
+	if err != nil {
+		return nil, errors.New("Cannot get SOPS data key: " + err.Error())
+	}
+	c, err := newSOPSCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	mac := sha512.New()
+	root, err := c.decryptObject(dec, nil, mac)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.verifyMAC(doc.SOPS, mac); err != nil {
+		return nil, err
+	}
+	if len(root.order) == 1 {
+		if opt, ok := root.options["data"]; ok && len(opt.value) == 1 {
+			//Binary format holding a whole cfg
+			return NewCFGFromString(opt.value[0])
+		}
+	}
+	cfg := NewCFG()
+	cfg.lock.Lock()
+	cfg.replaceContents(root)
+	cfg.unlock()
+	return cfg, nil
+}
+
+//Load a SOPS encrypted file
+func NewCFGFromSOPSFile(filename string, provider SOPSKeyProvider) (*CFG, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NewCFGFromSOPS(data, provider)
+}
+
+//Write the cfg encrypted as a SOPS file with a data key from provider. Options ending with "_unencrypted" are written in the clear in the JSON format
+func (cfg *CFG) EncryptForSave(w io.Writer, provider SOPSKeyProvider, format SOPSFormat) error {
+	metadata := map[string]interface{}{
+		"version":            sopsVersion,
+		"lastmodified":       time.Now().UTC().Format(time.RFC3339),
+		"unencrypted_suffix": sopsUnencryptedSuffix,
+	}
+	key, err := provider.NewDataKey(metadata)
+	if err != nil {
+		return errors.New("Cannot create SOPS data key: " + err.Error())
+	}
+	c, err := newSOPSCipher(key)
+	if err != nil {
+		return err
+	}
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	var buf bytes.Buffer
+	mac := sha512.New()
+	switch format {
+	case SOPSBinary:
+		var dump bytes.Buffer
+		cfg.dumpToWriter(&dump, 0)
+		enc, err := c.encrypt(dump.String(), []string{"data"})
+		if err != nil {
+			return err
+		}
+		mac.Write(dump.Bytes())
+		buf.WriteString(`{"data":`)
+		writeJSONString(&buf, enc)
+	case SOPSJSON:
+		if _, ok := cfg.sections["sops"]; ok || cfg.options["sops"] != nil {
+			return errors.New("The sops name is reserved for the SOPS metadata")
+		}
+		if err := c.encryptSection(&buf, cfg, nil, mac); err != nil {
+			return err
+		}
+		buf.Truncate(buf.Len() - 1)
+		if len(cfg.order) > 0 {
+			buf.WriteByte(',')
+		}
+	default:
+		return errors.New("Unknown SOPS format")
+	}
+	macValue, err := c.encrypt(strings.ToUpper(hex.EncodeToString(mac.Sum(nil))), []string{metadata["lastmodified"].(string)})
+	if err != nil {
+		return err
+	}
+	metadata["mac"] = macValue
+	encodedMetadata, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	if format == SOPSBinary {
+		buf.WriteByte(',')
+	}
+	buf.WriteString(`"sops":`)
+	buf.Write(encodedMetadata)
+	buf.WriteString("}\n")
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+type sopsCipher struct {
+	aead cipher.AEAD
+}
+
+func newSOPSCipher(key []byte) (*sopsCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.New("Invalid SOPS data key: " + err.Error())
+	}
+	aead, err := cipher.NewGCMWithNonceSize(block, 32)
+	if err != nil {
+		return nil, err
+	}
+	return &sopsCipher{aead}, nil
+}
+
+//SOPS authenticates every value with the path of keys leading to it
+func sopsAdditionalData(path []string) []byte {
+	return []byte(strings.Join(path, ":") + ":")
+}
+
+func (c *sopsCipher) encrypt(plain string, path []string) (string, error) {
+	iv := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nil, iv, []byte(plain), sopsAdditionalData(path))
+	data, tag := sealed[:len(sealed)-c.aead.Overhead()], sealed[len(sealed)-c.aead.Overhead():]
+	enc := base64.StdEncoding
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:str]", enc.EncodeToString(data), enc.EncodeToString(iv), enc.EncodeToString(tag)), nil
+}
+
+func (c *sopsCipher) decrypt(value string, path []string) (string, error) {
+	match := sopsValueRegexp.FindStringSubmatch(value)
+	if match == nil {
+		return "", errors.New("Invalid SOPS value")
+	}
+	enc := base64.StdEncoding
+	data, err := enc.DecodeString(match[1])
+	if err != nil {
+		return "", err
+	}
+	iv, err := enc.DecodeString(match[2])
+	if err != nil {
+		return "", err
+	}
+	tag, err := enc.DecodeString(match[3])
+	if err != nil {
+		return "", err
+	}
+	if len(iv) != c.aead.NonceSize() {
+		return "", errors.New("Invalid SOPS value iv")
+	}
+	plain, err := c.aead.Open(nil, iv, append(data, tag...), sopsAdditionalData(path))
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("Cannot decrypt %s: %s", strings.Join(path, SplitChar), err.Error()))
+	}
+	return string(plain), nil
+}
+
+func (c *sopsCipher) verifyMAC(metadata map[string]interface{}, mac hash.Hash) error {
+	stored, _ := metadata["mac"].(string)
+	lastModified, _ := metadata["lastmodified"].(string)
+	expected, err := c.decrypt(stored, []string{lastModified})
+	if err != nil {
+		return errors.New("Cannot decrypt SOPS MAC: " + err.Error())
+	}
+	if expected != strings.ToUpper(hex.EncodeToString(mac.Sum(nil))) {
+		return errors.New("SOPS MAC mismatch, the file has been tampered with")
+	}
+	return nil
+}
+
+//Decrypt the members of a JSON object which opening token has already been read into a detached section
+func (c *sopsCipher) decryptObject(dec *json.Decoder, path []string, mac hash.Hash) (*CFG, error) {
+	sec := newCFG()
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		name := key.(string)
+		if len(path) == 0 && name == "sops" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if name == "" || sec.sections[name] != nil || sec.options[name] != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid or duplicated name '%s'", name))
+		}
+		childPath := append(append([]string{}, path...), name)
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		if token == json.Delim('{') {
+			sub, err := c.decryptObject(dec, childPath, mac)
+			if err != nil {
+				return nil, err
+			}
+			sec.insertChild(name, -1, nil, sub)
+			continue
+		}
+		values := make([]string, 0, 1)
+		if token == json.Delim('[') {
+			for dec.More() {
+				token, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := c.decryptScalar(token, childPath, mac)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, value)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+		} else {
+			value, err := c.decryptScalar(token, childPath, mac)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		sec.insertChild(name, -1, &option{value: values}, nil)
+	}
+	_, err := dec.Token()
+	return sec, err
+}
+
+func (c *sopsCipher) decryptScalar(token json.Token, path []string, mac hash.Hash) (string, error) {
+	var value string
+	switch t := token.(type) {
+	case string:
+		value = t
+		if strings.HasPrefix(t, "ENC[") {
+			var err error
+			if value, err = c.decrypt(t, path); err != nil {
+				return "", err
+			}
+		}
+	case json.Number:
+		value = t.String()
+	case bool:
+		//SOPS hashes booleans as Python does
+		value = "False"
+		if t {
+			value = "True"
+		}
+		mac.Write([]byte(value))
+		return strings.ToLower(value), nil
+	default:
+		return "", errors.New(fmt.Sprintf("Unsupported value in %s", strings.Join(path, SplitChar)))
+	}
+	mac.Write([]byte(value))
+	return value, nil
+}
+
+//Write a section as a JSON object with all it's values encrypted
+func (c *sopsCipher) encryptSection(buf *bytes.Buffer, sec *CFG, path []string, mac hash.Hash) error {
+	buf.WriteByte('{')
+	for iN, name := range sec.order {
+		if iN > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONString(buf, name)
+		buf.WriteByte(':')
+		childPath := append(append([]string{}, path...), name)
+		if sub, ok := sec.sections[name]; ok {
+			if err := c.encryptSection(buf, sub, childPath, mac); err != nil {
+				return err
+			}
+			continue
+		}
+		values := sec.options[name].value
+		if len(values) != 1 {
+			buf.WriteByte('[')
+		}
+		for iV, value := range values {
+			if iV > 0 {
+				buf.WriteByte(',')
+			}
+			mac.Write([]byte(value))
+			if !strings.HasSuffix(name, sopsUnencryptedSuffix) {
+				var err error
+				if value, err = c.encrypt(value, childPath); err != nil {
+					return err
+				}
+			}
+			writeJSONString(buf, value)
+		}
+		if len(values) != 1 {
+			buf.WriteByte(']')
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
@@ -0,0 +1,58 @@
+package cfg
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSOPS(t *testing.T) {
+	key := SOPSStaticKey(bytes.Repeat([]byte{7}, 32))
+	cfg, _ := NewCFGFromString("#Database\nDB\n{\n\tPassword = s3cr3t\n\tHosts = a\n\tHosts += b\n\tPort_unencrypted = 5432\n}\nWeb\n{ < DB\n}\n")
+	for _, format := range []SOPSFormat{SOPSBinary, SOPSJSON} {
+		var buf bytes.Buffer
+		if err := cfg.EncryptForSave(&buf, key, format); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+		if !IsSOPS(data) || bytes.Contains(data, []byte("s3cr3t")) {
+			t.Fatal("Unexpected encrypted file:", buf.String())
+		}
+		loaded, err := NewCFGFromSOPS(data, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format == SOPSBinary && !loaded.RealEqual(cfg) {
+			t.Error("Binary file lost contents:\n" + loaded.String())
+		}
+		if format == SOPSJSON {
+			if !strings.Contains(buf.String(), `"Port_unencrypted":"5432"`) {
+				t.Error("Unencrypted value was encrypted")
+			}
+			if hosts, _ := loaded.GetOptionArray("DB/Hosts"); len(hosts) != 2 || hosts[1] != "b" {
+				t.Error("Unexpected hosts:", hosts)
+			}
+		}
+		if password, _ := loaded.GetOption("DB/Password"); password != "s3cr3t" {
+			t.Error("Unexpected password:", password)
+		}
+		if _, err := NewCFGFromSOPS(data, SOPSStaticKey(bytes.Repeat([]byte{8}, 32))); err == nil {
+			t.Error("Decrypted with the wrong key")
+		}
+	}
+
+	//Values moved around or removed are detected
+	var buf bytes.Buffer
+	cfg.EncryptForSave(&buf, key, SOPSJSON)
+	tampered := strings.Replace(buf.String(), `"Port_unencrypted":"5432"`, `"Port_unencrypted":"1"`, 1)
+	if _, err := NewCFGFromSOPS([]byte(tampered), key); err == nil || !strings.Contains(err.Error(), "MAC") {
+		t.Error("Tampering was not detected:", err)
+	}
+	if _, err := NewCFGFromSOPS([]byte("a = 1"), key); err == nil {
+		t.Error("Loaded a plain file")
+	}
+	yaml := "password: ENC[AES256_GCM,data:Zm9v,iv:Zm9v,tag:Zm9v,type:str]\nsops:\n    mac: ENC[AES256_GCM,data:Zm9v,iv:Zm9v,tag:Zm9v,type:str]\n"
+	if _, err := NewCFGFromSOPS([]byte(yaml), key); err == nil || !strings.Contains(err.Error(), "YAML") {
+		t.Error("Unexpected error for a YAML file:", err)
+	}
+}
@@ -0,0 +1,72 @@
+package cfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+//Number of events buffered per client. Clients that fall further behind are disconnected so they reconnect and resync
+const EventStreamBuffer = 256
+
+type eventStreamHandler struct {
+	cfg *CFG
+}
+
+//JSON representation of a ChangeEvent
+type jsonChangeEvent struct {
+	Path  string   `json:"path"`
+	Kind  string   `json:"kind"`
+	Value []string `json:"value,omitempty"`
+	Paths []string `json:"paths,omitempty"`
+}
+
+//Create an http.Handler streaming the changes done to the cfg as Server-Sent Events.
+//The optional "path" query parameter restricts the stream to the changes under that path.
+//Every event is sent as a "change" event with a JSON object holding the path, kind and new value of the change
+func NewEventStreamHandler(cfg *CFG) http.Handler {
+	return &eventStreamHandler{cfg}
+}
+
+func (h *eventStreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	events := make(chan ChangeEvent, EventStreamBuffer)
+	overflow := make(chan struct{})
+	//Watchers can be called by several writers at once
+	var overflowed sync.Once
+	cancel := h.cfg.Watch(r.URL.Query().Get("path"), func(event ChangeEvent) {
+		select {
+		case events <- event:
+		default:
+			overflowed.Do(func() { close(overflow) })
+		}
+	})
+	defer cancel()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-overflow:
+			return
+		case event := <-events:
+			data, err := json.Marshal(jsonChangeEvent{event.Path, event.Kind.String(), event.Value, event.Paths})
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: change\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
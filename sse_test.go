@@ -0,0 +1,94 @@
+package cfg
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEventStreamHandler(t *testing.T) {
+	cfg, _ := NewCFGFromString("s1 {\n}\ns2 {\n}")
+	server := httptest.NewServer(NewEventStreamHandler(cfg))
+	defer server.Close()
+	resp, err := http.Get(server.URL + "?path=s2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Error("Unexpected content type:", ct)
+	}
+	reader := bufio.NewReader(resp.Body)
+	if line, _ := reader.ReadString('\n'); line != ": connected\n" {
+		t.Fatal("Unexpected first line:", line)
+	}
+	reader.ReadString('\n')
+	cfg.SetOption("s1/op", "a", "")
+	cfg.SetOption("s2/op", "b", "")
+	lines := make([]string, 0)
+	for len(lines) < 3 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	expected := "event: change\ndata: {\"path\":\"s2/op\",\"kind\":\"option set\",\"value\":[\"b\"]}\n\n"
+	if strings.Join(lines, "") != expected {
+		t.Error("Unexpected event:", lines)
+	}
+}
+
+//Response writer that blocks the writes after the first one until released
+type blockedStream struct {
+	header    http.Header
+	writes    int
+	connected chan struct{}
+	release   chan struct{}
+}
+
+func (s *blockedStream) Header() http.Header { return s.header }
+func (s *blockedStream) WriteHeader(int)     {}
+func (s *blockedStream) Flush()              {}
+
+func (s *blockedStream) Write(p []byte) (int, error) {
+	if s.writes++; s.writes > 1 {
+		<-s.release
+	} else {
+		close(s.connected)
+	}
+	return len(p), nil
+}
+
+func TestEventStreamConcurrentOverflow(t *testing.T) {
+	cfg := NewCFG()
+	stream := &blockedStream{header: make(http.Header), connected: make(chan struct{}), release: make(chan struct{})}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		NewEventStreamHandler(cfg).ServeHTTP(stream, httptest.NewRequest("GET", "/", nil))
+	}()
+	<-stream.connected
+	var wg sync.WaitGroup
+	for iW := 0; iW < 8; iW++ {
+		wg.Add(1)
+		go func(iW int) {
+			defer wg.Done()
+			for i := 0; i < EventStreamBuffer; i++ {
+				cfg.SetOption(fmt.Sprintf("w%d", iW), fmt.Sprint(i), "")
+			}
+		}(iW)
+	}
+	wg.Wait()
+	close(stream.release)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Overflowed client was not disconnected")
+	}
+}
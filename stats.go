@@ -0,0 +1,64 @@
+package cfg
+
+//Size of a section and everything under it as returned by Stats
+type Stats struct {
+	//Sections under the section, aliases included
+	Sections int
+	Options  int
+	//Values of all the options
+	Values int
+	//Total length of the values in bytes
+	ValueBytes int
+	//Levels of sections under the section. 0 if it has no sub sections
+	MaxDepth int
+	//Number of sections inheriting by the length of their inheritance chain: InheritanceChains[2] is how many sections inherit
+	//from a section that inherits from another one
+	InheritanceChains map[int]int
+	//Length of the longest inheritance chain
+	MaxInheritanceChain int
+}
+
+//Compute the size of this section and it's contents. The contents of aliases and inherited ones are not counted
+func (cfg *CFG) Stats() Stats {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	stats := Stats{InheritanceChains: make(map[int]int)}
+	cfg.addStats(&stats, 0)
+	return stats
+}
+
+func (cfg *CFG) addStats(stats *Stats, depth int) {
+	if depth > stats.MaxDepth {
+		stats.MaxDepth = depth
+	}
+	if chain := cfg.inheritanceChain(); chain > 0 {
+		stats.InheritanceChains[chain]++
+		if chain > stats.MaxInheritanceChain {
+			stats.MaxInheritanceChain = chain
+		}
+	}
+	for _, opt := range cfg.options {
+		stats.Options++
+		stats.Values += len(opt.value)
+		for _, value := range opt.value {
+			stats.ValueBytes += len(value)
+		}
+	}
+	for _, sec := range cfg.sections {
+		stats.Sections++
+		if sec.alias == nil {
+			sec.addStats(stats, depth+1)
+		} else if depth+1 > stats.MaxDepth {
+			stats.MaxDepth = depth + 1
+		}
+	}
+}
+
+//Number of sections this one inherits from, directly or not
+func (cfg *CFG) inheritanceChain() int {
+	length := 0
+	for from := cfg.inheritance; from != nil; from = from.inheritance {
+		length++
+	}
+	return length
+}
@@ -0,0 +1,19 @@
+package cfg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	cfg, _ := NewCFGFromString("Base {\n\tHost = db1\n\tPorts = 1\n\tPorts += 22\n}\nSite {< Base\n\tPool {\n\t\tSize = 4\n\t}\n}\nEdge {< Site\n\t@alias DB = Base\n}\n")
+	expected := Stats{Sections: 5, Options: 3, Values: 4, ValueBytes: 7, MaxDepth: 2, InheritanceChains: map[int]int{1: 1, 2: 1}, MaxInheritanceChain: 2}
+	if stats := cfg.Stats(); !reflect.DeepEqual(stats, expected) {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	site, _ := cfg.GetSection("Site")
+	expected = Stats{Sections: 1, Options: 1, Values: 1, ValueBytes: 1, MaxDepth: 1, InheritanceChains: map[int]int{1: 1}, MaxInheritanceChain: 1}
+	if stats := site.Stats(); !reflect.DeepEqual(stats, expected) {
+		t.Errorf("Unexpected section stats: %+v", stats)
+	}
+}
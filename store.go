@@ -0,0 +1,157 @@
+package cfg
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+//Place a whole tree is kept in, like a file, a Redis hash or a configuration database. Implement it to plug in other backends,
+//which can then be followed with Follow
+type Store interface {
+	//Read the stored tree
+	Load() (*CFG, error)
+	//Replace the stored tree with cfg. Stores that cannot be written return ErrReadOnly
+	Save(cfg *CFG) error
+	//Call changed every time the stored tree may have changed, from a different goroutine, until stop is called
+	Watch(changed func()) (stop func(), err error)
+}
+
+//Keep cfg in sync with a store. The stored tree replaces the contents of cfg right away and every time it changes, so watchers of cfg
+//get events for the paths that changed. Errors loading it afterwards are sent to onError (if not nil) and the current contents are kept.
+//Call the returned function to stop following the store
+func Follow(cfg *CFG, store Store, onError func(error)) (stop func(), err error) {
+	update := func() error {
+		candidate, err := store.Load()
+		if err != nil {
+			return err
+		}
+		if err := cfg.writeLock(); err != nil {
+			return err
+		}
+		cfg.replaceContents(candidate)
+		cfg.unlock()
+		return nil
+	}
+	//Watch first so no change done while loading is missed
+	var lock sync.Mutex
+	ready := false
+	stop, err = store.Watch(func() {
+		lock.Lock()
+		defer lock.Unlock()
+		if !ready {
+			return
+		}
+		if err := update(); err != nil && onError != nil {
+			onError(err)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if err := update(); err != nil {
+		stop()
+		return nil, err
+	}
+	ready = true
+	return stop, nil
+}
+
+//Periodically call check until stop is called
+func pollStore(interval time.Duration, check func()) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				check()
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+//Store keeping the tree in a file
+type FileStore struct {
+	Filename string
+	//Time between the checks of the modification time and size of the file done by Watch. Defaults to one second
+	Interval time.Duration
+}
+
+func (s *FileStore) Load() (*CFG, error) {
+	return NewCFGFromFile(s.Filename)
+}
+
+//Replace the file atomically with the dump of cfg
+func (s *FileStore) Save(cfg *CFG) error {
+	return writeFileAtomically(s.Filename, cfg.DumpToWriter)
+}
+
+func (s *FileStore) Watch(changed func()) (func(), error) {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	last, _ := os.Stat(s.Filename)
+	return pollStore(interval, func() {
+		info, err := os.Stat(s.Filename)
+		if err != nil || last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			return
+		}
+		last = info
+		changed()
+	}), nil
+}
+
+//Read only store fetching the tree from a Source, like URLSource or GitSource
+type SourceStore struct {
+	Source Source
+	//Time between the fetches done by Watch to find changes. Defaults to one minute
+	Interval time.Duration
+}
+
+func (s *SourceStore) Load() (*CFG, error) {
+	data, err := s.Source.Fetch(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return NewCFGFromReader(bytes.NewReader(data))
+}
+
+func (s *SourceStore) Save(cfg *CFG) error {
+	return newError(ErrReadOnly, fmt.Sprintf("Cannot save to a %T", s.Source))
+}
+
+//Fetch the source every interval and call changed when the contents fetched change. Failed fetches are ignored
+func (s *SourceStore) Watch(changed func()) (func(), error) {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	data, err := s.Source.Fetch(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	last := sha256.Sum256(data)
+	return pollStore(interval, func() {
+		data, err := s.Source.Fetch(context.Background())
+		if err != nil {
+			return
+		}
+		if hash := sha256.Sum256(data); hash != last {
+			last = hash
+			changed()
+		}
+	}), nil
+}
@@ -0,0 +1,67 @@
+package cfg
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//Wait until cond holds or fail after a second
+func waitFor(t *testing.T, what string, cond func() bool) {
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for", what)
+		}
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := &FileStore{Filename: filepath.Join(dir, "app.cfg"), Interval: 10 * time.Millisecond}
+	original, _ := NewCFGFromString("DB {\n\tHost = db1\n}\n")
+	if err := store.Save(original); err != nil {
+		t.Fatal(err)
+	}
+	cfg := NewCFG()
+	stop, err := Follow(cfg, store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	if v, _ := cfg.GetOption("DB/Host"); v != "db1" {
+		t.Fatal("Stored tree not loaded:", v)
+	}
+	updated, _ := NewCFGFromString("DB {\n\tHost = db2\n\tPort = 5432\n}\n")
+	if err := store.Save(updated); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the change of the file", func() bool { return cfg.GetValue("DB/Host", "") == "db2" })
+}
+
+func TestSourceStore(t *testing.T) {
+	source := &testSource{data: "A = 1\n"}
+	store := &SourceStore{Source: source, Interval: 10 * time.Millisecond}
+	if err := store.Save(NewCFG()); !errors.Is(err, ErrReadOnly) {
+		t.Error("Saved to a read only store:", err)
+	}
+	cfg := NewCFG()
+	stop, err := Follow(cfg, store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	if v, _ := cfg.GetOption("A"); v != "1" {
+		t.Fatal("Source not loaded:", v)
+	}
+	source.lock.Lock()
+	source.data = "A = 2\n"
+	source.lock.Unlock()
+	waitFor(t, "the change of the source", func() bool { return cfg.GetValue("A", "") == "2" })
+}
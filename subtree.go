@@ -0,0 +1,78 @@
+package cfg
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+//Save this section as a standalone cfg file, with the paths of the inheritance and aliases between it's sub sections relative
+//to it. Links to sections outside it are written with their full path. The file is replaced atomically. See LoadSubtreeInto
+func (cfg *CFG) SaveSubtree(filename string) error {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	dup := cfg.copyTree()
+	return writeFileAtomically(filename, func(w io.Writer) error { return dup.dumpToWriter(w, 0) })
+}
+
+//Replace the contents of the section at path (relative to this section, created if missing) with a file saved by SaveSubtree or
+//any other standalone cfg. Inheritance and aliases in the file are looked up relative to the section first and then from the root.
+//Nothing is changed if the file cannot be loaded
+func (cfg *CFG) LoadSubtreeInto(path string, filename string) error {
+	fi, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+	candidate := newCFG()
+	candidate.lock = new(sync.RWMutex)
+	var links []inheritanceLink
+	if err := candidate.loadSource(fi, filename, &links); err != nil {
+		return err
+	}
+	//Paths of the sections with links relative to the candidate, as adopting them moves them
+	linked := make([]string, len(links))
+	for iL, link := range links {
+		linked[iL] = link.section.childPath("")
+	}
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
+	root := cfg.root()
+	backup := root.copyTree()
+	mark := cfg.recordMark()
+	if err := cfg.loadSubtree(SplitPath(path), candidate, links, linked); err != nil {
+		root.replaceContents(backup)
+		cfg.dropRecorded(mark)
+		return err
+	}
+	return nil
+}
+
+func (cfg *CFG) loadSubtree(path []string, candidate *CFG, links []inheritanceLink, linked []string) error {
+	target, err := cfg.ensureSection(path)
+	if err != nil {
+		return err
+	}
+	target.replaceContents(candidate)
+	for iL, link := range links {
+		sec := target
+		if linked[iL] != "" {
+			sec, _ = target.getString(linked[iL], false, 0)
+		}
+		dest := link.inheritance
+		if found, _ := target.getString(dest, false, 0); found != nil {
+			dest = target.childPath(dest)
+		}
+		if link.alias {
+			err = sec.setAliasTarget(dest)
+		} else {
+			err = sec.setInheritance(dest)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package cfg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSubtree(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgsubtree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "web.cfg")
+	cfg, _ := NewCFGFromString("Shared {\n\tX = 1\n}\nComponents {\n\tWeb {\n\t\tBase {\n\t\t\tPort = 80\n\t\t}\n\t\tSite {< Components/Web/Base\n\t\t}\n\t\tOther {< Shared\n\t\t}\n\t\t@alias B = Components/Web/Base\n\t}\n}\n")
+	web, _ := cfg.GetSection("Components/Web")
+	if err := web.SaveSubtree(filename); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(filename)
+	if expected := "Base {\n\tPort = 80\n}\nSite {< Base\n}\nOther {< Shared\n}\n@alias B = Base\n"; string(data) != expected {
+		t.Error("Unexpected subtree:", string(data))
+	}
+	dest, _ := NewCFGFromString("Shared {\n\tX = 2\n}\nApps {\n\tWeb {\n\t\tOld = yes\n\t}\n}\n")
+	if err := dest.LoadSubtreeInto("Apps/Web", filename); err != nil {
+		t.Fatal(err)
+	}
+	if dest.Exists("Apps/Web/Old") || dest.GetValue("Apps/Web/Site/Port", "") != "80" || dest.GetValue("Apps/Web/Other/X", "") != "2" || dest.GetValue("Apps/Web/B/Port", "") != "80" {
+		t.Error("Unexpected tree:", dest.String())
+	}
+	apps, _ := dest.GetSection("Apps")
+	if err := apps.LoadSubtreeInto("Copy", filename); err != nil || dest.GetValue("Apps/Copy/Site/Port", "") != "80" {
+		t.Error("Cannot load a second copy:", err, dest.String())
+	}
+	empty := NewCFG()
+	if err := empty.LoadSubtreeInto("Web", filename); err == nil || empty.Exists("Web") {
+		t.Error("Loaded subtree with missing links:", err, empty.String())
+	}
+}
@@ -0,0 +1,96 @@
+package cfg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//Parameter set by Instantiate with the number of each instance, starting at 1
+const InstanceParam = "index"
+
+//Create a copy of the template section for each set of params. Instance i (starting at 1) is created at fmt.Sprintf(pattern, i),
+//for instance "Workers/w%02d", creating the missing parent sections. "${name}" in the values and comments of the copies is replaced by
+//the param name, and "${index}" by the number of the instance unless params set it. Instances inherit what the template inherits.
+//Nothing is created if any instance fails
+func (cfg *CFG) Instantiate(template string, pattern string, params ...map[string]string) error {
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
+	tmpl, _ := cfg.getString(template, true, 0)
+	if tmpl == nil {
+		return newError(ErrNotFound, fmt.Sprintf("Template %s does not exist", template))
+	}
+	root := cfg.root()
+	backup := root.copyTree()
+	mark := cfg.recordMark()
+	for iP, values := range params {
+		path := pattern
+		if strings.Contains(pattern, "%") {
+			path = fmt.Sprintf(pattern, iP+1)
+		}
+		if _, ok := values[InstanceParam]; !ok {
+			values = withParam(values, InstanceParam, strconv.Itoa(iP+1))
+		}
+		if err := cfg.instantiate(tmpl, path, values); err != nil {
+			root.replaceContents(backup)
+			cfg.dropRecorded(mark)
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *CFG) instantiate(tmpl *CFG, path string, params map[string]string) error {
+	p := SplitPath(path)
+	if len(p) == 0 {
+		return newError(ErrNotFound, "Empty path for an instance of "+tmpl.path())
+	}
+	parent, err := cfg.ensureSection(p[:len(p)-1])
+	if err != nil {
+		return err
+	}
+	instance, err := parent.createSection(p[len(p)-1], "")
+	if err != nil {
+		return err
+	}
+	contents := tmpl.copyTree()
+	contents.expandParams(paramsReplacer(params))
+	instance.replaceContents(contents)
+	instance.inheritance = tmpl.inheritance
+	return nil
+}
+
+//Copy of params with name set to value
+func withParam(params map[string]string, name string, value string) map[string]string {
+	dup := make(map[string]string, len(params)+1)
+	for k, v := range params {
+		dup[k] = v
+	}
+	dup[name] = value
+	return dup
+}
+
+//Replacer of "${name}" by the value of each param
+func paramsReplacer(params map[string]string) *strings.Replacer {
+	var pairs []string
+	for name, value := range params {
+		pairs = append(pairs, "${"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+//Replace the params in the values and comments of a copied section
+func (cfg *CFG) expandParams(r *strings.Replacer) {
+	cfg.comment = r.Replace(cfg.comment)
+	for _, opt := range cfg.options {
+		opt.comment = r.Replace(opt.comment)
+		for iV, value := range opt.value {
+			opt.value[iV] = r.Replace(value)
+		}
+	}
+	for _, sec := range cfg.sections {
+		sec.expandParams(r)
+	}
+}
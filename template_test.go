@@ -0,0 +1,34 @@
+package cfg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInstantiate(t *testing.T) {
+	data := "Defaults {\n\tTimeout = 30\n}\nTemplates {\n\t#Worker ${name}\n\tWorker {< /Defaults\n\t\tName = ${name}\n\t\tPort = 90${index}0\n\t\tLimits {\n\t\t\tQueue = ${queue}\n\t\t}\n\t}\n}\n"
+	cfg, _ := NewCFGFromString(data)
+	err := cfg.Instantiate("/Templates/Worker", "/Workers/w%02d", map[string]string{"name": "alpha", "queue": "fast"}, map[string]string{"name": "beta", "queue": "slow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, value := range map[string]string{"Workers/w01/Name": "alpha", "Workers/w01/Port": "9010", "Workers/w02/Port": "9020",
+		"Workers/w02/Limits/Queue": "slow", "Workers/w02/Timeout": "30", "Templates/Worker/Name": "${name}"} {
+		if v, _ := cfg.GetOption(path); v != value {
+			t.Errorf("Unexpected value for %s: %s", path, v)
+		}
+	}
+	if sec, _ := cfg.GetSection("Workers/w02"); sec.comment != "Worker beta" || sec.inheritance.path() != "Defaults" {
+		t.Error("Unexpected instance:", sec.comment, sec.inheritance)
+	}
+	dump := cfg.String()
+	if err := cfg.Instantiate("Templates/Worker", "Workers/w%02d", map[string]string{}, map[string]string{}, map[string]string{}); !errors.Is(err, ErrExists) {
+		t.Error("Unexpected error:", err)
+	}
+	if cfg.String() != dump || cfg.ExistsSection("Workers/w03") {
+		t.Error("Failed instantiation modified the tree:", cfg.String())
+	}
+	if err := cfg.Instantiate("Templates/Missing", "Other", nil); !errors.Is(err, ErrNotFound) {
+		t.Error("Unexpected error:", err)
+	}
+}
@@ -0,0 +1,98 @@
+package cfg
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+//Options of PrintTree
+type PrintOptions struct {
+	//Levels of sections to show. 0 shows all of them
+	MaxDepth int
+	//Show the values of the options after their names
+	ShowValues bool
+	//Color sections, aliases and values with ANSI escape codes for terminals
+	Color bool
+}
+
+const (
+	colorSection = "\x1b[1;34m"
+	colorAlias   = "\x1b[36m"
+	colorValue   = "\x1b[32m"
+	colorReset   = "\x1b[0m"
+)
+
+//Render the sections and options under this section as a tree, like the tree command does with directories:
+//
+//	.
+//	├── DB < Defaults
+//	│   ├── Host = db1
+//	│   └── Port = 3306
+//	└── Database -> DB
+//
+//Inherited sections are shown after "<" and the targets of aliases after "->"
+func (cfg *CFG) PrintTree(w io.Writer, opts PrintOptions) error {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	return bufferedDump(w, func(b *bufio.Writer) error {
+		name := "."
+		if cfg.parent != nil {
+			name = cfg.path()
+		}
+		if err := writeStrings(b, opts.paint(colorSection, name), "\n"); err != nil {
+			return err
+		}
+		return cfg.printTree(b, "", 1, opts)
+	})
+}
+
+func (opts PrintOptions) paint(color string, text string) string {
+	if !opts.Color {
+		return text
+	}
+	return color + text + colorReset
+}
+
+func (cfg *CFG) printTree(b *bufio.Writer, prefix string, depth int, opts PrintOptions) error {
+	for iN, name := range cfg.order {
+		branch, indent := "├── ", "│   "
+		if iN == len(cfg.order)-1 {
+			branch, indent = "└── ", "    "
+		}
+		if err := writeStrings(b, prefix, branch); err != nil {
+			return err
+		}
+		sec, ok := cfg.sections[name]
+		if !ok {
+			line := name
+			if opts.ShowValues {
+				line += " = " + opts.paint(colorValue, strings.Join(cfg.options[name].value, ", "))
+			}
+			if err := writeStrings(b, line, "\n"); err != nil {
+				return err
+			}
+			continue
+		}
+		if sec.alias != nil {
+			if err := writeStrings(b, opts.paint(colorAlias, name), " -> ", sec.alias.path(), "\n"); err != nil {
+				return err
+			}
+			continue
+		}
+		line := opts.paint(colorSection, name)
+		if sec.inheritance != nil {
+			line += " < " + sec.inheritance.path()
+		}
+		if err := writeStrings(b, line, "\n"); err != nil {
+			return err
+		}
+		if opts.MaxDepth > 0 && depth >= opts.MaxDepth {
+			continue
+		}
+		if err := sec.printTree(b, prefix+indent, depth+1, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package cfg
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrintTree(t *testing.T) {
+	cfg, err := NewCFGFromString("Defaults {\n\tPort = 3306\n}\nDB {< Defaults\n\tHost = db1\n\tReplicas {\n\t\tR1 = db2\n\t\tR1 += db3\n\t}\n}\n@alias Database = DB\nName = api\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := cfg.PrintTree(&b, PrintOptions{ShowValues: true}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `.
+├── Defaults
+│   └── Port = 3306
+├── DB < Defaults
+│   ├── Host = db1
+│   └── Replicas
+│       └── R1 = db2, db3
+├── Database -> DB
+└── Name = api
+`
+	if b.String() != expected {
+		t.Error("Unexpected tree:\n" + b.String())
+	}
+	b.Reset()
+	if err := cfg.PrintTree(&b, PrintOptions{MaxDepth: 1}); err != nil {
+		t.Fatal(err)
+	}
+	expected = ".\n├── Defaults\n├── DB < Defaults\n├── Database -> DB\n└── Name\n"
+	if b.String() != expected {
+		t.Error("Unexpected limited tree:\n" + b.String())
+	}
+	b.Reset()
+	if err := cfg.getSection("DB", false).PrintTree(&b, PrintOptions{Color: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), colorSection+"DB"+colorReset+"\n") {
+		t.Error("Unexpected colored tree:\n" + b.String())
+	}
+}
@@ -0,0 +1,100 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//Separates the name of an option from it's declared type when loading and dumping, like in "Port:int = 5"
+const typeHintChar = ":"
+
+//Split "name:type" into the name and the type if type is one of the types options can declare. Otherwise the whole text is the name
+func splitTypeHint(name string) (string, string) {
+	pos := strings.LastIndex(name, typeHintChar)
+	if pos < 0 {
+		return name, ""
+	}
+	typ := strings.Trim(name[pos+1:], trimChars)
+	if check := schemaTypes[typ]; check == nil {
+		return name, ""
+	}
+	return strings.Trim(name[:pos], trimChars), typ
+}
+
+//Check that values are valid for the declared type typ. Options without type accept any value
+func checkType(typ string, values []string) error {
+	if typ == "" {
+		return nil
+	}
+	for _, value := range values {
+		if err := schemaTypes[typ](value); err != nil {
+			return errors.New(fmt.Sprintf("%s is not a valid %s", value, typ))
+		}
+	}
+	return nil
+}
+
+//Values to write to option name of this section once normalized and checked against it's declared type. opt is nil if the option
+//does not exist yet
+func (cfg *CFG) checkedValues(name string, opt *option, values []string) ([]string, error) {
+	values = cfg.root().normalize(cfg.childPath(name), values)
+	if opt == nil {
+		return values, nil
+	}
+	if err := checkType(opt.typ, values); err != nil {
+		return nil, errors.New(fmt.Sprintf("Cannot set %s: %s", cfg.childPath(name), err.Error()))
+	}
+	return values, nil
+}
+
+//Set an option declaring the type of it's values: string, int, float, bool or duration. The values written afterwards, via the
+//setters or loading contents, must be valid for the type, which is kept in dumps as "name:type = value".
+//This overwrites the option and it's type if it exists
+func (cfg *CFG) SetTypedOptionArray(name string, typ string, value []string, comment string) error {
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
+	return cfg.setTypedOptionArray(name, typ, value, comment)
+}
+
+func (cfg *CFG) setTypedOptionArray(name string, typ string, value []string, comment string) error {
+	if check := schemaTypes[typ]; check == nil {
+		return errors.New(fmt.Sprintf("Unknown type %s for option %s", typ, name))
+	}
+	if err := checkType(typ, value); err != nil {
+		return errors.New(fmt.Sprintf("Cannot set %s: %s", cfg.childPath(name), err.Error()))
+	}
+	//The old type must not reject the new values, it is restored if the option cannot be set
+	_, opt := cfg.getString(name, false, 0)
+	previous := ""
+	if opt != nil {
+		previous, opt.typ = opt.typ, ""
+	}
+	if err := cfg.setOptionArray(name, value, comment); err != nil {
+		if opt != nil {
+			opt.typ = previous
+		}
+		return err
+	}
+	_, opt = cfg.getString(name, false, 0)
+	opt.typ = typ
+	return nil
+}
+
+//Like SetTypedOptionArray with a single value
+func (cfg *CFG) SetTypedOption(name string, typ string, value string, comment string) error {
+	return cfg.SetTypedOptionArray(name, typ, []string{value}, comment)
+}
+
+//Declared type of an option, following inheritance. Returns false if the option does not exist or has no type
+func (cfg *CFG) GetType(name string) (string, bool) {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	opt := cfg.lookupOption(name)
+	if opt == nil || opt.typ == "" {
+		return "", false
+	}
+	return opt.typ, true
+}
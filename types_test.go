@@ -0,0 +1,109 @@
+package cfg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTypeHints(t *testing.T) {
+	cfg, err := NewCFGFromString("DB {\n\tPort:int = 3306\n\tHost = db1\n\tURL:x = y\n}\nApp {< DB\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ, ok := cfg.GetType("App/Port"); !ok || typ != "int" {
+		t.Error("Unexpected type:", typ, ok)
+	}
+	if _, ok := cfg.GetType("DB/Host"); ok {
+		t.Error("Untyped option has a type")
+	}
+	if !cfg.Exists("DB/URL:x") {
+		t.Error("Unknown types are part of the name")
+	}
+	if err := cfg.SetOption("DB/Port", "many", ""); err == nil || err.Error() != "Cannot set DB/Port: many is not a valid int" {
+		t.Error("Unexpected error:", err)
+	}
+	if err := cfg.SetOption("DB/Port", "3307", ""); err != nil || cfg.GetValue("DB/Port", "") != "3307" {
+		t.Error("Cannot set valid value:", err)
+	}
+	if err := cfg.SetTypedOption("DB/Timeout", "duration", "5", ""); err == nil {
+		t.Error("Set invalid typed value")
+	}
+	if err := cfg.SetTypedOption("DB/Port", "string", "many", ""); err != nil {
+		t.Error("Cannot change the type:", err)
+	}
+	if err := cfg.SetTypedOptionArray("DB/Ratio", "number", []string{"1"}, ""); err == nil {
+		t.Error("Set option with unknown type")
+	}
+	cfg.SetTypedOptionArray("DB/Debug", "bool", []string{"yes", "no"}, "")
+	expected := "DB {\n\tPort:string = many\n\tHost = db1\n\tURL:x = y\n\tDebug:bool = yes\n\tDebug += no\n}\nApp {< DB\n}\n"
+	if cfg.String() != expected {
+		t.Error("Unexpected dump:", cfg.String())
+	}
+	loaded, err := NewCFGFromString(expected)
+	if err != nil || loaded.String() != expected {
+		t.Error("Dump does not round trip:", err, loaded.String())
+	}
+	if _, err := NewCFGFromString("a:int = 1\na += b\n"); err == nil || err.Error() != "b is not a valid int (line 2)" {
+		t.Error("Unexpected error:", err)
+	}
+	if _, err := NewCFGFromString("a:float = b\n"); err == nil {
+		t.Error("Loaded invalid typed value")
+	}
+}
+
+func TestTypeHintsPatches(t *testing.T) {
+	cfg := NewCFG()
+	cfg.SetTypedOptionArray("port", "int", []string{"1", "2"}, "")
+	if err := cfg.ApplyMergePatch([]byte(`{"port":"abc"}`)); err == nil {
+		t.Error("Merge patch set an invalid typed value")
+	}
+	if err := cfg.ApplyMergePatch([]byte(`{"port":"3"}`)); err != nil {
+		t.Error("Merge patch could not set a valid value:", err)
+	}
+	for _, patch := range []string{
+		`[{"op":"replace","path":"/port","value":"abc"}]`,
+		`[{"op":"add","path":"/port","value":["4","x"]}]`,
+		`[{"op":"add","path":"/port/0","value":"x"}]`,
+		`[{"op":"replace","path":"/port/0","value":"x"}]`,
+	} {
+		if err := cfg.ApplyJSONPatch([]byte(patch)); err == nil {
+			t.Error("JSON patch set an invalid typed value:", patch)
+		}
+	}
+	if err := cfg.ApplyJSONPatch([]byte(`[{"op":"replace","path":"/port","value":"5"},{"op":"add","path":"/port/-","value":"6"}]`)); err != nil {
+		t.Fatal(err)
+	}
+	if typ, ok := cfg.GetType("port"); !ok || typ != "int" {
+		t.Error("Patching the option lost it's type:", typ)
+	}
+	if expected := "port:int = 5\nport += 6\n"; cfg.String() != expected {
+		t.Error("Unexpected dump:", cfg.String())
+	}
+}
+
+func TestTypeHintsKeptOnFailure(t *testing.T) {
+	cfg := NewCFG()
+	cfg.SetTypedOption("port", "int", "1", "")
+	cfg.LockOption("port", true)
+	if err := cfg.SetTypedOption("port", "string", "x", ""); !errors.Is(err, ErrLocked) {
+		t.Error("Changed a locked option:", err)
+	}
+	if typ, ok := cfg.GetType("port"); !ok || typ != "int" {
+		t.Error("Failing to set the option lost it's type:", typ, ok)
+	}
+}
+
+func TestTypeHintsInsertContents(t *testing.T) {
+	cfg, _ := NewCFGFromString("port:int = 1\n")
+	bad, _ := NewCFGFromString("port = abc\n")
+	if err := cfg.InsertContents(bad); err == nil {
+		t.Error("Merged an invalid typed value")
+	}
+	good, _ := NewCFGFromString("port = 2\n")
+	if err := cfg.InsertContents(good); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "port:int = 2\n"; cfg.String() != expected {
+		t.Error("Unexpected dump:", cfg.String())
+	}
+}
@@ -0,0 +1,220 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultListSeparator = ","
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+//Unmarshal fills the struct pointed by v with the contents of the section under path.
+//Every exported field is mapped to the option named as the field or as its `cfg:"name"` tag (`cfg:"-"` skips the field).
+//Fields of a type with a converter registered with RegisterConverter are parsed with it and fields implementing
+//encoding.TextUnmarshaler get the raw value in UnmarshalText.
+//Other struct fields are filled from the sub section with the same name, except embedded structs without a cfg tag which are
+//filled from the same section as encoding/json flattens them. Options and sections are looked up following inheritance,
+//and the anchors and references in the values are resolved, as the getters do. Pointer fields are only set if there is a value
+//for them, so a nil pointer tells an option or a section missing apart from the zero value.
+//If an option does not exist, the environment variable named in the `env:"VAR"` tag is used and,
+//if that one is not defined either, the value of the `default:"..."` tag. Slice fields split env and default values by ","
+func (cfg *CFG) Unmarshal(path string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("Unmarshal needs a non nil pointer to a struct")
+	}
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	sec := cfg
+	if p := SplitPath(path); len(p) > 0 {
+		if sec, _ = cfg.get(p, true, 0); sec == nil {
+			return newError(ErrNotFound, fmt.Sprintf("Section %s does not exist", path))
+		}
+	}
+	return sec.unmarshal(strings.Join(SplitPath(path), SplitChar), rv.Elem())
+}
+
+//Fill a struct from this section. cfg can be nil if the section does not exist so env and default values still apply
+func (cfg *CFG) unmarshal(path string, rv reflect.Value) error {
+	rt := rv.Type()
+	for iF := 0; iF < rt.NumField(); iF++ {
+		field := rt.Field(iF)
+		fv := rv.Field(iF)
+		if field.Anonymous && field.Tag.Get("cfg") == "" {
+			embedded, err := cfg.unmarshalEmbedded(path, fv)
+			if err != nil {
+				return err
+			}
+			if embedded {
+				continue
+			}
+		}
+		if !fv.CanSet() {
+			continue
+		}
+		name := field.Tag.Get("cfg")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fullPath := name
+		if path != "" {
+			fullPath = path + SplitChar + name
+		}
+		isPtr := fv.Kind() == reflect.Ptr
+		target := fv
+		if isPtr {
+			target = reflect.New(fv.Type().Elem()).Elem()
+		}
+		if target.Kind() == reflect.Struct && parserFor(target.Type()) == nil {
+			var sub *CFG
+			if cfg != nil {
+				sub = cfg.getSection(name, true)
+			}
+			//Pointers to structs stay nil if there is no section for them
+			if isPtr && sub == nil {
+				continue
+			}
+			if err := sub.unmarshal(fullPath, target); err != nil {
+				return err
+			}
+			if isPtr {
+				fv.Set(target.Addr())
+			}
+			continue
+		}
+		var values []string
+		if cfg != nil {
+			var err error
+			if values, err = cfg.lookupValues(name); err != nil && err != errMissingOption {
+				return errors.New(fmt.Sprintf("Cannot unmarshal %s into field %s: %s", fullPath, field.Name, err.Error()))
+			}
+		}
+		if values == nil {
+			raw, ok := "", false
+			if env := field.Tag.Get("env"); env != "" {
+				raw, ok = os.LookupEnv(env)
+			}
+			if !ok {
+				raw, ok = field.Tag.Lookup("default")
+			}
+			if !ok {
+				continue
+			}
+			if target.Kind() == reflect.Slice {
+				values = strings.Split(raw, defaultListSeparator)
+				for iV := range values {
+					values[iV] = strings.Trim(values[iV], trimChars)
+				}
+			} else {
+				values = []string{raw}
+			}
+		}
+		if err := decodeValues(target, values); err != nil {
+			return errors.New(fmt.Sprintf("Cannot unmarshal %s into field %s: %s", fullPath, field.Name, err.Error()))
+		}
+		if isPtr {
+			fv.Set(target.Addr())
+		}
+	}
+	return nil
+}
+
+//Fill an embedded struct from this same section, as encoding/json flattens them. Returns false if fv is not an embedded struct.
+//Pointers to embedded structs are only allocated if the section exists
+func (cfg *CFG) unmarshalEmbedded(path string, fv reflect.Value) (bool, error) {
+	ft := fv.Type()
+	isPtr := ft.Kind() == reflect.Ptr
+	if isPtr {
+		ft = ft.Elem()
+	}
+	if ft.Kind() != reflect.Struct || parserFor(ft) != nil {
+		return false, nil
+	}
+	if !isPtr {
+		return true, cfg.unmarshal(path, fv)
+	}
+	if cfg == nil || !fv.CanSet() {
+		return true, nil
+	}
+	target := reflect.New(ft)
+	if err := cfg.unmarshal(path, target.Elem()); err != nil {
+		return true, err
+	}
+	fv.Set(target)
+	return true, nil
+}
+
+//Decode option values into v. Slices get one element per value, anything else gets the values joined as GetOption does
+func decodeValues(v reflect.Value, values []string) error {
+	if v.Kind() == reflect.Slice {
+		slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for iV, val := range values {
+			if err := decodeValue(slice.Index(iV), val); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	return decodeValue(v, strings.Join(values, SplitChar))
+}
+
+//Decode a single raw value into v
+func decodeValue(v reflect.Value, raw string) error {
+	if parse := parserFor(v.Type()); parse != nil {
+		converted, err := parse(raw)
+		if err != nil {
+			return err
+		}
+		v.Set(converted)
+		return nil
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := parseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := parseInteger(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := parseUnsigned(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return errors.New("Unsupported type " + v.Type().String())
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package cfg
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+type unmarshalDB struct {
+	Host string `default:"localhost"`
+	Port int    `cfg:"port" env:"CFG_TEST_DB_PORT" default:"5432"`
+}
+
+type unmarshalTarget struct {
+	Name    string
+	Tags    []string `cfg:"tags"`
+	Workers uint     `default:"4"`
+	Timeout time.Duration
+	Ratio   float64 `env:"CFG_TEST_RATIO" default:"0.5"`
+	Debug   bool    `default:"true"`
+	Ignored string  `cfg:"-"`
+	DB      unmarshalDB
+}
+
+func TestUnmarshal(t *testing.T) {
+	data := "s1 {\nName = srv\ntags = a\ntags += b\nTimeout = 2s\nDebug = false\nIgnored = x\nDB {\nHost = db1\n}\n}\ns2 {< s1\nName = srv2\n}"
+	cfg, err := NewCFGFromString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("CFG_TEST_DB_PORT", "6000")
+	defer os.Unsetenv("CFG_TEST_DB_PORT")
+	var target unmarshalTarget
+	if err := cfg.Unmarshal("s2", &target); err != nil {
+		t.Fatal(err)
+	}
+	if target.Name != "srv2" || !equalSlices(target.Tags, []string{"a", "b"}) || target.Timeout != 2*time.Second {
+		t.Error("Unexpected option values: ", target)
+	}
+	if target.Workers != 4 || target.Ratio != 0.5 || target.Debug || target.Ignored != "" {
+		t.Error("Unexpected default values: ", target)
+	}
+	if target.DB.Host != "db1" || target.DB.Port != 6000 {
+		t.Error("Unexpected sub section values: ", target.DB)
+	}
+	if err := cfg.Unmarshal("s3", &target); err == nil {
+		t.Error("Unmarshalled a non existant section")
+	}
+	if err := cfg.Unmarshal("s1", target); err == nil {
+		t.Error("Unmarshalled into a non pointer")
+	}
+	bad, _ := NewCFGFromString("Workers = many")
+	if err := bad.Unmarshal("/", &target); err == nil {
+		t.Error("Unmarshalled an invalid number")
+	}
+}
+
+type unmarshalPointers struct {
+	Host    string
+	Port    *int
+	Debug   *bool
+	Replica *unmarshalDB
+	Backup  *unmarshalDB
+	URL     string
+	Hosts   []string
+}
+
+func TestUnmarshalResolved(t *testing.T) {
+	data := "@define dbhost = db1\nBase {\n\tPort = 3306\n\tReplica {\n\t\tHost = &dbhost\n\t}\n}\nApp {< Base\n\tHost = &dbhost\n\tURL = mysql://@{Host}:@{Port}\n\tHosts = @{Host}\n\tHosts += db2\n}\n"
+	cfg, err := NewCFGFromString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var target unmarshalPointers
+	if err := cfg.Unmarshal("App", &target); err != nil {
+		t.Fatal(err)
+	}
+	if target.Host != "db1" || target.URL != "mysql://db1:3306" || !equalSlices(target.Hosts, []string{"db1", "db2"}) {
+		t.Error("Unexpected resolved values: ", target)
+	}
+	if target.Port == nil || *target.Port != 3306 || target.Debug != nil {
+		t.Error("Unexpected pointer values: ", target.Port, target.Debug)
+	}
+	if target.Replica == nil || target.Replica.Host != "db1" || target.Replica.Port != 5432 || target.Backup != nil {
+		t.Error("Unexpected pointer sections: ", target.Replica, target.Backup)
+	}
+	loop, _ := NewCFGFromString("a = @{b}\nb = @{a}\n")
+	var looped struct {
+		A string `cfg:"a"`
+	}
+	if err := loop.Unmarshal("/", &looped); err == nil {
+		t.Error("Unmarshalled a reference loop")
+	}
+}
+
+type unmarshalCommon struct {
+	Host string
+	Port int
+}
+
+//Exported so the embedded pointer can be set
+type UnmarshalPool struct {
+	Size int
+}
+
+type unmarshalEmbedded struct {
+	unmarshalCommon
+	*UnmarshalPool
+	Name string
+	Mode uint
+}
+
+func TestUnmarshalEmbedded(t *testing.T) {
+	cfg, _ := NewCFGFromString("Host = db1\nPort = 010\nSize = 08080\nName = srv\nMode = 0755\n")
+	var target unmarshalEmbedded
+	if err := cfg.Unmarshal("/", &target); err != nil {
+		t.Fatal(err)
+	}
+	if target.Host != "db1" || target.Port != 10 || target.Name != "srv" || target.Mode != 755 {
+		t.Error("Unexpected values:", target)
+	}
+	if target.UnmarshalPool == nil || target.Size != 8080 {
+		t.Error("Embedded pointer was not filled from the same section:", target.UnmarshalPool)
+	}
+}
@@ -0,0 +1,271 @@
+package cfg
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+//Version of the last write of an option. Timestamp comes from a hybrid logical clock (nanoseconds since the epoch that never go backwards
+//and always move past any version seen from other writers) and Writer identifies who made the write
+type OptionVersion struct {
+	Timestamp int64
+	Writer    string
+}
+
+//Is this version newer than other? Ties in the timestamp are broken by the writer so every replica takes the same decision
+func (v OptionVersion) After(other OptionVersion) bool {
+	if v.Timestamp != other.Timestamp {
+		return v.Timestamp > other.Timestamp
+	}
+	return v.Writer > other.Writer
+}
+
+//Versions kept for a tree. It lives in the root
+type versioning struct {
+	writer string
+	clock  int64
+	//Versions of the removals of options and sections by path
+	tombstones map[string]OptionVersion
+	//Changes of the current batch already carry their versions
+	skip bool
+}
+
+//Keep versions for every option write and removal done to this tree as writer. This is needed to reconcile trees edited concurrently with ReconcileWith
+func (cfg *CFG) EnableVersioning(writer string) {
+	cfg.lock.Lock()
+	defer cfg.lock.Unlock()
+	root := cfg.root()
+	if root.versioning == nil {
+		root.versioning = &versioning{tombstones: make(map[string]OptionVersion)}
+	}
+	root.versioning.writer = writer
+}
+
+//Version of the last write of an option. Options that have not been written since versioning was enabled have a zero version
+func (cfg *CFG) OptionVersion(name string) (OptionVersion, bool) {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	if _, opt := cfg.getString(name, false, 0); opt != nil {
+		return opt.version, true
+	}
+	return OptionVersion{}, false
+}
+
+func (v *versioning) next() OptionVersion {
+	now := time.Now().UnixNano()
+	if now <= v.clock {
+		now = v.clock + 1
+	}
+	v.clock = now
+	return OptionVersion{now, v.writer}
+}
+
+//Move the clock past a version seen from another writer
+func (v *versioning) observe(version OptionVersion) {
+	if version.Timestamp > v.clock {
+		v.clock = version.Timestamp
+	}
+}
+
+//Assign versions to the options changed by a batch of events
+func (v *versioning) stamp(root *CFG, events []ChangeEvent) {
+	if v.skip || len(events) == 0 {
+		v.skip = false
+		return
+	}
+	for _, event := range events {
+		switch event.Kind {
+		case OptionSet:
+			if _, opt := root.get(SplitPath(event.Path), false, 0); opt != nil {
+				opt.version = v.next()
+				delete(v.tombstones, event.Path)
+			}
+		case OptionRemoved, SectionRemoved:
+			v.tombstones[event.Path] = v.next()
+		}
+	}
+}
+
+//Newest removal affecting path (the removal of the path itself or of any of it's parents)
+func (v *versioning) removal(path string) (OptionVersion, bool) {
+	var newest OptionVersion
+	found := false
+	p := SplitPath(path)
+	for iP := range p {
+		if tomb, ok := v.tombstones[strings.Join(p[:iP+1], SplitChar)]; ok && (!found || tomb.After(newest)) {
+			newest, found = tomb, true
+		}
+	}
+	return newest, found
+}
+
+//State of an option path in a tree: it's current option or the removal that deleted it
+type versionedEntry struct {
+	opt     *option
+	removal OptionVersion
+}
+
+func (e versionedEntry) version() OptionVersion {
+	if e.opt != nil {
+		return e.opt.version
+	}
+	return e.removal
+}
+
+//Does e win over other in a last writer wins merge? Existing options win over removals with the same version and
+//equal versions with different values are decided by the values so the result is the same regardless of the direction of the merge
+func (e versionedEntry) wins(other versionedEntry) bool {
+	if e.version() != other.version() {
+		return e.version().After(other.version())
+	}
+	if e.opt == nil || other.opt == nil {
+		return e.opt != nil
+	}
+	return strings.Join(e.opt.value, "\n") > strings.Join(other.opt.value, "\n")
+}
+
+//Collect all options under this section by path
+func (cfg *CFG) collectOptions(prefix string, options map[string]*option, order *[]string) {
+	for _, name := range cfg.order {
+		path := joinEventPath(prefix, name)
+		if sec, ok := cfg.sections[name]; ok {
+			sec.collectOptions(path, options, order)
+		} else {
+			if _, ok := options[path]; !ok {
+				*order = append(*order, path)
+			}
+			options[path] = cfg.options[name]
+		}
+	}
+}
+
+//Merge the options of other into this tree using last writer wins: for every option the newest write or removal of both trees is kept.
+//Both trees need versioning enabled (other may be a tree loaded without it, then all it's options are older than any versioned write).
+//Reconciling two trees in any direction ends with the same options. Comments and inheritance are not reconciled
+func (cfg *CFG) ReconcileWith(other *CFG) error {
+	//Sections of the same tree share the lock and have nothing to reconcile
+	if other.lock == cfg.lock {
+		return nil
+	}
+	//Copy what is needed from the other tree before taking our lock so two trees reconciling with each other do not deadlock
+	remote := make(map[string]*option)
+	remotePaths := make([]string, 0)
+	otherTombstones := map[string]OptionVersion{}
+	other.lock.RLock()
+	otherRoot := other.root()
+	otherRoot.collectOptions("", remote, &remotePaths)
+	for path, opt := range remote {
+		copied := *opt
+		copied.value = append([]string{}, opt.value...)
+		remote[path] = &copied
+	}
+	if otherRoot.versioning != nil {
+		for path, tomb := range otherRoot.versioning.tombstones {
+			otherTombstones[path] = tomb
+		}
+	}
+	other.lock.RUnlock()
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
+	root := cfg.root()
+	if root.versioning == nil {
+		return errors.New("Versioning is not enabled")
+	}
+	v := root.versioning
+	//The changes done here carry the versions of the writes they come from
+	defer func() { v.skip = true }()
+	//Leave the tree and the tombstones as they were if something cannot be reconciled
+	backup := root.copyTree()
+	mark := root.recordMark()
+	tombstones := make(map[string]OptionVersion, len(v.tombstones))
+	for path, tomb := range v.tombstones {
+		tombstones[path] = tomb
+	}
+	if err := root.reconcile(v, remote, remotePaths, otherTombstones); err != nil {
+		root.replaceContents(backup)
+		root.dropRecorded(mark)
+		v.tombstones = tombstones
+		return err
+	}
+	return nil
+}
+
+//Apply the options and tombstones copied from another tree that are newer than the ones in this tree, which has to be the root
+func (cfg *CFG) reconcile(v *versioning, remote map[string]*option, remotePaths []string, otherTombstones map[string]OptionVersion) error {
+	local := make(map[string]*option)
+	paths := make([]string, 0)
+	cfg.collectOptions("", local, &paths)
+	for _, path := range remotePaths {
+		if _, ok := local[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	for path, tomb := range otherTombstones {
+		v.observe(tomb)
+		if current, ok := v.tombstones[path]; !ok || tomb.After(current) {
+			v.tombstones[path] = tomb
+		}
+	}
+	//Versions taken by options with the same values are only set once everything else succeeded
+	adopted := make(map[*option]OptionVersion)
+	for _, path := range paths {
+		mine := versionedEntry{opt: local[path]}
+		theirs := versionedEntry{opt: remote[path]}
+		if theirs.opt != nil {
+			v.observe(theirs.opt.version)
+		}
+		if mine.opt == nil {
+			mine.removal, _ = v.removal(path)
+		}
+		if theirs.opt == nil {
+			theirs.removal, _ = v.removal(path)
+		}
+		if !theirs.wins(mine) {
+			continue
+		}
+		p := SplitPath(path)
+		if theirs.opt == nil {
+			if mine.opt != nil {
+				parent, _ := cfg.get(p, false, 1)
+				parent.removeChild(p[len(p)-1])
+			}
+			continue
+		}
+		if mine.opt != nil && equalValues(mine.opt.value, theirs.opt.value) {
+			adopted[mine.opt] = theirs.opt.version
+			continue
+		}
+		parent, err := cfg.ensureSection(p[:len(p)-1])
+		if err != nil {
+			return err
+		}
+		if err := parent.setOptionArray(p[len(p)-1], append([]string{}, theirs.opt.value...), theirs.opt.comment); err != nil {
+			return err
+		}
+		parent.options[p[len(p)-1]].version = theirs.opt.version
+		delete(v.tombstones, path)
+	}
+	for opt, version := range adopted {
+		opt.version = version
+	}
+	cfg.pruneRemoved("", v)
+	return nil
+}
+
+//Remove the sections that have been emptied by the reconciliation because they were removed in the other tree
+func (cfg *CFG) pruneRemoved(prefix string, v *versioning) {
+	for _, name := range append([]string{}, cfg.order...) {
+		sec, ok := cfg.sections[name]
+		if !ok {
+			continue
+		}
+		path := joinEventPath(prefix, name)
+		sec.pruneRemoved(path, v)
+		if _, removed := v.tombstones[path]; removed && len(sec.order) == 0 && sec.findHeir(cfg.root()) == nil {
+			cfg.removeChild(name)
+		}
+	}
+}
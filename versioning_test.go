@@ -0,0 +1,117 @@
+package cfg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconcileWith(t *testing.T) {
+	base := "DB\n{\n\tHost = db1\n\tPort = 3306\n}\nWeb\n{\n\tPort = 80\n}\nOld\n{\n\tKey = 1\n}\n"
+	a, _ := NewCFGFromString(base)
+	b, _ := NewCFGFromString(base)
+	if err := a.ReconcileWith(b); err == nil {
+		t.Error("Reconciled without versioning")
+	}
+	a.EnableVersioning("admin-a")
+	b.EnableVersioning("admin-b")
+
+	//Concurrent edits of different keys
+	a.SetOption("DB/Host", "db2", "")
+	b.SetOption("Web/Port", "8080", "")
+	b.Remove("Old")
+	a.SetOption("New", "a", "")
+	//Both edit the same key, b writes last
+	a.SetOption("DB/Port", "1", "")
+	b.SetOption("DB/Port", "2", "")
+	if v, ok := b.OptionVersion("DB/Port"); !ok || v.Writer != "admin-b" || v.Timestamp == 0 {
+		t.Error("Unexpected version:", v)
+	}
+
+	if err := a.ReconcileWith(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ReconcileWith(a); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"DB/Host": "db2", "DB/Port": "2", "Web/Port": "8080", "New": "a"}
+	for _, tree := range []*CFG{a, b} {
+		for path, value := range expected {
+			if v, _ := tree.GetOption(path); v != value {
+				t.Errorf("%s is '%s' instead of '%s'", path, v, value)
+			}
+		}
+		if tree.Exists("Old") {
+			t.Error("Removed section was resurrected:\n" + tree.String())
+		}
+	}
+	if a.Hash() != b.Hash() {
+		t.Error("Trees did not converge:\n" + a.String() + "\n" + b.String())
+	}
+
+	//Changes observed through the reconciliation keep their versions
+	va, _ := a.OptionVersion("Web/Port")
+	vb, _ := b.OptionVersion("Web/Port")
+	if va != vb {
+		t.Error("Versions differ after reconciling:", va, vb)
+	}
+	//New writes are newer than anything seen
+	a.SetOption("Web/Port", "81", "")
+	if v, _ := a.OptionVersion("Web/Port"); !v.After(vb) {
+		t.Error("New write is not newer than reconciled one")
+	}
+}
+
+func TestReconcileWithSameTree(t *testing.T) {
+	tree, _ := NewCFGFromString("DB\n{\n\tHost = db1\n}\n")
+	tree.EnableVersioning("admin")
+	db, _ := tree.GetSection("DB")
+	done := make(chan error, 2)
+	go func() {
+		done <- tree.ReconcileWith(tree)
+		done <- db.ReconcileWith(tree)
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Error(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Reconciling a tree with itself deadlocked")
+		}
+	}
+	if v, _ := tree.GetOption("DB/Host"); v != "db1" {
+		t.Error("Reconciling with itself changed the tree:", v)
+	}
+}
+
+func TestReconcileWithRollback(t *testing.T) {
+	base := "A\n{\n\tKey = 1\n}\nOld\n{\n\tKey = 1\n}\nWeb\n{\n\tPort = 80\n}\nZ\n{\n\tKey = 1\n}\n"
+	a, _ := NewCFGFromString(base)
+	b, _ := NewCFGFromString(base)
+	a.EnableVersioning("admin-a")
+	b.EnableVersioning("admin-b")
+	a.LockOption("Z/Key", true)
+	a.SetOption("Web/Port", "8080", "")
+	version, _ := a.OptionVersion("Web/Port")
+	b.SetOption("A/Key", "2", "")
+	b.Remove("Old")
+	b.SetOption("Web/Port", "8080", "")
+	b.SetOption("Z/Key", "2", "")
+	data := a.String()
+	if len(b.root().versioning.tombstones) == 0 || len(a.root().versioning.tombstones) != 0 {
+		t.Fatal("Unexpected tombstones")
+	}
+	if err := a.ReconcileWith(b); err == nil {
+		t.Fatal("Reconciled over a locked option")
+	}
+	if a.String() != data {
+		t.Error("Failed reconciliation was not rolled back:\n" + a.String())
+	}
+	if v, _ := a.OptionVersion("Web/Port"); v != version {
+		t.Error("Failed reconciliation changed a version:", v, version)
+	}
+	if len(a.root().versioning.tombstones) != 0 {
+		t.Error("Failed reconciliation kept the tombstones of the other tree")
+	}
+}
@@ -0,0 +1,158 @@
+package cfg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+//Append only log of the mutations done to a tree. It lives in the root
+type mutationLog struct {
+	//Taken before releasing the lock of the tree and held while writing, so entries keep the order of the changes
+	lock sync.Mutex
+	w    io.Writer
+	err  error
+}
+
+//Append every mutation done to the tree to w as a JSON line (one per change, with the same fields as the event stream).
+//The entries of a modification are written right after the lock of the tree is released, so readers are not blocked by slow writers,
+//holding a lock of the log taken before releasing it, so the log has the same order as the changes.
+//Replaying the log with ReplayLog on top of the last file saved with SaveToFile reconstructs the tree after a crash.
+//Comments are not logged. Pass nil to stop logging
+func (cfg *CFG) SetMutationLog(w io.Writer) {
+	cfg.lock.Lock()
+	defer cfg.lock.Unlock()
+	root := cfg.root()
+	if w == nil {
+		root.mutationLog = nil
+		return
+	}
+	root.mutationLog = &mutationLog{w: w}
+}
+
+//First error found writing to the mutation log. Once writing fails nothing else is logged
+func (cfg *CFG) MutationLogError() error {
+	cfg.lock.RLock()
+	defer cfg.lock.RUnlock()
+	if log := cfg.root().mutationLog; log != nil {
+		log.lock.Lock()
+		defer log.lock.Unlock()
+		return log.err
+	}
+	return nil
+}
+
+//Write the entries of events. The lock of the log must be held
+func (log *mutationLog) write(events []ChangeEvent) {
+	if log.err != nil {
+		return
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	for _, event := range events {
+		if err := enc.Encode(jsonChangeEvent{event.Path, event.Kind.String(), event.Value, event.Paths}); err != nil {
+			log.err = err
+			return
+		}
+	}
+	_, log.err = log.w.Write(buf.Bytes())
+}
+
+//Empty the log if the writer supports it (as *os.File does)
+func (log *mutationLog) truncate() error {
+	log.lock.Lock()
+	defer log.lock.Unlock()
+	truncater, ok := log.w.(interface {
+		Truncate(size int64) error
+	})
+	if !ok {
+		return nil
+	}
+	if err := truncater.Truncate(0); err != nil {
+		return err
+	}
+	if seeker, ok := log.w.(io.Seeker); ok {
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseChangeKind(name string) (ChangeKind, error) {
+	for kind := OptionSet; kind <= RolledBack; kind++ {
+		if kind.String() == name {
+			return kind, nil
+		}
+	}
+	return 0, errors.New("Unknown change kind " + name)
+}
+
+//Apply the mutations of a log written via SetMutationLog. Replay it before setting a log on this tree or the replayed changes will be logged again
+func (cfg *CFG) ReplayLog(r io.Reader) error {
+	if err := cfg.writeLock(); err != nil {
+		return err
+	}
+	defer cfg.unlock()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.Trim(scanner.Bytes(), trimChars)) == 0 {
+			continue
+		}
+		var entry jsonChangeEvent
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return errors.New(fmt.Sprintf("Invalid log entry: %s (line %d)", err.Error(), line))
+		}
+		kind, err := parseChangeKind(entry.Kind)
+		if err == nil {
+			err = cfg.replayChange(kind, SplitPath(entry.Path), entry.Value)
+		}
+		if err != nil {
+			return errors.New(fmt.Sprintf("Cannot replay %s %s: %s (line %d)", entry.Kind, entry.Path, err.Error(), line))
+		}
+	}
+	return scanner.Err()
+}
+
+func (cfg *CFG) replayChange(kind ChangeKind, p []string, value []string) error {
+	if len(p) == 0 {
+		return errors.New("Empty path")
+	}
+	switch kind {
+	case SectionCreated:
+		_, err := cfg.ensureSection(p)
+		return err
+	case OptionSet:
+		parent, err := cfg.ensureSection(p[:len(p)-1])
+		if err != nil {
+			return err
+		}
+		//The log only has the changes that were allowed when they were made
+		return parent.forced(func() error {
+			return parent.setOptionArray(p[len(p)-1], value, "")
+		})
+	case OptionRemoved, SectionRemoved:
+		if sec, opt := cfg.get(p, false, 0); sec == nil && opt == nil {
+			return nil
+		}
+		return cfg.remove(p)
+	case InheritanceSet:
+		sec, _ := cfg.get(p, false, 0)
+		if sec == nil {
+			return newError(ErrNotFound, "Section does not exist")
+		}
+		if len(value) == 0 {
+			sec.inheritance = nil
+			sec.record(InheritanceSet, sec.path(), nil)
+			return nil
+		}
+		return sec.setInheritance(value[0])
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package cfg
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestMutationLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgwal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "app.cfg")
+	cfg, _ := NewCFGFromString("Base\n{\n\tPort = 80\n}\nWeb\n{\n\tHost = a\n}\n")
+	logFile, err := os.OpenFile(filepath.Join(dir, "app.log"), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+	cfg.SetMutationLog(logFile)
+	cfg.SetOption("Lost", "1", "")
+	if err := cfg.SaveToFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := logFile.Stat(); info.Size() != 0 {
+		t.Error("Log was not truncated after saving")
+	}
+
+	cfg.SetOption("Web/Host", "b", "")
+	cfg.SetOptionArray("Web/Hosts", []string{"c", "d"}, "")
+	cfg.CreateSection("New", "")
+	cfg.SetOption("New/Key", "v", "")
+	web, _ := cfg.GetSection("Web")
+	web.SetInheritance("Base")
+	cfg.Remove("Lost")
+	cfg.ApplyJSONPatch([]byte(`[{"op":"add","path":"/New/Key","value":"x"},{"op":"test","path":"/Nope","value":"x"}]`))
+	if err := cfg.MutationLogError(); err != nil {
+		t.Fatal(err)
+	}
+
+	recovered, err := NewCFGFromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(logFile.Name())
+	if err := recovered.ReplayLog(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if !recovered.Equal(cfg) {
+		t.Error("Recovered tree differs:\n" + recovered.String() + "\nexpected:\n" + cfg.String())
+	}
+	if err := NewCFG().ReplayLog(bytes.NewBufferString("{\"path\":\"a\",\"kind\":\"bogus\"}\n")); err == nil {
+		t.Error("Replayed an invalid log")
+	}
+}
+
+func TestMutationLogConcurrentWrites(t *testing.T) {
+	cfg := NewCFG()
+	var log bytes.Buffer
+	cfg.SetMutationLog(&log)
+	var wg sync.WaitGroup
+	for iW := 0; iW < 4; iW++ {
+		wg.Add(1)
+		go func(iW int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				cfg.SetOption("Shared", fmt.Sprint(iW, i), "")
+				cfg.SetOption(fmt.Sprintf("Writer%d/Last", iW), fmt.Sprint(i), "")
+			}
+		}(iW)
+	}
+	wg.Wait()
+	recovered := NewCFG()
+	if err := recovered.ReplayLog(&log); err != nil {
+		t.Fatal(err)
+	}
+	if !recovered.Equal(cfg) {
+		t.Error("Log is not in the order of the changes:\n" + recovered.String() + "\nexpected:\n" + cfg.String())
+	}
+}
@@ -0,0 +1,42 @@
+package cfg
+
+//Name of the section holding the defaults of it's siblings: the options and sections of "Queues/*" apply to every section
+//under Queues lacking them, after the ones inherited
+const WildcardSection = "*"
+
+//Wildcard section with the defaults of this one, if any
+func (cfg *CFG) wildcard() *CFG {
+	if cfg.parent == nil {
+		return nil
+	}
+	wildcard, ok := cfg.parent.sections[WildcardSection]
+	if !ok {
+		return nil
+	}
+	wildcard = wildcard.resolveAlias()
+	if wildcard == cfg {
+		return nil
+	}
+	return wildcard
+}
+
+//Sections the contents of this one are looked up in, in the order getOption does: itself, the ones it inherits from and it's wildcard
+func (cfg *CFG) effectiveChain() []*CFG {
+	return cfg.appendChain(nil)
+}
+
+func (cfg *CFG) appendChain(chain []*CFG) []*CFG {
+	for _, sec := range chain {
+		if sec == cfg {
+			return chain
+		}
+	}
+	chain = append(chain, cfg)
+	if cfg.inheritance != nil {
+		chain = cfg.inheritance.appendChain(chain)
+	}
+	if wildcard := cfg.wildcard(); wildcard != nil {
+		chain = wildcard.appendChain(chain)
+	}
+	return chain
+}
@@ -0,0 +1,39 @@
+package cfg
+
+import (
+	"testing"
+)
+
+func TestWildcardSection(t *testing.T) {
+	data := "Defaults {\n\tPriority = 1\n}\nQueues {\n\t* {< Defaults\n\t\tMaxJobs = 10\n\t\tLimits {\n\t\t\tCPU = 2\n\t\t}\n\t}\n\tShort {\n\t\tMaxJobs = 100\n\t}\n\tLong {\n\t\tPriority = 5\n\t}\n}\n"
+	cfg, err := NewCFGFromString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, value := range map[string]string{"Queues/Short/MaxJobs": "100", "Queues/Long/MaxJobs": "10", "Queues/Short/Priority": "1",
+		"Queues/Long/Priority": "5", "Queues/Long/Limits/CPU": "2", "Queues/*/MaxJobs": "10"} {
+		if v, _ := cfg.GetOption(path); v != value {
+			t.Errorf("Unexpected value for %s: %s", path, v)
+		}
+	}
+	if cfg.ExistsOption("Defaults/MaxJobs") {
+		t.Error("Wildcard applied outside of it's parent")
+	}
+	if cfg.String() != data {
+		t.Error("Wildcard defaults dumped:", cfg.String())
+	}
+	long, _ := cfg.GetSection("Queues/Long")
+	if m := long.ToEffectiveMap(); m["MaxJobs"] != "10" || m["Priority"] != "5" {
+		t.Error("Unexpected effective map:", m)
+	}
+	if err := cfg.SetOption("Queues/Short/MaxJobs", "10", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Minimize(); err != nil {
+		t.Fatal(err)
+	}
+	short, _ := cfg.GetSection("Queues/Short")
+	if !short.ExistsOption("MaxJobs") || len(short.options) != 0 {
+		t.Error("Option with the wildcard value not minimized:", short.String())
+	}
+}
@@ -0,0 +1,139 @@
+package cfg
+
+import (
+	"bytes"
+	"errors"
+	"path"
+	"sort"
+	"sync"
+)
+
+//Minimal ZooKeeper client API needed to map a znode hierarchy into a cfg. Adapt your ZooKeeper client to it.
+//Watch channels must receive (or be closed) once when the node data or children change
+type ZKConn interface {
+	GetW(path string) ([]byte, <-chan struct{}, error)
+	ChildrenW(path string) ([]string, <-chan struct{}, error)
+}
+
+//Load the znode hierarchy under root into a new cfg. The data of each znode is a cfg fragment with the options of the section
+//and its children are sub sections (in lexical order)
+func LoadFromZK(conn ZKConn, root string) (*CFG, error) {
+	cfg, _, err := loadZKTree(conn, root)
+	return cfg, err
+}
+
+func loadZKTree(conn ZKConn, root string) (*CFG, []<-chan struct{}, error) {
+	cfg := NewCFG()
+	watches := make([]<-chan struct{}, 0)
+	if err := cfg.loadZKNode(conn, root, &watches); err != nil {
+		return nil, nil, err
+	}
+	return cfg, watches, nil
+}
+
+func (cfg *CFG) loadZKNode(conn ZKConn, znode string, watches *[]<-chan struct{}) error {
+	data, dataWatch, err := conn.GetW(znode)
+	if err != nil {
+		return errors.New("Cannot read znode " + znode + ": " + err.Error())
+	}
+	*watches = append(*watches, dataWatch)
+	if len(bytes.Trim(data, trimChars)) > 0 {
+		fragment, err := NewCFGFromReader(bytes.NewReader(data))
+		if err != nil {
+			return errors.New("Invalid cfg in znode " + znode + ": " + err.Error())
+		}
+		cfg.lock.Lock()
+		cfg.replaceContents(fragment)
+		cfg.unlock()
+	}
+	children, childrenWatch, err := conn.ChildrenW(znode)
+	if err != nil {
+		return errors.New("Cannot list znode " + znode + ": " + err.Error())
+	}
+	*watches = append(*watches, childrenWatch)
+	sort.Strings(children)
+	for _, child := range children {
+		sec, ok := cfg.GetSection(child)
+		if !ok {
+			if sec, err = cfg.CreateSection(child, ""); err != nil {
+				return errors.New("Cannot map znode " + path.Join(znode, child) + ": " + err.Error())
+			}
+		}
+		if err := sec.loadZKNode(conn, path.Join(znode, child), watches); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Read only store with a znode hierarchy, as read by LoadFromZK
+type ZKStore struct {
+	Conn ZKConn
+	Root string
+}
+
+func (s *ZKStore) Load() (*CFG, error) {
+	return LoadFromZK(s.Conn, s.Root)
+}
+
+func (s *ZKStore) Save(cfg *CFG) error {
+	return newError(ErrReadOnly, "Cannot save to ZooKeeper")
+}
+
+//Call changed every time a watch of the hierarchy fires. The hierarchy is read again to set the watches for the next change
+func (s *ZKStore) Watch(changed func()) (func(), error) {
+	_, watches, err := loadZKTree(s.Conn, s.Root)
+	if err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	go func() {
+		for {
+			if !waitAny(watches, done) {
+				return
+			}
+			if _, watches, err = loadZKTree(s.Conn, s.Root); err != nil {
+				//Without watches we cannot know when to retry, so wait for the next explicit change of the root
+				watches = nil
+				if _, rootWatch, err := s.Conn.GetW(s.Root); err == nil {
+					watches = []<-chan struct{}{rootWatch}
+				}
+			}
+			changed()
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }, nil
+}
+
+//Keep cfg in sync with the znode hierarchy under root. Every time a watch fires the hierarchy is read again and the cfg updated,
+//so watchers of the cfg get events for the paths that changed. Errors reading the hierarchy are sent to onError (if not nil)
+//and the current contents are kept. Call the returned function to stop following ZooKeeper
+func FollowZK(cfg *CFG, conn ZKConn, root string, onError func(error)) (stop func(), err error) {
+	return Follow(cfg, &ZKStore{conn, root}, onError)
+}
+
+//Wait until any of the channels fires. Returns false if done is closed first
+func waitAny(channels []<-chan struct{}, done chan struct{}) bool {
+	fired := make(chan struct{}, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	for _, c := range channels {
+		go func(c <-chan struct{}) {
+			select {
+			case <-c:
+				select {
+				case fired <- struct{}{}:
+				default:
+				}
+			case <-stop:
+			}
+		}(c)
+	}
+	select {
+	case <-fired:
+		return true
+	case <-done:
+		return false
+	}
+}
@@ -0,0 +1,94 @@
+package cfg
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//In memory znode hierarchy
+type fakeZK struct {
+	lock    sync.Mutex
+	data    map[string]string
+	watches []chan struct{}
+}
+
+func (z *fakeZK) watch() chan struct{} {
+	c := make(chan struct{})
+	z.watches = append(z.watches, c)
+	return c
+}
+
+func (z *fakeZK) GetW(path string) ([]byte, <-chan struct{}, error) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	data, ok := z.data[path]
+	if !ok {
+		return nil, nil, errors.New("no node")
+	}
+	return []byte(data), z.watch(), nil
+}
+
+func (z *fakeZK) ChildrenW(path string) ([]string, <-chan struct{}, error) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	children := make([]string, 0)
+	for p := range z.data {
+		if strings.HasPrefix(p, path+"/") && !strings.Contains(p[len(path)+1:], "/") {
+			children = append(children, p[len(path)+1:])
+		}
+	}
+	return children, z.watch(), nil
+}
+
+func (z *fakeZK) set(path, data string) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.data[path] = data
+	for _, c := range z.watches {
+		close(c)
+	}
+	z.watches = nil
+}
+
+func TestZK(t *testing.T) {
+	zk := &fakeZK{data: map[string]string{
+		"/cs":            "Version = 1",
+		"/cs/Systems":    "",
+		"/cs/Systems/DB": "Host = db1\nPort = 3306",
+		"/cs/Agents":     "Polling = 10",
+	}}
+	cfg, err := LoadFromZK(zk, "/cs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Version = 1\nAgents {\n\tPolling = 10\n}\nSystems {\n\tDB {\n\t\tHost = db1\n\t\tPort = 3306\n\t}\n}\n"
+	if cfg.String() != expected {
+		t.Error("Unexpected tree:\n" + cfg.String())
+	}
+	if _, err := LoadFromZK(zk, "/nope"); err == nil {
+		t.Error("Loaded a non existant znode")
+	}
+	followed := NewCFG()
+	changes := make(chan ChangeEvent, 10)
+	followed.Watch("Systems/DB", func(e ChangeEvent) { changes <- e })
+	stop, err := FollowZK(followed, zk, "/cs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	for len(changes) > 0 {
+		<-changes
+	}
+	zk.set("/cs/Systems/DB", "Host = db2\nPort = 3306")
+	select {
+	case e := <-changes:
+		if e.Path != "Systems/DB/Host" || e.Value[0] != "db2" {
+			t.Error("Unexpected change:", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Change was not followed")
+	}
+}